@@ -2,35 +2,144 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/account"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/db"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/facade"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/login"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
 	_ "github.com/lib/pq"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// The secrets are read from the environment to keep them out of the process
+// list.
+const (
+	apiTokenEnv      = "CLIMANAGER_API_TOKEN"
+	passwordEnv      = "CLIMANAGER_PASSWORD"
+	sessionSecretEnv = "CLIMANAGER_SESSION_SECRET"
+	smtpPasswordEnv  = "CLIMANAGER_SMTP_PASSWORD"
 )
 
 func main() {
-	args := os.Args
-	if len(args) != 6 {
-		fmt.Println("Usage: ./a.out <host> <port> <user> <dbname> <password>")
+	dbCfg := db.DefaultConfig()
+	flag.StringVar(&dbCfg.Driver, "driver", dbCfg.Driver, "database driver: "+strings.Join(db.Drivers, ", "))
+	flag.StringVar(&dbCfg.SSLMode, "sslmode", dbCfg.SSLMode, "disable, require, verify-ca or verify-full")
+	flag.StringVar(&dbCfg.SSLCert, "sslcert", "", "client certificate file")
+	flag.StringVar(&dbCfg.SSLKey, "sslkey", "", "client private key file")
+	flag.StringVar(&dbCfg.SSLRootCert, "sslrootcert", "", "root CA certificate file used by verify-ca and verify-full")
+	flag.StringVar(&dbCfg.PassFile, "passfile", "", "pgpass file used when no password is given (default $PGPASSFILE or ~/.pgpass)")
+	flag.StringVar(&dbCfg.ApplicationName, "app-name", dbCfg.ApplicationName, "application_name reported to Postgres")
+	flag.StringVar(&dbCfg.Tenant, "tenant", "", "tenant whose users and logs the session works on, the default tenant when empty")
+	flag.DurationVar(&dbCfg.ConnectTimeout, "connect-timeout", dbCfg.ConnectTimeout, "timeout of a single connection attempt")
+	flag.DurationVar(&dbCfg.StartupTimeout, "startup-timeout", dbCfg.StartupTimeout, "how long to wait for the database to accept connections")
+	flag.IntVar(&dbCfg.MaxOpenConns, "max-open-conns", dbCfg.MaxOpenConns, "maximum number of open connections, 0 is unlimited")
+	flag.IntVar(&dbCfg.MaxIdleConns, "max-idle-conns", dbCfg.MaxIdleConns, "maximum number of idle connections")
+	flag.DurationVar(&dbCfg.ConnMaxLifetime, "conn-max-lifetime", dbCfg.ConnMaxLifetime, "maximum time a connection may be reused, 0 is forever")
+	flag.DurationVar(&dbCfg.ConnMaxIdleTime, "conn-max-idle-time", dbCfg.ConnMaxIdleTime, "maximum time a connection may stay idle, 0 is forever")
+	cacheCfg := middleware.DefaultCacheConfig()
+	flag.IntVar(&cacheCfg.Capacity, "cache-size", cacheCfg.Capacity, "maximum number of cached users")
+	flag.DurationVar(&cacheCfg.TTL, "cache-ttl", cacheCfg.TTL, "how long a cached user stays valid")
+	flag.DurationVar(&cacheCfg.NegativeTTL, "cache-negative-ttl", cacheCfg.NegativeTTL, "how long a missing user stays cached, 0 disables")
+	breakerCfg := middleware.DefaultBreakerConfig()
+	flag.IntVar(&breakerCfg.FailureThreshold, "breaker-failures", breakerCfg.FailureThreshold, "consecutive database outage errors that open the circuit breaker")
+	flag.DurationVar(&breakerCfg.OpenTimeout, "breaker-open-timeout", breakerCfg.OpenTimeout, "how long an open circuit breaker fails fast before letting a probe through")
+	flag.IntVar(&breakerCfg.HalfOpenSuccesses, "breaker-probes", breakerCfg.HalfOpenSuccesses, "successful probes that close the circuit breaker again")
+	cacheNotify := flag.Bool("cache-notify", false, "invalidate the cache on changes made by other processes (LISTEN/NOTIFY)")
+	metricsAddr := flag.String("metrics-addr", "", "serve Prometheus metrics on this address, e.g. :9090")
+	traceOut := flag.String("trace-out", "", "write trace spans to stdout or this file")
+	traceFormat := flag.String("trace-format", "json", "trace output format: json or otlp")
+	tui := flag.Bool("tui", false, "run the full-screen interface instead of the prompt")
+	serve := flag.String("serve", "", "serve the REST API on this address, e.g. :8080, instead of the prompt")
+	grpcAddr := flag.String("grpc-addr", "", "serve the gRPC API on this address, e.g. :9000, instead of the prompt")
+	remote := flag.String("remote", "", "work on the server at http(s)://host:port or grpc(s)://host:port instead of a database")
+	apiToken := flag.String("api-token", "", "operator API token sent to -remote (default $"+apiTokenEnv+")")
+	operator := flag.String("operator", "", "operator to log in as, the password is read from $"+passwordEnv+" or asked for")
+	loginCfg := login.DefaultConfig()
+	flag.IntVar(&loginCfg.MaxFailures, "login-max-failures", loginCfg.MaxFailures, "failed user logins in a row that lock the email, 0 never locks")
+	flag.DurationVar(&loginCfg.LockoutDuration, "login-lockout", loginCfg.LockoutDuration, "how long an email stays locked, and after which its failed logins are forgotten")
+	flag.DurationVar(&loginCfg.SessionTTL, "session-ttl", loginCfg.SessionTTL, "how long a user session token is valid, signed with $"+sessionSecretEnv)
+	mailer := flag.String("mailer", "stdout", "how tokens are mailed to users: stdout, file:<path>, smtp://[user@]host:port or smtps://..., the password is read from $"+smtpPasswordEnv)
+	mailFrom := flag.String("mail-from", "noreply@localhost", "sender address of the mails to users")
+	accountCfg := account.DefaultConfig()
+	flag.DurationVar(&accountCfg.VerifyTTL, "verify-ttl", accountCfg.VerifyTTL, "how long an email verification token is valid")
+	flag.DurationVar(&accountCfg.ResetTTL, "reset-ttl", accountCfg.ResetTTL, "how long a password reset token is valid")
+	attributesSchema := flag.String("attributes-schema", "", "JSON Schema file the user attributes must match")
+	history := flag.String("history", defaultHistoryFile(), "file keeping the command history, empty disables it")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: ./a.out [options] <host> <port> <user> <dbname> [password]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       ./a.out [options] -driver sqlite <path>")
+		fmt.Fprintln(flag.CommandLine.Output(), "       ./a.out [options] -remote <url>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *apiToken == "" {
+		*apiToken = os.Getenv(apiTokenEnv)
+	}
+
+	args := flag.Args()
+	switch {
+	case *remote != "" && len(args) == 0:
+	case dbCfg.Driver == db.DriverSQLite && len(args) == 1:
+		dbCfg.DbName = args[0]
+	case dbCfg.Driver != db.DriverSQLite && (len(args) == 4 || len(args) == 5):
+		dbCfg.Host = args[0]
+		dbCfg.Port = args[1]
+		dbCfg.User = args[2]
+		dbCfg.DbName = args[3]
+		if len(args) == 5 {
+			dbCfg.Password = args[4]
+		}
+	default:
+		flag.Usage()
 		return
 	}
-	host := args[1]
-	port := args[2]
-	user := args[3]
-	dbname := args[4]
-	password := args[5]
-
-	ctx := context.Background()
-	ctx, cancel := context.WithCancel(ctx)
+	cfg := facade.Config{
+		DB:           dbCfg,
+		Cache:        cacheCfg,
+		Breaker:      breakerCfg,
+		CacheNotify:  *cacheNotify,
+		MetricsAddr:  *metricsAddr,
+		TraceOut:     *traceOut,
+		TraceFormat:  *traceFormat,
+		HistoryFile:  *history,
+		TUI:          *tui,
+		ServeAddr:    *serve,
+		GRPCAddr:     *grpcAddr,
+		Remote:       *remote,
+		APIToken:     *apiToken,
+		Operator:     *operator,
+		Password:     os.Getenv(passwordEnv),
+		Login:        loginCfg,
+		Mailer:       *mailer,
+		MailFrom:     *mailFrom,
+		SMTPPassword: os.Getenv(smtpPasswordEnv),
+		Accounts:     accountCfg,
+
+		AttributesSchema: *attributesSchema,
+	}
+	cfg.Login.Secret = []byte(os.Getenv(sessionSecretEnv))
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	cancelingSignals := make(chan os.Signal, 1)
-	err := facade.RunCliManager(ctx, host, port, user, dbname, password)
-	if err != nil {
+	if err := facade.RunCliManager(ctx, cfg); err != nil {
 		fmt.Println(err)
 		cancel()
-		return
+		os.Exit(1)
 	}
-	<-cancelingSignals
+}
 
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".simpleCLIdbManager_history")
 }
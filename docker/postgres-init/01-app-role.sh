@@ -0,0 +1,12 @@
+#!/bin/sh
+# Creates the role the application connects as. It owns the tables it
+# creates, but is neither a superuser nor has BYPASSRLS, so row level
+# security keeps its sessions to the rows of their tenant. Postgres runs the
+# scripts of this directory only when it initializes an empty data directory.
+set -e
+
+psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" \
+	-v app_user="$APP_DB_USERNAME" -v app_password="$APP_DB_PASSWORD" <<'EOSQL'
+create role :"app_user" login nosuperuser nobypassrls password :'app_password';
+grant usage, create on schema public to :"app_user";
+EOSQL
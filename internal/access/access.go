@@ -0,0 +1,246 @@
+// Package access manages the roles and groups of the managed users, which
+// the applications built on the users check to decide what a user may do.
+package access
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"log"
+	"slices"
+	"time"
+)
+
+// Service keeps the roles and groups of the users in users. Viewers may read
+// them, editors change them, and every change is recorded in logs.
+type Service struct {
+	users  repository.IRepositoryUser
+	roles  repository.IRepositoryRole
+	groups repository.IRepositoryGroup
+	logs   repository.IRepositoryLog
+	now    func() time.Time
+}
+
+func NewService(users repository.IRepositoryUser, roles repository.IRepositoryRole, groups repository.IRepositoryGroup, logs repository.IRepositoryLog) *Service {
+	return &Service{users: users, roles: roles, groups: groups, logs: logs, now: time.Now}
+}
+
+func (s *Service) Roles(ctx context.Context) ([]models.UserRole, error) {
+	if err := auth.Require(ctx, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.roles.GetRoles(ctx)
+}
+
+func (s *Service) AddRole(ctx context.Context, name, description string) error {
+	if err := auth.Require(ctx, models.RoleEditor); err != nil {
+		return err
+	}
+	role := models.UserRole{Name: name, Description: description, CreatedAt: s.now()}
+	if err := role.Validate(); err != nil {
+		return err
+	}
+	if _, err := s.roles.GetRoleByName(ctx, name); err == nil {
+		return fmt.Errorf("role %s already exists", name)
+	}
+	if _, err := s.roles.InsertRole(ctx, role); err != nil {
+		return err
+	}
+	s.record(ctx, fmt.Sprintf("Role %s added", name))
+	return nil
+}
+
+// DeleteRole deletes a role and revokes it from everyone who has it.
+func (s *Service) DeleteRole(ctx context.Context, name string) error {
+	role, err := s.role(ctx, name)
+	if err != nil {
+		return err
+	}
+	if _, err := s.roles.DeleteRoleById(ctx, role.ID); err != nil {
+		return err
+	}
+	s.record(ctx, fmt.Sprintf("Role %s deleted", name))
+	return nil
+}
+
+// AssignRole gives the named role to the user with userID. It reports
+// false if the user has it already, and sql.ErrNoRows if there is no such
+// user.
+func (s *Service) AssignRole(ctx context.Context, name string, userID int) (bool, error) {
+	role, err := s.role(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	current, err := s.UserRoles(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if slices.ContainsFunc(current, func(r models.UserRole) bool { return r.ID == role.ID }) {
+		return false, nil
+	}
+	if _, err := s.roles.AssignRole(ctx, role.ID, userID); err != nil {
+		return false, err
+	}
+	s.record(ctx, fmt.Sprintf("Role %s assigned to user with id %d", name, userID))
+	return true, nil
+}
+
+// RevokeRole takes the named role from the user with userID. It reports
+// false if the user does not have it.
+func (s *Service) RevokeRole(ctx context.Context, name string, userID int) (bool, error) {
+	role, err := s.role(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	res, err := s.roles.RevokeRole(ctx, role.ID, userID)
+	if err != nil {
+		return false, err
+	}
+	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+		return false, err
+	}
+	s.record(ctx, fmt.Sprintf("Role %s revoked from user with id %d", name, userID))
+	return true, nil
+}
+
+// UserRoles returns the roles of the user with userID, sql.ErrNoRows if
+// there is no such user.
+func (s *Service) UserRoles(ctx context.Context, userID int) ([]models.UserRole, error) {
+	if err := s.user(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.roles.GetUserRoles(ctx, userID)
+}
+
+func (s *Service) Groups(ctx context.Context) ([]models.Group, error) {
+	if err := auth.Require(ctx, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.groups.GetGroups(ctx)
+}
+
+func (s *Service) AddGroup(ctx context.Context, name, description string) error {
+	if err := auth.Require(ctx, models.RoleEditor); err != nil {
+		return err
+	}
+	group := models.Group{Name: name, Description: description, CreatedAt: s.now()}
+	if err := group.Validate(); err != nil {
+		return err
+	}
+	if _, err := s.groups.GetGroupByName(ctx, name); err == nil {
+		return fmt.Errorf("group %s already exists", name)
+	}
+	if _, err := s.groups.InsertGroup(ctx, group); err != nil {
+		return err
+	}
+	s.record(ctx, fmt.Sprintf("Group %s added", name))
+	return nil
+}
+
+// DeleteGroup deletes a group, its members stay.
+func (s *Service) DeleteGroup(ctx context.Context, name string) error {
+	group, err := s.group(ctx, name)
+	if err != nil {
+		return err
+	}
+	if _, err := s.groups.DeleteGroupById(ctx, group.ID); err != nil {
+		return err
+	}
+	s.record(ctx, fmt.Sprintf("Group %s deleted", name))
+	return nil
+}
+
+// AddMember adds the user with userID to the named group. It reports false
+// if the user is a member already, and sql.ErrNoRows if there is no such
+// user.
+func (s *Service) AddMember(ctx context.Context, name string, userID int) (bool, error) {
+	group, err := s.group(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	current, err := s.UserGroups(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if slices.ContainsFunc(current, func(g models.Group) bool { return g.ID == group.ID }) {
+		return false, nil
+	}
+	if _, err := s.groups.AddGroupMember(ctx, group.ID, userID); err != nil {
+		return false, err
+	}
+	s.record(ctx, fmt.Sprintf("User with id %d added to group %s", userID, name))
+	return true, nil
+}
+
+// RemoveMember removes the user with userID from the named group. It
+// reports false if the user is not a member.
+func (s *Service) RemoveMember(ctx context.Context, name string, userID int) (bool, error) {
+	group, err := s.group(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	res, err := s.groups.RemoveGroupMember(ctx, group.ID, userID)
+	if err != nil {
+		return false, err
+	}
+	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+		return false, err
+	}
+	s.record(ctx, fmt.Sprintf("User with id %d removed from group %s", userID, name))
+	return true, nil
+}
+
+// UserGroups returns the groups of the user with userID, sql.ErrNoRows if
+// there is no such user.
+func (s *Service) UserGroups(ctx context.Context, userID int) ([]models.Group, error) {
+	if err := s.user(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.groups.GetUserGroups(ctx, userID)
+}
+
+// role returns the named role if the operator of ctx is an editor.
+func (s *Service) role(ctx context.Context, name string) (models.UserRole, error) {
+	if err := auth.Require(ctx, models.RoleEditor); err != nil {
+		return models.UserRole{}, err
+	}
+	role, err := s.roles.GetRoleByName(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.UserRole{}, fmt.Errorf("role %s not found", name)
+	}
+	return role, err
+}
+
+// group returns the named group if the operator of ctx is an editor.
+func (s *Service) group(ctx context.Context, name string) (models.Group, error) {
+	if err := auth.Require(ctx, models.RoleEditor); err != nil {
+		return models.Group{}, err
+	}
+	group, err := s.groups.GetGroupByName(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Group{}, fmt.Errorf("group %s not found", name)
+	}
+	return group, err
+}
+
+// user checks that the user with id exists and the operator of ctx may see
+// it.
+func (s *Service) user(ctx context.Context, id int) error {
+	if err := auth.Require(ctx, models.RoleViewer); err != nil {
+		return err
+	}
+	_, err := s.users.GetUserById(ctx, id)
+	return err
+}
+
+// record writes msg to the audit log on behalf of the operator of ctx.
+func (s *Service) record(ctx context.Context, msg string) {
+	entry := models.Log{LogTime: s.now(), LogMessage: msg, Actor: auth.Actor(ctx)}
+	if _, err := s.logs.InsertLog(ctx, entry); err != nil {
+		log.Printf("[WARN] failed to insert log: %v", err)
+	}
+}
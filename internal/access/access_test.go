@@ -0,0 +1,77 @@
+package access
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"testing"
+)
+
+func TestService_AssignAndRevokeRoles(t *testing.T) {
+	users := imp.NewMemoryRepoUser()
+	logs := imp.NewMemoryRepoLog()
+	s := NewService(users, imp.NewMemoryRepoRole(users), imp.NewMemoryRepoGroup(users), logs)
+	editor := auth.WithOperator(context.Background(), models.Operator{Name: "ed", Role: models.RoleEditor})
+	viewer := auth.WithOperator(context.Background(), models.Operator{Name: "vera", Role: models.RoleViewer})
+	if _, err := users.InsertUser(editor, models.User{Name: "Ann", Email: "ann@example.com", Password: "secret"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting a user", err)
+	}
+
+	if err := s.AddRole(viewer, "admin", ""); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("got '%v' when a viewer adds a role, expected ErrForbidden", err)
+	}
+	if err := s.AddRole(editor, "admin", "Full access"); err != nil {
+		t.Fatalf("an error '%s' was not expected when adding a role", err)
+	}
+	if err := s.AddRole(editor, "admin", ""); err == nil {
+		t.Fatalf("expected an error when adding a role twice")
+	}
+
+	for i, expected := range []bool{true, false} {
+		assigned, err := s.AssignRole(editor, "admin", 1)
+		if err != nil || assigned != expected {
+			t.Fatalf("got %v, '%v' for assignment %d, expected %v", assigned, err, i+1, expected)
+		}
+	}
+	if _, err := s.AssignRole(editor, "admin", 9); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("got '%v' for a missing user, expected sql.ErrNoRows", err)
+	}
+	if _, err := s.AssignRole(editor, "missing", 1); err == nil || err.Error() != "role missing not found" {
+		t.Fatalf("got '%v' for a missing role", err)
+	}
+	roles, err := s.UserRoles(viewer, 1)
+	if err != nil || len(roles) != 1 || roles[0].Name != "admin" {
+		t.Fatalf("got %+v, '%v', expected the admin role", roles, err)
+	}
+
+	if revoked, err := s.RevokeRole(editor, "admin", 1); err != nil || !revoked {
+		t.Fatalf("got %v, '%v', expected the role to be revoked", revoked, err)
+	}
+	if revoked, err := s.RevokeRole(editor, "admin", 1); err != nil || revoked {
+		t.Fatalf("got %v, '%v', expected nothing to revoke", revoked, err)
+	}
+
+	entries, err := logs.GetLogs(context.Background())
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting logs", err)
+	}
+	var messages []string
+	for _, entry := range entries {
+		if entry.Actor != "ed" {
+			t.Fatalf("got actor %q in %+v, expected ed", entry.Actor, entry)
+		}
+		messages = append(messages, entry.LogMessage)
+	}
+	expected := []string{"Role admin added", "Role admin assigned to user with id 1", "Role admin revoked from user with id 1"}
+	if len(messages) != len(expected) {
+		t.Fatalf("got %q, expected %q", messages, expected)
+	}
+	for i := range expected {
+		if messages[i] != expected[i] {
+			t.Fatalf("got %q, expected %q", messages, expected)
+		}
+	}
+}
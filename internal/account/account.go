@@ -0,0 +1,209 @@
+// Package account lets the managed users verify their email and reset their
+// password with single-use tokens sent to them by mail.
+package account
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/mail"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that are unknown, expired,
+	// used already or were sent to an email the user no longer has.
+	ErrInvalidToken = errors.New("invalid, expired or used token")
+	// ErrAlreadyVerified is returned when asking a user with a verified
+	// email to verify it again.
+	ErrAlreadyVerified = errors.New("the email is verified already")
+)
+
+type Config struct {
+	// VerifyTTL is how long an email verification token is valid.
+	VerifyTTL time.Duration
+	// ResetTTL is how long a password reset token is valid.
+	ResetTTL time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{VerifyTTL: 24 * time.Hour, ResetTTL: time.Hour}
+}
+
+// Service issues and consumes the tokens of the users in users. Issuing a
+// token invalidates the earlier ones of the user for the same purpose.
+type Service struct {
+	// db is the database of users and tokens, a token is used up in one
+	// transaction with the change it makes there. The in-memory
+	// repositories go without.
+	db     *sql.DB
+	users  repository.IRepositoryUser
+	tokens repository.IRepositoryUserToken
+	mailer mail.Mailer
+	cfg    Config
+	now    func() time.Time
+}
+
+func NewService(db *sql.DB, users repository.IRepositoryUser, tokens repository.IRepositoryUserToken, mailer mail.Mailer, cfg Config) *Service {
+	return &Service{db: db, users: users, tokens: tokens, mailer: mailer, cfg: cfg, now: time.Now}
+}
+
+// SendVerification mails an email verification token to the user with id.
+func (s *Service) SendVerification(ctx context.Context, id int) (models.User, error) {
+	user, err := s.users.GetUserById(ctx, id)
+	if err != nil {
+		return models.User{}, err
+	}
+	if user.EmailVerified() {
+		return models.User{}, ErrAlreadyVerified
+	}
+	err = s.send(ctx, user, models.PurposeVerifyEmail, s.cfg.VerifyTTL, "Verify your email address",
+		"use this token to verify your email address %s:")
+	return user, err
+}
+
+// VerifyEmail consumes an email verification token and marks the email it
+// was sent to as verified. A pending user becomes active.
+func (s *Service) VerifyEmail(ctx context.Context, token string) (models.User, error) {
+	var user models.User
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		var err error
+		if user, err = s.consume(ctx, models.PurposeVerifyEmail, token); err != nil {
+			return err
+		}
+		user.EmailVerifiedAt = s.now()
+		if user.Status == models.StatusPending {
+			if user, err = user.Transition(models.StatusActive); err != nil {
+				return err
+			}
+			ctx = middleware.WithReason(ctx, "email verified")
+		}
+		_, err = s.users.UpdateUserById(ctx, user.ID, user)
+		return err
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// SendPasswordReset mails a password reset token to the user with id.
+func (s *Service) SendPasswordReset(ctx context.Context, id int) (models.User, error) {
+	user, err := s.users.GetUserById(ctx, id)
+	if err != nil {
+		return models.User{}, err
+	}
+	err = s.send(ctx, user, models.PurposeResetPassword, s.cfg.ResetTTL, "Reset your password",
+		"use this token to set a new password for %s:")
+	return user, err
+}
+
+// ResetPassword consumes a password reset token and sets the password of
+// the user it was sent to. An invalid password is rejected before the token
+// is used up.
+func (s *Service) ResetPassword(ctx context.Context, token, password string) (models.User, error) {
+	if err := ValidatePassword(password); err != nil {
+		return models.User{}, err
+	}
+	var user models.User
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		var err error
+		if user, err = s.consume(ctx, models.PurposeResetPassword, token); err != nil {
+			return err
+		}
+		user.Password = password
+		if _, err := s.users.UpdateUserById(ctx, user.ID, user); err != nil {
+			return err
+		}
+		// Reset tokens sent earlier must not undo this one.
+		_, err = s.tokens.DeleteUserTokens(ctx, models.PurposeResetPassword, user.ID)
+		return err
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// inTx runs fn in a transaction on db, so a token consumed in fn stays
+// usable when a later change fails. A transaction already in ctx is joined.
+func (s *Service) inTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.db == nil || repository.TxFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(repository.WithTx(ctx, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ValidatePassword checks a new password of a user like User.Validate.
+func ValidatePassword(password string) error {
+	var verr models.ValidationError
+	if errors.As(models.User{Password: password}.Validate(), &verr) && verr.Field("password") != "" {
+		return models.ValidationError{{Field: "password", Message: verr.Field("password")}}
+	}
+	return nil
+}
+
+func (s *Service) send(ctx context.Context, user models.User, purpose models.TokenPurpose, ttl time.Duration, subject, intro string) error {
+	token := rand.Text()
+	now := s.now()
+	expiresAt := now.Add(ttl)
+	if _, err := s.tokens.DeleteUserTokens(ctx, purpose, user.ID); err != nil {
+		return err
+	}
+	_, err := s.tokens.InsertUserToken(ctx, models.UserToken{
+		Purpose:   purpose,
+		Hash:      hashToken(token),
+		UserID:    user.ID,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\n"+intro+"\n\n    %s\n\nIt expires at %s UTC.\n",
+		user.Name, user.Email, token, expiresAt.UTC().Format(time.DateTime))
+	if err := s.mailer.Send(ctx, mail.Message{To: user.Email, Subject: subject, Body: body}); err != nil {
+		return fmt.Errorf("could not mail the token: %w", err)
+	}
+	return nil
+}
+
+// consume uses up token and returns the user it was sent to.
+func (s *Service) consume(ctx context.Context, purpose models.TokenPurpose, token string) (models.User, error) {
+	consumed, err := s.tokens.ConsumeUserToken(ctx, purpose, hashToken(strings.TrimSpace(token)), s.now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrInvalidToken
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	user, err := s.users.GetUserById(ctx, consumed.UserID)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && !strings.EqualFold(user.Email, consumed.Email) {
+		return models.User{}, ErrInvalidToken
+	}
+	return user, err
+}
+
+// hashToken returns the hex SHA-256 hash stored instead of token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,193 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/db"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/mail"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// outbox keeps the sent messages.
+type outbox []mail.Message
+
+func (o *outbox) Send(ctx context.Context, msg mail.Message) error {
+	*o = append(*o, msg)
+	return nil
+}
+
+// lastToken returns the token in the last message.
+func (o *outbox) lastToken(t *testing.T) string {
+	t.Helper()
+	if len(*o) == 0 {
+		t.Fatalf("no message was sent")
+	}
+	for _, line := range strings.Split((*o)[len(*o)-1].Body, "\n") {
+		if token, ok := strings.CutPrefix(line, "    "); ok {
+			return token
+		}
+	}
+	t.Fatalf("no token in %q", (*o)[len(*o)-1].Body)
+	return ""
+}
+
+func newTestService(t *testing.T) (*Service, repository.IRepositoryUser, *outbox) {
+	t.Helper()
+	users := middleware.NewPasswordMiddleware(imp.NewMemoryRepoUser())
+	if _, err := users.InsertUser(context.Background(), models.User{Name: "Ann", Email: "ann@example.com", Password: "secret"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting a user", err)
+	}
+	out := &outbox{}
+	return NewService(nil, users, imp.NewMemoryRepoUserToken(), out, DefaultConfig()), users, out
+}
+
+func TestService_VerifyEmail(t *testing.T) {
+	s, users, out := newTestService(t)
+	ctx := context.Background()
+
+	if _, err := s.SendVerification(ctx, 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when sending a verification", err)
+	}
+	first := out.lastToken(t)
+	if _, err := s.SendVerification(ctx, 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when sending a verification again", err)
+	}
+	token := out.lastToken(t)
+	if msg := (*out)[1]; msg.To != "ann@example.com" || msg.Subject != "Verify your email address" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	if _, err := s.VerifyEmail(ctx, first); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got '%v', expected the replaced token to be invalid", err)
+	}
+	if user, err := s.VerifyEmail(ctx, token); err != nil || !user.EmailVerified() {
+		t.Fatalf("got %+v, '%v', expected the email to be verified", user, err)
+	}
+	if user, _ := users.GetUserById(ctx, 1); !user.EmailVerified() || user.Status != models.StatusActive {
+		t.Fatalf("the verification was not stored or the pending user not activated: %+v", user)
+	}
+	if _, err := s.VerifyEmail(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got '%v', expected the token to be single-use", err)
+	}
+	if _, err := s.SendVerification(ctx, 1); !errors.Is(err, ErrAlreadyVerified) {
+		t.Fatalf("got '%v', expected ErrAlreadyVerified", err)
+	}
+}
+
+func TestService_VerifyEmailAfterChange(t *testing.T) {
+	s, users, out := newTestService(t)
+	ctx := context.Background()
+
+	if _, err := s.SendVerification(ctx, 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when sending a verification", err)
+	}
+	user, _ := users.GetUserById(ctx, 1)
+	user.Email = "annie@example.com"
+	if _, err := users.UpdateUserById(ctx, 1, user); err != nil {
+		t.Fatalf("an error '%s' was not expected when changing the email", err)
+	}
+	if _, err := s.VerifyEmail(ctx, out.lastToken(t)); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got '%v', expected a token for the old email to be invalid", err)
+	}
+}
+
+func TestService_ResetPassword(t *testing.T) {
+	s, users, out := newTestService(t)
+	ctx := context.Background()
+
+	if _, err := s.SendPasswordReset(ctx, 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when sending a reset", err)
+	}
+	token := out.lastToken(t)
+
+	var verr models.ValidationError
+	if _, err := s.ResetPassword(ctx, token, ""); !errors.As(err, &verr) || verr.Field("password") != "is required" {
+		t.Fatalf("got '%v', expected the empty password to be rejected", err)
+	}
+	if _, err := s.ResetPassword(ctx, token, "new secret"); err != nil {
+		t.Fatalf("an error '%s' was not expected when resetting the password", err)
+	}
+	if user, _ := users.GetUserById(ctx, 1); bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("new secret")) != nil {
+		t.Fatalf("the password was not changed: %+v", user)
+	}
+	if _, err := s.ResetPassword(ctx, token, "other secret"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got '%v', expected the token to be single-use", err)
+	}
+
+	if _, err := s.SendPasswordReset(ctx, 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when sending a reset", err)
+	}
+	s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, err := s.ResetPassword(ctx, out.lastToken(t), "other secret"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got '%v', expected the expired token to be refused", err)
+	}
+}
+
+// failingUpdates fails every update of a user while fail is set.
+type failingUpdates struct {
+	repository.IRepositoryUser
+	fail bool
+}
+
+func (f *failingUpdates) UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error) {
+	if f.fail {
+		return nil, errors.New("connection reset")
+	}
+	return f.IRepositoryUser.UpdateUserById(ctx, id, user)
+}
+
+func TestService_FailedUpdateKeepsToken(t *testing.T) {
+	ctx := context.Background()
+	cfg := db.DefaultConfig()
+	cfg.Driver = db.DriverSQLite
+	cfg.DbName = filepath.Join(t.TempDir(), "test.db")
+	con, err := db.Connect(ctx, cfg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a sqlite database", err)
+	}
+	defer con.Close()
+	if err := db.Migrate(con, db.DriverSQLite); err != nil {
+		t.Fatalf("an error '%s' was not expected when migrating", err)
+	}
+
+	users := &failingUpdates{IRepositoryUser: middleware.NewPasswordMiddleware(imp.NewSqliteRepoUser(con)), fail: true}
+	if _, err := users.InsertUser(ctx, models.User{Name: "Ann", Email: "ann@example.com", Password: "secret"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting a user", err)
+	}
+	out := &outbox{}
+	s := NewService(con, users, imp.NewSqliteRepoUserToken(con), out, DefaultConfig())
+
+	if _, err := s.SendVerification(ctx, 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when sending a verification", err)
+	}
+	verification := out.lastToken(t)
+	if _, err := s.SendPasswordReset(ctx, 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when sending a reset", err)
+	}
+	reset := out.lastToken(t)
+
+	if _, err := s.VerifyEmail(ctx, verification); err == nil {
+		t.Fatalf("an error was expected when the user cannot be updated")
+	}
+	if _, err := s.ResetPassword(ctx, reset, "new secret"); err == nil {
+		t.Fatalf("an error was expected when the user cannot be updated")
+	}
+
+	users.fail = false
+	if user, err := s.VerifyEmail(ctx, verification); err != nil || user.Status != models.StatusActive {
+		t.Fatalf("got '%v' and %+v, expected the verification token to be usable again", err, user)
+	}
+	if _, err := s.ResetPassword(ctx, reset, "new secret"); err != nil {
+		t.Fatalf("an error '%s' was not expected when resetting the password again", err)
+	}
+}
@@ -0,0 +1,96 @@
+// Package auth identifies the operators working on the users and checks
+// what their roles allow.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrUnauthenticated is returned when no operator is known.
+	ErrUnauthenticated = errors.New("not logged in")
+	// ErrInvalidCredentials is returned for an unknown operator, a wrong
+	// password or an unknown API token, without telling which.
+	ErrInvalidCredentials = errors.New("invalid operator name, password or token")
+	// ErrForbidden is returned when the role of the operator does not allow
+	// an action.
+	ErrForbidden = errors.New("permission denied")
+)
+
+// tokenPrefix makes tokens recognizable, e.g. by secret scanners.
+const tokenPrefix = "clim_"
+
+// minPasswordLength applies to operator passwords.
+const minPasswordLength = 8
+
+type operatorKey struct{}
+
+// WithOperator returns a context acting on behalf of operator.
+func WithOperator(ctx context.Context, operator models.Operator) context.Context {
+	return context.WithValue(ctx, operatorKey{}, operator)
+}
+
+// OperatorFromContext returns the operator set by WithOperator.
+func OperatorFromContext(ctx context.Context) (models.Operator, bool) {
+	operator, ok := ctx.Value(operatorKey{}).(models.Operator)
+	return operator, ok
+}
+
+// Actor returns the name of the operator of ctx, empty if there is none.
+func Actor(ctx context.Context) string {
+	operator, _ := OperatorFromContext(ctx)
+	return operator.Name
+}
+
+// Require returns nil if the operator of ctx has at least the role required.
+func Require(ctx context.Context, required models.Role) error {
+	operator, ok := OperatorFromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	if !operator.Role.Allows(required) {
+		return fmt.Errorf("%w: %s is a %s, this needs %s", ErrForbidden, operator.Name, operator.Role, required)
+	}
+	return nil
+}
+
+// ValidatePassword checks a new operator password.
+func ValidatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return models.ValidationError{{Field: "password", Message: fmt.Sprintf("must be at least %d characters", minPasswordLength)}}
+	}
+	return nil
+}
+
+func HashPassword(password string) (string, error) {
+	if err := ValidatePassword(password); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// NewToken returns a new API token and the hash that is stored of it.
+func NewToken() (token, hash string) {
+	token = tokenPrefix + rand.Text()
+	return token, HashToken(token)
+}
+
+// HashToken hashes an API token for lookup. Tokens are random, so a fast
+// hash is enough, unlike for passwords.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
+}
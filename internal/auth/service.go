@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Service logs operators in and manages them. Managing operators needs the
+// admin role, except for an operator creating tokens for itself.
+type Service struct {
+	repo repository.IRepositoryOperator
+	// dummyHash is compared against when the operator is unknown, so the
+	// time taken does not tell whether it exists.
+	dummyHash []byte
+}
+
+func NewService(repo repository.IRepositoryOperator) *Service {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	return &Service{repo: repo, dummyHash: hash}
+}
+
+// Login returns the operator with the given name and password.
+func (s *Service) Login(ctx context.Context, name, password string) (models.Operator, error) {
+	operator, err := s.repo.GetOperatorByName(ctx, name)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.Operator{}, err
+	}
+	hash := []byte(operator.PasswordHash)
+	if err != nil || len(hash) == 0 {
+		hash = s.dummyHash
+		operator = models.Operator{}
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || operator.ID == 0 {
+		return models.Operator{}, ErrInvalidCredentials
+	}
+	return operator, nil
+}
+
+// Authenticate returns the operator owning the API token.
+func (s *Service) Authenticate(ctx context.Context, token string) (models.Operator, error) {
+	operator, err := s.repo.GetOperatorByTokenHash(ctx, HashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Operator{}, ErrInvalidCredentials
+	}
+	return operator, err
+}
+
+// HasOperators reports whether any operator exists, until then local
+// sessions run as Bootstrap.
+func (s *Service) HasOperators(ctx context.Context) (bool, error) {
+	operators, err := s.repo.GetOperators(ctx)
+	return len(operators) > 0, err
+}
+
+// Bootstrap is the operator of local sessions on a database without
+// operators, so the first admin can be created.
+var Bootstrap = models.Operator{Name: "bootstrap", Role: models.RoleAdmin}
+
+func (s *Service) Operators(ctx context.Context) ([]models.Operator, error) {
+	if err := Require(ctx, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return s.repo.GetOperators(ctx)
+}
+
+// AddOperator creates an operator, an empty password makes one that can
+// only use API tokens.
+func (s *Service) AddOperator(ctx context.Context, name string, role models.Role, password string) error {
+	if err := Require(ctx, models.RoleAdmin); err != nil {
+		return err
+	}
+	operator := models.Operator{Name: name, Role: role, CreatedAt: time.Now()}
+	if err := operator.Validate(); err != nil {
+		return err
+	}
+	if password != "" {
+		var err error
+		if operator.PasswordHash, err = HashPassword(password); err != nil {
+			return err
+		}
+	}
+	if _, err := s.repo.GetOperatorByName(ctx, name); err == nil {
+		return fmt.Errorf("operator %s already exists", name)
+	}
+	_, err := s.repo.InsertOperator(ctx, operator)
+	return err
+}
+
+func (s *Service) SetRole(ctx context.Context, name string, role models.Role) error {
+	operator, err := s.manage(ctx, name)
+	if err != nil {
+		return err
+	}
+	if operator.Role == models.RoleAdmin && role != models.RoleAdmin {
+		if err := s.keepAdmin(ctx); err != nil {
+			return err
+		}
+	}
+	operator.Role = role
+	if err := operator.Validate(); err != nil {
+		return err
+	}
+	_, err = s.repo.UpdateOperatorById(ctx, operator.ID, operator)
+	return err
+}
+
+// SetPassword changes the password of an operator, admins may change any,
+// others only their own.
+func (s *Service) SetPassword(ctx context.Context, name, password string) error {
+	operator, err := s.self(ctx, name)
+	if err != nil {
+		return err
+	}
+	if operator.PasswordHash, err = HashPassword(password); err != nil {
+		return err
+	}
+	_, err = s.repo.UpdateOperatorById(ctx, operator.ID, operator)
+	return err
+}
+
+// DeleteOperator deletes an operator together with its API tokens.
+func (s *Service) DeleteOperator(ctx context.Context, name string) error {
+	operator, err := s.manage(ctx, name)
+	if err != nil {
+		return err
+	}
+	if operator.Role == models.RoleAdmin {
+		if err := s.keepAdmin(ctx); err != nil {
+			return err
+		}
+	}
+	_, err = s.repo.DeleteOperatorById(ctx, operator.ID)
+	return err
+}
+
+// CreateToken creates an API token of the named operator and returns it, it
+// cannot be shown again. Admins may create tokens for anyone, others only
+// for themselves.
+func (s *Service) CreateToken(ctx context.Context, name, tokenName string) (string, error) {
+	operator, err := s.self(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	token, hash := NewToken()
+	_, err = s.repo.InsertAPIToken(ctx, models.APIToken{OperatorID: operator.ID, Name: tokenName, Hash: hash, CreatedAt: time.Now()})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// manage returns the named operator if the operator of ctx is an admin.
+func (s *Service) manage(ctx context.Context, name string) (models.Operator, error) {
+	if err := Require(ctx, models.RoleAdmin); err != nil {
+		return models.Operator{}, err
+	}
+	return s.get(ctx, name)
+}
+
+// self returns the named operator if it is the operator of ctx or that is
+// an admin.
+func (s *Service) self(ctx context.Context, name string) (models.Operator, error) {
+	if err := Require(ctx, models.RoleViewer); err != nil {
+		return models.Operator{}, err
+	}
+	if Actor(ctx) != name {
+		if err := Require(ctx, models.RoleAdmin); err != nil {
+			return models.Operator{}, err
+		}
+	}
+	return s.get(ctx, name)
+}
+
+func (s *Service) get(ctx context.Context, name string) (models.Operator, error) {
+	operator, err := s.repo.GetOperatorByName(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Operator{}, fmt.Errorf("operator %s not found", name)
+	}
+	return operator, err
+}
+
+// keepAdmin fails unless there are several admins, one must remain.
+func (s *Service) keepAdmin(ctx context.Context) error {
+	operators, err := s.repo.GetOperators(ctx)
+	if err != nil {
+		return err
+	}
+	admins := 0
+	for _, operator := range operators {
+		if operator.Role == models.RoleAdmin {
+			admins++
+		}
+	}
+	if admins < 2 {
+		return errors.New("the last admin cannot be removed")
+	}
+	return nil
+}
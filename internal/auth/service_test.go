@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"strings"
+	"testing"
+)
+
+func TestService_LoginAndTokens(t *testing.T) {
+	s := NewService(imp.NewMemoryRepoOperator())
+	admin := WithOperator(context.Background(), Bootstrap)
+
+	if err := s.AddOperator(admin, "ann", models.RoleEditor, "correct horse"); err != nil {
+		t.Fatalf("an error '%s' was not expected when adding an operator", err)
+	}
+	if err := s.AddOperator(admin, "ci", models.RoleViewer, ""); err != nil {
+		t.Fatalf("an error '%s' was not expected when adding a token-only operator", err)
+	}
+
+	ann, err := s.Login(context.Background(), "ann", "correct horse")
+	if err != nil || ann.Name != "ann" || ann.Role != models.RoleEditor {
+		t.Fatalf("got %+v, '%v', expected ann to log in", ann, err)
+	}
+	for _, login := range [][2]string{{"ann", "wrong"}, {"nobody", "correct horse"}, {"ci", ""}} {
+		if _, err := s.Login(context.Background(), login[0], login[1]); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("got '%v' for %v, expected ErrInvalidCredentials", err, login)
+		}
+	}
+
+	token, err := s.CreateToken(admin, "ci", "pipeline")
+	if err != nil || !strings.HasPrefix(token, tokenPrefix) {
+		t.Fatalf("got %q, '%v', expected a new token", token, err)
+	}
+	if ci, err := s.Authenticate(context.Background(), token); err != nil || ci.Name != "ci" {
+		t.Fatalf("got %+v, '%v', expected the token of ci", ci, err)
+	}
+	if _, err := s.Authenticate(context.Background(), token+"x"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("got '%v', expected ErrInvalidCredentials", err)
+	}
+
+	// Others may only create tokens for themselves.
+	asAnn := WithOperator(context.Background(), ann)
+	if _, err := s.CreateToken(asAnn, "ann", "laptop"); err != nil {
+		t.Fatalf("an error '%s' was not expected when creating an own token", err)
+	}
+	if _, err := s.CreateToken(asAnn, "ci", "stolen"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("got '%v', expected ErrForbidden", err)
+	}
+}
+
+func TestService_ManagingNeedsAdmin(t *testing.T) {
+	s := NewService(imp.NewMemoryRepoOperator())
+	bootstrap := WithOperator(context.Background(), Bootstrap)
+	if err := s.AddOperator(bootstrap, "root", models.RoleAdmin, "long enough"); err != nil {
+		t.Fatalf("an error '%s' was not expected when adding an operator", err)
+	}
+	root, _ := s.Login(context.Background(), "root", "long enough")
+	admin := WithOperator(context.Background(), root)
+
+	var verr models.ValidationError
+	if err := s.AddOperator(admin, "bob", models.RoleViewer, "short"); !errors.As(err, &verr) {
+		t.Fatalf("got '%v', expected a short password to be rejected", err)
+	}
+	if err := s.AddOperator(admin, "bob", models.RoleViewer, "long enough"); err != nil {
+		t.Fatalf("an error '%s' was not expected when adding an operator", err)
+	}
+	if err := s.AddOperator(admin, "bob", models.RoleViewer, "long enough"); err == nil {
+		t.Fatalf("expected an error when adding bob twice")
+	}
+	bob, _ := s.Login(context.Background(), "bob", "long enough")
+
+	asBob := WithOperator(context.Background(), bob)
+	if err := s.SetRole(asBob, "bob", models.RoleAdmin); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("got '%v', expected a viewer not to promote itself", err)
+	}
+	if err := s.AddOperator(context.Background(), "eve", models.RoleAdmin, "long enough"); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("got '%v', expected ErrUnauthenticated without an operator", err)
+	}
+
+	for _, change := range []func() error{
+		func() error { return s.SetRole(admin, "root", models.RoleEditor) },
+		func() error { return s.DeleteOperator(admin, "root") },
+	} {
+		if err := change(); err == nil || !strings.Contains(err.Error(), "last admin") {
+			t.Fatalf("got '%v', expected the last admin to stay", err)
+		}
+	}
+	if err := s.SetRole(admin, "bob", models.RoleAdmin); err != nil {
+		t.Fatalf("an error '%s' was not expected when promoting bob", err)
+	}
+	if err := s.DeleteOperator(admin, "root"); err != nil {
+		t.Fatalf("an error '%s' was not expected when deleting an admin while another remains", err)
+	}
+}
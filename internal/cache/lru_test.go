@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU[int, string](2)
+
+	c.Set(1, "one", time.Minute)
+	c.Set(2, "two", time.Minute)
+	if _, ok := c.Get(1); !ok {
+		t.Fatalf("expected key 1 to be cached")
+	}
+	c.Set(3, "three", time.Minute)
+
+	if _, ok := c.Get(2); ok {
+		t.Fatalf("expected key 2 to be evicted")
+	}
+	if v, ok := c.Get(1); !ok || v != "one" {
+		t.Fatalf("got %q, %v, expected \"one\", true", v, ok)
+	}
+	if c.Len() != 2 || c.Evictions() != 1 {
+		t.Fatalf("got len %d and %d evictions, expected 2 and 1", c.Len(), c.Evictions())
+	}
+}
+
+func TestLRU_ExpiresEntries(t *testing.T) {
+	now := time.Now()
+	c := NewLRU[int, string](10)
+	c.now = func() time.Time { return now }
+
+	c.Set(1, "one", time.Second)
+	now = now.Add(2 * time.Second)
+
+	if _, ok := c.Get(1); ok {
+		t.Fatalf("expected key 1 to be expired")
+	}
+	if c.Len() != 0 {
+		t.Fatalf("expected the expired entry to be removed, len is %d", c.Len())
+	}
+}
+
+func TestLRU_DeleteAndPurge(t *testing.T) {
+	c := NewLRU[int, string](10)
+	c.Set(1, "one", time.Minute)
+	c.Set(2, "two", time.Minute)
+
+	if !c.Delete(1) {
+		t.Fatalf("expected key 1 to be deleted")
+	}
+	if c.Delete(1) {
+		t.Fatalf("expected second delete of key 1 to report false")
+	}
+	c.Purge()
+	if c.Len() != 0 {
+		t.Fatalf("expected empty cache after purge, len is %d", c.Len())
+	}
+}
@@ -1,13 +1,223 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	_ "github.com/lib/pq"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
 )
 
-func Connect(host string, port string, user string, dbname string, password string) (*sql.DB, error) {
-	//connString := "host=127.0.0.1 port=5432 user=bohdan sslmode=disable dbname=mydatabase password=12345"
-	connString := fmt.Sprintf("host=%s port=%s user=%s sslmode=disable dbname=%s password=%s", host, port, user, dbname, password)
-	return sql.Open("postgres", connString)
+type Config struct {
+	// Driver is one of Drivers, postgres when empty.
+	Driver   string
+	Host     string
+	Port     string
+	User     string
+	DbName   string
+	Password string
+
+	// SSLMode is one of disable, require, verify-ca or verify-full.
+	SSLMode     string
+	SSLCert     string
+	SSLKey      string
+	SSLRootCert string
+	// PassFile is the pgpass file the password is looked up in when Password
+	// is empty, it defaults to $PGPASSFILE or ~/.pgpass.
+	PassFile string
+
+	ApplicationName string
+	ConnectTimeout  time.Duration
+	// Tenant names the tenant of every Postgres session in the app.tenant
+	// setting, which the row level security policies check. Empty leaves the
+	// sessions on the default tenant. With a tenant Connect refuses roles
+	// that bypass the policies.
+	Tenant string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// StartupTimeout is how long Connect keeps pinging a database that is
+	// not accepting connections yet, e.g. a container that is still starting.
+	StartupTimeout time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Driver:          DriverPostgres,
+		Port:            "5432",
+		SSLMode:         "disable",
+		ApplicationName: "simpleCLIdbManager",
+		ConnectTimeout:  5 * time.Second,
+		MaxOpenConns:    10,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: 30 * time.Minute,
+		ConnMaxIdleTime: 5 * time.Minute,
+		StartupTimeout:  30 * time.Second,
+	}
+}
+
+var sslModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
+func (cfg Config) Validate() error {
+	if !slices.Contains(sslModes, cfg.SSLMode) {
+		return fmt.Errorf("invalid sslmode %q, expected one of %s", cfg.SSLMode, strings.Join(sslModes, ", "))
+	}
+	if (cfg.SSLCert == "") != (cfg.SSLKey == "") {
+		return errors.New("client certificate and key must be given together")
+	}
+	if cfg.SSLMode == "disable" && (cfg.SSLCert != "" || cfg.SSLRootCert != "") {
+		return errors.New("certificates are given but sslmode is disable")
+	}
+	if cfg.Tenant != "" {
+		return models.ValidateTenantName(cfg.Tenant)
+	}
+	return nil
+}
+
+var connValueEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// quoteConnValue quotes a keyword/value connection string value, so spaces,
+// quotes and backslashes in e.g. a password are passed through verbatim.
+func quoteConnValue(v string) string {
+	return "'" + connValueEscaper.Replace(v) + "'"
+}
+
+func ConnString(cfg Config) (string, error) {
+	if err := cfg.Validate(); err != nil {
+		return "", err
+	}
+	password := cfg.Password
+	if password == "" {
+		var err error
+		password, err = lookupPassFile(cfg)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	params := [][2]string{
+		{"host", cfg.Host},
+		{"port", cfg.Port},
+		{"user", cfg.User},
+		{"dbname", cfg.DbName},
+		{"sslmode", cfg.SSLMode},
+		{"sslcert", cfg.SSLCert},
+		{"sslkey", cfg.SSLKey},
+		{"sslrootcert", cfg.SSLRootCert},
+		{"password", password},
+		{"application_name", cfg.ApplicationName},
+	}
+	if cfg.ConnectTimeout > 0 {
+		params = append(params, [2]string{"connect_timeout", fmt.Sprint(max(1, int(cfg.ConnectTimeout.Seconds())))})
+	}
+	if cfg.Tenant != "" {
+		// Validate keeps the name from adding other options.
+		params = append(params, [2]string{"options", "-c app.tenant=" + cfg.Tenant})
+	}
+
+	var b strings.Builder
+	for _, p := range params {
+		if p[1] == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(p[0] + "=" + quoteConnValue(p[1]))
+	}
+	return b.String(), nil
+}
+
+// Connect opens the connection pool and waits until the database answers a ping.
+func Connect(ctx context.Context, cfg Config) (*sql.DB, error) {
+	driver, dsn, err := DataSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+	con, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	con.SetMaxOpenConns(cfg.MaxOpenConns)
+	con.SetMaxIdleConns(cfg.MaxIdleConns)
+	con.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	con.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if err := waitForDatabase(ctx, con, cfg.StartupTimeout); err != nil {
+		con.Close()
+		return nil, err
+	}
+	if driver == DriverPostgres && cfg.Tenant != "" {
+		if err := checkRowSecurity(ctx, con); err != nil {
+			con.Close()
+			return nil, err
+		}
+	}
+	return con, nil
+}
+
+// checkRowSecurity refuses the roles row level security does not apply to,
+// their sessions would see the rows of every tenant.
+func checkRowSecurity(ctx context.Context, con *sql.DB) error {
+	const query = "select rolname, rolsuper, rolbypassrls from pg_roles where rolname = current_user;"
+	var role string
+	var super, bypass bool
+	if err := con.QueryRowContext(ctx, query).Scan(&role, &super, &bypass); err != nil {
+		return fmt.Errorf("could not check the role of the connection: %w", err)
+	}
+	if super || bypass {
+		return fmt.Errorf("role %q bypasses row level security, which keeps the tenants apart: connect as a role that is neither a superuser nor has BYPASSRLS", role)
+	}
+	return nil
+}
+
+// isPermanentConnectError reports whether retrying the ping cannot help,
+// such as wrong credentials or a missing database.
+func isPermanentConnectError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "28", "3D": // invalid_authorization_specification, invalid_catalog_name
+			return true
+		}
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1045 || myErr.Number == 1049 // access denied, unknown database
+	}
+	return false
+}
+
+func waitForDatabase(ctx context.Context, con *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	delay := 250 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := con.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if isPermanentConnectError(err) {
+			return err
+		}
+		log.Printf("[INFO] database is not ready (attempt %d): %v", attempt, err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database did not become ready within %s: %w", timeout, err)
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, 5*time.Second)
+	}
 }
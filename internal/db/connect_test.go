@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestConnString_QuotesValues(t *testing.T) {
+	cfg := Config{
+		Host:     "localhost",
+		Port:     "5432",
+		User:     "bohdan",
+		DbName:   "my db",
+		Password: `pa ss'w\rd`,
+		SSLMode:  "disable",
+	}
+	got, err := ConnString(cfg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when building the connection string", err)
+	}
+	expected := `host='localhost' port='5432' user='bohdan' dbname='my db' sslmode='disable' password='pa ss\'w\\rd'`
+	if got != expected {
+		t.Fatalf("got %s, expected %s", got, expected)
+	}
+}
+
+func TestConnString_TLSOptions(t *testing.T) {
+	cfg := Config{
+		Host:        "db.internal",
+		Port:        "5432",
+		User:        "bohdan",
+		DbName:      "users",
+		Password:    "secret",
+		SSLMode:     "verify-full",
+		SSLCert:     "/certs/client.crt",
+		SSLKey:      "/certs/client.key",
+		SSLRootCert: "/certs/root.crt",
+	}
+	got, err := ConnString(cfg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when building the connection string", err)
+	}
+	for _, part := range []string{"sslmode='verify-full'", "sslcert='/certs/client.crt'", "sslkey='/certs/client.key'", "sslrootcert='/certs/root.crt'"} {
+		if !strings.Contains(got, part) {
+			t.Fatalf("expected %s in %s", part, got)
+		}
+	}
+}
+
+func TestConnString_Tenant(t *testing.T) {
+	cfg := Config{Host: "localhost", Port: "5432", User: "bohdan", DbName: "users", Password: "secret", SSLMode: "disable", Tenant: "acme"}
+	got, err := ConnString(cfg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when building the connection string", err)
+	}
+	if !strings.HasSuffix(got, "options='-c app.tenant=acme'") {
+		t.Fatalf("expected the tenant setting in %s", got)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"unknown sslmode", Config{SSLMode: "prefer"}},
+		{"cert without key", Config{SSLMode: "require", SSLCert: "/certs/client.crt"}},
+		{"certs with sslmode disable", Config{SSLMode: "disable", SSLRootCert: "/certs/root.crt"}},
+		{"tenant adding options", Config{SSLMode: "disable", Tenant: "acme -c row_security=off"}},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); err == nil {
+			t.Fatalf("%s: an error was expected", tt.name)
+		}
+	}
+}
+
+func TestConnString_PassFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pgpass")
+	content := "# comment\nother:5432:*:bohdan:wrong\nlocalhost:5432:*:bohdan:s\\:ecret\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("an error '%s' was not expected when writing the pgpass file", err)
+	}
+
+	cfg := Config{Host: "localhost", Port: "5432", User: "bohdan", DbName: "users", SSLMode: "disable", PassFile: path}
+	got, err := ConnString(cfg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when building the connection string", err)
+	}
+	if !strings.Contains(got, "password='s:ecret'") {
+		t.Fatalf("expected the password from the pgpass file in %s", got)
+	}
+}
+
+func TestConnString_PassFileWithLoosePermissionsIsIgnored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pgpass")
+	if err := os.WriteFile(path, []byte("*:*:*:*:secret\n"), 0o644); err != nil {
+		t.Fatalf("an error '%s' was not expected when writing the pgpass file", err)
+	}
+
+	cfg := Config{Host: "localhost", Port: "5432", User: "bohdan", DbName: "users", SSLMode: "disable", PassFile: path}
+	got, err := ConnString(cfg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when building the connection string", err)
+	}
+	if strings.Contains(got, "password=") {
+		t.Fatalf("expected no password in %s", got)
+	}
+}
+
+func TestCheckRowSecurity(t *testing.T) {
+	for _, c := range []struct {
+		name          string
+		super, bypass bool
+		refused       bool
+	}{
+		{"app", false, false, false},
+		{"postgres", true, false, true},
+		{"migrator", false, true, true},
+	} {
+		con, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		mock.ExpectQuery(regexp.QuoteMeta("select rolname, rolsuper, rolbypassrls from pg_roles where rolname = current_user;")).
+			WillReturnRows(sqlmock.NewRows([]string{"rolname", "rolsuper", "rolbypassrls"}).AddRow(c.name, c.super, c.bypass))
+
+		err = checkRowSecurity(context.Background(), con)
+		if (err != nil) != c.refused {
+			t.Fatalf("got '%v' for role %s, expected refused %v", err, c.name, c.refused)
+		}
+		con.Close()
+	}
+}
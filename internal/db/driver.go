@@ -0,0 +1,90 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+	DriverSQLite   = "sqlite"
+)
+
+var Drivers = []string{DriverPostgres, DriverMySQL, DriverSQLite}
+
+// DataSource returns the database/sql driver name and data source name for cfg.
+func DataSource(cfg Config) (string, string, error) {
+	switch cfg.Driver {
+	case DriverPostgres, "":
+		dsn, err := ConnString(cfg)
+		return DriverPostgres, dsn, err
+	case DriverMySQL:
+		dsn, err := mysqlDSN(cfg)
+		return DriverMySQL, dsn, err
+	case DriverSQLite:
+		return DriverSQLite, sqliteDSN(cfg), nil
+	default:
+		return "", "", fmt.Errorf("unknown driver %q, expected one of %v", cfg.Driver, Drivers)
+	}
+}
+
+func mysqlDSN(cfg Config) (string, error) {
+	if err := cfg.Validate(); err != nil {
+		return "", err
+	}
+	if cfg.SSLCert != "" || cfg.SSLRootCert != "" || cfg.SSLMode == "verify-ca" {
+		return "", errors.New("client certificates and verify-ca are only supported by the postgres driver")
+	}
+
+	mc := mysql.NewConfig()
+	mc.User = cfg.User
+	mc.Passwd = cfg.Password
+	mc.Net = "tcp"
+	mc.Addr = net.JoinHostPort(cfg.Host, cfg.Port)
+	mc.DBName = cfg.DbName
+	mc.ParseTime = true
+	mc.Loc = time.Local
+	mc.Timeout = cfg.ConnectTimeout
+	switch cfg.SSLMode {
+	case "require":
+		mc.TLSConfig = "skip-verify"
+	case "verify-full":
+		mc.TLSConfig = "true"
+	}
+	if cfg.ApplicationName != "" {
+		mc.ConnectionAttributes = "program_name:" + cfg.ApplicationName
+	}
+	return mc.FormatDSN(), nil
+}
+
+// sqlitePath escapes the characters that end the path of a file: URI.
+var sqlitePath = strings.NewReplacer("%", "%25", "?", "%3F", "#", "%23")
+
+// sqliteDSN uses DbName as the path of the database file.
+func sqliteDSN(cfg Config) string {
+	params := url.Values{}
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Set("_time_format", "sqlite")
+	return "file:" + sqlitePath.Replace(cfg.DbName) + "?" + params.Encode()
+}
+
+// serverVersionQuery returns the statement reporting the server version.
+func serverVersionQuery(driver string) string {
+	switch driver {
+	case DriverMySQL:
+		return "SELECT VERSION();"
+	case DriverSQLite:
+		return "SELECT sqlite_version();"
+	default:
+		return "SHOW server_version;"
+	}
+}
@@ -0,0 +1,30 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Health struct {
+	Latency       time.Duration
+	ServerVersion string
+	Stats         sql.DBStats
+}
+
+// CheckHealth measures a round trip to the server and reports its version
+// together with the connection pool statistics.
+func CheckHealth(ctx context.Context, con *sql.DB, driver string) (Health, error) {
+	var health Health
+	start := time.Now()
+	if err := con.PingContext(ctx); err != nil {
+		return health, err
+	}
+	health.Latency = time.Since(start)
+
+	if err := con.QueryRowContext(ctx, serverVersionQuery(driver)).Scan(&health.ServerVersion); err != nil {
+		return health, err
+	}
+	health.Stats = con.Stats()
+	return health, nil
+}
@@ -1,9 +1,18 @@
 package db
 
-import "database/sql"
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
 
-func Migrate(db *sql.DB) error {
-	queries := []string{
+	"golang.org/x/crypto/bcrypt"
+)
+
+// migrations are applied in order and recorded in schema_migrations by their
+// position, so new ones are only ever appended. The first ones predate that
+// table and are safe to run again on databases created before it.
+var migrations = map[string][]string{
+	DriverPostgres: {
 		`create table if not exists logs(
     		id int GENERATED ALWAYS AS IDENTITY,
     		log_time timestamp not null default now(),
@@ -16,12 +25,610 @@ func Migrate(db *sql.DB) error {
 			password varchar(255) not null,
 			registered_at timestamp not null default now()
 		);`,
+		`create or replace function notify_users_changed() returns trigger as $$
+		begin
+			perform pg_notify('users_changed', coalesce(new.id, old.id)::text);
+			return null;
+		end;
+		$$ language plpgsql;`,
+		`create or replace trigger users_changed
+			after insert or update or delete on users
+			for each row execute function notify_users_changed();`,
+		`alter table logs add column if not exists details text;`,
+		`create table if not exists operators(
+			id int generated always as identity primary key,
+			name varchar(50) not null unique,
+			role varchar(10) not null,
+			password_hash varchar(255),
+			created_at timestamp not null default now()
+		);`,
+		`create table if not exists api_tokens(
+			id int generated always as identity primary key,
+			operator_id int not null references operators(id) on delete cascade,
+			name varchar(50) not null,
+			token_hash char(64) not null unique,
+			created_at timestamp not null default now()
+		);`,
+		`alter table logs add column if not exists actor varchar(50);`,
+		`create table if not exists login_lockouts(
+			email varchar(50) primary key,
+			failures int not null default 0,
+			locked_until timestamp
+		);`,
+		`create table if not exists revoked_sessions(
+			id varchar(64) primary key,
+			expires_at timestamp not null
+		);`,
+		`alter table users add column if not exists email_verified_at timestamp;`,
+		`create table if not exists email_verification_tokens(
+			token_hash char(64) primary key,
+			user_id int not null,
+			email varchar(50) not null,
+			created_at timestamp not null,
+			expires_at timestamp not null,
+			used_at timestamp
+		);`,
+		`create table if not exists password_reset_tokens(
+			token_hash char(64) primary key,
+			user_id int not null,
+			email varchar(50) not null,
+			created_at timestamp not null,
+			expires_at timestamp not null,
+			used_at timestamp
+		);`,
+		// Users from before the lifecycle keep working, so they are active.
+		`alter table users add column if not exists status varchar(20) not null default 'active'
+			check (status in ('pending', 'active', 'suspended', 'locked', 'deleted'));`,
+		`create index if not exists users_status_idx on users (status);`,
+		// The roles and groups reference the users, which needs a key.
+		`alter table users add primary key (id);`,
+		`create table if not exists roles(
+			id int generated always as identity primary key,
+			name varchar(50) not null unique,
+			description varchar(255) not null default '',
+			created_at timestamp not null default now()
+		);`,
+		`create table if not exists groups(
+			id int generated always as identity primary key,
+			name varchar(50) not null unique,
+			description varchar(255) not null default '',
+			created_at timestamp not null default now()
+		);`,
+		`create table if not exists user_roles(
+			user_id int not null references users(id) on delete cascade,
+			role_id int not null references roles(id) on delete cascade,
+			primary key (user_id, role_id)
+		);`,
+		`create index if not exists user_roles_role_id_idx on user_roles (role_id);`,
+		`create table if not exists group_members(
+			user_id int not null references users(id) on delete cascade,
+			group_id int not null references groups(id) on delete cascade,
+			primary key (user_id, group_id)
+		);`,
+		`create index if not exists group_members_group_id_idx on group_members (group_id);`,
+		`alter table users add column if not exists attributes jsonb not null default '{}';`,
+		// GetUsers filters the attributes by containment, which the index
+		// serves.
+		`create index if not exists users_attributes_idx on users using gin (attributes);`,
+		`create table if not exists tenants(
+			id int generated always as identity primary key,
+			name varchar(50) not null unique,
+			created_at timestamp not null default now()
+		);`,
+		// The users and logs from before tenants belong to the first one.
+		`insert into tenants(name) values ('default') on conflict (name) do nothing;`,
+		// The tenant of a session is named by the app.tenant setting, which
+		// the connection string sets. Sessions without it work on the
+		// default tenant, an unknown name on none.
+		`create or replace function current_tenant_id() returns int as $$
+			select id from tenants where name = coalesce(nullif(current_setting('app.tenant', true), ''), 'default');
+		$$ language sql stable;`,
+		`alter table users add column if not exists tenant_id int not null default 1 references tenants(id);`,
+		`alter table users alter column tenant_id set default current_tenant_id();`,
+		`create index if not exists users_tenant_id_idx on users (tenant_id);`,
+		`alter table logs add column if not exists tenant_id int not null default 1 references tenants(id);`,
+		`alter table logs alter column tenant_id set default current_tenant_id();`,
+		`create index if not exists logs_tenant_id_idx on logs (tenant_id);`,
+		// Row level security keeps a session to the rows of its tenant even
+		// when a query forgets to, also for the owner of the tables.
+		// Superusers and roles with BYPASSRLS still see every row.
+		`alter table users enable row level security;`,
+		`alter table users force row level security;`,
+		`create policy users_tenant_isolation on users
+			using (tenant_id = current_tenant_id())
+			with check (tenant_id = current_tenant_id());`,
+		`alter table logs enable row level security;`,
+		`alter table logs force row level security;`,
+		`create policy logs_tenant_isolation on logs
+			using (tenant_id = current_tenant_id())
+			with check (tenant_id = current_tenant_id());`,
+		// An email belongs to one user of a tenant, whatever its case. The
+		// repositories report a violation as a ValidationError of the email.
+		`create unique index if not exists users_tenant_email_idx on users (tenant_id, lower(email));`,
+		`alter table users rename column password to password_hash;`,
+		hashPasswords,
+		// Failures are forgotten a while after the last one.
+		`alter table login_lockouts add column if not exists failed_at timestamp not null default now();`,
+		`create index if not exists login_lockouts_failed_at_idx on login_lockouts (failed_at);`,
+		hashLogPasswords,
+		// The lockouts, revoked sessions and tokens belong to the tenant of
+		// their user as well. An email is locked in one tenant only, the
+		// session IDs and token hashes are random and stay unique alone.
+		`alter table login_lockouts add column if not exists tenant_id int not null default 1 references tenants(id);`,
+		`alter table login_lockouts alter column tenant_id set default current_tenant_id();`,
+		`alter table revoked_sessions add column if not exists tenant_id int not null default 1 references tenants(id);`,
+		`alter table revoked_sessions alter column tenant_id set default current_tenant_id();`,
+		`alter table email_verification_tokens add column if not exists tenant_id int not null default 1 references tenants(id);`,
+		`alter table email_verification_tokens alter column tenant_id set default current_tenant_id();`,
+		`alter table password_reset_tokens add column if not exists tenant_id int not null default 1 references tenants(id);`,
+		`alter table password_reset_tokens alter column tenant_id set default current_tenant_id();`,
+		`alter table login_lockouts drop constraint login_lockouts_pkey, add primary key (tenant_id, email);`,
+		`alter table login_lockouts enable row level security;`,
+		`alter table login_lockouts force row level security;`,
+		`create policy login_lockouts_tenant_isolation on login_lockouts
+			using (tenant_id = current_tenant_id())
+			with check (tenant_id = current_tenant_id());`,
+		`alter table revoked_sessions enable row level security;`,
+		`alter table revoked_sessions force row level security;`,
+		`create policy revoked_sessions_tenant_isolation on revoked_sessions
+			using (tenant_id = current_tenant_id())
+			with check (tenant_id = current_tenant_id());`,
+		`alter table email_verification_tokens enable row level security;`,
+		`alter table email_verification_tokens force row level security;`,
+		`create policy email_verification_tokens_tenant_isolation on email_verification_tokens
+			using (tenant_id = current_tenant_id())
+			with check (tenant_id = current_tenant_id());`,
+		`alter table password_reset_tokens enable row level security;`,
+		`alter table password_reset_tokens force row level security;`,
+		`create policy password_reset_tokens_tenant_isolation on password_reset_tokens
+			using (tenant_id = current_tenant_id())
+			with check (tenant_id = current_tenant_id());`,
+	},
+	DriverMySQL: {
+		`create table if not exists logs(
+			id int not null auto_increment primary key,
+			log_time datetime(6) not null default current_timestamp(6),
+			log_message varchar(255) not null
+		);`,
+		`create table if not exists users(
+			id int not null auto_increment primary key,
+			name varchar(20) not null,
+			email varchar(50) not null,
+			password varchar(255) not null,
+			registered_at datetime(6) not null default current_timestamp(6)
+		);`,
+		`alter table logs add column details text;`,
+		`create table if not exists operators(
+			id int not null auto_increment primary key,
+			name varchar(50) not null unique,
+			role varchar(10) not null,
+			password_hash varchar(255),
+			created_at datetime(6) not null default current_timestamp(6)
+		);`,
+		`create table if not exists api_tokens(
+			id int not null auto_increment primary key,
+			operator_id int not null,
+			name varchar(50) not null,
+			token_hash char(64) not null unique,
+			created_at datetime(6) not null default current_timestamp(6),
+			foreign key (operator_id) references operators(id) on delete cascade
+		);`,
+		`alter table logs add column actor varchar(50);`,
+		`create table if not exists login_lockouts(
+			email varchar(50) not null primary key,
+			failures int not null default 0,
+			locked_until datetime(6) null
+		);`,
+		`create table if not exists revoked_sessions(
+			id varchar(64) not null primary key,
+			expires_at datetime(6) not null
+		);`,
+		`alter table users add column email_verified_at datetime(6) null;`,
+		`create table if not exists email_verification_tokens(
+			token_hash char(64) not null primary key,
+			user_id int not null,
+			email varchar(50) not null,
+			created_at datetime(6) not null,
+			expires_at datetime(6) not null,
+			used_at datetime(6) null,
+			index (user_id)
+		);`,
+		`create table if not exists password_reset_tokens(
+			token_hash char(64) not null primary key,
+			user_id int not null,
+			email varchar(50) not null,
+			created_at datetime(6) not null,
+			expires_at datetime(6) not null,
+			used_at datetime(6) null,
+			index (user_id)
+		);`,
+		`alter table users add column status varchar(20) not null default 'active'
+			check (status in ('pending', 'active', 'suspended', 'locked', 'deleted')), add index (status);`,
+		`create table if not exists roles(
+			id int not null auto_increment primary key,
+			name varchar(50) not null unique,
+			description varchar(255) not null default '',
+			created_at datetime(6) not null default current_timestamp(6)
+		);`,
+		// GROUPS is a reserved word in MySQL.
+		"create table if not exists `groups`(" + `
+			id int not null auto_increment primary key,
+			name varchar(50) not null unique,
+			description varchar(255) not null default '',
+			created_at datetime(6) not null default current_timestamp(6)
+		);`,
+		`create table if not exists user_roles(
+			user_id int not null,
+			role_id int not null,
+			primary key (user_id, role_id),
+			index (role_id)
+		);`,
+		`create table if not exists group_members(
+			user_id int not null,
+			group_id int not null,
+			primary key (user_id, group_id),
+			index (group_id)
+		);`,
+		// Without foreign keys to users, which would keep the tests from
+		// truncating it, a trigger deletes the roles and groups of users.
+		`create trigger users_delete_memberships after delete on users for each row
+		begin
+			delete from user_roles where user_id = old.id;
+			delete from group_members where user_id = old.id;
+		end;`,
+		`alter table users add column attributes json not null default (json_object());`,
+		`create table if not exists tenants(
+			id int not null auto_increment primary key,
+			name varchar(50) not null unique,
+			created_at datetime(6) not null default current_timestamp(6)
+		);`,
+		// The users and logs from before tenants belong to the first one.
+		`insert ignore into tenants(name) values ('default');`,
+		`alter table users add column tenant_id int not null default 1, add index (tenant_id);`,
+		`alter table logs add column tenant_id int not null default 1, add index (tenant_id);`,
+		`create unique index users_tenant_email_idx on users (tenant_id, (lower(email)));`,
+		`alter table users rename column password to password_hash;`,
+		hashPasswords,
+		// Failures are forgotten a while after the last one.
+		`alter table login_lockouts add column failed_at datetime(6) not null default current_timestamp(6), add index (failed_at);`,
+		hashLogPasswords,
+		// The lockouts, revoked sessions and tokens belong to the tenant of
+		// their user as well.
+		`alter table login_lockouts add column tenant_id int not null default 1 first, drop primary key, add primary key (tenant_id, email);`,
+		`alter table revoked_sessions add column tenant_id int not null default 1;`,
+		`alter table email_verification_tokens add column tenant_id int not null default 1;`,
+		`alter table password_reset_tokens add column tenant_id int not null default 1;`,
+	},
+	DriverSQLite: {
+		`create table if not exists logs(
+			id integer primary key autoincrement,
+			log_time datetime not null default current_timestamp,
+			log_message varchar(255) not null
+		);`,
+		`create table if not exists users(
+			id integer primary key autoincrement,
+			name varchar(20) not null,
+			email varchar(50) not null,
+			password varchar(255) not null,
+			registered_at datetime not null default current_timestamp
+		);`,
+		`alter table logs add column details text;`,
+		`create table if not exists operators(
+			id integer primary key autoincrement,
+			name varchar(50) not null unique,
+			role varchar(10) not null,
+			password_hash varchar(255),
+			created_at datetime not null default current_timestamp
+		);`,
+		`create table if not exists api_tokens(
+			id integer primary key autoincrement,
+			operator_id integer not null references operators(id) on delete cascade,
+			name varchar(50) not null,
+			token_hash char(64) not null unique,
+			created_at datetime not null default current_timestamp
+		);`,
+		`alter table logs add column actor varchar(50);`,
+		`create table if not exists login_lockouts(
+			email varchar(50) not null primary key,
+			failures integer not null default 0,
+			locked_until datetime
+		);`,
+		`create table if not exists revoked_sessions(
+			id varchar(64) not null primary key,
+			expires_at datetime not null
+		);`,
+		`alter table users add column email_verified_at datetime;`,
+		`create table if not exists email_verification_tokens(
+			token_hash char(64) not null primary key,
+			user_id integer not null,
+			email varchar(50) not null,
+			created_at datetime not null,
+			expires_at datetime not null,
+			used_at datetime
+		);`,
+		`create table if not exists password_reset_tokens(
+			token_hash char(64) not null primary key,
+			user_id integer not null,
+			email varchar(50) not null,
+			created_at datetime not null,
+			expires_at datetime not null,
+			used_at datetime
+		);`,
+		`alter table users add column status varchar(20) not null default 'active'
+			check (status in ('pending', 'active', 'suspended', 'locked', 'deleted'));`,
+		`create index if not exists users_status_idx on users (status);`,
+		`create table if not exists roles(
+			id integer primary key autoincrement,
+			name varchar(50) not null unique,
+			description varchar(255) not null default '',
+			created_at datetime not null default current_timestamp
+		);`,
+		`create table if not exists groups(
+			id integer primary key autoincrement,
+			name varchar(50) not null unique,
+			description varchar(255) not null default '',
+			created_at datetime not null default current_timestamp
+		);`,
+		`create table if not exists user_roles(
+			user_id integer not null,
+			role_id integer not null,
+			primary key (user_id, role_id)
+		);`,
+		`create index if not exists user_roles_role_id_idx on user_roles (role_id);`,
+		`create table if not exists group_members(
+			user_id integer not null,
+			group_id integer not null,
+			primary key (user_id, group_id)
+		);`,
+		`create index if not exists group_members_group_id_idx on group_members (group_id);`,
+		// SQLite only enforces foreign keys when asked to, a trigger deletes
+		// the roles and groups of users.
+		`create trigger if not exists users_delete_memberships after delete on users
+		begin
+			delete from user_roles where user_id = old.id;
+			delete from group_members where user_id = old.id;
+		end;`,
+		`alter table users add column attributes text not null default '{}';`,
+		`create table if not exists tenants(
+			id integer primary key autoincrement,
+			name varchar(50) not null unique,
+			created_at datetime not null default current_timestamp
+		);`,
+		// The users and logs from before tenants belong to the first one.
+		`insert or ignore into tenants(name) values ('default');`,
+		`alter table users add column tenant_id integer not null default 1;`,
+		`create index if not exists users_tenant_id_idx on users (tenant_id);`,
+		`alter table logs add column tenant_id integer not null default 1;`,
+		`create index if not exists logs_tenant_id_idx on logs (tenant_id);`,
+		`create unique index if not exists users_tenant_email_idx on users (tenant_id, lower(email));`,
+		`alter table users rename column password to password_hash;`,
+		hashPasswords,
+		// Failures are forgotten a while after the last one. SQLite only
+		// adds columns with a constant default, the existing rows count as
+		// stale.
+		`alter table login_lockouts add column failed_at datetime not null default '1970-01-01 00:00:00';`,
+		`create index if not exists login_lockouts_failed_at_idx on login_lockouts (failed_at);`,
+		hashLogPasswords,
+		// The lockouts, revoked sessions and tokens belong to the tenant of
+		// their user as well. SQLite cannot change a primary key, so the
+		// lockouts move to a new table.
+		`create table login_lockouts_new(
+			tenant_id integer not null default 1,
+			email varchar(50) not null,
+			failures integer not null default 0,
+			locked_until datetime,
+			failed_at datetime not null default '1970-01-01 00:00:00',
+			primary key (tenant_id, email)
+		);`,
+		`insert into login_lockouts_new (email, failures, locked_until, failed_at)
+			select email, failures, locked_until, failed_at from login_lockouts;`,
+		`drop table login_lockouts;`,
+		`alter table login_lockouts_new rename to login_lockouts;`,
+		`create index if not exists login_lockouts_failed_at_idx on login_lockouts (failed_at);`,
+		`alter table revoked_sessions add column tenant_id integer not null default 1;`,
+		`alter table email_verification_tokens add column tenant_id integer not null default 1;`,
+		`alter table password_reset_tokens add column tenant_id integer not null default 1;`,
+	},
+}
+
+// hashPasswords stands in migrations for the step replacing the plain text
+// passwords of the users with their bcrypt hashes, which SQL cannot compute.
+const hashPasswords = "-- hash the passwords of the users"
+
+// hashLogPasswords stands in migrations for the step replacing the plain text
+// passwords kept in the audit records of the logs with their hashes.
+const hashLogPasswords = "-- hash the passwords in the logs"
+
+const createMigrationsTable = `create table if not exists schema_migrations(
+	version int not null primary key
+);`
+
+// Migrate brings the schema up to date, skipping the migrations already
+// recorded in schema_migrations.
+func Migrate(db *sql.DB, driver string) error {
+	if driver == "" {
+		driver = DriverPostgres
+	}
+	queries, ok := migrations[driver]
+	if !ok {
+		return fmt.Errorf("no migrations for driver %q", driver)
+	}
+	if _, err := db.Exec(createMigrationsTable); err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+	for i, query := range queries {
+		version := i + 1
+		if applied[version] {
+			continue
+		}
+		if err := applyMigration(db, driver, version, query); err != nil {
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+	}
+	return nil
+}
+
+func appliedMigrations(db *sql.DB) (map[int]bool, error) {
+	rows, err := db.Query("select version from schema_migrations;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// applyMigration runs query and records it in one transaction. MySQL commits
+// DDL statements implicitly, there a failure may leave the change without
+// its record, which the following run then fails on.
+func applyMigration(db *sql.DB, driver string, version int, query string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	switch query {
+	case hashPasswords:
+		err = hashUserPasswords(tx, driver)
+	case hashLogPasswords:
+		err = hashLoggedPasswords(tx, driver)
+	default:
+		_, err = tx.Exec(query)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("insert into schema_migrations(version) values (%d);", version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// hashUserPasswords replaces the passwords of all users, which are plain text
+// until this migration, with their bcrypt hashes. The row level security of
+// Postgres would hide the users of the other tenants, the owner of the table
+// lifts it for the transaction.
+func hashUserPasswords(tx *sql.Tx, driver string) error {
+	update := "update users set password_hash = ? where id = ?;"
+	if driver == DriverPostgres {
+		if _, err := tx.Exec("alter table users no force row level security;"); err != nil {
+			return err
+		}
+		update = "update users set password_hash = $1 where id = $2;"
+	}
+
+	rows, err := tx.Query("select id, password_hash from users where password_hash <> '';")
+	if err != nil {
+		return err
+	}
+	passwords := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var password string
+		if err := rows.Scan(&id, &password); err != nil {
+			rows.Close()
+			return err
+		}
+		passwords[id] = password
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
 	}
-	for _, query := range queries {
-		_, err := db.Exec(query)
+
+	for id, password := range passwords {
+		hash, err := hashPassword(password)
 		if err != nil {
+			return fmt.Errorf("user %d: %w", id, err)
+		}
+		if _, err := tx.Exec(update, hash, id); err != nil {
 			return err
 		}
 	}
-	return nil
+
+	if driver == DriverPostgres {
+		_, err = tx.Exec("alter table users force row level security;")
+	}
+	return err
+}
+
+// hashLoggedPasswords replaces the plain text passwords the audit records of
+// updates and deletes kept of the previous values with their hashes, which
+// undo restores.
+func hashLoggedPasswords(tx *sql.Tx, driver string) error {
+	update := "update logs set details = ? where id = ?;"
+	if driver == DriverPostgres {
+		if _, err := tx.Exec("alter table logs no force row level security;"); err != nil {
+			return err
+		}
+		update = "update logs set details = $1 where id = $2;"
+	}
+
+	rows, err := tx.Query(`select id, details from logs where details like '%"password":%';`)
+	if err != nil {
+		return err
+	}
+	details := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var d string
+		if err := rows.Scan(&id, &d); err != nil {
+			rows.Close()
+			return err
+		}
+		details[id] = d
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, d := range details {
+		var record map[string]json.RawMessage
+		var before map[string]json.RawMessage
+		if json.Unmarshal([]byte(d), &record) != nil || json.Unmarshal(record["before"], &before) != nil {
+			continue
+		}
+		var password string
+		if json.Unmarshal(before["password"], &password) != nil {
+			continue
+		}
+		hash, err := hashPassword(password)
+		if err != nil {
+			return fmt.Errorf("log %d: %w", id, err)
+		}
+		delete(before, "password")
+		before["password_hash"], _ = json.Marshal(hash)
+		record["before"], _ = json.Marshal(before)
+		updated, _ := json.Marshal(record)
+		if _, err := tx.Exec(update, string(updated), id); err != nil {
+			return err
+		}
+	}
+
+	if driver == DriverPostgres {
+		_, err = tx.Exec("alter table logs force row level security;")
+	}
+	return err
+}
+
+// hashPassword returns the bcrypt hash of a password stored before the
+// hashes. Longer passwords were accepted then, bcrypt compares only their
+// first 72 bytes, which GenerateFromPassword insists on.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password[:min(len(password), 72)]), bcrypt.DefaultCost)
+	return string(hash), err
 }
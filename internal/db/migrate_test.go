@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMigrate_UpgradesUnversionedDatabase(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Driver = DriverSQLite
+	cfg.DbName = filepath.Join(t.TempDir(), "test.db")
+	con, err := Connect(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a sqlite database", err)
+	}
+	defer con.Close()
+
+	// A database created before migrations were versioned has the tables
+	// but no schema_migrations.
+	for _, query := range migrations[DriverSQLite][:2] {
+		if _, err := con.Exec(query); err != nil {
+			t.Fatalf("an error '%s' was not expected when creating the old schema", err)
+		}
+	}
+	for range 2 {
+		if err := Migrate(con, DriverSQLite); err != nil {
+			t.Fatalf("an error '%s' was not expected when migrating", err)
+		}
+	}
+
+	var applied int
+	if err := con.QueryRow("select count(*) from schema_migrations;").Scan(&applied); err != nil {
+		t.Fatalf("an error '%s' was not expected when counting migrations", err)
+	}
+	if applied != len(migrations[DriverSQLite]) {
+		t.Fatalf("got %d applied migrations, expected %d", applied, len(migrations[DriverSQLite]))
+	}
+	if _, err := con.Exec("insert into logs (log_message, details) values ('x', '{}');"); err != nil {
+		t.Fatalf("an error '%s' was not expected when using the new column", err)
+	}
+}
+
+func TestMigrate_HashesPlainPasswords(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Driver = DriverSQLite
+	cfg.DbName = filepath.Join(t.TempDir(), "test.db")
+	con, err := Connect(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a sqlite database", err)
+	}
+	defer con.Close()
+
+	// The users stored before the hashes kept their passwords in plain text.
+	all := migrations[DriverSQLite]
+	migrations[DriverSQLite] = all[:slices.Index(all, hashPasswords)-1]
+	err = Migrate(con, DriverSQLite)
+	migrations[DriverSQLite] = all
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when creating the old schema", err)
+	}
+	if _, err := con.Exec("insert into users (name, email, password) values ('ann', 'ann@example.com', 'secret');"); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting a user", err)
+	}
+	if _, err := con.Exec(`insert into logs (log_message, details) values ('Deleting user with id 1 succeeded', '{"op":"delete","user_id":1,"before":{"id":1,"name":"ann","password":"secret"}}');`); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting a log", err)
+	}
+
+	if err := Migrate(con, DriverSQLite); err != nil {
+		t.Fatalf("an error '%s' was not expected when migrating", err)
+	}
+	var hash string
+	if err := con.QueryRow("select password_hash from users where name = 'ann';").Scan(&hash); err != nil {
+		t.Fatalf("an error '%s' was not expected when reading the hash", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte("secret")) != nil {
+		t.Fatalf("got %q, expected the bcrypt hash of the password", hash)
+	}
+
+	var details string
+	if err := con.QueryRow("select details from logs;").Scan(&details); err != nil {
+		t.Fatalf("an error '%s' was not expected when reading the log", err)
+	}
+	var record struct {
+		Before map[string]any `json:"before"`
+	}
+	if err := json.Unmarshal([]byte(details), &record); err != nil {
+		t.Fatalf("an error '%s' was not expected when decoding %s", err, details)
+	}
+	if _, ok := record.Before["password"]; ok {
+		t.Fatalf("got %s, expected the password to be gone", details)
+	}
+	logged, _ := record.Before["password_hash"].(string)
+	if bcrypt.CompareHashAndPassword([]byte(logged), []byte("secret")) != nil {
+		t.Fatalf("got %s, expected the bcrypt hash of the password", details)
+	}
+}
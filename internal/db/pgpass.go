@@ -0,0 +1,103 @@
+package db
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func passFilePath(cfg Config) string {
+	if cfg.PassFile != "" {
+		return cfg.PassFile
+	}
+	if env := os.Getenv("PGPASSFILE"); env != "" {
+		return env
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".pgpass")
+}
+
+// lookupPassFile returns the password of the first pgpass entry matching
+// cfg, or "" when there is no file or no matching entry. Like libpq it
+// ignores files that other users can read.
+func lookupPassFile(cfg Config) (string, error) {
+	path := passFilePath(cfg)
+	if path == "" {
+		return "", nil
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) && cfg.PassFile == "" {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		log.Printf("[WARN] password file %s has group or world access; permissions should be u=rw (0600) or less", path)
+		return "", nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return matchPassFile(f, cfg)
+}
+
+func matchPassFile(r io.Reader, cfg Config) (string, error) {
+	want := []string{cfg.Host, cfg.Port, cfg.DbName, cfg.User}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := splitPassFileLine(line)
+		if len(fields) != 5 {
+			continue
+		}
+		matches := true
+		for i, w := range want {
+			if fields[i] != "*" && fields[i] != w {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return fields[4], nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// splitPassFileLine splits a hostname:port:database:username:password line,
+// where \: and \\ stand for a literal colon and backslash.
+func splitPassFileLine(line string) []string {
+	var fields []string
+	var field strings.Builder
+	escaped := false
+	for _, c := range line {
+		switch {
+		case escaped:
+			field.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == ':':
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(c)
+		}
+	}
+	return append(fields, field.String())
+}
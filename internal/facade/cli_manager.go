@@ -1,30 +1,381 @@
 package facade
 
 import (
+	"cmp"
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/account"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/db"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/login"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/mail"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/metrics"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
-	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/profile"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/rpc"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/runner"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/server"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/tracing"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/tui"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
 )
 
-func RunCliManager(ctx context.Context, host string, port string, user string, dbname string, password string) error {
-	con, err := db.Connect(host, port, user, dbname, password)
+type Config struct {
+	DB db.Config
+
+	Cache middleware.CacheConfig
+	// Breaker configures the circuit breaker of the database repositories.
+	Breaker middleware.BreakerConfig
+	// CacheNotify enables cross-process invalidation of the users cache
+	// through Postgres LISTEN/NOTIFY.
+	CacheNotify bool
+
+	// MetricsAddr is the address of the Prometheus /metrics listener,
+	// empty disables it.
+	MetricsAddr string
+
+	// TraceOut is "stdout" or a file the spans are appended to, empty disables tracing.
+	TraceOut string
+	// TraceFormat is "json" for one plain span per line or "otlp" for the OTLP/JSON file format.
+	TraceFormat string
+
+	// HistoryFile keeps the command history of terminal sessions, empty keeps
+	// it for the current session only.
+	HistoryFile string
+	// TUI runs the full-screen interface instead of the prompt.
+	TUI bool
+	// ServeAddr serves the REST API on this address instead of running an
+	// interactive session, empty disables it.
+	ServeAddr string
+	// GRPCAddr serves the gRPC API on this address, like ServeAddr. Both
+	// may be served at once.
+	GRPCAddr string
+	// Remote is the server the session works on instead of connecting to
+	// the database in DB: http:// or https:// for the REST API, grpc:// or
+	// grpcs:// for the gRPC API.
+	Remote string
+	// APIToken is the API token of an operator sent to Remote. The served
+	// APIs accept the tokens created with the operators command.
+	APIToken string
+	// Operator and Password log a local session in. They are not needed
+	// until the first operator has been added, an empty Password is asked
+	// for on the terminal.
+	Operator string
+	Password string
+	// Login configures how the managed users log in. Without a secret a
+	// random one is generated, so sessions end when the process exits.
+	Login login.Config
+
+	// Mailer selects how tokens are mailed to the users, see mail.Open.
+	Mailer string
+	// MailFrom is the sender of the mails.
+	MailFrom string
+	// SMTPPassword authenticates the user of an SMTP Mailer.
+	SMTPPassword string
+	Accounts     account.Config
+
+	// AttributesSchema is a file with the JSON Schema the attributes of the
+	// users must match, empty accepts any attributes.
+	AttributesSchema string
+}
+
+// RunCliManager runs the interactive session until the user quits, the input
+// ends or ctx is canceled. In serve mode it serves the REST and gRPC APIs
+// until ctx is canceled instead.
+func RunCliManager(ctx context.Context, cfg Config) error {
+	traceOut, err := setupTracing(cfg.TraceOut, cfg.TraceFormat)
+	if err != nil {
+		return err
+	}
+	defer traceOut.Close()
+
+	if cfg.Remote != "" {
+		err = runRemote(ctx, cfg)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	}
+
+	con, err := db.Connect(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer con.Close()
+	err = db.Migrate(con, cfg.DB.Driver)
+	if err != nil {
+		return err
+	}
+	tenant, err := findTenant(ctx, newTenantRepository(cfg.DB.Driver, con), cfg.DB.Tenant)
+	if err != nil {
+		return err
+	}
+
+	var schema *profile.Schema
+	if cfg.AttributesSchema != "" {
+		if schema, err = profile.LoadSchema(cfg.AttributesSchema); err != nil {
+			return err
+		}
+	}
+
+	userCache := middleware.NewUserCache(cfg.Cache)
+	if cfg.CacheNotify && cfg.DB.Driver == db.DriverPostgres {
+		connString, err := db.ConnString(cfg.DB)
+		if err == nil {
+			err = userCache.Listen(ctx, connString)
+		}
+		if err != nil {
+			log.Printf("[WARN] cross-process cache invalidation disabled: %v", err)
+		}
+	}
+
+	stack := NewStack(con, cfg.DB.Driver, tenant, userCache, cfg.Breaker)
+	if cfg.MetricsAddr != "" {
+		serveMetrics(ctx, cfg.MetricsAddr, stack.Registry)
+	}
+	if len(cfg.Login.Secret) == 0 {
+		if cfg.ServeAddr != "" {
+			log.Printf("[WARN] no session secret given, sessions end when the server stops")
+		}
+		cfg.Login.Secret = login.NewSecret()
+	}
+	sessions, err := stack.Login(cfg.Login)
 	if err != nil {
 		return err
 	}
-	err = db.Migrate(con)
+	mailer, closer, err := mail.Open(cfg.Mailer, cfg.MailFrom, cfg.SMTPPassword)
 	if err != nil {
 		return err
 	}
+	defer closer.Close()
 
-	repoUser := imp.NewPostgresRepoUser(con)
-	repoTx := middleware.NewTransactionalMiddleware(con, repoUser)
-	repoLogging := middleware.NewLoggerMiddleware(imp.NewPostgresRepoLog(con), repoTx)
+	if cfg.ServeAddr != "" || cfg.GRPCAddr != "" {
+		err = serve(ctx, cfg, stack, sessions)
+	} else if ctx, err = operatorLogin(ctx, cfg, stack.Operators); err == nil {
+		if cfg.TUI {
+			err = tui.New(stack.Users, stack.Logs).Run(ctx)
+		} else {
+			err = runPrompt(ctx, cfg, stack.Users, stack.Logs, runner.Status{
+				Breakers:  []*middleware.CircuitBreaker{stack.Breaker},
+				Caches:    []*middleware.UserCache{stack.Cache},
+				DB:        con,
+				Driver:    cfg.DB.Driver,
+				Operators: stack.Operators,
+				Login:     sessions,
+				Accounts:  stack.Accounts(mailer, cfg.Accounts),
+				Access:    stack.Access(),
+				Profile:   stack.Profile(schema),
+				Tenants:   stack.Tenants(),
+			})
+		}
+	}
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// findTenant returns the tenant named name, the default one when name is
+// empty.
+func findTenant(ctx context.Context, tenants repository.IRepositoryTenant, name string) (models.Tenant, error) {
+	name = cmp.Or(name, models.DefaultTenant)
+	tenant, err := tenants.GetTenantByName(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return tenant, fmt.Errorf("unknown tenant %s, an admin adds it with: tenants add %s", name, name)
+	}
+	return tenant, err
+}
 
+// operatorLogin returns ctx acting on behalf of the operator of cfg. Until the first
+// operator has been added, local sessions run as auth.Bootstrap.
+func operatorLogin(ctx context.Context, cfg Config, svc *auth.Service) (context.Context, error) {
+	exists, err := svc.HasOperators(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		log.Printf("[WARN] no operators yet, running as %s; add an admin with: operators add <name> admin", auth.Bootstrap.Name)
+		return auth.WithOperator(ctx, auth.Bootstrap), nil
+	}
+	if cfg.Operator == "" {
+		return nil, errors.New("log in with -operator <name>")
+	}
+	password := cfg.Password
+	if password == "" {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil, fmt.Errorf("no password given for operator %s", cfg.Operator)
+		}
+		fmt.Fprintf(os.Stderr, "Password for %s: ", cfg.Operator)
+		secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		password = string(secret)
+	}
+	operator, err := svc.Login(ctx, cfg.Operator, password)
+	if err != nil {
+		return nil, err
+	}
+	return auth.WithOperator(ctx, operator), nil
+}
+
+// serve serves the configured APIs until ctx is canceled or one of them
+// fails, which stops the others.
+func serve(ctx context.Context, cfg Config, stack *Stack, sessions *login.Service) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var servers []func() error
+	if cfg.ServeAddr != "" {
+		handler := server.WithLogin(sessions,
+			server.Authenticate(stack.Operators, server.NewHandler(stack.Users, stack.Logs)))
+		servers = append(servers, func() error {
+			return server.Serve(ctx, cfg.ServeAddr, handler, 10*time.Second)
+		})
+	}
+	if cfg.GRPCAddr != "" {
+		servers = append(servers, func() error {
+			return rpc.Serve(ctx, cfg.GRPCAddr, rpc.NewServer(stack.Users, stack.Logs, stack.Events, stack.Operators), 10*time.Second)
+		})
+	}
+	if exists, err := stack.Operators.HasOperators(ctx); err == nil && !exists {
+		log.Printf("[WARN] no operators yet, every call is rejected until one is added with an API token")
+	}
+
+	errc := make(chan error, len(servers))
+	for _, serve := range servers {
+		go func() {
+			err := serve()
+			cancel()
+			errc <- err
+		}()
+	}
+	var first error
+	for range servers {
+		if err := <-errc; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// runRemote runs the interactive session on a server, no database
+// connection is needed.
+func runRemote(ctx context.Context, cfg Config) error {
+	if cfg.ServeAddr != "" || cfg.GRPCAddr != "" {
+		return errors.New("serving needs a database connection, not a remote server")
+	}
+	if cfg.DB.Tenant != "" {
+		return errors.New("a remote server works on its own tenant, -tenant is given to the server")
+	}
+	users, logs, closer, err := remoteRepositories(cfg.Remote, cfg.APIToken)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	if cfg.TUI {
+		return tui.New(users, logs).Run(ctx)
+	}
+	return runPrompt(ctx, cfg, users, logs, runner.Status{})
+}
+
+// remoteRepositories returns the repositories of the server at remote, the
+// scheme selects the API.
+func remoteRepositories(remote, token string) (repository.IRepositoryUser, repository.IRepositoryLog, io.Closer, error) {
+	scheme, _, _ := strings.Cut(remote, "://")
+	switch scheme {
+	case "grpc", "grpcs":
+		conn, err := rpc.Dial(remote, token)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return rpc.NewRemoteRepoUser(conn), rpc.NewRemoteRepoLog(conn), conn, nil
+	case "http", "https":
+		client, err := server.NewClient(remote, token)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return server.NewRemoteRepoUser(client), server.NewRemoteRepoLog(client), nopCloser{}, nil
+	default:
+		return nil, nil, nil, fmt.Errorf("invalid remote %q, expected http://, https://, grpc:// or grpcs://", remote)
+	}
+}
+
+// runPrompt runs the command prompt, with line editing when attached to a terminal.
+func runPrompt(ctx context.Context, cfg Config, users repository.IRepositoryUser, logs repository.IRepositoryLog, status runner.Status) error {
+	var r *runner.Runner
+	if term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())) {
+		var err error
+		r, err = runner.NewTerminalRunner(users, logs, status, os.Stdin, os.Stdout, cfg.HistoryFile)
+		if err != nil {
+			return err
+		}
+	} else {
+		r = runner.NewRunner(users, logs, status, os.Stdin, os.Stdout)
+	}
+	return r.Run(ctx)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+func setupTracing(out string, format string) (io.Closer, error) {
+	if out == "" {
+		return nopCloser{}, nil
+	}
+
+	var w io.WriteCloser = os.Stdout
+	var closer io.Closer = nopCloser{}
+	if out != "stdout" {
+		f, err := os.OpenFile(out, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		w, closer = f, f
+	}
+
+	var exporter tracing.Exporter
+	switch format {
+	case "", "json":
+		exporter = tracing.NewJSONExporter(w)
+	case "otlp":
+		exporter = tracing.NewOTLPFileExporter(w, "simpleCLIdbManager")
+	default:
+		closer.Close()
+		return nil, fmt.Errorf("unknown trace format %q, expected json or otlp", format)
+	}
+	tracing.SetDefault(tracing.NewTracer(exporter))
+	return closer, nil
+}
+
+func serveMetrics(ctx context.Context, addr string, registry *metrics.Registry) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", registry.Handler())
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[WARN] metrics listener stopped: %v", err)
+		}
+	}()
 	go func() {
-		defer con.Close()
-		runner.Logic(ctx, repoLogging)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
 	}()
-	return nil
 }
@@ -0,0 +1,175 @@
+package facade
+
+import (
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/access"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/account"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/db"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/login"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/mail"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/metrics"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/profile"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/tenant"
+)
+
+// Stack is the repository chain with all middleware applied, together with
+// the shared state the middleware reports on. Its users and logs are those
+// of a single tenant.
+type Stack struct {
+	Users    repository.IRepositoryUser
+	Logs     repository.IRepositoryLog
+	Breaker  *middleware.CircuitBreaker
+	Cache    *middleware.UserCache
+	Registry *metrics.Registry
+	// Events receives the changes made through Users.
+	Events *middleware.UserEvents
+	// Operators authenticates the operators that Users and Logs require in
+	// the context.
+	Operators *auth.Service
+
+	// users, logs and logins back the login service, which checks the
+	// passwords of users without an operator.
+	users  repository.IRepositoryUser
+	logs   repository.IRepositoryLog
+	logins repository.IRepositoryLogin
+	// tokens keeps the tokens of the account service, which uses them up in
+	// transactions on db.
+	tokens repository.IRepositoryUserToken
+	db     *sql.DB
+	// roles and groups back the access service.
+	roles  repository.IRepositoryRole
+	groups repository.IRepositoryGroup
+	// attributes backs the profile service, it drops the changed users from
+	// Cache.
+	attributes repository.IRepositoryUserAttribute
+	// tenant is the tenant of the users and logs, tenants backs the tenant
+	// service.
+	tenant  models.Tenant
+	tenants repository.IRepositoryTenant
+}
+
+// NewStack returns the stack working on the users and logs of tenant, their
+// logins and tokens. The operators, roles and groups are shared by every
+// tenant.
+func NewStack(con *sql.DB, driver string, tenant models.Tenant, cache *middleware.UserCache, breakerCfg middleware.BreakerConfig) *Stack {
+	repoUser, repoLogDb, repoOperator, repoLogin, repoToken := newRepositories(driver, con, tenant.ID)
+	repoRole, repoGroup := newAccessRepositories(driver, con)
+	repoAttribute := middleware.NewCachingAttributeMiddleware(cache, newAttributeRepository(driver, con, tenant.ID))
+	repoTx := middleware.NewTransactionalMiddleware(con, repoUser)
+	retryPolicy := middleware.DefaultRetryPolicy()
+	retryPolicy.Transactional = true
+	repoRetry := middleware.NewRetryMiddleware(retryPolicy, repoTx)
+	breaker := middleware.NewCircuitBreaker(driver, breakerCfg)
+	repoBreaker := middleware.NewCircuitBreakerUserMiddleware(breaker, repoRetry)
+	registry := metrics.NewRegistry()
+	metrics.RegisterDBStats(registry, con)
+	middleware.RegisterCacheStats(registry, cache)
+	repoMetrics := middleware.NewRepositoryMetrics(registry)
+	repoMeasured := middleware.NewMetricsUserMiddleware(repoMetrics, repoBreaker)
+	repoCache := middleware.NewCachingMiddleware(cache, repoMeasured)
+	events := middleware.NewUserEvents()
+	repoLog := middleware.NewMetricsLogMiddleware(repoMetrics,
+		middleware.NewCircuitBreakerLogMiddleware(breaker, repoLogDb))
+
+	return &Stack{
+		Users: middleware.NewLoggerMiddleware(repoLog,
+			middleware.NewAuthorizationUserMiddleware(middleware.NewPasswordMiddleware(middleware.NewEventsMiddleware(events, repoCache)))),
+		Logs:      middleware.NewAuthorizationLogMiddleware(repoLog),
+		Breaker:   breaker,
+		Cache:     cache,
+		Registry:  registry,
+		Events:    events,
+		Operators: auth.NewService(repoOperator),
+		users:     repoCache,
+		logs:      repoLog,
+		logins:    repoLogin,
+		tokens:    repoToken,
+		db:        con,
+		roles:     repoRole,
+		groups:    repoGroup,
+
+		attributes: repoAttribute,
+		tenant:     tenant,
+		tenants:    newTenantRepository(driver, con),
+	}
+}
+
+// Login returns the service logging the users of the stack in.
+func (s *Stack) Login(cfg login.Config) (*login.Service, error) {
+	return login.NewService(s.users, s.logs, s.logins, cfg)
+}
+
+// Accounts returns the service mailing tokens to the users of the stack. It
+// changes them through Users, so it needs an operator as well.
+func (s *Stack) Accounts(mailer mail.Mailer, cfg account.Config) *account.Service {
+	return account.NewService(s.db, s.Users, s.tokens, mailer, cfg)
+}
+
+// Access returns the service managing the roles and groups of the users of
+// the stack. It checks the role of the operator itself.
+func (s *Stack) Access() *access.Service {
+	return access.NewService(s.users, s.roles, s.groups, s.logs)
+}
+
+// Profile returns the service managing the attributes of the users of the
+// stack, schema checks them unless it is nil.
+func (s *Stack) Profile(schema *profile.Schema) *profile.Service {
+	return profile.NewService(s.attributes, s.logs, schema)
+}
+
+// Tenants returns the service managing the tenants, which records in the
+// logs of the tenant of the stack.
+func (s *Stack) Tenants() *tenant.Service {
+	return tenant.NewService(s.tenants, s.logs, s.tenant)
+}
+
+func newRepositories(driver string, con *sql.DB, tenantID int) (repository.IRepositoryUser, repository.IRepositoryLog, repository.IRepositoryOperator, repository.IRepositoryLogin, repository.IRepositoryUserToken) {
+	switch driver {
+	case db.DriverMySQL:
+		return imp.NewMysqlRepoUserForTenant(con, tenantID), imp.NewMysqlRepoLogForTenant(con, tenantID), imp.NewMysqlRepoOperator(con), imp.NewMysqlRepoLoginForTenant(con, tenantID), imp.NewMysqlRepoUserTokenForTenant(con, tenantID)
+	case db.DriverSQLite:
+		return imp.NewSqliteRepoUserForTenant(con, tenantID), imp.NewSqliteRepoLogForTenant(con, tenantID), imp.NewSqliteRepoOperator(con), imp.NewSqliteRepoLoginForTenant(con, tenantID), imp.NewSqliteRepoUserTokenForTenant(con, tenantID)
+	default:
+		return imp.NewPostgresRepoUserForTenant(con, tenantID), imp.NewPostgresRepoLogForTenant(con, tenantID), imp.NewPostgresRepoOperator(con), imp.NewPostgresRepoLoginForTenant(con, tenantID), imp.NewPostgresRepoUserTokenForTenant(con, tenantID)
+	}
+}
+
+func newAccessRepositories(driver string, con *sql.DB) (repository.IRepositoryRole, repository.IRepositoryGroup) {
+	switch driver {
+	case db.DriverMySQL:
+		return imp.NewMysqlRepoRole(con), imp.NewMysqlRepoGroup(con)
+	case db.DriverSQLite:
+		return imp.NewSqliteRepoRole(con), imp.NewSqliteRepoGroup(con)
+	default:
+		return imp.NewPostgresRepoRole(con), imp.NewPostgresRepoGroup(con)
+	}
+}
+
+func newAttributeRepository(driver string, con *sql.DB, tenantID int) repository.IRepositoryUserAttribute {
+	switch driver {
+	case db.DriverMySQL:
+		return imp.NewMysqlRepoUserAttributeForTenant(con, tenantID)
+	case db.DriverSQLite:
+		return imp.NewSqliteRepoUserAttributeForTenant(con, tenantID)
+	default:
+		return imp.NewPostgresRepoUserAttributeForTenant(con, tenantID)
+	}
+}
+
+// newTenantRepository returns the tenants of the database of driver, which
+// are needed to find the tenant of a stack before it exists.
+func newTenantRepository(driver string, con *sql.DB) repository.IRepositoryTenant {
+	switch driver {
+	case db.DriverMySQL:
+		return imp.NewMysqlRepoTenant(con)
+	case db.DriverSQLite:
+		return imp.NewSqliteRepoTenant(con)
+	default:
+		return imp.NewPostgresRepoTenant(con)
+	}
+}
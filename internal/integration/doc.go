@@ -0,0 +1,4 @@
+// Package integration holds end-to-end tests running the repositories and
+// the whole middleware stack against a throwaway Postgres server started by
+// pgtest. The tests are skipped when no Postgres installation is found.
+package integration
@@ -0,0 +1,365 @@
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/db"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/facade"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/pgtest"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/repotest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	cluster    *pgtest.Cluster
+	clusterErr error
+)
+
+var defaultTenant = models.Tenant{ID: models.DefaultTenantID, Name: models.DefaultTenant}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if !testing.Short() {
+		cluster, clusterErr = pgtest.NewCluster()
+	}
+	code := m.Run()
+	if cluster != nil {
+		cluster.Close()
+	}
+	os.Exit(code)
+}
+
+func newDatabase(t *testing.T) (*sql.DB, db.Config) {
+	t.Helper()
+	requireCluster(t, clusterErr)
+	return cluster.NewDatabase(t)
+}
+
+func requireCluster(t *testing.T, err error) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("integration tests are skipped in short mode")
+	}
+	if errors.Is(err, pgtest.ErrUnavailable) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when starting postgres", err)
+	}
+}
+
+// findUser returns the user with the given email, Postgres does not report
+// the ID of an inserted row.
+func findUser(t *testing.T, ctx context.Context, repo repository.IRepositoryUser, email string) models.User {
+	t.Helper()
+	users, err := repo.GetUsers(ctx, models.UserFilter{})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting users", err)
+	}
+	for _, user := range users {
+		if user.Email == email {
+			return user
+		}
+	}
+	t.Fatalf("user %s not found", email)
+	return models.User{}
+}
+
+func eventually(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return cond()
+}
+
+func TestPostgresRepositories_Conformance(t *testing.T) {
+	requireCluster(t, clusterErr)
+	repotest.RunUserTests(t, func(t *testing.T) repository.IRepositoryUser {
+		con, _ := newDatabase(t)
+		return imp.NewPostgresRepoUser(con)
+	})
+	repotest.RunLogTests(t, func(t *testing.T) repository.IRepositoryLog {
+		con, _ := newDatabase(t)
+		return imp.NewPostgresRepoLog(con)
+	})
+	repotest.RunOperatorTests(t, func(t *testing.T) repository.IRepositoryOperator {
+		con, _ := newDatabase(t)
+		return imp.NewPostgresRepoOperator(con)
+	})
+	repotest.RunLoginTests(t, func(t *testing.T) repository.IRepositoryLogin {
+		con, _ := newDatabase(t)
+		return imp.NewPostgresRepoLogin(con)
+	})
+	repotest.RunUserTokenTests(t, func(t *testing.T) repository.IRepositoryUserToken {
+		con, _ := newDatabase(t)
+		return imp.NewPostgresRepoUserToken(con)
+	})
+	repotest.RunTenantTests(t, func(t *testing.T) repository.IRepositoryTenant {
+		con, _ := newDatabase(t)
+		return imp.NewPostgresRepoTenant(con)
+	})
+	repotest.RunTenantIsolationTests(t, func(t *testing.T) repotest.TenantRepos {
+		con, _ := newDatabase(t)
+		return repotest.TenantRepos{
+			Tenants: imp.NewPostgresRepoTenant(con),
+			Users:   func(tenantID int) repository.IRepositoryUser { return imp.NewPostgresRepoUserForTenant(con, tenantID) },
+			Logs:    func(tenantID int) repository.IRepositoryLog { return imp.NewPostgresRepoLogForTenant(con, tenantID) },
+			Attributes: func(tenantID int) repository.IRepositoryUserAttribute {
+				return imp.NewPostgresRepoUserAttributeForTenant(con, tenantID)
+			},
+			Logins: func(tenantID int) repository.IRepositoryLogin {
+				return imp.NewPostgresRepoLoginForTenant(con, tenantID)
+			},
+			Tokens: func(tenantID int) repository.IRepositoryUserToken {
+				return imp.NewPostgresRepoUserTokenForTenant(con, tenantID)
+			},
+		}
+	})
+}
+
+// TestRowLevelSecurity connects as an application role limited to a tenant
+// and checks that even unscoped repositories only reach its rows.
+func TestRowLevelSecurity(t *testing.T) {
+	ctx := context.Background()
+	con, cfg := newDatabase(t)
+	tenants := imp.NewPostgresRepoTenant(con)
+	if _, err := tenants.InsertTenant(ctx, models.Tenant{Name: "acme", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting tenant", err)
+	}
+	acme, err := tenants.GetTenantByName(ctx, "acme")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting tenant", err)
+	}
+	if _, err := imp.NewPostgresRepoUser(con).InsertUser(ctx, models.User{Name: "alice", Email: "alice@example.com", PasswordHash: "pw-hash"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting user", err)
+	}
+	alice := findUser(t, ctx, imp.NewPostgresRepoUserForTenant(con, models.DefaultTenantID), "alice@example.com")
+
+	// The superuser of the cluster bypasses row level security.
+	role := "app_" + cfg.DbName
+	for _, query := range []string{
+		"CREATE ROLE " + role + " LOGIN;",
+		"GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO " + role + ";",
+		"GRANT USAGE ON ALL SEQUENCES IN SCHEMA public TO " + role + ";",
+	} {
+		if _, err := con.ExecContext(ctx, query); err != nil {
+			t.Fatalf("an error '%s' was not expected when creating the application role", err)
+		}
+	}
+	superuser := cfg.User
+	cfg.User = role
+	cfg.Tenant = "acme"
+	app, err := db.Connect(ctx, cfg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when connecting as %s", err, role)
+	}
+	defer app.Close()
+
+	users := imp.NewPostgresRepoUser(app)
+	if _, err := users.InsertUser(ctx, models.User{Name: "bob", Email: "bob@example.com", PasswordHash: "pw-hash"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting user", err)
+	}
+	all, err := users.GetUsers(ctx, models.UserFilter{})
+	if err != nil || len(all) != 1 || all[0].Name != "bob" {
+		t.Fatalf("got %+v, '%v', expected only bob", all, err)
+	}
+	findUser(t, ctx, imp.NewPostgresRepoUserForTenant(con, acme.ID), "bob@example.com")
+
+	if _, err := users.GetUserById(ctx, alice.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("got error '%v' when getting another tenant's user, expected sql.ErrNoRows", err)
+	}
+	res, err := users.DeleteUserById(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when deleting another tenant's user", err)
+	}
+	if affected, _ := res.RowsAffected(); affected != 0 {
+		t.Fatalf("got %d rows affected when deleting another tenant's user, expected 0", affected)
+	}
+	if _, err := imp.NewPostgresRepoUserForTenant(app, models.DefaultTenantID).InsertUser(ctx,
+		models.User{Name: "mallory", Email: "mallory@example.com", PasswordHash: "pw-hash"}); err == nil {
+		t.Fatalf("expected an error when inserting into another tenant")
+	}
+
+	if _, err := imp.NewPostgresRepoLog(app).InsertLog(ctx, models.Log{LogTime: time.Now(), LogMessage: "acme log"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting log", err)
+	}
+	logs, err := imp.NewPostgresRepoLogForTenant(con, acme.ID).GetLogs(ctx)
+	if err != nil || len(logs) != 1 || logs[0].LogMessage != "acme log" {
+		t.Fatalf("got %+v, '%v', expected the log in acme", logs, err)
+	}
+
+	now := time.Now()
+	if _, err := imp.NewPostgresRepoLogin(app).AddFailure(ctx, "alice@example.com", now, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("an error '%s' was not expected when counting a failure", err)
+	}
+	if _, err := imp.NewPostgresRepoLoginForTenant(con, models.DefaultTenantID).GetLockout(ctx, "alice@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("got error '%v', expected the failure counted in acme only", err)
+	}
+
+	// Roles bypassing row level security are refused for a tenant.
+	cfg.User = superuser
+	if su, err := db.Connect(ctx, cfg); err == nil {
+		su.Close()
+		t.Fatalf("expected an error when connecting as a superuser")
+	}
+}
+
+func TestStack_UserLifecycle(t *testing.T) {
+	ctx := auth.WithOperator(context.Background(), auth.Bootstrap)
+	con, _ := newDatabase(t)
+	stack := facade.NewStack(con, db.DriverPostgres, defaultTenant, middleware.NewUserCache(middleware.DefaultCacheConfig()), middleware.DefaultBreakerConfig())
+
+	if _, err := stack.Users.InsertUser(ctx, models.User{Name: "alice", Email: "alice@example.com", Password: "pw"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting user", err)
+	}
+	alice := findUser(t, ctx, stack.Users, "alice@example.com")
+
+	for range 2 {
+		if _, err := stack.Users.GetUserById(ctx, alice.ID); err != nil {
+			t.Fatalf("an error '%s' was not expected when getting user by id", err)
+		}
+	}
+	if stats := stack.Cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Fatalf("expected one cache miss and one hit, got %+v", stats)
+	}
+
+	if _, err := stack.Users.UpdateUserById(ctx, alice.ID, models.User{Name: "alicia", Email: alice.Email, Password: "pw2"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when updating user", err)
+	}
+	got, err := stack.Users.GetUserById(ctx, alice.ID)
+	if err != nil || got.Name != "alicia" || bcrypt.CompareHashAndPassword([]byte(got.PasswordHash), []byte("pw2")) != nil {
+		t.Fatalf("got %+v and error '%v' after update, expected the new values", got, err)
+	}
+
+	if _, err := stack.Users.DeleteUserById(ctx, alice.ID); err != nil {
+		t.Fatalf("an error '%s' was not expected when deleting user", err)
+	}
+	if _, err := stack.Users.GetUserById(ctx, alice.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("got error '%v' after delete, expected sql.ErrNoRows", err)
+	}
+
+	logs, err := stack.Logs.GetLogs(ctx)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting logs", err)
+	}
+	var messages []string
+	for _, log := range logs {
+		messages = append(messages, log.LogMessage)
+	}
+	for _, expected := range []string{"Insert user succeeded", fmt.Sprintf("Getting user by id: %d -- failed", alice.ID)} {
+		if !strings.Contains(strings.Join(messages, "\n"), expected) {
+			t.Fatalf("expected log %q, got %q", expected, messages)
+		}
+	}
+
+	var b strings.Builder
+	if _, err := stack.Registry.WriteTo(&b); err != nil {
+		t.Fatalf("an error '%s' was not expected when writing metrics", err)
+	}
+	for _, expected := range []string{`operation="InsertUser"`, `class="not_found"`, "db_pool_open_connections"} {
+		if !strings.Contains(b.String(), expected) {
+			t.Fatalf("expected %s in metrics:\n%s", expected, b.String())
+		}
+	}
+}
+
+func TestCache_InvalidatedByOtherProcess(t *testing.T) {
+	ctx, cancel := context.WithCancel(auth.WithOperator(context.Background(), auth.Bootstrap))
+	defer cancel()
+	con, cfg := newDatabase(t)
+
+	cache := middleware.NewUserCache(middleware.DefaultCacheConfig())
+	connString, err := db.ConnString(cfg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when building the connection string", err)
+	}
+	if err := cache.Listen(ctx, connString); err != nil {
+		t.Fatalf("an error '%s' was not expected when listening for changes", err)
+	}
+	stack := facade.NewStack(con, db.DriverPostgres, defaultTenant, cache, middleware.DefaultBreakerConfig())
+
+	if _, err := stack.Users.InsertUser(ctx, models.User{Name: "bob", Email: "bob@example.com", Password: "pw"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting user", err)
+	}
+	bob := findUser(t, ctx, stack.Users, "bob@example.com")
+	if _, err := stack.Users.GetUserById(ctx, bob.ID); err != nil {
+		t.Fatalf("an error '%s' was not expected when getting user by id", err)
+	}
+
+	// Another process writes to the table directly, bypassing this cache.
+	other := imp.NewPostgresRepoUser(con)
+	if _, err := other.UpdateUserById(ctx, bob.ID, models.User{Name: "robert", Email: bob.Email, PasswordHash: bob.PasswordHash}); err != nil {
+		t.Fatalf("an error '%s' was not expected when updating user", err)
+	}
+	if !eventually(t, 5*time.Second, func() bool {
+		got, err := stack.Users.GetUserById(ctx, bob.ID)
+		return err == nil && got.Name == "robert"
+	}) {
+		t.Fatalf("cache was not invalidated by the notification, stats %+v", cache.Stats())
+	}
+}
+
+func TestCircuitBreaker_DatabaseOutage(t *testing.T) {
+	requireCluster(t, clusterErr)
+	// The outage gets its own server so the shared one keeps running.
+	outage, err := pgtest.NewCluster()
+	requireCluster(t, err)
+	defer outage.Close()
+	con, _ := outage.NewDatabase(t)
+
+	ctx := context.Background()
+	breaker := middleware.NewCircuitBreaker("postgres", middleware.BreakerConfig{
+		FailureThreshold:  2,
+		OpenTimeout:       200 * time.Millisecond,
+		HalfOpenSuccesses: 1,
+	})
+	policy := middleware.DefaultRetryPolicy()
+	policy.MaxAttempts = 2
+	policy.BaseDelay = 10 * time.Millisecond
+	repo := middleware.NewCircuitBreakerUserMiddleware(breaker,
+		middleware.NewRetryMiddleware(policy, imp.NewPostgresRepoUser(con)))
+
+	if _, err := repo.GetUsers(ctx, models.UserFilter{}); err != nil {
+		t.Fatalf("an error '%s' was not expected before the outage", err)
+	}
+	if err := outage.Stop(); err != nil {
+		t.Fatalf("an error '%s' was not expected when stopping postgres", err)
+	}
+	if !eventually(t, 10*time.Second, func() bool {
+		_, err := repo.GetUsers(ctx, models.UserFilter{})
+		return errors.Is(err, middleware.ErrCircuitOpen)
+	}) {
+		t.Fatalf("breaker did not open during the outage, status %+v", breaker.Status())
+	}
+
+	if err := outage.Start(); err != nil {
+		t.Fatalf("an error '%s' was not expected when restarting postgres", err)
+	}
+	if !eventually(t, 10*time.Second, func() bool {
+		_, err := repo.GetUsers(ctx, models.UserFilter{})
+		return err == nil
+	}) {
+		t.Fatalf("breaker did not recover after the outage, status %+v", breaker.Status())
+	}
+	if state := breaker.Status().State; state != middleware.BreakerClosed {
+		t.Fatalf("got breaker state %s after recovery, expected closed", state)
+	}
+}
@@ -0,0 +1,273 @@
+// Package login authenticates the managed users with their passwords and
+// issues signed session tokens for them.
+package login
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrInvalidCredentials is returned for an unknown email or a wrong
+	// password, without telling which.
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrInvalidToken is returned for session tokens that are malformed,
+	// not signed with the secret, expired or revoked.
+	ErrInvalidToken = errors.New("invalid, expired or revoked session token")
+	// ErrInactive is returned for the right password of a user who is not
+	// active, e.g. a suspended one.
+	ErrInactive = errors.New("the account is not active")
+)
+
+// LockedError is returned while logins with an email are refused after too
+// many failed attempts.
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return "too many failed logins, try again later"
+}
+
+// minSecretLength is the key size of HMAC-SHA256.
+const minSecretLength = 32
+
+// maxEmailLength is the size of the email columns.
+const maxEmailLength = 50
+
+type Config struct {
+	// MaxFailures is the number of failed logins in a row after which an
+	// email is locked, 0 never locks.
+	MaxFailures int
+	// LockoutDuration is how long a locked email stays locked. Failures
+	// are forgotten when there was none for as long.
+	LockoutDuration time.Duration
+	// SessionTTL is how long a session token is valid.
+	SessionTTL time.Duration
+	// Secret signs the session tokens, tokens signed with another secret
+	// are rejected.
+	Secret []byte
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxFailures:     5,
+		LockoutDuration: 15 * time.Minute,
+		SessionTTL:      time.Hour,
+	}
+}
+
+// NewSecret returns a random secret for Config.Secret.
+func NewSecret() []byte {
+	secret := make([]byte, minSecretLength)
+	rand.Read(secret)
+	return secret
+}
+
+// Service verifies the passwords of the users in users and records every
+// attempt in logs.
+type Service struct {
+	users repository.IRepositoryUser
+	logs  repository.IRepositoryLog
+	repo  repository.IRepositoryLogin
+	cfg   Config
+	now   func() time.Time
+	// dummyHash is compared against when the user is unknown, so the
+	// answer takes as long as for a wrong password.
+	dummyHash []byte
+}
+
+func NewService(users repository.IRepositoryUser, logs repository.IRepositoryLog, repo repository.IRepositoryLogin, cfg Config) (*Service, error) {
+	if len(cfg.Secret) < minSecretLength {
+		return nil, fmt.Errorf("the session secret must be at least %d bytes", minSecretLength)
+	}
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	return &Service{users: users, logs: logs, repo: repo, cfg: cfg, now: time.Now, dummyHash: hash}, nil
+}
+
+// Verify returns the user with email if password is theirs. Failed attempts
+// are counted per email, known or not, and lock it after too many of them.
+func (s *Service) Verify(ctx context.Context, email, password string) (models.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" || utf8.RuneCountInString(email) > maxEmailLength {
+		return models.User{}, ErrInvalidCredentials
+	}
+	now := s.now()
+	lockout, err := s.repo.GetLockout(ctx, email)
+	switch {
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return models.User{}, err
+	case lockout.Locked(now):
+		s.record(ctx, fmt.Sprintf("Login of %s refused, locked until %s", email, lockout.LockedUntil.Format(time.DateTime)))
+		return models.User{}, &LockedError{Until: lockout.LockedUntil}
+	}
+
+	user, err := s.find(ctx, email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, err
+	}
+	hash := []byte(user.PasswordHash)
+	if len(hash) == 0 {
+		hash = s.dummyHash
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || user.PasswordHash == "" {
+		return models.User{}, s.fail(ctx, email, now)
+	}
+
+	if lockout.Failures > 0 || !lockout.LockedUntil.IsZero() {
+		if _, err := s.repo.DeleteLockout(ctx, email); err != nil {
+			return models.User{}, err
+		}
+	}
+	if user.Status != models.StatusActive {
+		s.record(ctx, fmt.Sprintf("Login of %s refused, the user is %s", email, user.Status))
+		return models.User{}, ErrInactive
+	}
+	s.record(ctx, fmt.Sprintf("Login of %s succeeded", email))
+	return user, nil
+}
+
+// fail counts a failed login and returns the error to answer it with.
+func (s *Service) fail(ctx context.Context, email string, now time.Time) error {
+	msg := fmt.Sprintf("Login of %s failed", email)
+	var err error = ErrInvalidCredentials
+	if s.cfg.MaxFailures > 0 {
+		failures, countErr := s.repo.AddFailure(ctx, email, now, now.Add(-s.cfg.LockoutDuration))
+		if countErr != nil {
+			return countErr
+		}
+		if failures >= s.cfg.MaxFailures {
+			until := now.Add(s.cfg.LockoutDuration)
+			if _, lockErr := s.repo.LockEmail(ctx, email, until); lockErr != nil {
+				return lockErr
+			}
+			msg += fmt.Sprintf(", locked until %s", until.Format(time.DateTime))
+			err = &LockedError{Until: until}
+		}
+	}
+	s.record(ctx, msg)
+	return err
+}
+
+// find returns the user with email, which is unique among the users.
+func (s *Service) find(ctx context.Context, email string) (models.User, error) {
+	users, err := s.users.GetUsers(ctx, models.UserFilter{Email: email})
+	if err != nil {
+		return models.User{}, err
+	}
+	if len(users) == 0 {
+		return models.User{}, sql.ErrNoRows
+	}
+	return users[0], nil
+}
+
+// Session is a session started by Login.
+type Session struct {
+	Token  string
+	Claims Claims
+	User   models.User
+}
+
+// Login verifies the password like Verify and starts a session of the user.
+func (s *Service) Login(ctx context.Context, email, password string) (Session, error) {
+	user, err := s.Verify(ctx, email, password)
+	if err != nil {
+		return Session{}, err
+	}
+	now := s.now()
+	claims := Claims{
+		ID:        rand.Text(),
+		Subject:   strconv.Itoa(user.ID),
+		Email:     user.Email,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.cfg.SessionTTL).Unix(),
+	}
+	token, err := sign(claims, s.cfg.Secret)
+	if err != nil {
+		return Session{}, err
+	}
+	return Session{Token: token, Claims: claims, User: user}, nil
+}
+
+// Validate returns the claims of a session token that is still valid. The
+// sessions of users who are no longer active are not.
+func (s *Service) Validate(ctx context.Context, token string) (Claims, error) {
+	claims, err := parse(token, s.cfg.Secret)
+	if err != nil {
+		return Claims{}, err
+	}
+	if s.now().Unix() >= claims.ExpiresAt {
+		return Claims{}, ErrInvalidToken
+	}
+	revoked, err := s.repo.IsSessionRevoked(ctx, claims.ID)
+	if err != nil {
+		return Claims{}, err
+	}
+	if revoked {
+		return Claims{}, ErrInvalidToken
+	}
+	id, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	user, err := s.users.GetUserById(ctx, id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return Claims{}, ErrInvalidToken
+	case err != nil:
+		return Claims{}, err
+	case user.Status != models.StatusActive:
+		return Claims{}, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// Revoke ends the session of token before it expires.
+func (s *Service) Revoke(ctx context.Context, token string) error {
+	claims, err := s.Validate(ctx, token)
+	if err != nil {
+		return err
+	}
+	if _, err := s.repo.RevokeSession(ctx, claims.ID, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		return err
+	}
+	s.record(ctx, fmt.Sprintf("Session of %s revoked", claims.Email))
+	return nil
+}
+
+// Unlock lifts the lockout of email and forgets its failed logins. It
+// reports whether there was anything to lift.
+func (s *Service) Unlock(ctx context.Context, email string) (bool, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	res, err := s.repo.DeleteLockout(ctx, email)
+	if err != nil {
+		return false, err
+	}
+	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+		return false, err
+	}
+	s.record(ctx, fmt.Sprintf("Lockout of %s lifted", email))
+	return true, nil
+}
+
+// record writes msg to the audit log on behalf of the operator of ctx, if
+// any.
+func (s *Service) record(ctx context.Context, msg string) {
+	entry := models.Log{LogTime: s.now(), LogMessage: msg, Actor: auth.Actor(ctx)}
+	if _, err := s.logs.InsertLog(ctx, entry); err != nil {
+		log.Printf("[WARN] failed to insert log: %v", err)
+	}
+}
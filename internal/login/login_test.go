@@ -0,0 +1,194 @@
+package login
+
+import (
+	"context"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestService returns a service over in-memory repositories holding
+// ann@example.com with password "secret" and a clock the test sets.
+func newTestService(t *testing.T, cfg Config) (*Service, repository.IRepositoryLog, *time.Time) {
+	t.Helper()
+	users := imp.NewMemoryRepoUser()
+	logs := imp.NewMemoryRepoLog()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	user := models.User{Name: "Ann", Email: "ann@example.com", PasswordHash: string(hash), RegisteredAt: time.Now(), Status: models.StatusActive}
+	if _, err := users.InsertUser(context.Background(), user); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting a user", err)
+	}
+	cfg.Secret = []byte(strings.Repeat("k", minSecretLength))
+	s, err := NewService(users, logs, imp.NewMemoryRepoLogin(), cfg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when creating the service", err)
+	}
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, logs, &now
+}
+
+func TestNewService_RejectsShortSecret(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Secret = []byte("short")
+	if _, err := NewService(imp.NewMemoryRepoUser(), imp.NewMemoryRepoLog(), imp.NewMemoryRepoLogin(), cfg); err == nil {
+		t.Fatalf("expected an error for a short secret")
+	}
+}
+
+func TestService_VerifyLocksOut(t *testing.T) {
+	s, logs, now := newTestService(t, Config{MaxFailures: 3, LockoutDuration: time.Minute, SessionTTL: time.Hour})
+	ctx := context.Background()
+
+	if user, err := s.Verify(ctx, "ANN@example.com", "secret"); err != nil || user.Name != "Ann" {
+		t.Fatalf("got %+v, '%v', expected the user", user, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.Verify(ctx, "ann@example.com", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("got '%v', expected ErrInvalidCredentials", err)
+		}
+	}
+	var locked *LockedError
+	if _, err := s.Verify(ctx, "ann@example.com", "wrong"); !errors.As(err, &locked) || !locked.Until.Equal(now.Add(time.Minute)) {
+		t.Fatalf("got '%v', expected the email to be locked for a minute", err)
+	}
+	if _, err := s.Verify(ctx, "ann@example.com", "secret"); !errors.As(err, &locked) {
+		t.Fatalf("got '%v', expected the right password to be refused while locked", err)
+	}
+
+	*now = now.Add(time.Minute)
+	if _, err := s.Verify(ctx, "ann@example.com", "secret"); err != nil {
+		t.Fatalf("an error '%s' was not expected after the lockout ended", err)
+	}
+	if _, err := s.Verify(ctx, "nobody@example.com", "secret"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("got '%v', expected ErrInvalidCredentials for an unknown email", err)
+	}
+
+	entries, _ := logs.GetLogs(ctx)
+	var messages []string
+	for _, entry := range entries {
+		messages = append(messages, entry.LogMessage)
+	}
+	want := []string{
+		"Login of ann@example.com succeeded",
+		"Login of ann@example.com failed",
+		"Login of ann@example.com failed",
+		"Login of ann@example.com failed, locked until 2025-01-02 03:05:05",
+		"Login of ann@example.com refused, locked until 2025-01-02 03:05:05",
+		"Login of ann@example.com succeeded",
+		"Login of nobody@example.com failed",
+	}
+	if strings.Join(messages, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got the log\n%s\nexpected\n%s", strings.Join(messages, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestService_ForgetsOldFailures(t *testing.T) {
+	s, _, now := newTestService(t, Config{MaxFailures: 2, LockoutDuration: time.Minute, SessionTTL: time.Hour})
+	ctx := context.Background()
+
+	if _, err := s.Verify(ctx, "ann@example.com", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("got '%v', expected ErrInvalidCredentials", err)
+	}
+	*now = now.Add(time.Minute + time.Second)
+	if _, err := s.Verify(ctx, "ann@example.com", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("got '%v', expected the first failure to be forgotten", err)
+	}
+	var locked *LockedError
+	if _, err := s.Verify(ctx, "ann@example.com", "wrong"); !errors.As(err, &locked) {
+		t.Fatalf("got '%v', expected two failures in a row to lock the email", err)
+	}
+}
+
+func TestService_Unlock(t *testing.T) {
+	s, _, _ := newTestService(t, Config{MaxFailures: 1, LockoutDuration: time.Hour, SessionTTL: time.Hour})
+	ctx := context.Background()
+
+	var locked *LockedError
+	if _, err := s.Verify(ctx, "ann@example.com", "wrong"); !errors.As(err, &locked) {
+		t.Fatalf("got '%v', expected the email to be locked", err)
+	}
+	if unlocked, err := s.Unlock(ctx, "Ann@example.com"); err != nil || !unlocked {
+		t.Fatalf("got %v, '%v', expected the lockout to be lifted", unlocked, err)
+	}
+	if _, err := s.Verify(ctx, "ann@example.com", "secret"); err != nil {
+		t.Fatalf("an error '%s' was not expected after unlocking", err)
+	}
+	if unlocked, err := s.Unlock(ctx, "ann@example.com"); err != nil || unlocked {
+		t.Fatalf("got %v, '%v', expected nothing to unlock", unlocked, err)
+	}
+}
+
+func TestService_SessionTokens(t *testing.T) {
+	s, _, now := newTestService(t, DefaultConfig())
+	ctx := context.Background()
+
+	session, err := s.Login(ctx, "ann@example.com", "secret")
+	token, claims := session.Token, session.Claims
+	if err != nil || session.User.Name != "Ann" || claims.Subject != "1" || claims.Email != "ann@example.com" || claims.ExpiresAt != now.Add(time.Hour).Unix() {
+		t.Fatalf("got %+v, '%v', expected a session of ann", claims, err)
+	}
+	if got, err := s.Validate(ctx, token); err != nil || got != claims {
+		t.Fatalf("got %+v, '%v', expected %+v", got, err, claims)
+	}
+
+	session, err = s.Login(ctx, "ann@example.com", "secret")
+	other := session.Token
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when logging in again", err)
+	}
+	if err := s.Revoke(ctx, token); err != nil {
+		t.Fatalf("an error '%s' was not expected when revoking", err)
+	}
+	if _, err := s.Validate(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got '%v', expected the revoked token to be rejected", err)
+	}
+	if _, err := s.Validate(ctx, other); err != nil {
+		t.Fatalf("an error '%s' was not expected for another session", err)
+	}
+
+	header, rest, _ := strings.Cut(other, ".")
+	_, sig, _ := strings.Cut(rest, ".")
+	forged, _ := sign(Claims{ID: "x", Subject: "2", ExpiresAt: now.Add(time.Hour).Unix()}, []byte(strings.Repeat("x", minSecretLength)))
+	for _, bad := range []string{"", "abc", other + "x", header + ".e30." + sig, forged} {
+		if _, err := s.Validate(ctx, bad); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("got '%v' for %q, expected ErrInvalidToken", err, bad)
+		}
+	}
+
+	*now = now.Add(time.Hour)
+	if _, err := s.Validate(ctx, other); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got '%v', expected the expired token to be rejected", err)
+	}
+}
+
+func TestService_InactiveUsers(t *testing.T) {
+	s, _, _ := newTestService(t, DefaultConfig())
+	ctx := context.Background()
+
+	session, err := s.Login(ctx, "ann@example.com", "secret")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when logging in", err)
+	}
+	user := session.User
+	user.Status = models.StatusSuspended
+	if _, err := s.users.UpdateUserById(ctx, user.ID, user); err != nil {
+		t.Fatalf("an error '%s' was not expected when suspending the user", err)
+	}
+
+	if _, err := s.Verify(ctx, "ann@example.com", "secret"); !errors.Is(err, ErrInactive) {
+		t.Fatalf("got '%v', expected ErrInactive for a suspended user", err)
+	}
+	if _, err := s.Verify(ctx, "ann@example.com", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("got '%v', expected a wrong password not to tell the status", err)
+	}
+	if _, err := s.Validate(ctx, session.Token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got '%v', expected the session of a suspended user to be invalid", err)
+	}
+}
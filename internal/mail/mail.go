@@ -0,0 +1,110 @@
+// Package mail sends the messages the managed users get, such as their email
+// verification and password reset tokens.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends messages. Implementations are safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// format returns msg as an RFC 5322 message sent by from.
+func format(from string, msg Message, date time.Time) ([]byte, error) {
+	for _, header := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, errors.New("mail headers must not contain line breaks")
+		}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes(), nil
+}
+
+// WriterMailer writes the messages to a writer instead of sending them, for
+// development and tests.
+type WriterMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+func NewWriterMailer(w io.Writer, from string) Mailer {
+	return &WriterMailer{w: w, from: from}
+}
+
+func (m *WriterMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := format(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// A blank line separates the messages.
+	_, err = fmt.Fprintf(m.w, "%s\r\n\r\n", data)
+	return err
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// Open returns the mailer selected by spec:
+//
+//	stdout                         write the messages to stdout
+//	file:<path>                    append the messages to a file
+//	smtp://[user@]host:port        send them, with STARTTLS if offered
+//	smtps://[user@]host:port       send them over TLS
+//
+// The SMTP password is given separately to keep it out of the spec. The
+// closer releases the file of file: specs.
+func Open(spec, from, password string) (Mailer, io.Closer, error) {
+	switch {
+	case spec == "stdout":
+		return NewWriterMailer(os.Stdout, from), nopCloser{}, nil
+	case strings.HasPrefix(spec, "file:"):
+		f, err := os.OpenFile(strings.TrimPrefix(spec, "file:"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewWriterMailer(f, from), f, nil
+	case strings.HasPrefix(spec, "smtp://"), strings.HasPrefix(spec, "smtps://"):
+		cfg, err := parseSMTP(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.From = from
+		cfg.Password = password
+		return NewSMTPMailer(cfg), nopCloser{}, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid mailer %q, expected stdout, file:<path>, smtp:// or smtps://", spec)
+	}
+}
@@ -0,0 +1,111 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestWriterMailer(t *testing.T) {
+	var out bytes.Buffer
+	m := NewWriterMailer(&out, "noreply@example.com")
+	msg := Message{To: "ann@example.com", Subject: "Verify your email address", Body: "line 1\nline 2\n"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("an error '%s' was not expected when sending", err)
+	}
+	for _, want := range []string{"From: noreply@example.com\r\n", "To: ann@example.com\r\n", "Subject: Verify your email address\r\n", "\r\n\r\nline 1\r\nline 2\r\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in the message:\n%s", want, out.String())
+		}
+	}
+
+	msg.To = "ann@example.com\r\nBcc: eve@example.com"
+	if err := m.Send(context.Background(), msg); err == nil {
+		t.Fatalf("expected a header with a line break to be rejected")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, spec := range []string{"", "smtp://host", "smtp://", "mailto:ann@example.com"} {
+		if _, _, err := Open(spec, "noreply@example.com", ""); err == nil {
+			t.Fatalf("expected an error for %q", spec)
+		}
+	}
+	m, closer, err := Open("smtps://ann@mail.example.com:465", "noreply@example.com", "pw")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening an SMTP mailer", err)
+	}
+	defer closer.Close()
+	cfg := m.(*SMTPMailer).cfg
+	if cfg != (SMTPConfig{Addr: "mail.example.com:465", TLS: true, Username: "ann", Password: "pw", From: "noreply@example.com"}) {
+		t.Fatalf("unexpected SMTP config %+v", cfg)
+	}
+}
+
+// fakeSMTP accepts one message on a local port and sends its data to the
+// returned channel.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when listening", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	data := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ready")
+		var body strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				reply("250 ok")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					body.WriteString(line)
+				}
+				data <- body.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 unknown")
+			}
+		}
+	}()
+	return listener.Addr().String(), data
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, data := fakeSMTP(t)
+	m := NewSMTPMailer(SMTPConfig{Addr: addr, From: "noreply@example.com"})
+	if err := m.Send(context.Background(), Message{To: "ann@example.com", Subject: "Hi", Body: "hello"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when sending", err)
+	}
+	got := <-data
+	if !strings.Contains(got, "To: ann@example.com\r\n") || !strings.HasSuffix(got, "\r\n\r\nhello\r\n") {
+		t.Fatalf("unexpected message data:\n%s", got)
+	}
+}
@@ -0,0 +1,103 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/url"
+	"time"
+)
+
+type SMTPConfig struct {
+	// Addr is the host:port of the server.
+	Addr string
+	// TLS connects with TLS from the start instead of upgrading with
+	// STARTTLS.
+	TLS bool
+	// Username and Password authenticate with PLAIN, which net/smtp only
+	// allows over TLS or to localhost. An empty Username skips it.
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPMailer sends every message over a new connection.
+type SMTPMailer struct {
+	cfg SMTPConfig
+}
+
+func NewSMTPMailer(cfg SMTPConfig) Mailer {
+	return &SMTPMailer{cfg: cfg}
+}
+
+func parseSMTP(spec string) (SMTPConfig, error) {
+	u, err := url.Parse(spec)
+	if err != nil || u.Host == "" || u.Port() == "" {
+		return SMTPConfig{}, fmt.Errorf("invalid SMTP server %q, expected smtp://[user@]host:port", spec)
+	}
+	return SMTPConfig{Addr: u.Host, TLS: u.Scheme == "smtps", Username: u.User.Username()}, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	data, err := format(m.cfg.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(m.cfg.Addr)
+	if err != nil {
+		return err
+	}
+
+	var conn net.Conn
+	if m.cfg.TLS {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: host}}
+		conn, err = dialer.DialContext(ctx, "tcp", m.cfg.Addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", m.cfg.Addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(time.Minute))
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok && !m.cfg.TLS {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if m.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(m.cfg.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("the SMTP server rejected the message: %w", err)
+	}
+	return client.Quit()
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import "database/sql"
+
+// RegisterDBStats exposes the connection pool statistics of db, the current
+// state as gauges and the totals since the pool was opened as counters.
+func RegisterDBStats(r *Registry, db *sql.DB) {
+	gauges := []struct {
+		name string
+		help string
+		fn   func(s sql.DBStats) float64
+	}{
+		{"db_pool_max_open_connections", "Maximum number of open connections to the database.",
+			func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"db_pool_open_connections", "Number of established connections, both in use and idle.",
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"db_pool_in_use_connections", "Number of connections currently in use.",
+			func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"db_pool_idle_connections", "Number of idle connections.",
+			func(s sql.DBStats) float64 { return float64(s.Idle) }},
+	}
+	for _, g := range gauges {
+		fn := g.fn
+		r.NewGaugeFunc(g.name, g.help, func() float64 { return fn(db.Stats()) })
+	}
+
+	counters := []struct {
+		name string
+		help string
+		fn   func(s sql.DBStats) float64
+	}{
+		{"db_pool_wait_count_total", "Total number of connections waited for.",
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"db_pool_wait_duration_seconds_total", "Total time blocked waiting for a new connection.",
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"db_pool_max_idle_closed_total", "Total number of connections closed due to SetMaxIdleConns.",
+			func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"db_pool_max_idle_time_closed_total", "Total number of connections closed due to SetConnMaxIdleTime.",
+			func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+		{"db_pool_max_lifetime_closed_total", "Total number of connections closed due to SetConnMaxLifetime.",
+			func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+	for _, c := range counters {
+		fn := c.fn
+		r.NewCounterFunc(c.name, c.help, func() float64 { return fn(db.Stats()) })
+	}
+}
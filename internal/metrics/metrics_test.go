@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("errors_total", "Errors.", "operation", "class")
+	h := r.NewHistogramVec("duration_seconds", "Duration.", []float64{0.1, 1}, "operation")
+	r.NewGaugeFunc("open_connections", "Open connections.", func() float64 { return 3 })
+	r.NewCounterFunc("waits_total", "Waits.", func() float64 { return 7 })
+
+	c.Inc("GetUsers", "transient")
+	c.Inc("GetUsers", "transient")
+	h.Observe(0.05, "GetUsers")
+	h.Observe(0.5, "GetUsers")
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatalf("an error '%s' was not expected when writing metrics", err)
+	}
+
+	expected := `# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{operation="GetUsers",le="0.1"} 1
+duration_seconds_bucket{operation="GetUsers",le="1"} 2
+duration_seconds_bucket{operation="GetUsers",le="+Inf"} 2
+duration_seconds_sum{operation="GetUsers"} 0.55
+duration_seconds_count{operation="GetUsers"} 2
+# HELP errors_total Errors.
+# TYPE errors_total counter
+errors_total{operation="GetUsers",class="transient"} 2
+# HELP open_connections Open connections.
+# TYPE open_connections gauge
+open_connections 3
+# HELP waits_total Waits.
+# TYPE waits_total counter
+waits_total 7
+`
+	if b.String() != expected {
+		t.Fatalf("got:\n%s\nexpected:\n%s", b.String(), expected)
+	}
+}
+
+func TestFormatLabels_Escapes(t *testing.T) {
+	got := formatLabels([]string{"msg"}, []string{"a \"quoted\"\nline\\"}, "", "")
+	expected := `{msg="a \"quoted\"\nline\\"}`
+	if got != expected {
+		t.Fatalf("got %s, expected %s", got, expected)
+	}
+}
+
+func TestRegisterDBStats_TotalsAreCounters(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewRegistry()
+	RegisterDBStats(r, db)
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatalf("an error '%s' was not expected when writing metrics", err)
+	}
+	for _, line := range []string{
+		"# TYPE db_pool_open_connections gauge\n",
+		"# TYPE db_pool_wait_count_total counter\n",
+		"# TYPE db_pool_wait_duration_seconds_total counter\n",
+		"# TYPE db_pool_max_lifetime_closed_total counter\n",
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Fatalf("got:\n%s\nexpected it to contain %q", b.String(), line)
+		}
+	}
+}
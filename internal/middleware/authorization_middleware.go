@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+// AuthorizationUserMiddleware lets viewers read users and editors change
+// them. Calls without an operator in the context fail with
+// auth.ErrUnauthenticated.
+type AuthorizationUserMiddleware struct {
+	next repository.IRepositoryUser
+}
+
+func (a *AuthorizationUserMiddleware) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	if err := auth.Require(ctx, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.next.GetUsers(ctx, filter)
+}
+
+func (a *AuthorizationUserMiddleware) GetUserById(ctx context.Context, id int) (models.User, error) {
+	if err := auth.Require(ctx, models.RoleViewer); err != nil {
+		return models.User{}, err
+	}
+	return a.next.GetUserById(ctx, id)
+}
+
+func (a *AuthorizationUserMiddleware) InsertUser(ctx context.Context, user models.User) (sql.Result, error) {
+	if err := auth.Require(ctx, models.RoleEditor); err != nil {
+		return nil, err
+	}
+	return a.next.InsertUser(ctx, user)
+}
+
+func (a *AuthorizationUserMiddleware) DeleteUserById(ctx context.Context, id int) (sql.Result, error) {
+	if err := auth.Require(ctx, models.RoleEditor); err != nil {
+		return nil, err
+	}
+	return a.next.DeleteUserById(ctx, id)
+}
+
+func (a *AuthorizationUserMiddleware) UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error) {
+	if err := auth.Require(ctx, models.RoleEditor); err != nil {
+		return nil, err
+	}
+	return a.next.UpdateUserById(ctx, id, user)
+}
+
+func NewAuthorizationUserMiddleware(next repository.IRepositoryUser) repository.IRepositoryUser {
+	return &AuthorizationUserMiddleware{next: next}
+}
+
+// AuthorizationLogMiddleware lets viewers read the audit log. Logs are
+// written by the logger middleware, so writing needs an admin.
+type AuthorizationLogMiddleware struct {
+	next repository.IRepositoryLog
+}
+
+func (a *AuthorizationLogMiddleware) GetLogs(ctx context.Context) ([]models.Log, error) {
+	if err := auth.Require(ctx, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.next.GetLogs(ctx)
+}
+
+func (a *AuthorizationLogMiddleware) GetLogById(ctx context.Context, id int) (models.Log, error) {
+	if err := auth.Require(ctx, models.RoleViewer); err != nil {
+		return models.Log{}, err
+	}
+	return a.next.GetLogById(ctx, id)
+}
+
+func (a *AuthorizationLogMiddleware) InsertLog(ctx context.Context, log models.Log) (sql.Result, error) {
+	if err := auth.Require(ctx, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return a.next.InsertLog(ctx, log)
+}
+
+func NewAuthorizationLogMiddleware(next repository.IRepositoryLog) repository.IRepositoryLog {
+	return &AuthorizationLogMiddleware{next: next}
+}
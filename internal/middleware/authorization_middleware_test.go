@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"testing"
+	"time"
+)
+
+func TestAuthorizationMiddleware_EnforcesRoles(t *testing.T) {
+	repo := NewAuthorizationUserMiddleware(imp.NewMemoryRepoUser())
+	user := models.User{Name: "John", Email: "john@example.com", PasswordHash: "secret", RegisteredAt: time.Now()}
+
+	if _, err := repo.GetUsers(context.Background(), models.UserFilter{}); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Fatalf("got '%v' without an operator, expected ErrUnauthenticated", err)
+	}
+
+	viewer := auth.WithOperator(context.Background(), models.Operator{Name: "vera", Role: models.RoleViewer})
+	if _, err := repo.GetUsers(viewer, models.UserFilter{}); err != nil {
+		t.Fatalf("an error '%s' was not expected when a viewer lists users", err)
+	}
+	if _, err := repo.InsertUser(viewer, user); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("got '%v' when a viewer inserts a user, expected ErrForbidden", err)
+	}
+
+	editor := auth.WithOperator(context.Background(), models.Operator{Name: "ed", Role: models.RoleEditor})
+	if _, err := repo.InsertUser(editor, user); err != nil {
+		t.Fatalf("an error '%s' was not expected when an editor inserts a user", err)
+	}
+	if _, err := repo.DeleteUserById(viewer, 1); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("got '%v' when a viewer deletes a user, expected ErrForbidden", err)
+	}
+	if _, err := repo.GetUserById(viewer, 1); err != nil {
+		t.Fatalf("the user was deleted by a viewer: %s", err)
+	}
+
+	logs := NewAuthorizationLogMiddleware(imp.NewMemoryRepoLog())
+	if _, err := logs.GetLogs(viewer); err != nil {
+		t.Fatalf("an error '%s' was not expected when a viewer reads the audit log", err)
+	}
+	if _, err := logs.InsertLog(editor, models.Log{LogMessage: "forged"}); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("got '%v' when an editor writes the audit log, expected ErrForbidden", err)
+	}
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/tracing"
+)
+
+type CachingMiddleware struct {
+	cache *UserCache
+	next  repository.IRepositoryUser
+}
+
+func (c *CachingMiddleware) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	return c.next.GetUsers(ctx, filter)
+}
+
+func (c *CachingMiddleware) GetUserById(ctx context.Context, id int) (models.User, error) {
+	ctx, span := tracing.Start(ctx, "CachingMiddleware.GetUserById")
+	defer span.End()
+
+	// Inside a transaction the row may hold changes others cannot see yet,
+	// e.g. in a dry run that is rolled back, so it is neither served from
+	// nor stored in the cache.
+	if repository.TxFromContext(ctx) != nil {
+		span.SetAttr(tracing.String("cache.result", "bypass"))
+		return c.next.GetUserById(ctx, id)
+	}
+
+	if entry, ok := c.cache.get(id); ok {
+		span.SetAttr(tracing.String("cache.result", "hit"))
+		if entry.notFound {
+			return models.User{}, sql.ErrNoRows
+		}
+		return entry.user, nil
+	}
+	span.SetAttr(tracing.String("cache.result", "miss"))
+
+	version := c.cache.version()
+	user, err := c.next.GetUserById(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.cache.setNotFound(id, version)
+		}
+		span.RecordError(err)
+		return user, err
+	}
+	c.cache.setUser(user, version)
+	return user, nil
+}
+
+func (c *CachingMiddleware) InsertUser(ctx context.Context, user models.User) (sql.Result, error) {
+	res, err := c.next.InsertUser(ctx, user)
+	// The id of the new row is not known here and it may have been cached
+	// as missing, so every entry is dropped.
+	c.cache.InvalidateAll()
+	return res, err
+}
+
+func (c *CachingMiddleware) DeleteUserById(ctx context.Context, id int) (sql.Result, error) {
+	res, err := c.next.DeleteUserById(ctx, id)
+	c.cache.Invalidate(id)
+	return res, err
+}
+
+func (c *CachingMiddleware) UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error) {
+	res, err := c.next.UpdateUserById(ctx, id, user)
+	c.cache.Invalidate(id)
+	return res, err
+}
+
+func NewCachingMiddleware(cache *UserCache, next repository.IRepositoryUser) repository.IRepositoryUser {
+	return &CachingMiddleware{cache: cache, next: next}
+}
+
+// CachingAttributeMiddleware drops the cached users whose attributes it
+// changes. The attributes themselves are always read from next.
+type CachingAttributeMiddleware struct {
+	cache *UserCache
+	next  repository.IRepositoryUserAttribute
+}
+
+func (c *CachingAttributeMiddleware) GetAttributes(ctx context.Context, userID int) (models.Attributes, error) {
+	return c.next.GetAttributes(ctx, userID)
+}
+
+func (c *CachingAttributeMiddleware) SetAttribute(ctx context.Context, userID int, key string, value any) (sql.Result, error) {
+	res, err := c.next.SetAttribute(ctx, userID, key, value)
+	c.cache.Invalidate(userID)
+	return res, err
+}
+
+func (c *CachingAttributeMiddleware) UnsetAttribute(ctx context.Context, userID int, key string) (sql.Result, error) {
+	res, err := c.next.UnsetAttribute(ctx, userID, key)
+	c.cache.Invalidate(userID)
+	return res, err
+}
+
+func NewCachingAttributeMiddleware(cache *UserCache, next repository.IRepositoryUserAttribute) repository.IRepositoryUserAttribute {
+	return &CachingAttributeMiddleware{cache: cache, next: next}
+}
@@ -0,0 +1,296 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/metrics"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"github.com/DATA-DOG/go-sqlmock"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCachingMiddleware_GetUserById_ReadThrough(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	userCache := NewUserCache(DefaultCacheConfig())
+	repo := NewCachingMiddleware(userCache, imp.NewPostgresRepoUser(dbUser))
+
+	mUser := models.User{
+		ID:           1,
+		Name:         "John",
+		Email:        "john@example.com",
+		PasswordHash: "secret",
+		RegisteredAt: time.Now(),
+	}
+
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes"}).
+			AddRow(mUser.ID, mUser.Name, mUser.Email, mUser.PasswordHash, mUser.RegisteredAt, nil, "active", "{}"))
+
+	for i := 0; i < 2; i++ {
+		us, err := repo.GetUserById(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when getting user by id", err)
+		}
+		if !userEquals(us, mUser) {
+			t.Fatalf("users are not equal")
+		}
+	}
+
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+	stats := userCache.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Fatalf("got %d hits and %d misses, expected 1 and 1", stats.Hits, stats.Misses)
+	}
+}
+
+func TestCachingMiddleware_GetUserById_NegativeCaching(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	userCache := NewUserCache(DefaultCacheConfig())
+	repo := NewCachingMiddleware(userCache, imp.NewPostgresRepoUser(dbUser))
+
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+		WithArgs(7).
+		WillReturnError(sql.ErrNoRows)
+
+	for i := 0; i < 2; i++ {
+		if _, err := repo.GetUserById(context.Background(), 7); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("expected sql.ErrNoRows, got %v", err)
+		}
+	}
+
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+	if stats := userCache.Stats(); stats.NegativeHits != 1 {
+		t.Fatalf("got %d negative hits, expected 1", stats.NegativeHits)
+	}
+}
+
+func TestCachingMiddleware_WritesInvalidate(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	userCache := NewUserCache(DefaultCacheConfig())
+	repo := NewCachingMiddleware(userCache, imp.NewPostgresRepoUser(dbUser))
+
+	mUser := models.User{
+		ID:           1,
+		Name:         "John",
+		Email:        "john@example.com",
+		PasswordHash: "secret",
+		RegisteredAt: time.Now(),
+	}
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes"}).
+			AddRow(mUser.ID, mUser.Name, mUser.Email, mUser.PasswordHash, mUser.RegisteredAt, nil, "active", "{}")
+	}
+
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+		WithArgs(1).
+		WillReturnRows(rows())
+	mockUser.ExpectExec(regexp.QuoteMeta("UPDATE users SET email_verified_at = CASE WHEN email = $2 THEN $5::timestamp END, name = $1, email = $2, password_hash = $3, status = COALESCE(NULLIF($6, ''), status) WHERE id = $4;")).
+		WithArgs(mUser.Name, mUser.Email, mUser.PasswordHash, sqlmock.AnyArg(), nil, "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+		WithArgs(1).
+		WillReturnRows(rows())
+	mockUser.ExpectExec(regexp.QuoteMeta("DELETE FROM users WHERE id = $1;")).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+		WithArgs(1).
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := repo.GetUserById(context.Background(), 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when getting user by id", err)
+	}
+	if _, err := repo.UpdateUserById(context.Background(), 1, mUser); err != nil {
+		t.Fatalf("an error '%s' was not expected when updating user", err)
+	}
+	if _, err := repo.GetUserById(context.Background(), 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when getting user by id", err)
+	}
+	if _, err := repo.DeleteUserById(context.Background(), 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when deleting user", err)
+	}
+	if _, err := repo.GetUserById(context.Background(), 1); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCachingMiddleware_InsertDropsNegativeEntries(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	userCache := NewUserCache(DefaultCacheConfig())
+	repo := NewCachingMiddleware(userCache, imp.NewPostgresRepoUser(dbUser))
+
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+		WithArgs(1).
+		WillReturnError(sql.ErrNoRows)
+	mockUser.ExpectQuery(regexp.QuoteMeta("INSERT INTO users (name, email, password_hash, registered_at, email_verified_at, status, attributes) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;")).
+		WithArgs("John", "john@example.com", "secret", sqlmock.AnyArg(), nil, "pending", "{}").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes"}).
+			AddRow(1, "John", "john@example.com", "secret", time.Now(), nil, "active", "{}"))
+
+	_, _ = repo.GetUserById(context.Background(), 1)
+	if _, err := repo.InsertUser(context.Background(), models.User{Name: "John", Email: "john@example.com", PasswordHash: "secret"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting user", err)
+	}
+	if _, err := repo.GetUserById(context.Background(), 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when getting user by id", err)
+	}
+
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCachingMiddleware_BypassedInTransaction(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	userCache := NewUserCache(DefaultCacheConfig())
+	repo := NewCachingMiddleware(userCache, imp.NewPostgresRepoUser(dbUser))
+
+	mockUser.ExpectBegin()
+	for range 2 {
+		mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes"}).
+				AddRow(1, "John", "john@example.com", "secret", time.Now(), nil, "active", "{}"))
+	}
+	mockUser.ExpectRollback()
+
+	tx, err := dbUser.Begin()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when beginning a transaction", err)
+	}
+	ctx := repository.WithTx(context.Background(), tx)
+	for range 2 {
+		if _, err := repo.GetUserById(ctx, 1); err != nil {
+			t.Fatalf("an error '%s' was not expected when getting user by id", err)
+		}
+	}
+	tx.Rollback()
+
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+	if stats := userCache.Stats(); stats.Size != 0 || stats.Hits != 0 {
+		t.Fatalf("got %d entries and %d hits, expected the cache to be untouched", stats.Size, stats.Hits)
+	}
+}
+
+func TestCachingAttributeMiddleware_SetInvalidates(t *testing.T) {
+	users := imp.NewMemoryRepoUser()
+	if _, err := users.InsertUser(context.Background(), models.User{Name: "John", Email: "john@example.com", PasswordHash: "secret"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting user", err)
+	}
+	userCache := NewUserCache(DefaultCacheConfig())
+	repo := NewCachingMiddleware(userCache, users)
+	attributes := NewCachingAttributeMiddleware(userCache, imp.NewMemoryRepoUserAttribute(users))
+
+	if _, err := repo.GetUserById(context.Background(), 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when getting user by id", err)
+	}
+	if _, err := attributes.SetAttribute(context.Background(), 1, "department", "ops"); err != nil {
+		t.Fatalf("an error '%s' was not expected when setting an attribute", err)
+	}
+	user, err := repo.GetUserById(context.Background(), 1)
+	if err != nil || user.Attributes["department"] != "ops" {
+		t.Fatalf("got %+v, '%v', expected the cached user to be read again", user, err)
+	}
+	if stats := userCache.Stats(); stats.Hits != 0 || stats.Misses != 2 {
+		t.Fatalf("got %d hits and %d misses, expected 0 and 2", stats.Hits, stats.Misses)
+	}
+}
+
+// invalidatingRepo invalidates the users it reads in cache, like a
+// notification of a change arriving while the row is read.
+type invalidatingRepo struct {
+	repository.IRepositoryUser
+	cache *UserCache
+}
+
+func (r invalidatingRepo) GetUserById(ctx context.Context, id int) (models.User, error) {
+	user, err := r.IRepositoryUser.GetUserById(ctx, id)
+	r.cache.Invalidate(id)
+	return user, err
+}
+
+func TestCachingMiddleware_InvalidatedWhileReading(t *testing.T) {
+	users := imp.NewMemoryRepoUser()
+	if _, err := users.InsertUser(context.Background(), models.User{Name: "John", Email: "john@example.com", PasswordHash: "secret"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting user", err)
+	}
+	userCache := NewUserCache(DefaultCacheConfig())
+	repo := NewCachingMiddleware(userCache, invalidatingRepo{IRepositoryUser: users, cache: userCache})
+
+	for _, id := range []int{1, 7} {
+		repo.GetUserById(context.Background(), id)
+		repo.GetUserById(context.Background(), id)
+	}
+	if stats := userCache.Stats(); stats.Size != 0 || stats.Hits != 0 || stats.NegativeHits != 0 || stats.Misses != 4 {
+		t.Fatalf("got %+v, expected nothing read before an invalidation to be cached", stats)
+	}
+}
+
+func TestRegisterCacheStats(t *testing.T) {
+	userCache := NewUserCache(DefaultCacheConfig())
+	repo := NewCachingMiddleware(userCache, imp.NewMemoryRepoUser())
+	registry := metrics.NewRegistry()
+	RegisterCacheStats(registry, userCache)
+
+	for range 2 {
+		repo.GetUserById(context.Background(), 7)
+	}
+	var b strings.Builder
+	if _, err := registry.WriteTo(&b); err != nil {
+		t.Fatalf("an error '%s' was not expected when writing metrics", err)
+	}
+	for _, line := range []string{
+		"# TYPE users_cache_misses_total counter\nusers_cache_misses_total 1\n",
+		"users_cache_negative_hits_total 1\n",
+		"# TYPE users_cache_entries gauge\nusers_cache_entries 1\n",
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Fatalf("got:\n%s\nexpected it to contain:\n%s", b.String(), line)
+		}
+	}
+}
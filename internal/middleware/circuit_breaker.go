@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/tracing"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open, database is unavailable")
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit.
+	FailureThreshold int
+	// OpenTimeout is how long calls fail fast before a probe is let through.
+	OpenTimeout time.Duration
+	// HalfOpenSuccesses is the number of successful probes that close the circuit again.
+	HalfOpenSuccesses int
+}
+
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold:  3,
+		OpenTimeout:       30 * time.Second,
+		HalfOpenSuccesses: 1,
+	}
+}
+
+type BreakerStatus struct {
+	Name      string
+	State     BreakerState
+	Failures  int
+	OpenedAt  time.Time
+	RetryAt   time.Time
+	LastError error
+}
+
+// CircuitBreaker is shared by the decorators that talk to the same database,
+// so an outage noticed by one of them fails the others fast as well.
+type CircuitBreaker struct {
+	name string
+	cfg  BreakerConfig
+	now  func() time.Time
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	successes int
+	probing   bool
+	openedAt  time.Time
+	lastErr   error
+}
+
+func NewCircuitBreaker(name string, cfg BreakerConfig) *CircuitBreaker {
+	if cfg.FailureThreshold < 1 {
+		cfg.FailureThreshold = 1
+	}
+	if cfg.HalfOpenSuccesses < 1 {
+		cfg.HalfOpenSuccesses = 1
+	}
+	// Without a timeout every call would probe and the circuit never fails fast.
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = DefaultBreakerConfig().OpenTimeout
+	}
+	return &CircuitBreaker{name: name, cfg: cfg, now: time.Now}
+}
+
+// isOutageError reports whether err means the database itself is unavailable,
+// as opposed to a failure of one particular statement.
+func isOutageError(err error) bool {
+	if err == nil || isRolledBackError(err) {
+		return false
+	}
+	var netErr net.Error
+	return IsRetryableError(err) || errors.As(err, &netErr)
+}
+
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		retryAt := b.openedAt.Add(b.cfg.OpenTimeout)
+		if b.now().Before(retryAt) {
+			return fmt.Errorf("%s: %w (next attempt at %s)", b.name, ErrCircuitOpen, retryAt.Format(time.TimeOnly))
+		}
+		b.state = BreakerHalfOpen
+		b.successes = 0
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%s: %w (probe in progress)", b.name, ErrCircuitOpen)
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if isOutageError(err) {
+		b.lastErr = err
+		b.failures++
+		if b.state == BreakerHalfOpen || b.failures >= b.cfg.FailureThreshold {
+			b.state = BreakerOpen
+			b.openedAt = b.now()
+		}
+		return
+	}
+
+	b.failures = 0
+	if b.state == BreakerHalfOpen {
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenSuccesses {
+			b.state = BreakerClosed
+			b.lastErr = nil
+		}
+	}
+}
+
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		Name:      b.name,
+		State:     b.state,
+		Failures:  b.failures,
+		LastError: b.lastErr,
+	}
+	if b.state != BreakerClosed {
+		status.OpenedAt = b.openedAt
+		status.RetryAt = b.openedAt.Add(b.cfg.OpenTimeout)
+	}
+	return status
+}
+
+func guard[T any](ctx context.Context, b *CircuitBreaker, name string, op func(context.Context) (T, error)) (T, error) {
+	ctx, span := tracing.Start(ctx, name, tracing.String("breaker.name", b.name))
+	defer span.End()
+
+	if err := b.allow(); err != nil {
+		span.RecordError(err)
+		var zero T
+		return zero, err
+	}
+	result, err := op(ctx)
+	b.record(err)
+	span.RecordError(err)
+	return result, err
+}
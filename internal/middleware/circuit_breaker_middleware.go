@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+type CircuitBreakerUserMiddleware struct {
+	breaker *CircuitBreaker
+	next    repository.IRepositoryUser
+}
+
+func (c *CircuitBreakerUserMiddleware) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	return guard(ctx, c.breaker, "CircuitBreakerUserMiddleware.GetUsers", func(ctx context.Context) ([]models.User, error) {
+		return c.next.GetUsers(ctx, filter)
+	})
+}
+
+func (c *CircuitBreakerUserMiddleware) GetUserById(ctx context.Context, id int) (models.User, error) {
+	return guard(ctx, c.breaker, "CircuitBreakerUserMiddleware.GetUserById", func(ctx context.Context) (models.User, error) {
+		return c.next.GetUserById(ctx, id)
+	})
+}
+
+func (c *CircuitBreakerUserMiddleware) InsertUser(ctx context.Context, user models.User) (sql.Result, error) {
+	return guard(ctx, c.breaker, "CircuitBreakerUserMiddleware.InsertUser", func(ctx context.Context) (sql.Result, error) {
+		return c.next.InsertUser(ctx, user)
+	})
+}
+
+func (c *CircuitBreakerUserMiddleware) DeleteUserById(ctx context.Context, id int) (sql.Result, error) {
+	return guard(ctx, c.breaker, "CircuitBreakerUserMiddleware.DeleteUserById", func(ctx context.Context) (sql.Result, error) {
+		return c.next.DeleteUserById(ctx, id)
+	})
+}
+
+func (c *CircuitBreakerUserMiddleware) UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error) {
+	return guard(ctx, c.breaker, "CircuitBreakerUserMiddleware.UpdateUserById", func(ctx context.Context) (sql.Result, error) {
+		return c.next.UpdateUserById(ctx, id, user)
+	})
+}
+
+func NewCircuitBreakerUserMiddleware(breaker *CircuitBreaker, next repository.IRepositoryUser) repository.IRepositoryUser {
+	return &CircuitBreakerUserMiddleware{breaker: breaker, next: next}
+}
+
+type CircuitBreakerLogMiddleware struct {
+	breaker *CircuitBreaker
+	next    repository.IRepositoryLog
+}
+
+func (c *CircuitBreakerLogMiddleware) GetLogs(ctx context.Context) ([]models.Log, error) {
+	return guard(ctx, c.breaker, "CircuitBreakerLogMiddleware.GetLogs", c.next.GetLogs)
+}
+
+func (c *CircuitBreakerLogMiddleware) GetLogById(ctx context.Context, id int) (models.Log, error) {
+	return guard(ctx, c.breaker, "CircuitBreakerLogMiddleware.GetLogById", func(ctx context.Context) (models.Log, error) {
+		return c.next.GetLogById(ctx, id)
+	})
+}
+
+func (c *CircuitBreakerLogMiddleware) InsertLog(ctx context.Context, log models.Log) (sql.Result, error) {
+	return guard(ctx, c.breaker, "CircuitBreakerLogMiddleware.InsertLog", func(ctx context.Context) (sql.Result, error) {
+		return c.next.InsertLog(ctx, log)
+	})
+}
+
+func NewCircuitBreakerLogMiddleware(breaker *CircuitBreaker, next repository.IRepositoryLog) repository.IRepositoryLog {
+	return &CircuitBreakerLogMiddleware{breaker: breaker, next: next}
+}
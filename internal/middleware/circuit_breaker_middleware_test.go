@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func newTestBreaker(now *time.Time) *CircuitBreaker {
+	b := NewCircuitBreaker("test", BreakerConfig{
+		FailureThreshold:  2,
+		OpenTimeout:       time.Minute,
+		HalfOpenSuccesses: 1,
+	})
+	b.now = func() time.Time { return *now }
+	return b
+}
+
+func TestCircuitBreakerUserMiddleware_OpensAndFailsFast(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	now := time.Now()
+	breaker := newTestBreaker(&now)
+	repo := NewCircuitBreakerUserMiddleware(breaker, imp.NewPostgresRepoUser(dbUser))
+
+	for i := 0; i < 2; i++ {
+		mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users;")).
+			WillReturnError(&pq.Error{Code: "57P01"})
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := repo.GetUsers(context.Background(), models.UserFilter{}); err == nil {
+			t.Fatalf("an error was expected when getting users")
+		}
+	}
+	if state := breaker.Status().State; state != BreakerOpen {
+		t.Fatalf("got state %s, expected open", state)
+	}
+
+	_, err = repo.GetUsers(context.Background(), models.UserFilter{})
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCircuitBreaker_NonPositiveOpenTimeoutFailsFast(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	now := time.Now()
+	breaker := NewCircuitBreaker("test", BreakerConfig{FailureThreshold: 1, OpenTimeout: -time.Second})
+	breaker.now = func() time.Time { return now }
+	repo := NewCircuitBreakerUserMiddleware(breaker, imp.NewPostgresRepoUser(dbUser))
+
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users;")).
+		WillReturnError(&pq.Error{Code: "57P01"})
+
+	if _, err := repo.GetUsers(context.Background(), models.UserFilter{}); err == nil {
+		t.Fatalf("an error was expected when getting users")
+	}
+	if _, err := repo.GetUsers(context.Background(), models.UserFilter{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if status := breaker.Status(); !status.RetryAt.Equal(now.Add(DefaultBreakerConfig().OpenTimeout)) {
+		t.Fatalf("got retry at %s, expected the default open timeout after %s", status.RetryAt, now)
+	}
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCircuitBreakerUserMiddleware_HalfOpenProbeCloses(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	now := time.Now()
+	breaker := newTestBreaker(&now)
+	repo := NewCircuitBreakerUserMiddleware(breaker, imp.NewPostgresRepoUser(dbUser))
+
+	for i := 0; i < 2; i++ {
+		mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+			WithArgs(1).
+			WillReturnError(&pq.Error{Code: "08006"})
+	}
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+		WithArgs(1).
+		WillReturnError(sql.ErrNoRows)
+
+	for i := 0; i < 2; i++ {
+		_, _ = repo.GetUserById(context.Background(), 1)
+	}
+	now = now.Add(2 * time.Minute)
+
+	_, err = repo.GetUserById(context.Background(), 1)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows from the probe, got %v", err)
+	}
+	if state := breaker.Status().State; state != BreakerClosed {
+		t.Fatalf("got state %s, expected closed", state)
+	}
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCircuitBreakerUserMiddleware_HalfOpenProbeFailureReopens(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	now := time.Now()
+	breaker := newTestBreaker(&now)
+	repo := NewCircuitBreakerUserMiddleware(breaker, imp.NewPostgresRepoUser(dbUser))
+
+	for i := 0; i < 3; i++ {
+		mockUser.ExpectExec(regexp.QuoteMeta("DELETE FROM users WHERE id = $1;")).
+			WithArgs(1).
+			WillReturnError(&pq.Error{Code: "57P03"})
+	}
+
+	for i := 0; i < 2; i++ {
+		_, _ = repo.DeleteUserById(context.Background(), 1)
+	}
+	now = now.Add(2 * time.Minute)
+	_, _ = repo.DeleteUserById(context.Background(), 1)
+
+	status := breaker.Status()
+	if status.State != BreakerOpen {
+		t.Fatalf("got state %s, expected open", status.State)
+	}
+	if !status.OpenedAt.Equal(now) {
+		t.Fatalf("expected the breaker to be reopened at %s, got %s", now, status.OpenedAt)
+	}
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCircuitBreakerLogMiddleware_IgnoresStatementErrors(t *testing.T) {
+	dbLog, mockLog, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbLog.Close()
+
+	now := time.Now()
+	breaker := newTestBreaker(&now)
+	repo := NewCircuitBreakerLogMiddleware(breaker, imp.NewPostgresRepoLog(dbLog))
+
+	for i := 0; i < 3; i++ {
+		mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message, details, actor) VALUES ($1, $2, $3, $4);")).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnError(&pq.Error{Code: "22001"})
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := repo.InsertLog(context.Background(), models.Log{LogTime: time.Now(), LogMessage: "msg"})
+		if errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("the breaker must not open on statement errors")
+		}
+	}
+	if state := breaker.Status().State; state != BreakerClosed {
+		t.Fatalf("got state %s, expected closed", state)
+	}
+	if err := mockLog.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
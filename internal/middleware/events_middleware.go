@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+// EventsMiddleware publishes every change that affected a row. Changes made
+// in a transaction of the caller are not published, the caller may still
+// roll them back.
+type EventsMiddleware struct {
+	events *UserEvents
+	next   repository.IRepositoryUser
+}
+
+func (e *EventsMiddleware) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	return e.next.GetUsers(ctx, filter)
+}
+
+func (e *EventsMiddleware) GetUserById(ctx context.Context, id int) (models.User, error) {
+	return e.next.GetUserById(ctx, id)
+}
+
+func (e *EventsMiddleware) InsertUser(ctx context.Context, user models.User) (sql.Result, error) {
+	res, err := e.next.InsertUser(ctx, user)
+	if err == nil {
+		if id, err := res.LastInsertId(); err == nil {
+			e.publish(ctx, UserCreated, int(id), res)
+		}
+	}
+	return res, err
+}
+
+func (e *EventsMiddleware) DeleteUserById(ctx context.Context, id int) (sql.Result, error) {
+	res, err := e.next.DeleteUserById(ctx, id)
+	if err == nil {
+		e.publish(ctx, UserDeleted, id, res)
+	}
+	return res, err
+}
+
+func (e *EventsMiddleware) UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error) {
+	res, err := e.next.UpdateUserById(ctx, id, user)
+	if err == nil {
+		e.publish(ctx, UserUpdated, id, res)
+	}
+	return res, err
+}
+
+func (e *EventsMiddleware) publish(ctx context.Context, typ UserEventType, id int, res sql.Result) {
+	if repository.TxFromContext(ctx) != nil {
+		return
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return
+	}
+	e.events.Publish(UserEvent{Type: typ, ID: id})
+}
+
+func NewEventsMiddleware(events *UserEvents, next repository.IRepositoryUser) repository.IRepositoryUser {
+	return &EventsMiddleware{events: events, next: next}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"testing"
+	"time"
+)
+
+func TestEventsMiddleware_PublishesChanges(t *testing.T) {
+	events := NewUserEvents()
+	sub := events.Subscribe(10)
+	defer sub.Cancel()
+	repo := NewEventsMiddleware(events, imp.NewMemoryRepoUser())
+	ctx := context.Background()
+
+	user := models.User{Name: "John", Email: "john@example.com", PasswordHash: "secret", RegisteredAt: time.Now()}
+	if _, err := repo.InsertUser(ctx, user); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting a user", err)
+	}
+	user.Name = "Johnny"
+	if _, err := repo.UpdateUserById(ctx, 1, user); err != nil {
+		t.Fatalf("an error '%s' was not expected when updating a user", err)
+	}
+	// Changes of missing users affect no row and are not published.
+	if _, err := repo.UpdateUserById(ctx, 7, user); err != nil {
+		t.Fatalf("an error '%s' was not expected when updating a missing user", err)
+	}
+	if _, err := repo.DeleteUserById(ctx, 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when deleting a user", err)
+	}
+
+	expected := []UserEvent{{UserCreated, 1}, {UserUpdated, 1}, {UserDeleted, 1}}
+	for _, want := range expected {
+		if got := <-sub.Events(); got != want {
+			t.Fatalf("got event %+v, expected %+v", got, want)
+		}
+	}
+	select {
+	case got := <-sub.Events():
+		t.Fatalf("got unexpected event %+v", got)
+	default:
+	}
+}
+
+func TestUserEvents_DropsSlowSubscribers(t *testing.T) {
+	events := NewUserEvents()
+	slow := events.Subscribe(1)
+	fast := events.Subscribe(10)
+	defer fast.Cancel()
+
+	events.Publish(UserEvent{UserCreated, 1})
+	events.Publish(UserEvent{UserCreated, 2})
+
+	if got := <-slow.Events(); got.ID != 1 {
+		t.Fatalf("got event %+v, expected the first one", got)
+	}
+	if _, ok := <-slow.Events(); ok {
+		t.Fatalf("expected the slow subscription to be closed")
+	}
+	if !errors.Is(slow.Err(), ErrSubscriberTooSlow) {
+		t.Fatalf("got '%v', expected ErrSubscriberTooSlow", slow.Err())
+	}
+	if len(fast.Events()) != 2 || fast.Err() != nil {
+		t.Fatalf("expected the fast subscription to get both events")
+	}
+	slow.Cancel()
+}
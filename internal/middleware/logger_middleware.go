@@ -1,13 +1,18 @@
 package middleware
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/tracing"
 )
 
 type LoggerMiddleware struct {
@@ -15,82 +20,180 @@ type LoggerMiddleware struct {
 	next  repository.IRepositoryUser
 }
 
-func (l *LoggerMiddleware) safeLog(msg string) {
-	if _, err := l.logDb.InsertLog(models.Log{
-		LogMessage: msg,
-		LogTime:    time.Now(),
-	}); err != nil {
+type batchKey struct{}
+
+// WithBatch tags the changes made with the returned context, so the audit
+// records of a command changing several users are undone together.
+func WithBatch(ctx context.Context, batch string) context.Context {
+	return context.WithValue(ctx, batchKey{}, batch)
+}
+
+// BatchFromContext returns the batch set by WithBatch, empty if none.
+func BatchFromContext(ctx context.Context) string {
+	batch, _ := ctx.Value(batchKey{}).(string)
+	return batch
+}
+
+type reasonKey struct{}
+
+// WithReason records reason in the audit records of the changes made with
+// the returned context, e.g. why a user was suspended.
+func WithReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, reasonKey{}, reason)
+}
+
+// ReasonFromContext returns the reason set by WithReason, empty if none.
+func ReasonFromContext(ctx context.Context) string {
+	reason, _ := ctx.Value(reasonKey{}).(string)
+	return reason
+}
+
+func (l *LoggerMiddleware) safeLog(ctx context.Context, msg string) {
+	l.insertLog(ctx, models.Log{LogMessage: msg, LogTime: time.Now()})
+}
+
+// logChange logs msg with the audit record of a successful change.
+func (l *LoggerMiddleware) logChange(ctx context.Context, msg string, record models.AuditRecord) {
+	record.Batch = BatchFromContext(ctx)
+	record.Reason = ReasonFromContext(ctx)
+	details, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("[WARN] failed to encode audit record: %v", err)
+	}
+	l.insertLog(ctx, models.Log{LogMessage: msg, LogTime: time.Now(), Details: string(details)})
+}
+
+// insertLog writes entry on behalf of the operator of ctx.
+func (l *LoggerMiddleware) insertLog(ctx context.Context, entry models.Log) {
+	entry.Actor = auth.Actor(ctx)
+	if _, err := l.logDb.InsertLog(ctx, entry); err != nil && !errors.Is(err, ErrCircuitOpen) {
 		log.Printf("[WARN] failed to insert log: %v", err)
 	}
 }
 
-func (l *LoggerMiddleware) GetUsers() ([]models.User, error) {
-	l.safeLog("Getting all users")
+// before reads the user a change is about to overwrite, so the change can be
+// undone. Of the password only the hash is kept. It is nil when the user
+// cannot be read.
+func (l *LoggerMiddleware) before(ctx context.Context, id int) *models.User {
+	user, err := l.next.GetUserById(ctx, id)
+	if err != nil {
+		return nil
+	}
+	user.Password = ""
+	return &user
+}
+
+// changed reports whether res says a row was changed.
+func changed(res sql.Result) bool {
+	rows, err := res.RowsAffected()
+	return err == nil && rows > 0
+}
+
+func (l *LoggerMiddleware) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	ctx, span := tracing.Start(ctx, "LoggerMiddleware.GetUsers")
+	defer span.End()
 
-	data, err := l.next.GetUsers()
+	l.safeLog(ctx, "Getting all users")
+
+	data, err := l.next.GetUsers(ctx, filter)
 
 	status := "succeeded"
 	if err != nil {
 		status = "failed"
 	}
-	l.safeLog(fmt.Sprintf("Getting all users %s", status))
+	l.safeLog(ctx, fmt.Sprintf("Getting all users %s", status))
 
+	span.RecordError(err)
 	return data, err
 }
 
-func (l *LoggerMiddleware) GetUserById(id int) (models.User, error) {
-	l.safeLog(fmt.Sprintf("Getting user by id: %d", id))
+func (l *LoggerMiddleware) GetUserById(ctx context.Context, id int) (models.User, error) {
+	ctx, span := tracing.Start(ctx, "LoggerMiddleware.GetUserById")
+	defer span.End()
+
+	l.safeLog(ctx, fmt.Sprintf("Getting user by id: %d", id))
 
-	data, err := l.next.GetUserById(id)
+	data, err := l.next.GetUserById(ctx, id)
 
 	status := "succeeded"
 	if err != nil {
 		status = "failed"
 	}
-	l.safeLog(fmt.Sprintf("Getting user by id: %d -- %s", id, status))
+	l.safeLog(ctx, fmt.Sprintf("Getting user by id: %d -- %s", id, status))
 
+	span.RecordError(err)
 	return data, err
 }
 
-func (l *LoggerMiddleware) InsertUser(user models.User) (sql.Result, error) {
-	l.safeLog("Trying to insert user")
+func (l *LoggerMiddleware) InsertUser(ctx context.Context, user models.User) (sql.Result, error) {
+	ctx, span := tracing.Start(ctx, "LoggerMiddleware.InsertUser")
+	defer span.End()
 
-	data, err := l.next.InsertUser(user)
+	l.safeLog(ctx, "Trying to insert user")
+
+	data, err := l.next.InsertUser(ctx, user)
 
-	status := "succeeded"
 	if err != nil {
-		status = "failed"
+		l.safeLog(ctx, "Insert user failed")
+	} else if id, idErr := data.LastInsertId(); idErr == nil {
+		l.logChange(ctx, "Insert user succeeded", models.AuditRecord{Op: models.AuditInsert, UserID: int(id)})
+	} else {
+		l.safeLog(ctx, "Insert user succeeded")
 	}
-	l.safeLog(fmt.Sprintf("Insert user %s", status))
 
+	span.RecordError(err)
 	return data, err
 }
 
-func (l *LoggerMiddleware) DeleteUserById(id int) (sql.Result, error) {
-	l.safeLog(fmt.Sprintf("Started deleting user with id %d", id))
+func (l *LoggerMiddleware) DeleteUserById(ctx context.Context, id int) (sql.Result, error) {
+	ctx, span := tracing.Start(ctx, "LoggerMiddleware.DeleteUserById")
+	defer span.End()
 
-	res, err := l.next.DeleteUserById(id)
+	l.safeLog(ctx, fmt.Sprintf("Started deleting user with id %d", id))
 
-	status := "succeeded"
-	if err != nil {
-		status = fmt.Sprintf("failed: %v", err)
+	before := l.before(ctx, id)
+	res, err := l.next.DeleteUserById(ctx, id)
+
+	switch {
+	case err != nil:
+		l.safeLog(ctx, fmt.Sprintf("Deleting user with id %d failed: %v", id, err))
+	case before != nil && changed(res):
+		l.logChange(ctx, fmt.Sprintf("Deleting user with id %d succeeded", id),
+			models.AuditRecord{Op: models.AuditDelete, UserID: id, Before: before})
+	default:
+		l.safeLog(ctx, fmt.Sprintf("Deleting user with id %d succeeded", id))
 	}
-	l.safeLog(fmt.Sprintf("Deleting user with id %d %s", id, status))
 
+	span.RecordError(err)
 	return res, err
 }
 
-func (l *LoggerMiddleware) UpdateUserById(id int, user models.User) (sql.Result, error) {
-	l.safeLog(fmt.Sprintf("Trying to update user with id %d", id))
-
-	res, err := l.next.UpdateUserById(id, user)
-
-	status := "succeeded"
-	if err != nil {
-		status = fmt.Sprintf("failed: %v", err)
+func (l *LoggerMiddleware) UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error) {
+	ctx, span := tracing.Start(ctx, "LoggerMiddleware.UpdateUserById")
+	defer span.End()
+
+	l.safeLog(ctx, fmt.Sprintf("Trying to update user with id %d", id))
+
+	before := l.before(ctx, id)
+	res, err := l.next.UpdateUserById(ctx, id, user)
+
+	switch {
+	case err != nil:
+		l.safeLog(ctx, fmt.Sprintf("Updating user with id %d failed: %v", id, err))
+	case before != nil && changed(res) && user.Status != "" && user.Status != before.Status:
+		msg := fmt.Sprintf("Changing status of user with id %d from %s to %s succeeded", id, before.Status, user.Status)
+		if reason := ReasonFromContext(ctx); reason != "" {
+			msg += ": " + reason
+		}
+		l.logChange(ctx, msg, models.AuditRecord{Op: models.AuditUpdate, UserID: id, Before: before})
+	case before != nil && changed(res):
+		l.logChange(ctx, fmt.Sprintf("Updating user with id %d succeeded", id),
+			models.AuditRecord{Op: models.AuditUpdate, UserID: id, Before: before})
+	default:
+		l.safeLog(ctx, fmt.Sprintf("Updating user with id %d succeeded", id))
 	}
-	l.safeLog(fmt.Sprintf("Updating user with id %d %s", id, status))
 
+	span.RecordError(err)
 	return res, err
 }
 
@@ -1,10 +1,14 @@
 package middleware
 
 import (
+	"context"
+	"database/sql/driver"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
 	"github.com/DATA-DOG/go-sqlmock"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -13,10 +17,21 @@ func userEquals(this models.User, other models.User) bool {
 	return this.Email == other.Email &&
 		this.ID == other.ID &&
 		this.Name == other.Name &&
-		this.Password == other.Password &&
+		this.PasswordHash == other.PasswordHash &&
 		this.RegisteredAt == other.RegisteredAt
 }
 
+// auditOf matches log details whose audit record keeps the password hash
+// of the previous values but no password.
+type auditOf struct {
+	hash string
+}
+
+func (a auditOf) Match(v driver.Value) bool {
+	details, ok := v.(string)
+	return ok && strings.Contains(details, `"password_hash":"`+a.hash+`"`) && !strings.Contains(details, `"password":`)
+}
+
 func TestLoggerMiddleware_GetUsers(t *testing.T) {
 	dbLog, mockLog, err := sqlmock.New()
 	if err != nil {
@@ -37,25 +52,25 @@ func TestLoggerMiddleware_GetUsers(t *testing.T) {
 	}
 
 	userRows := sqlmock.NewRows([]string{
-		"id", "name", "email", "password", "registered_at",
-	}).AddRow(1, "John", "john@example.com", "secret", time.Now())
+		"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes",
+	}).AddRow(1, "John", "john@example.com", "secret", time.Now(), nil, "active", "{}")
 
-	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT * FROM users;")).
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users;")).
 		WillReturnRows(userRows)
 
 	mockLog.ExpectExec(regexp.QuoteMeta(
-		"INSERT INTO logs (log_time, log_message) VALUES ($1, $2);",
+		"INSERT INTO logs (log_time, log_message, details, actor) VALUES ($1, $2, $3, $4);",
 	)).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	mockLog.ExpectExec(regexp.QuoteMeta(
-		"INSERT INTO logs (log_time, log_message) VALUES ($1, $2);",
+		"INSERT INTO logs (log_time, log_message, details, actor) VALUES ($1, $2, $3, $4);",
 	)).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(2, 1))
 
-	_, err = repo.GetUsers()
+	_, err = repo.GetUsers(context.Background(), models.UserFilter{})
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when getting users", err)
 	}
@@ -88,26 +103,26 @@ func TestLoggerMiddleware_GetUserById(t *testing.T) {
 		ID:           1,
 		Name:         "John",
 		Email:        "john@example.com",
-		Password:     "secret",
+		PasswordHash: "secret",
 		RegisteredAt: time.Now(),
 	}
 
-	userRows := sqlmock.NewRows([]string{"id", "name", "email", "password", "registered_at"}).
-		AddRow(mUser.ID, mUser.Name, mUser.Email, mUser.Password, mUser.RegisteredAt)
+	userRows := sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes"}).
+		AddRow(mUser.ID, mUser.Name, mUser.Email, mUser.PasswordHash, mUser.RegisteredAt, nil, "active", "{}")
 
-	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT * FROM users WHERE id = $1;")).
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
 		WithArgs(1).
 		WillReturnRows(userRows)
 
-	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message) VALUES ($1, $2);")).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message, details, actor) VALUES ($1, $2, $3, $4);")).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message) VALUES ($1, $2);")).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message, details, actor) VALUES ($1, $2, $3, $4);")).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	us, err := repo.GetUserById(1)
+	us, err := repo.GetUserById(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when getting user by id", err)
 	}
@@ -142,23 +157,23 @@ func TestLoggerMiddleware_InsertUser(t *testing.T) {
 		ID:           1,
 		Name:         "John",
 		Email:        "john@example.com",
-		Password:     "secret",
+		PasswordHash: "secret",
 		RegisteredAt: time.Now(),
 	}
 
-	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message) VALUES ($1, $2);")).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message, details, actor) VALUES ($1, $2, $3, $4);")).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message) VALUES ($1, $2);")).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message, details, actor) VALUES ($1, $2, $3, $4);")).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	mockUser.ExpectExec(regexp.QuoteMeta("INSERT INTO users (name, email, password, registered_at) VALUES ($1, $2, $3, $4);")).
-		WithArgs(mUser.Name, mUser.Email, mUser.Password, sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	mockUser.ExpectQuery(regexp.QuoteMeta("INSERT INTO users (name, email, password_hash, registered_at, email_verified_at, status, attributes) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;")).
+		WithArgs(mUser.Name, mUser.Email, mUser.PasswordHash, sqlmock.AnyArg(), nil, "pending", "{}").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
-	_, err = repo.InsertUser(mUser)
+	_, err = repo.InsertUser(context.Background(), mUser)
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when inserting user", err)
 	}
@@ -190,23 +205,27 @@ func TestLoggerMiddleware_UpdateUser(t *testing.T) {
 		ID:           1,
 		Name:         "John",
 		Email:        "john@example.com",
-		Password:     "secret",
+		PasswordHash: "secret",
 		RegisteredAt: time.Now(),
 	}
 
-	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message) VALUES ($1, $2);")).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message, details, actor) VALUES ($1, $2, $3, $4);")).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message) VALUES ($1, $2);")).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message, details, actor) VALUES ($1, $2, $3, $4);")).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), auditOf{hash: "old-secret"}, sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	mockUser.ExpectExec(regexp.QuoteMeta("UPDATE users SET name = $1, email = $2, password = $3, registered_at = $4 WHERE id = $5;")).
-		WithArgs(mUser.Name, mUser.Email, mUser.Password, sqlmock.AnyArg(), sqlmock.AnyArg()).
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes"}).
+			AddRow(mUser.ID, "Old", "old@example.com", "old-secret", mUser.RegisteredAt, nil, "active", "{}"))
+	mockUser.ExpectExec(regexp.QuoteMeta("UPDATE users SET email_verified_at = CASE WHEN email = $2 THEN $5::timestamp END, name = $1, email = $2, password_hash = $3, status = COALESCE(NULLIF($6, ''), status) WHERE id = $4;")).
+		WithArgs(mUser.Name, mUser.Email, mUser.PasswordHash, sqlmock.AnyArg(), nil, "").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	_, err = repo.UpdateUserById(mUser.ID, mUser)
+	_, err = repo.UpdateUserById(context.Background(), mUser.ID, mUser)
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when inserting user", err)
 	}
@@ -238,24 +257,28 @@ func TestLoggerMiddleware_DeleteUser(t *testing.T) {
 		ID:           1,
 		Name:         "John",
 		Email:        "john@example.com",
-		Password:     "secret",
+		PasswordHash: "secret",
 		RegisteredAt: time.Now(),
 	}
 
-	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message) VALUES ($1, $2);")).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message, details, actor) VALUES ($1, $2, $3, $4);")).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message) VALUES ($1, $2);")).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message, details, actor) VALUES ($1, $2, $3, $4);")).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes"}).
+			AddRow(mUser.ID, "Old", "old@example.com", "old-secret", mUser.RegisteredAt, nil, "active", "{}"))
 	mockUser.ExpectExec(regexp.QuoteMeta("DELETE FROM users WHERE id = $1;")).
 		WithArgs(1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	id := mUser.ID
-	_, err = repo.DeleteUserById(id)
+	_, err = repo.DeleteUserById(context.Background(), id)
 
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when inserting user", err)
@@ -267,3 +290,96 @@ func TestLoggerMiddleware_DeleteUser(t *testing.T) {
 		t.Fatalf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestLoggerMiddleware_RecordsChangesForUndo(t *testing.T) {
+	ctx := context.Background()
+	logs := imp.NewMemoryRepoLog()
+	repo := NewLoggerMiddleware(logs, imp.NewMemoryRepoUser())
+
+	if _, err := repo.InsertUser(ctx, models.User{Name: "John", Email: "john@example.com", PasswordHash: "secret"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting user", err)
+	}
+	update := models.User{Name: "Johnny", Email: "john@example.com", PasswordHash: "secret"}
+	if _, err := repo.UpdateUserById(WithBatch(ctx, "b1"), 1, update); err != nil {
+		t.Fatalf("an error '%s' was not expected when updating user", err)
+	}
+	if _, err := repo.DeleteUserById(ctx, 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when deleting user", err)
+	}
+	// Nothing is deleted, so there is nothing to undo.
+	if _, err := repo.DeleteUserById(ctx, 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when deleting a missing user", err)
+	}
+
+	entries, err := logs.GetLogs(ctx)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting logs", err)
+	}
+	var records []models.AuditRecord
+	for _, entry := range entries {
+		if record, ok := entry.Audit(); ok {
+			records = append(records, record)
+		}
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d audit records, expected 3: %+v", len(records), records)
+	}
+	if r := records[0]; r.Op != models.AuditInsert || r.UserID != 1 || r.Before != nil {
+		t.Fatalf("unexpected insert record %+v", r)
+	}
+	if r := records[1]; r.Op != models.AuditUpdate || r.Batch != "b1" || r.Before == nil || r.Before.Name != "John" {
+		t.Fatalf("unexpected update record %+v", r)
+	}
+	if r := records[2]; r.Op != models.AuditDelete || r.Batch != "" || r.Before == nil || r.Before.Name != "Johnny" {
+		t.Fatalf("unexpected delete record %+v", r)
+	}
+}
+
+func TestLoggerMiddleware_RecordsActor(t *testing.T) {
+	logs := imp.NewMemoryRepoLog()
+	repo := NewLoggerMiddleware(logs, imp.NewMemoryRepoUser())
+	ctx := auth.WithOperator(context.Background(), models.Operator{Name: "ed", Role: models.RoleEditor})
+
+	if _, err := repo.InsertUser(ctx, models.User{Name: "John", Email: "john@example.com", PasswordHash: "secret"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting user", err)
+	}
+	entries, err := logs.GetLogs(ctx)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting logs", err)
+	}
+	if len(entries) == 0 {
+		t.Fatalf("expected the insert to be logged")
+	}
+	for _, entry := range entries {
+		if entry.Actor != "ed" {
+			t.Fatalf("got actor %q in %+v, expected ed", entry.Actor, entry)
+		}
+	}
+}
+
+func TestLoggerMiddleware_RecordsStatusReason(t *testing.T) {
+	ctx := context.Background()
+	logs := imp.NewMemoryRepoLog()
+	repo := NewLoggerMiddleware(logs, imp.NewMemoryRepoUser())
+
+	if _, err := repo.InsertUser(ctx, models.User{Name: "John", Email: "john@example.com", PasswordHash: "secret"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting user", err)
+	}
+	suspended := models.User{Name: "John", Email: "john@example.com", PasswordHash: "secret", Status: models.StatusSuspended}
+	if _, err := repo.UpdateUserById(WithReason(ctx, "spam"), 1, suspended); err != nil {
+		t.Fatalf("an error '%s' was not expected when suspending user", err)
+	}
+
+	entries, err := logs.GetLogs(ctx)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting logs", err)
+	}
+	last := entries[len(entries)-1]
+	record, ok := last.Audit()
+	if !ok || record.Reason != "spam" || record.Before == nil || record.Before.Status != models.StatusPending {
+		t.Fatalf("unexpected audit record %+v in %+v", record, last)
+	}
+	if last.LogMessage != "Changing status of user with id 1 from pending to suspended succeeded: spam" {
+		t.Fatalf("unexpected message %q", last.LogMessage)
+	}
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/metrics"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/tracing"
+)
+
+type RepositoryMetrics struct {
+	duration *metrics.HistogramVec
+	errors   *metrics.CounterVec
+}
+
+func NewRepositoryMetrics(reg *metrics.Registry) *RepositoryMetrics {
+	return &RepositoryMetrics{
+		duration: reg.NewHistogramVec("repository_operation_duration_seconds",
+			"Latency of repository calls.", metrics.DefaultBuckets, "repository", "operation"),
+		errors: reg.NewCounterVec("repository_operation_errors_total",
+			"Failed repository calls by error class.", "repository", "operation", "class"),
+	}
+}
+
+// ErrorClass groups repository errors into a small set of metric labels.
+func ErrorClass(err error) string {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "not_found"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	case isRolledBackError(err):
+		return "rolled_back"
+	case isOutageError(err):
+		return "unavailable"
+	default:
+		return "other"
+	}
+}
+
+func measure[T any](ctx context.Context, m *RepositoryMetrics, repo, operation string, op func(context.Context) (T, error)) (T, error) {
+	ctx, span := tracing.Start(ctx, "MetricsMiddleware."+operation)
+	defer span.End()
+
+	start := time.Now()
+	result, err := op(ctx)
+	m.duration.Observe(time.Since(start).Seconds(), repo, operation)
+	if err != nil {
+		m.errors.Inc(repo, operation, ErrorClass(err))
+	}
+	span.RecordError(err)
+	return result, err
+}
+
+type MetricsUserMiddleware struct {
+	metrics *RepositoryMetrics
+	next    repository.IRepositoryUser
+}
+
+func (m *MetricsUserMiddleware) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	return measure(ctx, m.metrics, "users", "GetUsers", func(ctx context.Context) ([]models.User, error) {
+		return m.next.GetUsers(ctx, filter)
+	})
+}
+
+func (m *MetricsUserMiddleware) GetUserById(ctx context.Context, id int) (models.User, error) {
+	return measure(ctx, m.metrics, "users", "GetUserById", func(ctx context.Context) (models.User, error) {
+		return m.next.GetUserById(ctx, id)
+	})
+}
+
+func (m *MetricsUserMiddleware) InsertUser(ctx context.Context, user models.User) (sql.Result, error) {
+	return measure(ctx, m.metrics, "users", "InsertUser", func(ctx context.Context) (sql.Result, error) {
+		return m.next.InsertUser(ctx, user)
+	})
+}
+
+func (m *MetricsUserMiddleware) DeleteUserById(ctx context.Context, id int) (sql.Result, error) {
+	return measure(ctx, m.metrics, "users", "DeleteUserById", func(ctx context.Context) (sql.Result, error) {
+		return m.next.DeleteUserById(ctx, id)
+	})
+}
+
+func (m *MetricsUserMiddleware) UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error) {
+	return measure(ctx, m.metrics, "users", "UpdateUserById", func(ctx context.Context) (sql.Result, error) {
+		return m.next.UpdateUserById(ctx, id, user)
+	})
+}
+
+func NewMetricsUserMiddleware(metrics *RepositoryMetrics, next repository.IRepositoryUser) repository.IRepositoryUser {
+	return &MetricsUserMiddleware{metrics: metrics, next: next}
+}
+
+type MetricsLogMiddleware struct {
+	metrics *RepositoryMetrics
+	next    repository.IRepositoryLog
+}
+
+func (m *MetricsLogMiddleware) GetLogs(ctx context.Context) ([]models.Log, error) {
+	return measure(ctx, m.metrics, "logs", "GetLogs", m.next.GetLogs)
+}
+
+func (m *MetricsLogMiddleware) GetLogById(ctx context.Context, id int) (models.Log, error) {
+	return measure(ctx, m.metrics, "logs", "GetLogById", func(ctx context.Context) (models.Log, error) {
+		return m.next.GetLogById(ctx, id)
+	})
+}
+
+func (m *MetricsLogMiddleware) InsertLog(ctx context.Context, log models.Log) (sql.Result, error) {
+	return measure(ctx, m.metrics, "logs", "InsertLog", func(ctx context.Context) (sql.Result, error) {
+		return m.next.InsertLog(ctx, log)
+	})
+}
+
+func NewMetricsLogMiddleware(metrics *RepositoryMetrics, next repository.IRepositoryLog) repository.IRepositoryLog {
+	return &MetricsLogMiddleware{metrics: metrics, next: next}
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/metrics"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsUserMiddleware_RecordsLatencyAndErrors(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	reg := metrics.NewRegistry()
+	m := NewRepositoryMetrics(reg)
+	repo := NewMetricsUserMiddleware(m, imp.NewPostgresRepoUser(dbUser))
+
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes"}).
+			AddRow(1, "John", "john@example.com", "secret", time.Now(), nil, "active", "{}"))
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+		WithArgs(2).
+		WillReturnError(sql.ErrNoRows)
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users;")).
+		WillReturnError(&pq.Error{Code: "57P01"})
+
+	_, _ = repo.GetUserById(context.Background(), 1)
+	_, _ = repo.GetUserById(context.Background(), 2)
+	_, _ = repo.GetUsers(context.Background(), models.UserFilter{})
+
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+	if n := m.duration.Count("users", "GetUserById"); n != 2 {
+		t.Fatalf("got %d GetUserById observations, expected 2", n)
+	}
+	if v := m.errors.Value("users", "GetUserById", "not_found"); v != 1 {
+		t.Fatalf("got %v not_found errors, expected 1", v)
+	}
+	if v := m.errors.Value("users", "GetUsers", "unavailable"); v != 1 {
+		t.Fatalf("got %v unavailable errors, expected 1", v)
+	}
+
+	var b strings.Builder
+	if _, err := reg.WriteTo(&b); err != nil {
+		t.Fatalf("an error '%s' was not expected when writing metrics", err)
+	}
+	if !strings.Contains(b.String(), `repository_operation_duration_seconds_count{repository="users",operation="GetUserById"} 2`) {
+		t.Fatalf("unexpected metrics output:\n%s", b.String())
+	}
+}
+
+func TestMetricsLogMiddleware_InsertLog(t *testing.T) {
+	dbLog, mockLog, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbLog.Close()
+
+	m := NewRepositoryMetrics(metrics.NewRegistry())
+	repo := NewMetricsLogMiddleware(m, imp.NewPostgresRepoLog(dbLog))
+
+	mockLog.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message, details, actor) VALUES ($1, $2, $3, $4);")).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if _, err := repo.InsertLog(context.Background(), models.Log{LogTime: time.Now(), LogMessage: "msg"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting log", err)
+	}
+	if n := m.duration.Count("logs", "InsertLog"); n != 1 {
+		t.Fatalf("got %d InsertLog observations, expected 1", n)
+	}
+	if err := mockLog.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
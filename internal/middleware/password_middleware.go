@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordMiddleware hashes the new password of a user with bcrypt before it
+// is stored, so the plain text never reaches the repositories below it.
+// Users without a new password keep the hash they have.
+type PasswordMiddleware struct {
+	next repository.IRepositoryUser
+	cost int
+}
+
+func (p *PasswordMiddleware) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	return p.next.GetUsers(ctx, filter)
+}
+
+func (p *PasswordMiddleware) GetUserById(ctx context.Context, id int) (models.User, error) {
+	return p.next.GetUserById(ctx, id)
+}
+
+func (p *PasswordMiddleware) InsertUser(ctx context.Context, user models.User) (sql.Result, error) {
+	user, err := p.hash(user)
+	if err != nil {
+		return nil, err
+	}
+	return p.next.InsertUser(ctx, user)
+}
+
+func (p *PasswordMiddleware) DeleteUserById(ctx context.Context, id int) (sql.Result, error) {
+	return p.next.DeleteUserById(ctx, id)
+}
+
+func (p *PasswordMiddleware) UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error) {
+	user, err := p.hash(user)
+	if err != nil {
+		return nil, err
+	}
+	return p.next.UpdateUserById(ctx, id, user)
+}
+
+func (p *PasswordMiddleware) hash(user models.User) (models.User, error) {
+	if user.Password == "" {
+		return user, nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), p.cost)
+	if err != nil {
+		return user, err
+	}
+	user.PasswordHash = string(hash)
+	user.Password = ""
+	return user, nil
+}
+
+func NewPasswordMiddleware(next repository.IRepositoryUser) repository.IRepositoryUser {
+	return &PasswordMiddleware{next: next, cost: bcrypt.DefaultCost}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordMiddleware_HashesNewPasswords(t *testing.T) {
+	users := imp.NewMemoryRepoUser()
+	repo := &PasswordMiddleware{next: users, cost: bcrypt.MinCost}
+	ctx := context.Background()
+
+	if _, err := repo.InsertUser(ctx, models.User{Name: "John", Email: "john@example.com", Password: "secret"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting a user", err)
+	}
+	user, err := users.GetUserById(ctx, 1)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting the user", err)
+	}
+	if user.Password != "" || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("secret")) != nil {
+		t.Fatalf("got %+v, expected only the hash of secret", user)
+	}
+
+	// Without a new password the hash is kept.
+	hash := user.PasswordHash
+	user.Name = "Johnny"
+	if _, err := repo.UpdateUserById(ctx, 1, user); err != nil {
+		t.Fatalf("an error '%s' was not expected when updating the user", err)
+	}
+	if user, _ = users.GetUserById(ctx, 1); user.PasswordHash != hash {
+		t.Fatalf("got hash %q, expected %q", user.PasswordHash, hash)
+	}
+
+	user.Password = "hunter2"
+	if _, err := repo.UpdateUserById(ctx, 1, user); err != nil {
+		t.Fatalf("an error '%s' was not expected when changing the password", err)
+	}
+	user, _ = users.GetUserById(ctx, 1)
+	if user.Password != "" || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("hunter2")) != nil {
+		t.Fatalf("got %+v, expected only the hash of hunter2", user)
+	}
+}
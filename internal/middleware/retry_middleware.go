@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"syscall"
+	"time"
+
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/tracing"
+	"github.com/lib/pq"
+)
+
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// MaxElapsed is the total time budget for one call, including backoff.
+	MaxElapsed time.Duration
+	// Transactional means every call of next runs as one whole transaction,
+	// so writes that were rolled back by the server can be safely replayed.
+	// Calls joining a transaction of their caller are not, the caller's
+	// earlier work was rolled back with them.
+	Transactional bool
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   50 * time.Millisecond,
+		MaxDelay:    2 * time.Second,
+		MaxElapsed:  10 * time.Second,
+	}
+}
+
+type RetryMiddleware struct {
+	policy RetryPolicy
+	next   repository.IRepositoryUser
+}
+
+// IsRetryableError reports whether err is a transient failure after which
+// the same statement may succeed: serialization failures, deadlocks,
+// server shutdowns and dropped connections.
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if isRolledBackError(err) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "57P01", "57P02", "57P03": // admin_shutdown, crash_shutdown, cannot_connect_now
+			return true
+		}
+		return pqErr.Code.Class() == "08" // connection_exception
+	}
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// isRolledBackError reports whether the server aborted the transaction
+// itself, which guarantees that none of its writes were committed.
+func isRolledBackError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "40001" || pqErr.Code == "40P01" // serialization_failure, deadlock_detected
+}
+
+func (r *RetryMiddleware) backoff(attempt int) time.Duration {
+	delay := r.policy.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > r.policy.MaxDelay {
+		delay = r.policy.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return rand.N(delay + 1)
+}
+
+func retry[T any](ctx context.Context, r *RetryMiddleware, name string, idempotent bool, op func(context.Context) (T, error)) (T, error) {
+	ctx, span := tracing.Start(ctx, name)
+	defer span.End()
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		result, err := op(ctx)
+		if err == nil {
+			span.SetAttr(tracing.Int("retry.attempts", attempt))
+			return result, nil
+		}
+
+		retryable := IsRetryableError(err)
+		if !idempotent {
+			retryable = r.policy.Transactional && repository.TxFromContext(ctx) == nil && isRolledBackError(err)
+		}
+		if !retryable || attempt >= r.policy.MaxAttempts || ctx.Err() != nil {
+			span.SetAttr(tracing.Int("retry.attempts", attempt))
+			span.RecordError(err)
+			return result, err
+		}
+
+		delay := r.backoff(attempt)
+		if r.policy.MaxElapsed > 0 && time.Since(start)+delay > r.policy.MaxElapsed {
+			span.SetAttr(tracing.Int("retry.attempts", attempt))
+			span.RecordError(err)
+			return result, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			span.SetAttr(tracing.Int("retry.attempts", attempt))
+			span.RecordError(err)
+			return result, err
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *RetryMiddleware) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	return retry(ctx, r, "RetryMiddleware.GetUsers", true, func(ctx context.Context) ([]models.User, error) {
+		return r.next.GetUsers(ctx, filter)
+	})
+}
+
+func (r *RetryMiddleware) GetUserById(ctx context.Context, id int) (models.User, error) {
+	return retry(ctx, r, "RetryMiddleware.GetUserById", true, func(ctx context.Context) (models.User, error) {
+		return r.next.GetUserById(ctx, id)
+	})
+}
+
+func (r *RetryMiddleware) InsertUser(ctx context.Context, user models.User) (sql.Result, error) {
+	return retry(ctx, r, "RetryMiddleware.InsertUser", false, func(ctx context.Context) (sql.Result, error) {
+		return r.next.InsertUser(ctx, user)
+	})
+}
+
+func (r *RetryMiddleware) DeleteUserById(ctx context.Context, id int) (sql.Result, error) {
+	return retry(ctx, r, "RetryMiddleware.DeleteUserById", false, func(ctx context.Context) (sql.Result, error) {
+		return r.next.DeleteUserById(ctx, id)
+	})
+}
+
+func (r *RetryMiddleware) UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error) {
+	return retry(ctx, r, "RetryMiddleware.UpdateUserById", false, func(ctx context.Context) (sql.Result, error) {
+		return r.next.UpdateUserById(ctx, id, user)
+	})
+}
+
+func NewRetryMiddleware(policy RetryPolicy, next repository.IRepositoryUser) repository.IRepositoryUser {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	return &RetryMiddleware{policy: policy, next: next}
+}
@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func testRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    5 * time.Millisecond,
+		MaxElapsed:  time.Second,
+	}
+}
+
+func TestRetryMiddleware_GetUserById_RetriesSerializationFailure(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	repo := NewRetryMiddleware(testRetryPolicy(), imp.NewPostgresRepoUser(dbUser))
+
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+		WithArgs(1).
+		WillReturnError(&pq.Error{Code: "40001"})
+
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes"}).
+			AddRow(1, "John", "john@example.com", "secret", time.Now(), nil, "active", "{}"))
+
+	user, err := repo.GetUserById(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting user by id", err)
+	}
+	if user.ID != 1 {
+		t.Fatalf("got user %d, expected 1", user.ID)
+	}
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRetryMiddleware_GetUsers_GivesUpAfterMaxAttempts(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	repo := NewRetryMiddleware(testRetryPolicy(), imp.NewPostgresRepoUser(dbUser))
+
+	for i := 0; i < 3; i++ {
+		mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users;")).
+			WillReturnError(&pq.Error{Code: "57P01"})
+	}
+
+	_, err = repo.GetUsers(context.Background(), models.UserFilter{})
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "57P01" {
+		t.Fatalf("expected admin_shutdown error, got %v", err)
+	}
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRetryMiddleware_GetUsers_DoesNotRetryPermanentError(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	repo := NewRetryMiddleware(testRetryPolicy(), imp.NewPostgresRepoUser(dbUser))
+
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users;")).
+		WillReturnError(&pq.Error{Code: "42P01"})
+
+	if _, err = repo.GetUsers(context.Background(), models.UserFilter{}); err == nil {
+		t.Fatalf("an error was expected when getting users")
+	}
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRetryMiddleware_InsertUser_NotRetriedOutsideTransaction(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	repo := NewRetryMiddleware(testRetryPolicy(), imp.NewPostgresRepoUser(dbUser))
+
+	mockUser.ExpectQuery(regexp.QuoteMeta("INSERT INTO users (name, email, password_hash, registered_at, email_verified_at, status, attributes) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;")).
+		WithArgs("John", "john@example.com", "secret", sqlmock.AnyArg(), nil, "pending", "{}").
+		WillReturnError(&pq.Error{Code: "40001"})
+
+	_, err = repo.InsertUser(context.Background(), models.User{Name: "John", Email: "john@example.com", PasswordHash: "secret"})
+	if err == nil {
+		t.Fatalf("an error was expected when inserting user")
+	}
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRetryMiddleware_InsertUser_RetriesWholeTransaction(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	policy := testRetryPolicy()
+	policy.Transactional = true
+	repoTx := NewTransactionalMiddleware(dbUser, imp.NewPostgresRepoUser(dbUser))
+	repo := NewRetryMiddleware(policy, repoTx)
+
+	mockUser.ExpectBegin()
+	mockUser.ExpectQuery(regexp.QuoteMeta("INSERT INTO users (name, email, password_hash, registered_at, email_verified_at, status, attributes) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;")).
+		WithArgs("John", "john@example.com", "secret", sqlmock.AnyArg(), nil, "pending", "{}").
+		WillReturnError(&pq.Error{Code: "40P01"})
+	mockUser.ExpectRollback()
+
+	mockUser.ExpectBegin()
+	mockUser.ExpectQuery(regexp.QuoteMeta("INSERT INTO users (name, email, password_hash, registered_at, email_verified_at, status, attributes) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;")).
+		WithArgs("John", "john@example.com", "secret", sqlmock.AnyArg(), nil, "pending", "{}").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mockUser.ExpectCommit()
+
+	_, err = repo.InsertUser(context.Background(), models.User{Name: "John", Email: "john@example.com", PasswordHash: "secret"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting user", err)
+	}
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRetryMiddleware_InsertUser_DoesNotRetryJoinedTransaction(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	policy := testRetryPolicy()
+	policy.Transactional = true
+	repoTx := NewTransactionalMiddleware(dbUser, imp.NewPostgresRepoUser(dbUser))
+	repo := NewRetryMiddleware(policy, repoTx)
+
+	mockUser.ExpectBegin()
+	mockUser.ExpectQuery(regexp.QuoteMeta("INSERT INTO users (name, email, password_hash, registered_at, email_verified_at, status, attributes) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;")).
+		WithArgs("John", "john@example.com", "secret", sqlmock.AnyArg(), nil, "pending", "{}").
+		WillReturnError(&pq.Error{Code: "40P01"})
+	mockUser.ExpectRollback()
+
+	tx, err := dbUser.Begin()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when beginning a transaction", err)
+	}
+	_, err = repo.InsertUser(repository.WithTx(context.Background(), tx), models.User{Name: "John", Email: "john@example.com", PasswordHash: "secret"})
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "40P01" {
+		t.Fatalf("the deadlock was expected when inserting user, got %v", err)
+	}
+	_ = tx.Rollback()
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRetryMiddleware_StopsOnContextCancel(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	policy := testRetryPolicy()
+	policy.BaseDelay = time.Hour
+	policy.MaxDelay = time.Hour
+	policy.MaxElapsed = 0
+	repo := NewRetryMiddleware(policy, imp.NewPostgresRepoUser(dbUser))
+
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users;")).
+		WillReturnError(&pq.Error{Code: "40001"})
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	if _, err = repo.GetUsers(ctx, models.UserFilter{}); err == nil {
+		t.Fatalf("an error was expected when getting users")
+	}
+	if elapsed := time.Since(start); elapsed > time.Minute {
+		t.Fatalf("retry did not stop on cancel, took %s", elapsed)
+	}
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -1,9 +1,11 @@
 package middleware
 
 import (
+	"context"
 	"database/sql"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/tracing"
 )
 
 type TransactionalMiddleware struct {
@@ -11,95 +13,157 @@ type TransactionalMiddleware struct {
 	next repository.IRepositoryUser
 }
 
-func (t *TransactionalMiddleware) GetUsers() ([]models.User, error) {
-	tx, err := t.db.Begin()
+// txScope is the transaction of one call, empty when the call joined a
+// transaction of its caller, who then decides whether it commits.
+type txScope struct {
+	tx *sql.Tx
+}
+
+func (s txScope) Commit() error {
+	if s.tx == nil {
+		return nil
+	}
+	return s.tx.Commit()
+}
+
+func (s txScope) Rollback() {
+	if s.tx != nil {
+		_ = s.tx.Rollback()
+	}
+}
+
+// begin starts a transaction and returns a context carrying it, so the
+// repositories below run in it. A transaction already in ctx is joined.
+func (t *TransactionalMiddleware) begin(ctx context.Context) (context.Context, txScope, error) {
+	if repository.TxFromContext(ctx) != nil {
+		return ctx, txScope{}, nil
+	}
+	tx, err := t.db.BeginTx(ctx, nil)
 	if err != nil {
+		return ctx, txScope{}, err
+	}
+	return repository.WithTx(ctx, tx), txScope{tx: tx}, nil
+}
+
+func (t *TransactionalMiddleware) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	ctx, span := tracing.Start(ctx, "TransactionalMiddleware.GetUsers")
+	defer span.End()
+
+	ctx, tx, err := t.begin(ctx)
+	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	result, err := t.next.GetUsers()
+	result, err := t.next.GetUsers(ctx, filter)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 
 	return result, nil
 }
 
-func (t *TransactionalMiddleware) GetUserById(id int) (models.User, error) {
-	tx, err := t.db.Begin()
+func (t *TransactionalMiddleware) GetUserById(ctx context.Context, id int) (models.User, error) {
+	ctx, span := tracing.Start(ctx, "TransactionalMiddleware.GetUserById")
+	defer span.End()
+
+	ctx, tx, err := t.begin(ctx)
 	if err != nil {
+		span.RecordError(err)
 		return models.User{}, err
 	}
 	defer tx.Rollback()
 
-	result, err := t.next.GetUserById(id)
+	result, err := t.next.GetUserById(ctx, id)
 	if err != nil {
+		span.RecordError(err)
 		return models.User{}, err
 	}
 
 	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
 		return models.User{}, err
 	}
 
 	return result, nil
 }
 
-func (t *TransactionalMiddleware) InsertUser(user models.User) (sql.Result, error) {
-	tx, err := t.db.Begin()
+func (t *TransactionalMiddleware) InsertUser(ctx context.Context, user models.User) (sql.Result, error) {
+	ctx, span := tracing.Start(ctx, "TransactionalMiddleware.InsertUser")
+	defer span.End()
+
+	ctx, tx, err := t.begin(ctx)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	result, err := t.next.InsertUser(user)
+	result, err := t.next.InsertUser(ctx, user)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 
 	return result, nil
 }
 
-func (t *TransactionalMiddleware) DeleteUserById(id int) (sql.Result, error) {
-	tx, err := t.db.Begin()
+func (t *TransactionalMiddleware) DeleteUserById(ctx context.Context, id int) (sql.Result, error) {
+	ctx, span := tracing.Start(ctx, "TransactionalMiddleware.DeleteUserById")
+	defer span.End()
+
+	ctx, tx, err := t.begin(ctx)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	result, err := t.next.DeleteUserById(id)
+	result, err := t.next.DeleteUserById(ctx, id)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 
 	return result, nil
 }
 
-func (t *TransactionalMiddleware) UpdateUserById(id int, user models.User) (sql.Result, error) {
-	tx, err := t.db.Begin()
+func (t *TransactionalMiddleware) UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error) {
+	ctx, span := tracing.Start(ctx, "TransactionalMiddleware.UpdateUserById")
+	defer span.End()
+
+	ctx, tx, err := t.begin(ctx)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	result, err := t.next.UpdateUserById(id, user)
+	result, err := t.next.UpdateUserById(ctx, id, user)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 
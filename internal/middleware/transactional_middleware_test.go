@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"context"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
 	"github.com/DATA-DOG/go-sqlmock"
 	"regexp"
@@ -23,17 +25,17 @@ func TestTransactionalMiddleware_GetUsers(t *testing.T) {
 	}
 
 	userRows := sqlmock.NewRows([]string{
-		"id", "name", "email", "password", "registered_at",
-	}).AddRow(1, "John", "john@example.com", "secret", time.Now())
+		"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes",
+	}).AddRow(1, "John", "john@example.com", "secret", time.Now(), nil, "active", "{}")
 
 	mockUser.ExpectBegin()
 
-	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT * FROM users;")).
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users;")).
 		WillReturnRows(userRows)
 
 	mockUser.ExpectCommit()
 
-	_, err = repo.GetUsers()
+	_, err = repo.GetUsers(context.Background(), models.UserFilter{})
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when getting users", err)
 	}
@@ -57,22 +59,22 @@ func TestTransactionalMiddleware_GetUserById(t *testing.T) {
 		ID:           1,
 		Name:         "John",
 		Email:        "john@example.com",
-		Password:     "secret",
+		PasswordHash: "secret",
 		RegisteredAt: time.Now(),
 	}
 
-	userRows := sqlmock.NewRows([]string{"id", "name", "email", "password", "registered_at"}).
-		AddRow(mUser.ID, mUser.Name, mUser.Email, mUser.Password, mUser.RegisteredAt)
+	userRows := sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes"}).
+		AddRow(mUser.ID, mUser.Name, mUser.Email, mUser.PasswordHash, mUser.RegisteredAt, nil, "active", "{}")
 
 	mockUser.ExpectBegin()
 
-	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT * FROM users WHERE id = $1;")).
+	mockUser.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = $1;")).
 		WithArgs(1).
 		WillReturnRows(userRows)
 
 	mockUser.ExpectCommit()
 
-	us, err := repo.GetUserById(1)
+	us, err := repo.GetUserById(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when getting user by id", err)
 	}
@@ -98,19 +100,19 @@ func TestTransactionalMiddleware_InsertUser(t *testing.T) {
 		ID:           1,
 		Name:         "John",
 		Email:        "john@example.com",
-		Password:     "secret",
+		PasswordHash: "secret",
 		RegisteredAt: time.Now(),
 	}
 
 	mockUser.ExpectBegin()
 
-	mockUser.ExpectExec(regexp.QuoteMeta("INSERT INTO users (name, email, password, registered_at) VALUES ($1, $2, $3, $4);")).
-		WithArgs(mUser.Name, mUser.Email, mUser.Password, sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	mockUser.ExpectQuery(regexp.QuoteMeta("INSERT INTO users (name, email, password_hash, registered_at, email_verified_at, status, attributes) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;")).
+		WithArgs(mUser.Name, mUser.Email, mUser.PasswordHash, sqlmock.AnyArg(), nil, "pending", "{}").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
 	mockUser.ExpectCommit()
 
-	_, err = repo.InsertUser(mUser)
+	_, err = repo.InsertUser(context.Background(), mUser)
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when inserting user", err)
 	}
@@ -133,19 +135,19 @@ func TestTransactionalMiddleware_UpdateUser(t *testing.T) {
 		ID:           1,
 		Name:         "John",
 		Email:        "john@example.com",
-		Password:     "secret",
+		PasswordHash: "secret",
 		RegisteredAt: time.Now(),
 	}
 
 	mockUser.ExpectBegin()
 
-	mockUser.ExpectExec(regexp.QuoteMeta("UPDATE users SET name = $1, email = $2, password = $3, registered_at = $4 WHERE id = $5;")).
-		WithArgs(mUser.Name, mUser.Email, mUser.Password, sqlmock.AnyArg(), sqlmock.AnyArg()).
+	mockUser.ExpectExec(regexp.QuoteMeta("UPDATE users SET email_verified_at = CASE WHEN email = $2 THEN $5::timestamp END, name = $1, email = $2, password_hash = $3, status = COALESCE(NULLIF($6, ''), status) WHERE id = $4;")).
+		WithArgs(mUser.Name, mUser.Email, mUser.PasswordHash, sqlmock.AnyArg(), nil, "").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	mockUser.ExpectCommit()
 
-	_, err = repo.UpdateUserById(mUser.ID, mUser)
+	_, err = repo.UpdateUserById(context.Background(), mUser.ID, mUser)
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when inserting user", err)
 	}
@@ -168,7 +170,7 @@ func TestTransactionalMiddleware_DeleteUser(t *testing.T) {
 		ID:           1,
 		Name:         "John",
 		Email:        "john@example.com",
-		Password:     "secret",
+		PasswordHash: "secret",
 		RegisteredAt: time.Now(),
 	}
 
@@ -181,7 +183,7 @@ func TestTransactionalMiddleware_DeleteUser(t *testing.T) {
 	mockUser.ExpectCommit()
 
 	id := mUser.ID
-	_, err = repo.DeleteUserById(id)
+	_, err = repo.DeleteUserById(context.Background(), id)
 
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when inserting user", err)
@@ -190,3 +192,35 @@ func TestTransactionalMiddleware_DeleteUser(t *testing.T) {
 		t.Fatalf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestTransactionalMiddleware_JoinsCallerTransaction(t *testing.T) {
+	dbUser, mockUser, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer dbUser.Close()
+
+	repo := NewTransactionalMiddleware(dbUser, imp.NewPostgresRepoUser(dbUser))
+
+	mockUser.ExpectBegin()
+	mockUser.ExpectExec(regexp.QuoteMeta("DELETE FROM users WHERE id = $1;")).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mockUser.ExpectRollback()
+
+	tx, err := dbUser.Begin()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when beginning a transaction", err)
+	}
+	// No transaction of its own is begun or committed, the caller rolls back.
+	if _, err := repo.DeleteUserById(repository.WithTx(context.Background(), tx), 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when deleting user", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("an error '%s' was not expected when rolling back", err)
+	}
+
+	if err := mockUser.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/cache"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/metrics"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/lib/pq"
+)
+
+// UsersChangedChannel is the channel the users table trigger created by
+// db.Migrate notifies with the id of every inserted, updated or deleted row.
+const UsersChangedChannel = "users_changed"
+
+type CacheConfig struct {
+	Capacity    int
+	TTL         time.Duration
+	NegativeTTL time.Duration
+}
+
+func DefaultCacheConfig() CacheConfig {
+	return CacheConfig{
+		Capacity:    1000,
+		TTL:         time.Minute,
+		NegativeTTL: 5 * time.Second,
+	}
+}
+
+type CacheStats struct {
+	Hits          uint64
+	NegativeHits  uint64
+	Misses        uint64
+	Invalidations uint64
+	Evictions     uint64
+	Size          int
+}
+
+type cachedUser struct {
+	user     models.User
+	notFound bool
+}
+
+type UserCache struct {
+	cfg   CacheConfig
+	store *cache.LRU[int, cachedUser]
+
+	hits         atomic.Uint64
+	negativeHits atomic.Uint64
+	misses       atomic.Uint64
+
+	// mu orders the invalidations and the entries stored after a read, so
+	// a row read before an invalidation is not stored after it.
+	mu            sync.Mutex
+	invalidations uint64
+}
+
+func NewUserCache(cfg CacheConfig) *UserCache {
+	return &UserCache{cfg: cfg, store: cache.NewLRU[int, cachedUser](cfg.Capacity)}
+}
+
+func (c *UserCache) get(id int) (cachedUser, bool) {
+	entry, ok := c.store.Get(id)
+	switch {
+	case !ok:
+		c.misses.Add(1)
+	case entry.notFound:
+		c.negativeHits.Add(1)
+	default:
+		c.hits.Add(1)
+	}
+	return entry, ok
+}
+
+// version is taken before reading a user from the database and passed to
+// setUser or setNotFound, which drop what was read if an invalidation came
+// in meanwhile, e.g. a notification of a change by another process.
+func (c *UserCache) version() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.invalidations
+}
+
+func (c *UserCache) setUser(user models.User, version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.invalidations == version {
+		c.store.Set(user.ID, cachedUser{user: user}, c.cfg.TTL)
+	}
+}
+
+func (c *UserCache) setNotFound(id int, version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cfg.NegativeTTL > 0 && c.invalidations == version {
+		c.store.Set(id, cachedUser{notFound: true}, c.cfg.NegativeTTL)
+	}
+}
+
+func (c *UserCache) Invalidate(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store.Delete(id)
+	c.invalidations++
+}
+
+func (c *UserCache) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store.Purge()
+	c.invalidations++
+}
+
+func (c *UserCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:          c.hits.Load(),
+		NegativeHits:  c.negativeHits.Load(),
+		Misses:        c.misses.Load(),
+		Invalidations: c.version(),
+		Evictions:     c.store.Evictions(),
+		Size:          c.store.Len(),
+	}
+}
+
+// RegisterCacheStats exposes the statistics of c, which the status command
+// shows as well.
+func RegisterCacheStats(r *metrics.Registry, c *UserCache) {
+	counters := []struct {
+		name string
+		help string
+		fn   func(s CacheStats) uint64
+	}{
+		{"users_cache_hits_total", "Number of user lookups served from the cache.",
+			func(s CacheStats) uint64 { return s.Hits }},
+		{"users_cache_negative_hits_total", "Number of lookups of missing users served from the cache.",
+			func(s CacheStats) uint64 { return s.NegativeHits }},
+		{"users_cache_misses_total", "Number of user lookups read from the database.",
+			func(s CacheStats) uint64 { return s.Misses }},
+		{"users_cache_invalidations_total", "Number of invalidations of cached users.",
+			func(s CacheStats) uint64 { return s.Invalidations }},
+		{"users_cache_evictions_total", "Number of cached users evicted to make room.",
+			func(s CacheStats) uint64 { return s.Evictions }},
+	}
+	for _, counter := range counters {
+		fn := counter.fn
+		r.NewCounterFunc(counter.name, counter.help, func() float64 { return float64(fn(c.Stats())) })
+	}
+	r.NewGaugeFunc("users_cache_entries", "Number of cached users.", func() float64 { return float64(c.store.Len()) })
+}
+
+// Listen subscribes to UsersChangedChannel and drops the cached entries of
+// users changed by other processes until ctx is canceled. Notifications may
+// be lost while the listener reconnects, so the whole cache is dropped then.
+func (c *UserCache) Listen(ctx context.Context, connString string) error {
+	listener := pq.NewListener(connString, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("[WARN] users cache listener: %v", err)
+		}
+	})
+	if err := listener.Listen(UsersChangedChannel); err != nil {
+		listener.Close()
+		return err
+	}
+
+	go func() {
+		defer listener.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case n := <-listener.Notify:
+				if n == nil {
+					c.InvalidateAll()
+					continue
+				}
+				id, err := strconv.Atoi(n.Extra)
+				if err != nil {
+					c.InvalidateAll()
+					continue
+				}
+				c.Invalidate(id)
+			case <-time.After(90 * time.Second):
+				go listener.Ping()
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrSubscriberTooSlow is reported by a subscription that was dropped
+// because its buffer was full.
+var ErrSubscriberTooSlow = errors.New("the subscriber fell too far behind")
+
+type UserEventType int
+
+const (
+	UserCreated UserEventType = iota + 1
+	UserUpdated
+	UserDeleted
+)
+
+func (t UserEventType) String() string {
+	switch t {
+	case UserCreated:
+		return "created"
+	case UserUpdated:
+		return "updated"
+	case UserDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// UserEvent reports a change of a user made through the events middleware.
+type UserEvent struct {
+	Type UserEventType
+	ID   int
+}
+
+// UserEvents fans the events published by the events middleware out to the
+// subscribers. Publishing never blocks: a subscriber that falls behind is
+// dropped, its channel is closed and Subscription.Err reports it.
+type UserEvents struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func NewUserEvents() *UserEvents {
+	return &UserEvents{subs: make(map[*Subscription]struct{})}
+}
+
+type Subscription struct {
+	events *UserEvents
+	c      chan UserEvent
+	// dropped is set before c is closed by Publish.
+	dropped bool
+	closed  bool
+}
+
+// Subscribe returns a subscription receiving the events published from now
+// on, buffer is how many events it may fall behind.
+func (e *UserEvents) Subscribe(buffer int) *Subscription {
+	s := &Subscription{events: e, c: make(chan UserEvent, buffer)}
+	e.mu.Lock()
+	e.subs[s] = struct{}{}
+	e.mu.Unlock()
+	return s
+}
+
+func (e *UserEvents) Publish(event UserEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for s := range e.subs {
+		select {
+		case s.c <- event:
+		default:
+			s.dropped = true
+			s.close()
+		}
+	}
+}
+
+// Events returns the channel of events, it is closed once the subscription
+// is canceled or dropped.
+func (s *Subscription) Events() <-chan UserEvent {
+	return s.c
+}
+
+// Err returns ErrSubscriberTooSlow once the subscription was dropped.
+func (s *Subscription) Err() error {
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+	if s.dropped {
+		return ErrSubscriberTooSlow
+	}
+	return nil
+}
+
+// Cancel stops the subscription, it is safe to call more than once.
+func (s *Subscription) Cancel() {
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+	s.close()
+}
+
+// close must be called with the lock held.
+func (s *Subscription) close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(s.events.subs, s)
+	close(s.c)
+}
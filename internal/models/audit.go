@@ -0,0 +1,34 @@
+package models
+
+import "encoding/json"
+
+// Operations recorded in an AuditRecord.
+const (
+	AuditInsert = "insert"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditRecord describes a change to a user with enough detail to undo it.
+type AuditRecord struct {
+	Op     string `json:"op"`
+	UserID int    `json:"user_id"`
+	// Before is the user as it was before an update or delete.
+	Before *User `json:"before,omitempty"`
+	// Batch groups the records of one command, e.g. a bulk delete.
+	Batch string `json:"batch,omitempty"`
+	// Reason is why the change was made, e.g. why a user was suspended.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Audit decodes the record kept in the log details, ok is false for logs
+// without one.
+func (l Log) Audit() (record AuditRecord, ok bool) {
+	if l.Details == "" {
+		return AuditRecord{}, false
+	}
+	if err := json.Unmarshal([]byte(l.Details), &record); err != nil || record.Op == "" {
+		return AuditRecord{}, false
+	}
+	return record, true
+}
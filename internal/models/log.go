@@ -6,4 +6,8 @@ type Log struct {
 	Id         int64
 	LogTime    time.Time
 	LogMessage string
+	// Details is a JSON AuditRecord for logs of changes, empty otherwise.
+	Details string
+	// Actor is the name of the operator who acted, empty when unknown.
+	Actor string
 }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Lockout counts the failed logins with an email address. After too many
+// of them logins are refused until LockedUntil.
+type Lockout struct {
+	Email       string
+	Failures    int
+	LockedUntil time.Time
+	// FailedAt is when the last failure was counted.
+	FailedAt time.Time
+}
+
+// Locked reports whether logins are refused at now.
+func (l Lockout) Locked(now time.Time) bool {
+	return now.Before(l.LockedUntil)
+}
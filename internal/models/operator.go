@@ -0,0 +1,90 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Role is what an operator may do, each role may do everything the roles
+// before it may.
+type Role string
+
+const (
+	// RoleViewer reads users and the audit log.
+	RoleViewer Role = "viewer"
+	// RoleEditor also inserts, updates and deletes users.
+	RoleEditor Role = "editor"
+	// RoleAdmin also manages operators.
+	RoleAdmin Role = "admin"
+)
+
+// Roles lists the roles from the least to the most privileged.
+var Roles = []Role{RoleViewer, RoleEditor, RoleAdmin}
+
+func ParseRole(s string) (Role, error) {
+	for _, role := range Roles {
+		if string(role) == s {
+			return role, nil
+		}
+	}
+	return "", fmt.Errorf("unknown role %q, expected viewer, editor or admin", s)
+}
+
+func (r Role) rank() int {
+	for i, role := range Roles {
+		if role == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// Allows reports whether r may do what required may.
+func (r Role) Allows(required Role) bool {
+	return r.rank() >= 0 && r.rank() >= required.rank()
+}
+
+// Operator is a person or service working on the users, as opposed to the
+// users themselves.
+type Operator struct {
+	ID   int
+	Name string
+	Role Role
+	// PasswordHash is the bcrypt hash of the password, empty for operators
+	// that only use API tokens.
+	PasswordHash string
+	CreatedAt    time.Time
+}
+
+// Validate checks the operator against the limits of the operators table.
+func (o Operator) Validate() error {
+	var errs ValidationError
+	switch {
+	case strings.TrimSpace(o.Name) == "":
+		errs = append(errs, FieldError{Field: "name", Message: "is required"})
+	case utf8.RuneCountInString(o.Name) > 50:
+		errs = append(errs, FieldError{Field: "name", Message: "must be at most 50 characters"})
+	case strings.ContainsAny(o.Name, " \t\n"):
+		errs = append(errs, FieldError{Field: "name", Message: "must not contain spaces"})
+	}
+	if o.Role.rank() < 0 {
+		errs = append(errs, FieldError{Field: "role", Message: "must be viewer, editor or admin"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// APIToken lets an operator call the server APIs. Only the hash of the token
+// is stored, the token itself is shown once when it is created.
+type APIToken struct {
+	ID         int
+	OperatorID int
+	// Name tells the tokens of an operator apart, e.g. "ci".
+	Name      string
+	Hash      string
+	CreatedAt time.Time
+}
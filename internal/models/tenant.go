@@ -0,0 +1,33 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// DefaultTenant is the tenant of the users and logs from before tenants,
+// and of sessions that do not name one.
+const DefaultTenant = "default"
+
+// DefaultTenantID is the ID the migrations give DefaultTenant.
+const DefaultTenantID = 1
+
+// Tenant is a customer whose users and logs are kept apart from those of
+// the other tenants.
+type Tenant struct {
+	ID        int
+	Name      string
+	CreatedAt time.Time
+}
+
+// tenantName keeps tenant names safe to pass in a connection string.
+var tenantName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// ValidateTenantName checks that name may name a tenant.
+func ValidateTenantName(name string) error {
+	if !tenantName.MatchString(name) {
+		return fmt.Errorf("invalid tenant name %q, expected 1 to 50 lowercase letters, digits, _ or - starting with a letter or digit", name)
+	}
+	return nil
+}
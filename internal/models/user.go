@@ -1,11 +1,64 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type User struct {
-	ID           int
-	Name         string
-	Email        string
-	Password     string
-	RegisteredAt time.Time
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	// Password is a new password in plain text, which is hashed into
+	// PasswordHash before the user is stored. It is never read back.
+	Password string `json:"password,omitempty"`
+	// PasswordHash is the bcrypt hash of the password of the user.
+	PasswordHash string    `json:"password_hash,omitempty"`
+	RegisteredAt time.Time `json:"registered_at"`
+	// EmailVerifiedAt is when the user proved to own Email, zero until then.
+	// Changing the email clears it.
+	EmailVerifiedAt time.Time `json:"email_verified_at,omitzero"`
+	// Status is where the user is in the lifecycle, users inserted without
+	// one are pending.
+	Status UserStatus `json:"status"`
+	// Attributes are stored by InsertUser, UpdateUserById keeps the current
+	// ones. They change through IRepositoryUserAttribute.
+	Attributes Attributes `json:"attributes,omitempty"`
+}
+
+// UserFilter selects the users returned by GetUsers, the zero value selects
+// every user.
+type UserFilter struct {
+	// Email keeps the user with this email, compared case-insensitively.
+	Email  string
+	Status UserStatus
+	// Role and Group keep the users who have the role or are members of the
+	// group with that name.
+	Role  string
+	Group string
+	// Attributes keeps the users whose attributes have all these values.
+	Attributes Attributes
+}
+
+// Matches reports whether user has the status and attributes of the filter.
+// The user does not know its roles and groups, the repositories check those.
+func (f UserFilter) Matches(user User) bool {
+	if f.Email != "" && !strings.EqualFold(user.Email, f.Email) {
+		return false
+	}
+	if f.Status != "" && user.Status != f.Status {
+		return false
+	}
+	for key, value := range f.Attributes {
+		current, ok := user.Attributes[key]
+		if !ok || !attributeEqual(current, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// EmailVerified reports whether the user proved to own their email.
+func (u User) EmailVerified() bool {
+	return !u.EmailVerifiedAt.IsZero()
 }
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// UserRole is a role of the managed users, e.g. admin, which the
+// applications built on the users check to decide what a user may do. Unlike
+// Role it gives no rights in this tool.
+type UserRole struct {
+	ID          int
+	Name        string
+	Description string
+	CreatedAt   time.Time
+}
+
+// Group is a named set of users, e.g. a team.
+type Group struct {
+	ID          int
+	Name        string
+	Description string
+	CreatedAt   time.Time
+}
+
+// Validate checks the role against the limits of the roles table.
+func (r UserRole) Validate() error {
+	return validateAccess(r.Name, r.Description)
+}
+
+// Validate checks the group against the limits of the groups table.
+func (g Group) Validate() error {
+	return validateAccess(g.Name, g.Description)
+}
+
+func validateAccess(name, description string) error {
+	var errs ValidationError
+	switch {
+	case strings.TrimSpace(name) == "":
+		errs = append(errs, FieldError{Field: "name", Message: "is required"})
+	case utf8.RuneCountInString(name) > 50:
+		errs = append(errs, FieldError{Field: "name", Message: "must be at most 50 characters"})
+	case strings.ContainsAny(name, " \t\n"):
+		errs = append(errs, FieldError{Field: "name", Message: "must not contain spaces"})
+	}
+	if utf8.RuneCountInString(description) > 255 {
+		errs = append(errs, FieldError{Field: "description", Message: fmt.Sprintf("must be at most %d characters", 255)})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
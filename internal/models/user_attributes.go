@@ -0,0 +1,65 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+)
+
+// Attributes are the custom fields of a user, e.g. phone, department or
+// locale, keyed by name. The values are anything JSON can hold.
+type Attributes map[string]any
+
+// String returns the attributes as a JSON object.
+func (a Attributes) String() string {
+	if len(a) == 0 {
+		return "{}"
+	}
+	b, err := json.Marshal(map[string]any(a))
+	if err != nil {
+		return fmt.Sprintf("%v", map[string]any(a))
+	}
+	return string(b)
+}
+
+// Clone returns a copy of the attributes that can be changed without
+// changing a.
+func (a Attributes) Clone() Attributes {
+	if a == nil {
+		return nil
+	}
+	return maps.Clone(a)
+}
+
+var attributeKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+
+// ValidateAttributeKey checks that key can name an attribute in every
+// backend: 1 to 50 letters, digits, underscores or dashes.
+func ValidateAttributeKey(key string) error {
+	if !attributeKeyRe.MatchString(key) {
+		return fmt.Errorf("invalid attribute name %q, expected 1 to 50 letters, digits, _ or -", key)
+	}
+	return nil
+}
+
+// ParseAttributeValue reads the value of an attribute as JSON, so 30, true
+// and {"a":1} keep their type, and as a plain string when it is no JSON.
+func ParseAttributeValue(s string) any {
+	var value any
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		return s
+	}
+	return value
+}
+
+// attributeEqual reports whether the attribute values a and b are the same
+// JSON value.
+func attributeEqual(a, b any) bool {
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	jb, err := json.Marshal(b)
+	return err == nil && string(ja) == string(jb)
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// UserStatus is the state of a user account. Only active users may log in.
+type UserStatus string
+
+const (
+	// StatusPending users were created but not activated yet, e.g. until
+	// they verify their email.
+	StatusPending UserStatus = "pending"
+	StatusActive  UserStatus = "active"
+	// StatusSuspended users were disabled for a while, e.g. for a policy
+	// violation.
+	StatusSuspended UserStatus = "suspended"
+	// StatusLocked users were disabled for their own protection, e.g. after
+	// a compromised password.
+	StatusLocked UserStatus = "locked"
+	// StatusDeleted users are kept for the record but never come back.
+	StatusDeleted UserStatus = "deleted"
+)
+
+// UserStatuses lists every status in the order of the lifecycle.
+var UserStatuses = []UserStatus{StatusPending, StatusActive, StatusSuspended, StatusLocked, StatusDeleted}
+
+// userTransitions are the statuses each status may change to.
+var userTransitions = map[UserStatus][]UserStatus{
+	StatusPending:   {StatusActive, StatusDeleted},
+	StatusActive:    {StatusSuspended, StatusLocked, StatusDeleted},
+	StatusSuspended: {StatusActive, StatusDeleted},
+	StatusLocked:    {StatusActive, StatusDeleted},
+}
+
+// ParseUserStatus returns the status named s.
+func ParseUserStatus(s string) (UserStatus, error) {
+	status := UserStatus(s)
+	if !slices.Contains(UserStatuses, status) {
+		return "", fmt.Errorf("unknown status %q, expected one of %s", s, joinStatuses(UserStatuses))
+	}
+	return status, nil
+}
+
+// CanBecome reports whether the lifecycle allows changing from s to status.
+func (s UserStatus) CanBecome(status UserStatus) bool {
+	return slices.Contains(userTransitions[s], status)
+}
+
+// TransitionError is returned for a status change the lifecycle does not
+// allow.
+type TransitionError struct {
+	From, To UserStatus
+}
+
+func (e *TransitionError) Error() string {
+	next := userTransitions[e.From]
+	if len(next) == 0 {
+		return fmt.Sprintf("%s users cannot change status", e.From)
+	}
+	return fmt.Sprintf("%s users cannot become %s, only %s", e.From, e.To, joinStatuses(next))
+}
+
+// Transition returns the user with status, or a TransitionError if the
+// lifecycle does not allow the change.
+func (u User) Transition(status UserStatus) (User, error) {
+	if !u.Status.CanBecome(status) {
+		return User{}, &TransitionError{From: u.Status, To: status}
+	}
+	u.Status = status
+	return u, nil
+}
+
+func joinStatuses(statuses []UserStatus) string {
+	names := make([]string, len(statuses))
+	for i, s := range statuses {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ", ")
+}
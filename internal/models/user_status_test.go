@@ -0,0 +1,35 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUser_Transition(t *testing.T) {
+	user := User{ID: 1, Status: StatusPending}
+	for _, status := range []UserStatus{StatusActive, StatusSuspended, StatusActive, StatusLocked, StatusActive, StatusDeleted} {
+		next, err := user.Transition(status)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when changing %s to %s", err, user.Status, status)
+		}
+		user = next
+	}
+
+	var terr *TransitionError
+	if _, err := user.Transition(StatusActive); !errors.As(err, &terr) || terr.From != StatusDeleted {
+		t.Fatalf("got '%v', expected a TransitionError for a deleted user", err)
+	}
+	if _, err := (User{Status: StatusPending}).Transition(StatusSuspended); err == nil ||
+		err.Error() != "pending users cannot become suspended, only active, deleted" {
+		t.Fatalf("unexpected error '%v'", err)
+	}
+}
+
+func TestParseUserStatus(t *testing.T) {
+	if status, err := ParseUserStatus("locked"); err != nil || status != StatusLocked {
+		t.Fatalf("got %q, '%v', expected locked", status, err)
+	}
+	if _, err := ParseUserStatus("banned"); err == nil {
+		t.Fatalf("expected an error for an unknown status")
+	}
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// TokenPurpose is what a UserToken may be used for.
+type TokenPurpose string
+
+const (
+	// PurposeVerifyEmail tokens prove that a user owns their email.
+	PurposeVerifyEmail TokenPurpose = "verify-email"
+	// PurposeResetPassword tokens let a user set a new password.
+	PurposeResetPassword TokenPurpose = "reset-password"
+)
+
+// UserToken is a single-use token mailed to a user. Only the SHA-256 hash of
+// the token is stored.
+type UserToken struct {
+	Purpose TokenPurpose
+	Hash    string
+	UserID  int
+	// Email is the address the token was sent to, an email verification
+	// only counts while the user still has it.
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// UsedAt is when the token was consumed, zero while unused.
+	UsedAt time.Time
+}
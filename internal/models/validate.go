@@ -0,0 +1,69 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes why the value of a single field is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every invalid field of a value.
+type ValidationError []FieldError
+
+func (v ValidationError) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Field + " " + e.Message
+	}
+	return "invalid " + strings.Join(msgs, ", ")
+}
+
+// Field returns the message for field, empty if the field is valid.
+func (v ValidationError) Field(field string) string {
+	for _, e := range v {
+		if e.Field == field {
+			return e.Message
+		}
+	}
+	return ""
+}
+
+// Validate checks the user against the limits of the users table, it
+// returns a ValidationError or nil.
+func (u User) Validate() error {
+	var errs ValidationError
+	check := func(field, value string, maxLen int) bool {
+		switch {
+		case strings.TrimSpace(value) == "":
+			errs = append(errs, FieldError{Field: field, Message: "is required"})
+		case utf8.RuneCountInString(value) > maxLen:
+			errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf("must be at most %d characters", maxLen)})
+		default:
+			return true
+		}
+		return false
+	}
+	check("name", u.Name, 20)
+	if check("email", u.Email, 50) {
+		if addr, err := mail.ParseAddress(u.Email); err != nil || addr.Address != u.Email {
+			errs = append(errs, FieldError{Field: "email", Message: "is not a valid address"})
+		}
+	}
+	// A stored user keeps the hash of its password unless a new one is set.
+	// bcrypt only uses the first 72 bytes of a password.
+	if u.Password != "" || u.PasswordHash == "" {
+		if check("password", u.Password, 72) && len(u.Password) > 72 {
+			errs = append(errs, FieldError{Field: "password", Message: "must be at most 72 bytes"})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
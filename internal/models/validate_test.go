@@ -0,0 +1,41 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUser_Validate(t *testing.T) {
+	valid := User{Name: "Ann Lee", Email: "ann@example.com", Password: "secret"}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("an error '%s' was not expected for a valid user", err)
+	}
+
+	invalid := User{Name: strings.Repeat("n", 21), Email: "Ann <ann@example.com>", Password: " "}
+	var verr ValidationError
+	if err := invalid.Validate(); !errors.As(err, &verr) {
+		t.Fatalf("got error '%v', expected a ValidationError", err)
+	}
+	if len(verr) != 3 || verr.Field("name") != "must be at most 20 characters" ||
+		verr.Field("email") != "is not a valid address" || verr.Field("password") != "is required" {
+		t.Fatalf("unexpected validation errors %+v", verr)
+	}
+	if verr.Error() != "invalid name must be at most 20 characters, email is not a valid address, password is required" {
+		t.Fatalf("unexpected message %q", verr.Error())
+	}
+}
+
+func TestUser_ValidatePassword(t *testing.T) {
+	// A stored user keeps its hash without a new password.
+	stored := User{Name: "Ann Lee", Email: "ann@example.com", PasswordHash: "$2a$10$hash"}
+	if err := stored.Validate(); err != nil {
+		t.Fatalf("an error '%s' was not expected for a stored user", err)
+	}
+
+	var verr ValidationError
+	long := User{Name: "Ann Lee", Email: "ann@example.com", Password: strings.Repeat("é", 40)}
+	if err := long.Validate(); !errors.As(err, &verr) || verr.Field("password") != "must be at most 72 bytes" {
+		t.Fatalf("got error '%v', expected the password to be too long for bcrypt", err)
+	}
+}
@@ -0,0 +1,172 @@
+// Package pgtest runs a throwaway Postgres server for tests, using the
+// initdb and pg_ctl binaries of a local installation.
+package pgtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/db"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// ErrUnavailable is returned by NewCluster when no server can be started in
+// this environment, tests should be skipped then.
+var ErrUnavailable = errors.New("pgtest: postgres is not available")
+
+// Cluster is a Postgres server with its data directory in a temporary directory.
+type Cluster struct {
+	bin  string
+	dir  string
+	port int
+	seq  atomic.Int64
+}
+
+// binDir finds the directory holding initdb and pg_ctl, $PGTEST_BIN wins
+// over $PATH and the usual installation directories.
+func binDir() (string, error) {
+	if dir := os.Getenv("PGTEST_BIN"); dir != "" {
+		return dir, nil
+	}
+	if path, err := exec.LookPath("pg_ctl"); err == nil {
+		return filepath.Dir(path), nil
+	}
+	var candidates []string
+	for _, pattern := range []string{
+		"/usr/lib/postgresql/*/bin",
+		"/usr/pgsql-*/bin",
+		"/usr/local/pgsql/bin",
+		"/opt/homebrew/opt/postgresql*/bin",
+		"/usr/local/opt/postgresql*/bin",
+	} {
+		matches, _ := filepath.Glob(pattern)
+		candidates = append(candidates, matches...)
+	}
+	// Prefer the newest version, Glob sorts e.g. 15 before 16.
+	slices.Reverse(candidates)
+	for _, dir := range candidates {
+		if _, err := os.Stat(filepath.Join(dir, "initdb")); err == nil {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("%w: initdb and pg_ctl not found, set PGTEST_BIN", ErrUnavailable)
+}
+
+// NewCluster initializes and starts a new server listening on a free local
+// port. Close stops it and removes its files.
+func NewCluster() (*Cluster, error) {
+	bin, err := binDir()
+	if err != nil {
+		return nil, err
+	}
+	if os.Geteuid() == 0 {
+		return nil, fmt.Errorf("%w: postgres cannot be run as root", ErrUnavailable)
+	}
+	// The socket path has to stay short, so the default temp dir is used
+	// instead of t.TempDir.
+	dir, err := os.MkdirTemp("", "pgtest")
+	if err != nil {
+		return nil, err
+	}
+	c := &Cluster{bin: bin, dir: dir}
+
+	err = c.run("initdb", "-D", c.dataDir(), "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync")
+	if err == nil {
+		c.port, err = freePort()
+	}
+	if err == nil {
+		err = c.Start()
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Cluster) dataDir() string {
+	return filepath.Join(c.dir, "data")
+}
+
+func (c *Cluster) run(name string, args ...string) error {
+	out, err := exec.Command(filepath.Join(c.bin, name), args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("pgtest: %s failed: %w\n%s", name, err, out)
+	}
+	return nil
+}
+
+// Start starts the server again after Stop.
+func (c *Cluster) Start() error {
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses=127.0.0.1 -c fsync=off", c.port, c.dir)
+	return c.run("pg_ctl", "-D", c.dataDir(), "-l", filepath.Join(c.dir, "server.log"), "-o", options, "-w", "start")
+}
+
+// Stop shuts the server down immediately, keeping its data.
+func (c *Cluster) Stop() error {
+	return c.run("pg_ctl", "-D", c.dataDir(), "-m", "immediate", "-w", "stop")
+}
+
+// Close stops the server and removes the data directory.
+func (c *Cluster) Close() error {
+	err := c.Stop()
+	if rmErr := os.RemoveAll(c.dir); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// Config returns the connection config of database dbName on the server.
+func (c *Cluster) Config(dbName string) db.Config {
+	cfg := db.DefaultConfig()
+	cfg.Host = "127.0.0.1"
+	cfg.Port = strconv.Itoa(c.port)
+	cfg.User = "postgres"
+	cfg.DbName = dbName
+	return cfg
+}
+
+// NewDatabase creates an empty migrated database, which is closed when the
+// test finishes. Databases are not dropped, the cluster is removed as a whole.
+func (c *Cluster) NewDatabase(t testing.TB) (*sql.DB, db.Config) {
+	t.Helper()
+	ctx := context.Background()
+	name := fmt.Sprintf("test_%d", c.seq.Add(1))
+
+	admin, err := db.Connect(ctx, c.Config("postgres"))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when connecting to the cluster", err)
+	}
+	defer admin.Close()
+	if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+name+";"); err != nil {
+		t.Fatalf("an error '%s' was not expected when creating database %s", err, name)
+	}
+
+	cfg := c.Config(name)
+	con, err := db.Connect(ctx, cfg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when connecting to %s", err, name)
+	}
+	t.Cleanup(func() { con.Close() })
+	if err := db.Migrate(con, db.DriverPostgres); err != nil {
+		t.Fatalf("an error '%s' was not expected when migrating %s", err, name)
+	}
+	return con, cfg
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,123 @@
+// Package profile manages the custom attributes of the managed users, e.g.
+// their phone, department or locale, optionally checked against a JSON
+// Schema.
+package profile
+
+import (
+	"context"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"log"
+	"time"
+)
+
+// maxLoggedValue is how much of a value the audit log keeps, its messages
+// are at most 255 characters.
+const maxLoggedValue = 100
+
+// Service keeps the attributes of the users. Viewers may read them, editors
+// change them, and every change is recorded in logs.
+type Service struct {
+	attributes repository.IRepositoryUserAttribute
+	logs       repository.IRepositoryLog
+	// schema checks the attributes after every change, nil accepts any.
+	schema *Schema
+	now    func() time.Time
+}
+
+func NewService(attributes repository.IRepositoryUserAttribute, logs repository.IRepositoryLog, schema *Schema) *Service {
+	return &Service{attributes: attributes, logs: logs, schema: schema, now: time.Now}
+}
+
+// Attributes returns the attributes of the user with userID, sql.ErrNoRows
+// if there is no such user.
+func (s *Service) Attributes(ctx context.Context, userID int) (models.Attributes, error) {
+	if err := auth.Require(ctx, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.attributes.GetAttributes(ctx, userID)
+}
+
+// Set sets the attribute key of the user with userID to value. The
+// attributes must still match the schema afterwards.
+func (s *Service) Set(ctx context.Context, userID int, key string, value any) error {
+	current, err := s.current(ctx, userID, key)
+	if err != nil {
+		return err
+	}
+	current[key] = value
+	if err := s.check(current); err != nil {
+		return err
+	}
+	if _, err := s.attributes.SetAttribute(ctx, userID, key, value); err != nil {
+		return err
+	}
+	s.record(ctx, fmt.Sprintf("Attribute %s of user with id %d set to %s", key, userID, loggedValue(value)))
+	return nil
+}
+
+// Unset removes the attribute key of the user with userID. It reports false
+// if the user does not have it.
+func (s *Service) Unset(ctx context.Context, userID int, key string) (bool, error) {
+	current, err := s.current(ctx, userID, key)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := current[key]; !ok {
+		return false, nil
+	}
+	delete(current, key)
+	if err := s.check(current); err != nil {
+		return false, err
+	}
+	if _, err := s.attributes.UnsetAttribute(ctx, userID, key); err != nil {
+		return false, err
+	}
+	s.record(ctx, fmt.Sprintf("Attribute %s of user with id %d unset", key, userID))
+	return true, nil
+}
+
+// current returns a copy of the attributes of the user with userID that can
+// be changed, if the operator of ctx is an editor and key is valid.
+func (s *Service) current(ctx context.Context, userID int, key string) (models.Attributes, error) {
+	if err := auth.Require(ctx, models.RoleEditor); err != nil {
+		return nil, err
+	}
+	if err := models.ValidateAttributeKey(key); err != nil {
+		return nil, err
+	}
+	current, err := s.attributes.GetAttributes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if current = current.Clone(); current == nil {
+		current = make(models.Attributes)
+	}
+	return current, nil
+}
+
+func (s *Service) check(attributes models.Attributes) error {
+	if s.schema == nil {
+		return nil
+	}
+	return s.schema.Validate(attributes)
+}
+
+// record writes msg to the audit log on behalf of the operator of ctx.
+func (s *Service) record(ctx context.Context, msg string) {
+	entry := models.Log{LogTime: s.now(), LogMessage: msg, Actor: auth.Actor(ctx)}
+	if _, err := s.logs.InsertLog(ctx, entry); err != nil {
+		log.Printf("[WARN] failed to insert log: %v", err)
+	}
+}
+
+// loggedValue returns value as JSON, shortened to maxLoggedValue runes.
+func loggedValue(value any) string {
+	v := []rune(encode(value))
+	if len(v) > maxLoggedValue {
+		return string(v[:maxLoggedValue-3]) + "..."
+	}
+	return string(v)
+}
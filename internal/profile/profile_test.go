@@ -0,0 +1,75 @@
+package profile
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"testing"
+)
+
+func TestService_SetAndUnset(t *testing.T) {
+	users := imp.NewMemoryRepoUser()
+	logs := imp.NewMemoryRepoLog()
+	schema, err := ParseSchema([]byte(`{"properties": {"age": {"type": "integer"}}, "required": ["department"]}`))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when parsing the schema", err)
+	}
+	s := NewService(imp.NewMemoryRepoUserAttribute(users), logs, schema)
+	editor := auth.WithOperator(context.Background(), models.Operator{Name: "ed", Role: models.RoleEditor})
+	viewer := auth.WithOperator(context.Background(), models.Operator{Name: "vera", Role: models.RoleViewer})
+	if _, err := users.InsertUser(editor, models.User{Name: "Ann", Email: "ann@example.com", Password: "secret"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting a user", err)
+	}
+
+	if err := s.Set(viewer, 1, "department", "ops"); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("got '%v' when a viewer sets an attribute, expected ErrForbidden", err)
+	}
+	if err := s.Set(editor, 1, "age", 30); err == nil || err.Error() != "invalid attributes.department is required" {
+		t.Fatalf("got '%v', expected the missing department to be reported", err)
+	}
+	if err := s.Set(editor, 1, "department", "ops"); err != nil {
+		t.Fatalf("an error '%s' was not expected when setting an attribute", err)
+	}
+	if err := s.Set(editor, 1, "age", "thirty"); err == nil || err.Error() != "invalid attributes.age must be integer" {
+		t.Fatalf("got '%v', expected the age to be rejected", err)
+	}
+	if err := s.Set(editor, 1, "bad key", 1); err == nil {
+		t.Fatalf("expected an error for an invalid attribute name")
+	}
+	if err := s.Set(editor, 9, "department", "ops"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("got '%v' for a missing user, expected sql.ErrNoRows", err)
+	}
+	if err := s.Set(editor, 1, "age", 30); err != nil {
+		t.Fatalf("an error '%s' was not expected when setting an attribute", err)
+	}
+	if _, err := s.Unset(editor, 1, "department"); err == nil {
+		t.Fatalf("expected an error when unsetting a required attribute")
+	}
+	for i, expected := range []bool{true, false} {
+		unset, err := s.Unset(editor, 1, "age")
+		if err != nil || unset != expected {
+			t.Fatalf("got %v, '%v' for unset %d, expected %v", unset, err, i+1, expected)
+		}
+	}
+	attributes, err := s.Attributes(viewer, 1)
+	if err != nil || attributes.String() != `{"department":"ops"}` {
+		t.Fatalf("got %v, '%v', expected only the department", attributes, err)
+	}
+
+	entries, err := logs.GetLogs(context.Background())
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting logs", err)
+	}
+	expected := []string{"Attribute department of user with id 1 set to \"ops\"", "Attribute age of user with id 1 set to 30", "Attribute age of user with id 1 unset"}
+	if len(entries) != len(expected) {
+		t.Fatalf("got %+v, expected %q", entries, expected)
+	}
+	for i, entry := range entries {
+		if entry.LogMessage != expected[i] || entry.Actor != "ed" {
+			t.Fatalf("got %+v, expected %q by ed", entry, expected[i])
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"maps"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a JSON Schema the attributes of every user must match. It
+// understands the keywords most schemas of flat profile fields need:
+// type, enum, const, properties, required, additionalProperties, items,
+// minItems, maxItems, minLength, maxLength, pattern, minimum, maximum,
+// exclusiveMinimum and exclusiveMaximum. Other validation keywords are
+// rejected when the schema is parsed rather than silently ignored.
+type Schema struct {
+	// reject is the false schema, which nothing matches.
+	reject bool
+
+	types      []string
+	enum       []any
+	constant   *any
+	properties map[string]*Schema
+	required   []string
+	// additional checks the properties missing from properties.
+	additional *Schema
+	items      *Schema
+
+	minItems, maxItems   *int
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+
+	minimum, maximum                   *float64
+	exclusiveMinimum, exclusiveMaximum *float64
+}
+
+// annotations are keywords that describe a schema without constraining it.
+var annotations = []string{"$schema", "$id", "$comment", "title", "description", "default", "examples", "deprecated", "readOnly", "writeOnly"}
+
+var schemaTypes = []string{"object", "array", "string", "number", "integer", "boolean", "null"}
+
+// LoadSchema reads the schema in the file at path.
+func LoadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := ParseSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return schema, nil
+}
+
+// ParseSchema parses a JSON Schema document.
+func ParseSchema(data []byte) (*Schema, error) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return compile(doc, "#")
+}
+
+func compile(doc any, path string) (*Schema, error) {
+	switch doc := doc.(type) {
+	case bool:
+		return &Schema{reject: !doc}, nil
+	case map[string]any:
+		s := &Schema{}
+		for _, keyword := range slices.Sorted(maps.Keys(doc)) {
+			if err := s.set(keyword, doc[keyword], path+"/"+keyword); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("invalid schema at %s: expected an object or a boolean", path)
+	}
+}
+
+// set applies keyword of the schema at path.
+func (s *Schema) set(keyword string, value any, path string) error {
+	invalid := func(expected string) error {
+		return fmt.Errorf("invalid schema at %s: expected %s", path, expected)
+	}
+	var err error
+	switch keyword {
+	case "type":
+		switch value := value.(type) {
+		case string:
+			s.types = []string{value}
+		case []any:
+			for _, t := range value {
+				name, ok := t.(string)
+				if !ok {
+					return invalid("a type name")
+				}
+				s.types = append(s.types, name)
+			}
+		default:
+			return invalid("a type name or a list of them")
+		}
+		for _, t := range s.types {
+			if !slices.Contains(schemaTypes, t) {
+				return invalid("one of " + strings.Join(schemaTypes, ", "))
+			}
+		}
+	case "enum":
+		values, ok := value.([]any)
+		if !ok {
+			return invalid("a list")
+		}
+		s.enum = values
+	case "const":
+		s.constant = &value
+	case "properties":
+		props, ok := value.(map[string]any)
+		if !ok {
+			return invalid("an object")
+		}
+		s.properties = make(map[string]*Schema, len(props))
+		for _, name := range slices.Sorted(maps.Keys(props)) {
+			if s.properties[name], err = compile(props[name], path+"/"+name); err != nil {
+				return err
+			}
+		}
+	case "required":
+		names, ok := value.([]any)
+		if !ok {
+			return invalid("a list of property names")
+		}
+		for _, name := range names {
+			name, ok := name.(string)
+			if !ok {
+				return invalid("a list of property names")
+			}
+			s.required = append(s.required, name)
+		}
+	case "additionalProperties":
+		s.additional, err = compile(value, path)
+	case "items":
+		s.items, err = compile(value, path)
+	case "minItems":
+		s.minItems, err = count(value, path)
+	case "maxItems":
+		s.maxItems, err = count(value, path)
+	case "minLength":
+		s.minLength, err = count(value, path)
+	case "maxLength":
+		s.maxLength, err = count(value, path)
+	case "pattern":
+		expr, ok := value.(string)
+		if !ok {
+			return invalid("a regular expression")
+		}
+		if s.pattern, err = regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid schema at %s: %w", path, err)
+		}
+	case "minimum":
+		s.minimum, err = number(value, path)
+	case "maximum":
+		s.maximum, err = number(value, path)
+	case "exclusiveMinimum":
+		s.exclusiveMinimum, err = number(value, path)
+	case "exclusiveMaximum":
+		s.exclusiveMaximum, err = number(value, path)
+	default:
+		if !slices.Contains(annotations, keyword) {
+			return fmt.Errorf("unsupported schema keyword %s at %s", keyword, path)
+		}
+	}
+	return err
+}
+
+func count(value any, path string) (*int, error) {
+	n, ok := value.(float64)
+	if !ok || n < 0 || n != math.Trunc(n) {
+		return nil, fmt.Errorf("invalid schema at %s: expected a non-negative integer", path)
+	}
+	c := int(n)
+	return &c, nil
+}
+
+func number(value any, path string) (*float64, error) {
+	n, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("invalid schema at %s: expected a number", path)
+	}
+	return &n, nil
+}
+
+// Validate checks attributes against the schema. It returns a
+// models.ValidationError naming each attribute that does not match, or nil.
+func (s *Schema) Validate(attributes models.Attributes) error {
+	// A round trip gives the values the types the repositories read back,
+	// e.g. every number becomes a float64.
+	var doc any = map[string]any{}
+	if len(attributes) > 0 {
+		b, err := json.Marshal(attributes)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return err
+		}
+	}
+	var errs models.ValidationError
+	s.check(doc, "attributes", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// check adds an error to errs for each way value at field does not match s.
+func (s *Schema) check(value any, field string, errs *models.ValidationError) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, models.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.reject {
+		fail("is not allowed")
+		return
+	}
+	if len(s.types) > 0 && !slices.ContainsFunc(s.types, func(t string) bool { return hasType(value, t) }) {
+		fail("must be %s", strings.Join(s.types, " or "))
+		return
+	}
+	if s.constant != nil && !equal(value, *s.constant) {
+		fail("must be %s", encode(*s.constant))
+	}
+	if s.enum != nil && !slices.ContainsFunc(s.enum, func(v any) bool { return equal(value, v) }) {
+		names := make([]string, len(s.enum))
+		for i, v := range s.enum {
+			names[i] = encode(v)
+		}
+		fail("must be one of %s", strings.Join(names, ", "))
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		for _, name := range s.required {
+			if _, ok := value[name]; !ok {
+				*errs = append(*errs, models.FieldError{Field: field + "." + name, Message: "is required"})
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(value)) {
+			prop, ok := s.properties[name]
+			if !ok {
+				prop = s.additional
+			}
+			if prop != nil {
+				prop.check(value[name], field+"."+name, errs)
+			}
+		}
+	case []any:
+		if s.minItems != nil && len(value) < *s.minItems {
+			fail("must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(value) > *s.maxItems {
+			fail("must have at most %d items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range value {
+				s.items.check(item, fmt.Sprintf("%s[%d]", field, i), errs)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(value)
+		if s.minLength != nil && length < *s.minLength {
+			fail("must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			fail("must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			fail("must match %s", s.pattern)
+		}
+	case float64:
+		if s.minimum != nil && value < *s.minimum {
+			fail("must be at least %v", *s.minimum)
+		}
+		if s.maximum != nil && value > *s.maximum {
+			fail("must be at most %v", *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && value <= *s.exclusiveMinimum {
+			fail("must be more than %v", *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && value >= *s.exclusiveMaximum {
+			fail("must be less than %v", *s.exclusiveMaximum)
+		}
+	}
+}
+
+func hasType(value any, t string) bool {
+	switch value := value.(type) {
+	case map[string]any:
+		return t == "object"
+	case []any:
+		return t == "array"
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || t == "integer" && value == math.Trunc(value)
+	case bool:
+		return t == "boolean"
+	case nil:
+		return t == "null"
+	}
+	return false
+}
+
+func equal(a, b any) bool {
+	return encode(a) == encode(b)
+}
+
+// encode returns value as JSON, which sorts the keys of objects.
+func encode(value any) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
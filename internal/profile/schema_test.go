@@ -0,0 +1,65 @@
+package profile
+
+import (
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"testing"
+)
+
+const testSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"properties": {
+		"phone": {"type": "string", "pattern": "^\\+[0-9]{7,15}$"},
+		"department": {"enum": ["ops", "support", "sales"]},
+		"age": {"type": "integer", "minimum": 18, "maximum": 120},
+		"tags": {"type": "array", "items": {"type": "string", "maxLength": 10}, "maxItems": 3}
+	},
+	"required": ["department"],
+	"additionalProperties": false
+}`
+
+func TestSchema_Validate(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when parsing the schema", err)
+	}
+
+	valid := models.Attributes{"phone": "+380441234567", "department": "ops", "age": 30, "tags": []string{"a", "b"}}
+	if err := schema.Validate(valid); err != nil {
+		t.Fatalf("an error '%s' was not expected for %v", err, valid)
+	}
+
+	tests := []struct {
+		attributes models.Attributes
+		field      string
+		message    string
+	}{
+		{models.Attributes{}, "attributes.department", "is required"},
+		{models.Attributes{"department": "hr"}, "attributes.department", `must be one of "ops", "support", "sales"`},
+		{models.Attributes{"department": "ops", "phone": "0441234567"}, "attributes.phone", `must match ^\+[0-9]{7,15}$`},
+		{models.Attributes{"department": "ops", "age": 17}, "attributes.age", "must be at least 18"},
+		{models.Attributes{"department": "ops", "age": 30.5}, "attributes.age", "must be integer"},
+		{models.Attributes{"department": "ops", "tags": []string{"a", "much too long"}}, "attributes.tags[1]", "must be at most 10 characters"},
+		{models.Attributes{"department": "ops", "locale": "uk"}, "attributes.locale", "is not allowed"},
+	}
+	for _, test := range tests {
+		var verr models.ValidationError
+		if err := schema.Validate(test.attributes); !errors.As(err, &verr) || verr.Field(test.field) != test.message {
+			t.Fatalf("got '%v' for %v, expected %s %s", err, test.attributes, test.field, test.message)
+		}
+	}
+}
+
+func TestParseSchema_Unsupported(t *testing.T) {
+	for doc, expected := range map[string]string{
+		`{"properties": {"a": {"oneOf": []}}}`: "unsupported schema keyword oneOf at #/properties/a/oneOf",
+		`{"type": "text"}`:                     "invalid schema at #/type: expected one of object, array, string, number, integer, boolean, null",
+		`{"minLength": -1}`:                    "invalid schema at #/minLength: expected a non-negative integer",
+		`[]`:                                   "invalid schema at #: expected an object or a boolean",
+	} {
+		if _, err := ParseSchema([]byte(doc)); err == nil || err.Error() != expected {
+			t.Fatalf("got '%v' for %s, expected %q", err, doc, expected)
+		}
+	}
+}
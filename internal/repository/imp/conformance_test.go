@@ -0,0 +1,219 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/db"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/repotest"
+	"os"
+	"testing"
+)
+
+// Backends running on a server are tested against an existing database,
+// e.g. the docker compose one:
+//
+//	POSTGRES_TEST_DSN="host=localhost user=... password=... dbname=test sslmode=disable"
+//	MYSQL_TEST_DSN="user:password@tcp(localhost:3306)/test?parseTime=true"
+//
+// The tables of that database are truncated by the tests. The Postgres user
+// has to be a superuser or have BYPASSRLS, the unscoped repositories would
+// otherwise only see the rows of the default tenant.
+var serverBackends = []struct {
+	driver         string
+	env            string
+	truncate       []string
+	newUser        func(*sql.DB) repository.IRepositoryUser
+	newLog         func(*sql.DB) repository.IRepositoryLog
+	newOp          func(*sql.DB) repository.IRepositoryOperator
+	newLogin       func(*sql.DB) repository.IRepositoryLogin
+	newToken       func(*sql.DB) repository.IRepositoryUserToken
+	newRole        func(*sql.DB) repository.IRepositoryRole
+	newGroup       func(*sql.DB) repository.IRepositoryGroup
+	newAttr        func(*sql.DB) repository.IRepositoryUserAttribute
+	newTenant      func(*sql.DB) repository.IRepositoryTenant
+	newTenantUser  func(*sql.DB, int) repository.IRepositoryUser
+	newTenantLog   func(*sql.DB, int) repository.IRepositoryLog
+	newTenantAttr  func(*sql.DB, int) repository.IRepositoryUserAttribute
+	newTenantLogin func(*sql.DB, int) repository.IRepositoryLogin
+	newTenantToken func(*sql.DB, int) repository.IRepositoryUserToken
+}{
+	{
+		driver: db.DriverPostgres,
+		env:    "POSTGRES_TEST_DSN",
+		truncate: []string{"TRUNCATE users, logs, operators, api_tokens, login_lockouts, revoked_sessions," +
+			" email_verification_tokens, password_reset_tokens, user_roles, group_members, roles, groups RESTART IDENTITY;",
+			"DELETE FROM tenants WHERE name <> 'default';"},
+		newUser:  NewPostgresRepoUser,
+		newLog:   NewPostgresRepoLog,
+		newOp:    NewPostgresRepoOperator,
+		newLogin: NewPostgresRepoLogin,
+		newToken: NewPostgresRepoUserToken,
+		newRole:  NewPostgresRepoRole,
+		newGroup: NewPostgresRepoGroup,
+		newAttr:  NewPostgresRepoUserAttribute,
+
+		newTenant:      NewPostgresRepoTenant,
+		newTenantUser:  NewPostgresRepoUserForTenant,
+		newTenantLog:   NewPostgresRepoLogForTenant,
+		newTenantAttr:  NewPostgresRepoUserAttributeForTenant,
+		newTenantLogin: NewPostgresRepoLoginForTenant,
+		newTenantToken: NewPostgresRepoUserTokenForTenant,
+	},
+	{
+		driver: db.DriverMySQL,
+		env:    "MYSQL_TEST_DSN",
+		truncate: []string{"TRUNCATE TABLE users;", "TRUNCATE TABLE logs;",
+			"DELETE FROM api_tokens;", "DELETE FROM operators;",
+			"TRUNCATE TABLE login_lockouts;", "TRUNCATE TABLE revoked_sessions;",
+			"TRUNCATE TABLE email_verification_tokens;", "TRUNCATE TABLE password_reset_tokens;",
+			"TRUNCATE TABLE user_roles;", "TRUNCATE TABLE group_members;", "TRUNCATE TABLE roles;", "TRUNCATE TABLE `groups`;",
+			"DELETE FROM tenants WHERE name <> 'default';"},
+		newUser:  NewMysqlRepoUser,
+		newLog:   NewMysqlRepoLog,
+		newOp:    NewMysqlRepoOperator,
+		newLogin: NewMysqlRepoLogin,
+		newToken: NewMysqlRepoUserToken,
+		newRole:  NewMysqlRepoRole,
+		newGroup: NewMysqlRepoGroup,
+		newAttr:  NewMysqlRepoUserAttribute,
+
+		newTenant:      NewMysqlRepoTenant,
+		newTenantUser:  NewMysqlRepoUserForTenant,
+		newTenantLog:   NewMysqlRepoLogForTenant,
+		newTenantAttr:  NewMysqlRepoUserAttributeForTenant,
+		newTenantLogin: NewMysqlRepoLoginForTenant,
+		newTenantToken: NewMysqlRepoUserTokenForTenant,
+	},
+}
+
+func TestConformance_Memory(t *testing.T) {
+	repotest.RunUserTests(t, func(t *testing.T) repository.IRepositoryUser { return NewMemoryRepoUser() })
+	repotest.RunLogTests(t, func(t *testing.T) repository.IRepositoryLog { return NewMemoryRepoLog() })
+	repotest.RunOperatorTests(t, func(t *testing.T) repository.IRepositoryOperator { return NewMemoryRepoOperator() })
+	repotest.RunLoginTests(t, func(t *testing.T) repository.IRepositoryLogin { return NewMemoryRepoLogin() })
+	repotest.RunUserTokenTests(t, func(t *testing.T) repository.IRepositoryUserToken { return NewMemoryRepoUserToken() })
+	repotest.RunRoleTests(t, func(t *testing.T) (repository.IRepositoryUser, repository.IRepositoryRole) {
+		users := NewMemoryRepoUser()
+		return users, NewMemoryRepoRole(users)
+	})
+	repotest.RunGroupTests(t, func(t *testing.T) (repository.IRepositoryUser, repository.IRepositoryGroup) {
+		users := NewMemoryRepoUser()
+		return users, NewMemoryRepoGroup(users)
+	})
+	repotest.RunAttributeTests(t, func(t *testing.T) (repository.IRepositoryUser, repository.IRepositoryUserAttribute) {
+		users := NewMemoryRepoUser()
+		return users, NewMemoryRepoUserAttribute(users)
+	})
+	repotest.RunTenantTests(t, func(t *testing.T) repository.IRepositoryTenant { return NewMemoryRepoTenant() })
+}
+
+func TestConformance_Sqlite(t *testing.T) {
+	repotest.RunUserTests(t, func(t *testing.T) repository.IRepositoryUser { return NewSqliteRepoUser(openSqlite(t)) })
+	repotest.RunLogTests(t, func(t *testing.T) repository.IRepositoryLog { return NewSqliteRepoLog(openSqlite(t)) })
+	repotest.RunOperatorTests(t, func(t *testing.T) repository.IRepositoryOperator { return NewSqliteRepoOperator(openSqlite(t)) })
+	repotest.RunLoginTests(t, func(t *testing.T) repository.IRepositoryLogin { return NewSqliteRepoLogin(openSqlite(t)) })
+	repotest.RunUserTokenTests(t, func(t *testing.T) repository.IRepositoryUserToken { return NewSqliteRepoUserToken(openSqlite(t)) })
+	repotest.RunRoleTests(t, func(t *testing.T) (repository.IRepositoryUser, repository.IRepositoryRole) {
+		con := openSqlite(t)
+		return NewSqliteRepoUser(con), NewSqliteRepoRole(con)
+	})
+	repotest.RunGroupTests(t, func(t *testing.T) (repository.IRepositoryUser, repository.IRepositoryGroup) {
+		con := openSqlite(t)
+		return NewSqliteRepoUser(con), NewSqliteRepoGroup(con)
+	})
+	repotest.RunAttributeTests(t, func(t *testing.T) (repository.IRepositoryUser, repository.IRepositoryUserAttribute) {
+		con := openSqlite(t)
+		return NewSqliteRepoUser(con), NewSqliteRepoUserAttribute(con)
+	})
+	repotest.RunTenantTests(t, func(t *testing.T) repository.IRepositoryTenant { return NewSqliteRepoTenant(openSqlite(t)) })
+	repotest.RunTenantIsolationTests(t, func(t *testing.T) repotest.TenantRepos {
+		con := openSqlite(t)
+		return repotest.TenantRepos{
+			Tenants: NewSqliteRepoTenant(con),
+			Users:   func(tenantID int) repository.IRepositoryUser { return NewSqliteRepoUserForTenant(con, tenantID) },
+			Logs:    func(tenantID int) repository.IRepositoryLog { return NewSqliteRepoLogForTenant(con, tenantID) },
+			Attributes: func(tenantID int) repository.IRepositoryUserAttribute {
+				return NewSqliteRepoUserAttributeForTenant(con, tenantID)
+			},
+			Logins: func(tenantID int) repository.IRepositoryLogin { return NewSqliteRepoLoginForTenant(con, tenantID) },
+			Tokens: func(tenantID int) repository.IRepositoryUserToken {
+				return NewSqliteRepoUserTokenForTenant(con, tenantID)
+			},
+		}
+	})
+}
+
+func TestConformance_Server(t *testing.T) {
+	for _, backend := range serverBackends {
+		t.Run(backend.driver, func(t *testing.T) {
+			dsn := os.Getenv(backend.env)
+			if dsn == "" {
+				t.Skipf("%s is not set", backend.env)
+			}
+			con, err := sql.Open(backend.driver, dsn)
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening the database", err)
+			}
+			defer con.Close()
+			if err := db.Migrate(con, backend.driver); err != nil {
+				t.Fatalf("an error '%s' was not expected when migrating", err)
+			}
+
+			reset := func(t *testing.T) {
+				for _, query := range backend.truncate {
+					if _, err := con.ExecContext(context.Background(), query); err != nil {
+						t.Fatalf("an error '%s' was not expected when truncating", err)
+					}
+				}
+			}
+			repotest.RunUserTests(t, func(t *testing.T) repository.IRepositoryUser {
+				reset(t)
+				return backend.newUser(con)
+			})
+			repotest.RunLogTests(t, func(t *testing.T) repository.IRepositoryLog {
+				reset(t)
+				return backend.newLog(con)
+			})
+			repotest.RunOperatorTests(t, func(t *testing.T) repository.IRepositoryOperator {
+				reset(t)
+				return backend.newOp(con)
+			})
+			repotest.RunLoginTests(t, func(t *testing.T) repository.IRepositoryLogin {
+				reset(t)
+				return backend.newLogin(con)
+			})
+			repotest.RunUserTokenTests(t, func(t *testing.T) repository.IRepositoryUserToken {
+				reset(t)
+				return backend.newToken(con)
+			})
+			repotest.RunRoleTests(t, func(t *testing.T) (repository.IRepositoryUser, repository.IRepositoryRole) {
+				reset(t)
+				return backend.newUser(con), backend.newRole(con)
+			})
+			repotest.RunGroupTests(t, func(t *testing.T) (repository.IRepositoryUser, repository.IRepositoryGroup) {
+				reset(t)
+				return backend.newUser(con), backend.newGroup(con)
+			})
+			repotest.RunAttributeTests(t, func(t *testing.T) (repository.IRepositoryUser, repository.IRepositoryUserAttribute) {
+				reset(t)
+				return backend.newUser(con), backend.newAttr(con)
+			})
+			repotest.RunTenantTests(t, func(t *testing.T) repository.IRepositoryTenant {
+				reset(t)
+				return backend.newTenant(con)
+			})
+			repotest.RunTenantIsolationTests(t, func(t *testing.T) repotest.TenantRepos {
+				reset(t)
+				return repotest.TenantRepos{
+					Tenants:    backend.newTenant(con),
+					Users:      func(tenantID int) repository.IRepositoryUser { return backend.newTenantUser(con, tenantID) },
+					Logs:       func(tenantID int) repository.IRepositoryLog { return backend.newTenantLog(con, tenantID) },
+					Attributes: func(tenantID int) repository.IRepositoryUserAttribute { return backend.newTenantAttr(con, tenantID) },
+					Logins:     func(tenantID int) repository.IRepositoryLogin { return backend.newTenantLogin(con, tenantID) },
+					Tokens:     func(tenantID int) repository.IRepositoryUserToken { return backend.newTenantToken(con, tenantID) },
+				}
+			})
+		})
+	}
+}
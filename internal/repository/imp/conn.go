@@ -0,0 +1,38 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+// querier is the part of *sql.DB and *sql.Tx used by the repositories.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// conn returns the transaction carried by ctx, or db when there is none.
+func conn(ctx context.Context, db *sql.DB) querier {
+	if tx := repository.TxFromContext(ctx); tx != nil {
+		return tx
+	}
+	return db
+}
+
+// staticResult is the sql.Result of statements whose outcome is known
+// without asking the driver, e.g. the in-memory repositories or an insert
+// that returned the new id.
+type staticResult struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r staticResult) LastInsertId() (int64, error) {
+	return r.lastInsertId, nil
+}
+
+func (r staticResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
@@ -0,0 +1,169 @@
+package imp
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// MemoryRepoGroup is the groups counterpart of MemoryRepoUser.
+type MemoryRepoGroup struct {
+	mu     sync.RWMutex
+	groups map[int]models.Group
+	lastID int
+	// members maps the group IDs to the IDs of their members.
+	members map[int]map[int]bool
+}
+
+func (m *MemoryRepoGroup) GetGroups(ctx context.Context) ([]models.Group, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return sortedGroups(maps.Values(m.groups)), nil
+}
+
+func (m *MemoryRepoGroup) GetGroupByName(ctx context.Context, name string) (models.Group, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Group{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, group := range m.groups {
+		if group.Name == name {
+			return group, nil
+		}
+	}
+	return models.Group{}, sql.ErrNoRows
+}
+
+func (m *MemoryRepoGroup) InsertGroup(ctx context.Context, group models.Group) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.groups {
+		if existing.Name == group.Name {
+			return nil, fmt.Errorf("duplicate group name %q", group.Name)
+		}
+	}
+	m.lastID++
+	group.ID = m.lastID
+	m.groups[group.ID] = group
+	m.members[group.ID] = make(map[int]bool)
+	return staticResult{lastInsertId: int64(group.ID), rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoGroup) DeleteGroupById(ctx context.Context, id int) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.groups[id]; !ok {
+		return staticResult{}, nil
+	}
+	delete(m.groups, id)
+	delete(m.members, id)
+	return staticResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoGroup) AddGroupMember(ctx context.Context, groupID, userID int) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members, ok := m.members[groupID]
+	if !ok {
+		return nil, fmt.Errorf("group %d does not exist", groupID)
+	}
+	if members[userID] {
+		return staticResult{}, nil
+	}
+	members[userID] = true
+	return staticResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoGroup) RemoveGroupMember(ctx context.Context, groupID, userID int) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.members[groupID][userID] {
+		return staticResult{}, nil
+	}
+	delete(m.members[groupID], userID)
+	return staticResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoGroup) GetUserGroups(ctx context.Context, userID int) ([]models.Group, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var groups []models.Group
+	for id, members := range m.members {
+		if members[userID] {
+			groups = append(groups, m.groups[id])
+		}
+	}
+	return sortedGroups(slices.Values(groups)), nil
+}
+
+func (m *MemoryRepoGroup) matches(filter models.UserFilter, userID int) bool {
+	if filter.Group == "" {
+		return true
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for id, group := range m.groups {
+		if group.Name == filter.Group {
+			return m.members[id][userID]
+		}
+	}
+	return false
+}
+
+func (m *MemoryRepoGroup) forget(userID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, members := range m.members {
+		delete(members, userID)
+	}
+}
+
+func sortedGroups(groups iter.Seq[models.Group]) []models.Group {
+	return slices.SortedFunc(groups, func(a, b models.Group) int { return cmp.Compare(a.Name, b.Name) })
+}
+
+// NewMemoryRepoGroup keeps the groups of the users in users. When users
+// comes from NewMemoryRepoUser, its GetUsers filters by these groups and
+// deleting a user removes it from them, as the SQL repositories do.
+func NewMemoryRepoGroup(users repository.IRepositoryUser) repository.IRepositoryGroup {
+	repo := &MemoryRepoGroup{groups: make(map[int]models.Group), members: make(map[int]map[int]bool)}
+	if m, ok := users.(*MemoryRepoUser); ok {
+		m.addMembers(repo)
+	}
+	return repo
+}
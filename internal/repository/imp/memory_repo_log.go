@@ -0,0 +1,57 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"sync"
+)
+
+// MemoryRepoLog is the logs counterpart of MemoryRepoUser.
+type MemoryRepoLog struct {
+	mu   sync.RWMutex
+	logs []models.Log
+}
+
+func (m *MemoryRepoLog) GetLogs(ctx context.Context) ([]models.Log, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	logs := make([]models.Log, len(m.logs))
+	copy(logs, m.logs)
+	return logs, nil
+}
+
+func (m *MemoryRepoLog) GetLogById(ctx context.Context, id int) (models.Log, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Log{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Logs are never deleted, so the log with ID n is at index n-1.
+	if id < 1 || id > len(m.logs) {
+		return models.Log{}, sql.ErrNoRows
+	}
+	return m.logs[id-1], nil
+}
+
+func (m *MemoryRepoLog) InsertLog(ctx context.Context, log models.Log) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	log.Id = int64(len(m.logs) + 1)
+	m.logs = append(m.logs, log)
+	return staticResult{lastInsertId: log.Id, rowsAffected: 1}, nil
+}
+
+func NewMemoryRepoLog() repository.IRepositoryLog {
+	return &MemoryRepoLog{}
+}
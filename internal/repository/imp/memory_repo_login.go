@@ -0,0 +1,119 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"sync"
+	"time"
+)
+
+// MemoryRepoLogin is the login counterpart of MemoryRepoUser.
+type MemoryRepoLogin struct {
+	mu       sync.RWMutex
+	lockouts map[string]models.Lockout
+	revoked  map[string]time.Time
+}
+
+func (m *MemoryRepoLogin) GetLockout(ctx context.Context, email string) (models.Lockout, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Lockout{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	lockout, ok := m.lockouts[email]
+	if !ok {
+		return models.Lockout{}, sql.ErrNoRows
+	}
+	return lockout, nil
+}
+
+func (m *MemoryRepoLogin) AddFailure(ctx context.Context, email string, at, since time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for stale, lockout := range m.lockouts {
+		if lockout.FailedAt.Before(since) && !lockout.Locked(at) {
+			delete(m.lockouts, stale)
+		}
+	}
+	lockout, ok := m.lockouts[email]
+	if !ok {
+		lockout = models.Lockout{Email: email}
+	}
+	if lockout.FailedAt.Before(since) {
+		lockout.Failures = 0
+	}
+	lockout.Failures++
+	lockout.FailedAt = at
+	m.lockouts[email] = lockout
+	return lockout.Failures, nil
+}
+
+func (m *MemoryRepoLogin) LockEmail(ctx context.Context, email string, until time.Time) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lockout, ok := m.lockouts[email]
+	if !ok {
+		return staticResult{}, nil
+	}
+	lockout.Failures = 0
+	lockout.LockedUntil = until
+	m.lockouts[email] = lockout
+	return staticResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoLogin) DeleteLockout(ctx context.Context, email string) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.lockouts[email]; !ok {
+		return staticResult{}, nil
+	}
+	delete(m.lockouts, email)
+	return staticResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoLogin) RevokeSession(ctx context.Context, id string, expiresAt time.Time) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for revokedID, until := range m.revoked {
+		if until.Before(now) {
+			delete(m.revoked, revokedID)
+		}
+	}
+	m.revoked[id] = expiresAt
+	return staticResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoLogin) IsSessionRevoked(ctx context.Context, id string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.revoked[id]
+	return ok, nil
+}
+
+func NewMemoryRepoLogin() repository.IRepositoryLogin {
+	return &MemoryRepoLogin{lockouts: make(map[string]models.Lockout), revoked: make(map[string]time.Time)}
+}
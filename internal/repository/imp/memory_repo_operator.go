@@ -0,0 +1,153 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// errDuplicate mirrors the unique constraints of the operators and
+// api_tokens tables.
+var errDuplicate = errors.New("duplicate key value violates unique constraint")
+
+// MemoryRepoOperator is the operators counterpart of MemoryRepoUser.
+type MemoryRepoOperator struct {
+	mu          sync.RWMutex
+	operators   map[int]models.Operator
+	tokens      map[string]models.APIToken
+	lastID      int
+	lastTokenID int
+}
+
+func (m *MemoryRepoOperator) GetOperators(ctx context.Context) ([]models.Operator, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	operators := make([]models.Operator, 0, len(m.operators))
+	for _, id := range slices.Sorted(maps.Keys(m.operators)) {
+		operators = append(operators, m.operators[id])
+	}
+	return operators, nil
+}
+
+func (m *MemoryRepoOperator) GetOperatorByName(ctx context.Context, name string) (models.Operator, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Operator{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, operator := range m.operators {
+		if operator.Name == name {
+			return operator, nil
+		}
+	}
+	return models.Operator{}, sql.ErrNoRows
+}
+
+func (m *MemoryRepoOperator) InsertOperator(ctx context.Context, operator models.Operator) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.nameTaken(operator.Name, 0) {
+		return nil, errDuplicate
+	}
+	m.lastID++
+	operator.ID = m.lastID
+	m.operators[operator.ID] = operator
+	return staticResult{lastInsertId: int64(operator.ID), rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoOperator) UpdateOperatorById(ctx context.Context, id int, operator models.Operator) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.operators[id]
+	if !ok {
+		return staticResult{}, nil
+	}
+	if m.nameTaken(operator.Name, id) {
+		return nil, errDuplicate
+	}
+	current.Name = operator.Name
+	current.Role = operator.Role
+	current.PasswordHash = operator.PasswordHash
+	m.operators[id] = current
+	return staticResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoOperator) DeleteOperatorById(ctx context.Context, id int) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.operators[id]; !ok {
+		return staticResult{}, nil
+	}
+	delete(m.operators, id)
+	maps.DeleteFunc(m.tokens, func(_ string, token models.APIToken) bool { return token.OperatorID == id })
+	return staticResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoOperator) InsertAPIToken(ctx context.Context, token models.APIToken) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tokens[token.Hash]; ok {
+		return nil, errDuplicate
+	}
+	if _, ok := m.operators[token.OperatorID]; !ok {
+		return nil, errors.New("violates foreign key constraint: no such operator")
+	}
+	m.lastTokenID++
+	token.ID = m.lastTokenID
+	m.tokens[token.Hash] = token
+	return staticResult{lastInsertId: int64(token.ID), rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoOperator) GetOperatorByTokenHash(ctx context.Context, hash string) (models.Operator, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Operator{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	token, ok := m.tokens[hash]
+	if !ok {
+		return models.Operator{}, sql.ErrNoRows
+	}
+	return m.operators[token.OperatorID], nil
+}
+
+// nameTaken must be called with the lock held.
+func (m *MemoryRepoOperator) nameTaken(name string, exceptID int) bool {
+	for id, operator := range m.operators {
+		if id != exceptID && operator.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func NewMemoryRepoOperator() repository.IRepositoryOperator {
+	return &MemoryRepoOperator{operators: make(map[int]models.Operator), tokens: make(map[string]models.APIToken)}
+}
@@ -0,0 +1,169 @@
+package imp
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// MemoryRepoRole is the roles counterpart of MemoryRepoUser.
+type MemoryRepoRole struct {
+	mu     sync.RWMutex
+	roles  map[int]models.UserRole
+	lastID int
+	// members maps the role IDs to the IDs of the users who have them.
+	members map[int]map[int]bool
+}
+
+func (m *MemoryRepoRole) GetRoles(ctx context.Context) ([]models.UserRole, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return sortedRoles(maps.Values(m.roles)), nil
+}
+
+func (m *MemoryRepoRole) GetRoleByName(ctx context.Context, name string) (models.UserRole, error) {
+	if err := ctx.Err(); err != nil {
+		return models.UserRole{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, role := range m.roles {
+		if role.Name == name {
+			return role, nil
+		}
+	}
+	return models.UserRole{}, sql.ErrNoRows
+}
+
+func (m *MemoryRepoRole) InsertRole(ctx context.Context, role models.UserRole) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.roles {
+		if existing.Name == role.Name {
+			return nil, fmt.Errorf("duplicate role name %q", role.Name)
+		}
+	}
+	m.lastID++
+	role.ID = m.lastID
+	m.roles[role.ID] = role
+	m.members[role.ID] = make(map[int]bool)
+	return staticResult{lastInsertId: int64(role.ID), rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoRole) DeleteRoleById(ctx context.Context, id int) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.roles[id]; !ok {
+		return staticResult{}, nil
+	}
+	delete(m.roles, id)
+	delete(m.members, id)
+	return staticResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoRole) AssignRole(ctx context.Context, roleID, userID int) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members, ok := m.members[roleID]
+	if !ok {
+		return nil, fmt.Errorf("role %d does not exist", roleID)
+	}
+	if members[userID] {
+		return staticResult{}, nil
+	}
+	members[userID] = true
+	return staticResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoRole) RevokeRole(ctx context.Context, roleID, userID int) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.members[roleID][userID] {
+		return staticResult{}, nil
+	}
+	delete(m.members[roleID], userID)
+	return staticResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoRole) GetUserRoles(ctx context.Context, userID int) ([]models.UserRole, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var roles []models.UserRole
+	for id, members := range m.members {
+		if members[userID] {
+			roles = append(roles, m.roles[id])
+		}
+	}
+	return sortedRoles(slices.Values(roles)), nil
+}
+
+func (m *MemoryRepoRole) matches(filter models.UserFilter, userID int) bool {
+	if filter.Role == "" {
+		return true
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for id, role := range m.roles {
+		if role.Name == filter.Role {
+			return m.members[id][userID]
+		}
+	}
+	return false
+}
+
+func (m *MemoryRepoRole) forget(userID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, members := range m.members {
+		delete(members, userID)
+	}
+}
+
+func sortedRoles(roles iter.Seq[models.UserRole]) []models.UserRole {
+	return slices.SortedFunc(roles, func(a, b models.UserRole) int { return cmp.Compare(a.Name, b.Name) })
+}
+
+// NewMemoryRepoRole keeps the roles of the users in users. When users comes
+// from NewMemoryRepoUser, its GetUsers filters by these roles and deleting a
+// user revokes them, as the SQL repositories do.
+func NewMemoryRepoRole(users repository.IRepositoryUser) repository.IRepositoryRole {
+	repo := &MemoryRepoRole{roles: make(map[int]models.UserRole), members: make(map[int]map[int]bool)}
+	if m, ok := users.(*MemoryRepoUser); ok {
+		m.addMembers(repo)
+	}
+	return repo
+}
@@ -0,0 +1,76 @@
+package imp
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryRepoTenant is the tenants counterpart of MemoryRepoUser. Like the
+// migrations it starts with the default tenant.
+type MemoryRepoTenant struct {
+	mu      sync.RWMutex
+	tenants map[int]models.Tenant
+	lastID  int
+}
+
+func (m *MemoryRepoTenant) GetTenants(ctx context.Context) ([]models.Tenant, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.SortedFunc(maps.Values(m.tenants), func(a, b models.Tenant) int {
+		return cmp.Compare(a.Name, b.Name)
+	}), nil
+}
+
+func (m *MemoryRepoTenant) GetTenantByName(ctx context.Context, name string) (models.Tenant, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Tenant{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, tenant := range m.tenants {
+		if tenant.Name == name {
+			return tenant, nil
+		}
+	}
+	return models.Tenant{}, sql.ErrNoRows
+}
+
+func (m *MemoryRepoTenant) InsertTenant(ctx context.Context, tenant models.Tenant) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.tenants {
+		if existing.Name == tenant.Name {
+			return nil, fmt.Errorf("duplicate tenant name %q", tenant.Name)
+		}
+	}
+	m.lastID++
+	tenant.ID = m.lastID
+	m.tenants[tenant.ID] = tenant
+	return staticResult{lastInsertId: int64(tenant.ID), rowsAffected: 1}, nil
+}
+
+func NewMemoryRepoTenant() repository.IRepositoryTenant {
+	return &MemoryRepoTenant{
+		tenants: map[int]models.Tenant{
+			models.DefaultTenantID: {ID: models.DefaultTenantID, Name: models.DefaultTenant, CreatedAt: time.Now()},
+		},
+		lastID: models.DefaultTenantID,
+	}
+}
@@ -0,0 +1,160 @@
+package imp
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepoUser keeps users in memory with the semantics of the SQL
+// repositories: IDs are generated on insert and never reused, and missing
+// users are reported as sql.ErrNoRows.
+type MemoryRepoUser struct {
+	mu     sync.RWMutex
+	users  map[int]models.User
+	lastID int
+	now    func() time.Time
+	// members are the roles and groups of the users, GetUsers filters by
+	// them.
+	members []memoryMembers
+}
+
+// memoryMembers is what MemoryRepoUser needs of the memory repositories
+// keeping the roles or groups of its users.
+type memoryMembers interface {
+	// matches reports whether the user is in the role or group of filter.
+	matches(filter models.UserFilter, userID int) bool
+	// forget drops a deleted user.
+	forget(userID int)
+}
+
+func (m *MemoryRepoUser) addMembers(members memoryMembers) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.members = append(m.members, members)
+}
+
+func (m *MemoryRepoUser) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	users := make([]models.User, 0, len(m.users))
+	for _, id := range slices.Sorted(maps.Keys(m.users)) {
+		if filter.Matches(m.users[id]) {
+			users = append(users, m.users[id])
+		}
+	}
+	members := m.members
+	m.mu.RUnlock()
+
+	// The members are asked without holding the lock, they have their own.
+	return slices.DeleteFunc(users, func(user models.User) bool {
+		return slices.ContainsFunc(members, func(members memoryMembers) bool { return !members.matches(filter, user.ID) })
+	}), nil
+}
+
+func (m *MemoryRepoUser) GetUserById(ctx context.Context, id int) (models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return models.User{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return models.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (m *MemoryRepoUser) InsertUser(ctx context.Context, user models.User) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.emailTaken(user.Email, 0) {
+		return nil, errEmailTaken()
+	}
+	m.lastID++
+	user.ID = m.lastID
+	// Like the tables, only the hash of the password is kept.
+	user.Password = ""
+	user.RegisteredAt = m.now()
+	user.Status = cmp.Or(user.Status, models.StatusPending)
+	if len(user.Attributes) == 0 {
+		user.Attributes = nil
+	}
+	user.Attributes = user.Attributes.Clone()
+	m.users[user.ID] = user
+	return staticResult{lastInsertId: int64(user.ID), rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoUser) DeleteUserById(ctx context.Context, id int) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	if _, ok := m.users[id]; !ok {
+		m.mu.Unlock()
+		return staticResult{}, nil
+	}
+	delete(m.users, id)
+	members := m.members
+	m.mu.Unlock()
+
+	for _, members := range members {
+		members.forget(id)
+	}
+	return staticResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoUser) UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.users[id]
+	if !ok {
+		return staticResult{}, nil
+	}
+	if m.emailTaken(user.Email, id) {
+		return nil, errEmailTaken()
+	}
+	if user.Email != current.Email {
+		user.EmailVerifiedAt = time.Time{}
+	}
+	user.Status = cmp.Or(user.Status, current.Status)
+	user.Attributes = current.Attributes
+	user.Password = ""
+	user.ID = id
+	user.RegisteredAt = current.RegisteredAt
+	m.users[id] = user
+	return staticResult{rowsAffected: 1}, nil
+}
+
+// emailTaken reports whether a user other than the one with id has email,
+// like the unique index of the SQL repositories it ignores case.
+func (m *MemoryRepoUser) emailTaken(email string, id int) bool {
+	for _, user := range m.users {
+		if user.ID != id && strings.EqualFold(user.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+func NewMemoryRepoUser() repository.IRepositoryUser {
+	return &MemoryRepoUser{users: make(map[int]models.User), now: time.Now}
+}
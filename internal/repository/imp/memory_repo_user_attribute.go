@@ -0,0 +1,80 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+func (m *MemoryRepoUser) GetAttributes(ctx context.Context, userID int) (models.Attributes, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return user.Attributes.Clone(), nil
+}
+
+func (m *MemoryRepoUser) SetAttribute(ctx context.Context, userID int, key string, value any) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// The value is stored as the SQL repositories read it back.
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var stored any
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return staticResult{}, nil
+	}
+	attributes := user.Attributes.Clone()
+	if attributes == nil {
+		attributes = make(models.Attributes)
+	}
+	attributes[key] = stored
+	user.Attributes = attributes
+	m.users[userID] = user
+	return staticResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoUser) UnsetAttribute(ctx context.Context, userID int, key string) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return staticResult{}, nil
+	}
+	attributes := user.Attributes.Clone()
+	delete(attributes, key)
+	if len(attributes) == 0 {
+		attributes = nil
+	}
+	user.Attributes = attributes
+	m.users[userID] = user
+	return staticResult{rowsAffected: 1}, nil
+}
+
+// NewMemoryRepoUserAttribute changes the attributes of the users kept by
+// users, which must come from NewMemoryRepoUser.
+func NewMemoryRepoUserAttribute(users repository.IRepositoryUser) repository.IRepositoryUserAttribute {
+	return users.(*MemoryRepoUser)
+}
@@ -0,0 +1,79 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"maps"
+	"sync"
+	"time"
+)
+
+// MemoryRepoUserToken is the token counterpart of MemoryRepoUser.
+type MemoryRepoUserToken struct {
+	mu     sync.Mutex
+	tokens map[models.TokenPurpose]map[string]models.UserToken
+}
+
+func (m *MemoryRepoUserToken) InsertUserToken(ctx context.Context, token models.UserToken) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := userTokenTable(token.Purpose); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tokens := m.tokens[token.Purpose]
+	if _, ok := tokens[token.Hash]; ok {
+		return nil, errors.New("duplicate token hash")
+	}
+	tokens[token.Hash] = token
+	return staticResult{rowsAffected: 1}, nil
+}
+
+func (m *MemoryRepoUserToken) ConsumeUserToken(ctx context.Context, purpose models.TokenPurpose, hash string, now time.Time) (models.UserToken, error) {
+	if err := ctx.Err(); err != nil {
+		return models.UserToken{}, err
+	}
+	if _, err := userTokenTable(purpose); err != nil {
+		return models.UserToken{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[purpose][hash]
+	if !ok || !token.UsedAt.IsZero() || !token.ExpiresAt.After(now) {
+		return models.UserToken{}, sql.ErrNoRows
+	}
+	token.UsedAt = now
+	m.tokens[purpose][hash] = token
+	return token, nil
+}
+
+func (m *MemoryRepoUserToken) DeleteUserTokens(ctx context.Context, purpose models.TokenPurpose, userID int) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := userTokenTable(purpose); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tokens := m.tokens[purpose]
+	before := len(tokens)
+	maps.DeleteFunc(tokens, func(_ string, token models.UserToken) bool { return token.UserID == userID })
+	return staticResult{rowsAffected: int64(before - len(tokens))}, nil
+}
+
+func NewMemoryRepoUserToken() repository.IRepositoryUserToken {
+	tokens := make(map[models.TokenPurpose]map[string]models.UserToken)
+	for purpose := range userTokenTables {
+		tokens[purpose] = make(map[string]models.UserToken)
+	}
+	return &MemoryRepoUserToken{tokens: tokens}
+}
@@ -0,0 +1,116 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+type PostgresRepoGroup struct {
+	db *sql.DB
+}
+
+func (p PostgresRepoGroup) GetGroups(ctx context.Context) ([]models.Group, error) {
+	const query = "SELECT id, name, description, created_at FROM groups ORDER BY name;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoGroup.GetGroups", query)
+	defer span.End()
+
+	groups, err := queryGroups(ctx, conn(ctx, p.db), query)
+	span.RecordError(err)
+	return groups, err
+}
+
+func (p PostgresRepoGroup) GetGroupByName(ctx context.Context, name string) (models.Group, error) {
+	const query = "SELECT id, name, description, created_at FROM groups WHERE name = $1;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoGroup.GetGroupByName", query)
+	defer span.End()
+
+	group, err := scanGroup(conn(ctx, p.db).QueryRowContext(ctx, query, name))
+	span.RecordError(err)
+	return group, err
+}
+
+func (p PostgresRepoGroup) InsertGroup(ctx context.Context, group models.Group) (sql.Result, error) {
+	const query = "INSERT INTO groups (name, description, created_at) VALUES ($1, $2, $3) RETURNING id;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoGroup.InsertGroup", query)
+	defer span.End()
+
+	var id int64
+	err := conn(ctx, p.db).QueryRowContext(ctx, query, group.Name, group.Description, group.CreatedAt).Scan(&id)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return staticResult{lastInsertId: id, rowsAffected: 1}, nil
+}
+
+func (p PostgresRepoGroup) DeleteGroupById(ctx context.Context, id int) (sql.Result, error) {
+	const query = "DELETE FROM groups WHERE id = $1;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoGroup.DeleteGroupById", query)
+	defer span.End()
+
+	// The memberships are deleted by the foreign key.
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, id)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p PostgresRepoGroup) AddGroupMember(ctx context.Context, groupID, userID int) (sql.Result, error) {
+	const query = "INSERT INTO group_members (user_id, group_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoGroup.AddGroupMember", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, userID, groupID)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p PostgresRepoGroup) RemoveGroupMember(ctx context.Context, groupID, userID int) (sql.Result, error) {
+	const query = "DELETE FROM group_members WHERE user_id = $1 AND group_id = $2;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoGroup.RemoveGroupMember", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, userID, groupID)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p PostgresRepoGroup) GetUserGroups(ctx context.Context, userID int) ([]models.Group, error) {
+	const query = "SELECT groups.id, groups.name, groups.description, groups.created_at FROM groups" +
+		" JOIN group_members ON group_members.group_id = groups.id WHERE group_members.user_id = $1 ORDER BY groups.name;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoGroup.GetUserGroups", query)
+	defer span.End()
+
+	groups, err := queryGroups(ctx, conn(ctx, p.db), query, userID)
+	span.RecordError(err)
+	return groups, err
+}
+
+func queryGroups(ctx context.Context, q querier, query string, args ...any) ([]models.Group, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make([]models.Group, 0)
+	for rows.Next() {
+		group, err := scanGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+func scanGroup(row rowScanner) (models.Group, error) {
+	var group models.Group
+	err := row.Scan(&group.ID, &group.Name, &group.Description, &group.CreatedAt)
+	return group, err
+}
+
+func NewPostgresRepoGroup(db *sql.DB) repository.IRepositoryGroup {
+	return &PostgresRepoGroup{db: db}
+}
@@ -0,0 +1,32 @@
+package imp
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"regexp"
+	"testing"
+)
+
+func TestPostgresRepoGroup_RemoveGroupMember(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepoGroup(db)
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM group_members WHERE user_id = $1 AND group_id = $2;")).
+		WithArgs(7, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	res, err := repo.RemoveGroupMember(context.Background(), 3, 7)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when removing member", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected != 1 {
+		t.Fatalf("got %d rows affected, '%v', expected 1", affected, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
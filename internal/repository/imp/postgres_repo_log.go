@@ -1,51 +1,94 @@
 package imp
 
 import (
+	"context"
 	"database/sql"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
 )
 
 type PostgresRepoLog struct {
-	db *sql.DB
+	db     *sql.DB
+	tenant tenantScope
 }
 
-func (p PostgresRepoLog) GetLogs() ([]models.Log, error) {
-	rows, err := p.db.Query("SELECT * FROM logs;")
+func (p PostgresRepoLog) GetLogs(ctx context.Context) ([]models.Log, error) {
+	query, args := p.tenant.where("SELECT id, log_time, log_message, details, actor FROM logs", nil, dollar)
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoLog.GetLogs", query)
+	defer span.End()
+
+	rows, err := conn(ctx, p.db).QueryContext(ctx, query, args...)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 	defer rows.Close()
 	logs := make([]models.Log, 0)
 	for rows.Next() {
-		var log models.Log
-		err = rows.Scan(&log.Id, &log.LogTime, &log.LogMessage)
+		log, err := scanLog(rows)
 		if err != nil {
+			span.RecordError(err)
 			return nil, err
 		}
 		logs = append(logs, log)
 	}
 	err = rows.Err()
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 	return logs, nil
 }
 
-func (p PostgresRepoLog) GetLogById(id int) (models.Log, error) {
-	var log models.Log
-	err := p.db.QueryRow("SELECT * FROM logs WHERE id = $1;", id).Scan(&log.Id, &log.LogTime, &log.LogMessage)
+func (p PostgresRepoLog) GetLogById(ctx context.Context, id int) (models.Log, error) {
+	query, args := p.tenant.and("SELECT id, log_time, log_message, details, actor FROM logs WHERE id = $1", []any{id}, dollar)
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoLog.GetLogById", query)
+	defer span.End()
+
+	log, err := scanLog(conn(ctx, p.db).QueryRowContext(ctx, query, args...))
 	if err != nil {
+		span.RecordError(err)
 		return models.Log{}, err
 	}
 	return log, nil
 }
 
-func (p PostgresRepoLog) InsertLog(user models.Log) (sql.Result, error) {
-	res, err := p.db.Exec("INSERT INTO logs (log_time, log_message) VALUES ($1, $2);", user.LogTime, user.LogMessage)
+func (p PostgresRepoLog) InsertLog(ctx context.Context, user models.Log) (sql.Result, error) {
+	columns, values, args := p.tenant.insert("log_time, log_message, details, actor", "$1, $2, $3, $4",
+		[]any{user.LogTime, user.LogMessage, nullString(user.Details), nullString(user.Actor)}, dollar)
+	query := "INSERT INTO logs (" + columns + ") VALUES (" + values + ");"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoLog.InsertLog", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
 	return res, err
 }
 
 func NewPostgresRepoLog(db *sql.DB) repository.IRepositoryLog {
 	return &PostgresRepoLog{db: db}
 }
+
+// NewPostgresRepoLogForTenant returns the logs of the tenant with tenantID.
+func NewPostgresRepoLogForTenant(db *sql.DB, tenantID int) repository.IRepositoryLog {
+	return &PostgresRepoLog{db: db, tenant: tenantScope(tenantID)}
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanLog(row rowScanner) (models.Log, error) {
+	var log models.Log
+	var details, actor sql.NullString
+	err := row.Scan(&log.Id, &log.LogTime, &log.LogMessage, &details, &actor)
+	log.Details = details.String
+	log.Actor = actor.String
+	return log, err
+}
+
+// nullString stores empty strings as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -1,6 +1,7 @@
 package imp
 
 import (
+	"context"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
 	"github.com/DATA-DOG/go-sqlmock"
 	"regexp"
@@ -34,14 +35,14 @@ func TestPostgresRepoLog_GetLogs(t *testing.T) {
 		},
 	}
 
-	rows := sqlmock.NewRows([]string{"id", "log_time", "log_message"}).
-		AddRow(logs[0].Id, logs[0].LogTime, logs[0].LogMessage).
-		AddRow(logs[1].Id, logs[1].LogTime, logs[1].LogMessage)
+	rows := sqlmock.NewRows([]string{"id", "log_time", "log_message", "details", "actor"}).
+		AddRow(logs[0].Id, logs[0].LogTime, logs[0].LogMessage, nil, nil).
+		AddRow(logs[1].Id, logs[1].LogTime, logs[1].LogMessage, nil, nil)
 
-	mock.ExpectQuery(`SELECT \* FROM logs;`).
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, log_time, log_message, details, actor FROM logs;")).
 		WillReturnRows(rows)
 
-	data, err := repo.GetLogs()
+	data, err := repo.GetLogs(context.Background())
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when getting logs", err)
 	}
@@ -73,12 +74,12 @@ func TestPostgresRepoLog_GetLogById(t *testing.T) {
 		LogMessage: "msg1",
 	}
 
-	rows := sqlmock.NewRows([]string{"id", "log_time", "log_message"}).AddRow(log.Id, log.LogTime, log.LogMessage)
-	mock.ExpectQuery(`SELECT \* FROM logs WHERE id = \$1;`).
+	rows := sqlmock.NewRows([]string{"id", "log_time", "log_message", "details", "actor"}).AddRow(log.Id, log.LogTime, log.LogMessage, nil, nil)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, log_time, log_message, details, actor FROM logs WHERE id = $1;")).
 		WithArgs(1).
 		WillReturnRows(rows)
 
-	data, err := repo.GetLogById(1)
+	data, err := repo.GetLogById(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when getting log", err)
 	}
@@ -105,11 +106,11 @@ func TestPostgresRepoLog_CreateLog(t *testing.T) {
 		LogMessage: "msg1",
 	}
 
-	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message) VALUES ($1, $2)")).
-		WithArgs(sqlmock.AnyArg(), log.LogMessage).
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO logs (log_time, log_message, details, actor) VALUES ($1, $2, $3, $4)")).
+		WithArgs(sqlmock.AnyArg(), log.LogMessage, nil, nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	res, err := repo.InsertLog(log)
+	res, err := repo.InsertLog(context.Background(), log)
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when inserting log", err)
 	}
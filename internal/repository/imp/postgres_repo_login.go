@@ -0,0 +1,117 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"time"
+)
+
+type PostgresRepoLogin struct {
+	db     *sql.DB
+	tenant tenantScope
+}
+
+func (p PostgresRepoLogin) GetLockout(ctx context.Context, email string) (models.Lockout, error) {
+	query, args := p.tenant.and("SELECT email, failures, locked_until, failed_at FROM login_lockouts WHERE email = $1", []any{email}, dollar)
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoLogin.GetLockout", query)
+	defer span.End()
+
+	lockout, err := scanLockout(conn(ctx, p.db).QueryRowContext(ctx, query, args...))
+	span.RecordError(err)
+	return lockout, err
+}
+
+func (p PostgresRepoLogin) AddFailure(ctx context.Context, email string, at, since time.Time) (int, error) {
+	purge, purgeArgs := p.tenant.and("DELETE FROM login_lockouts WHERE failed_at < $1 AND (locked_until IS NULL OR locked_until < $2)",
+		[]any{since.UTC(), at.UTC()}, dollar)
+	// The upsert compares with since, the third argument before the tenant.
+	columns, values, args := p.tenant.insert("email, failures, failed_at", "$1, 1, $2", []any{email, at.UTC(), since.UTC()}, dollar)
+	count := "INSERT INTO login_lockouts AS l (" + columns + ") VALUES (" + values + ")" +
+		" ON CONFLICT (tenant_id, email) DO UPDATE SET failures = CASE WHEN l.failed_at < $3 THEN 1 ELSE l.failures + 1 END, failed_at = EXCLUDED.failed_at" +
+		" RETURNING failures;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoLogin.AddFailure", count)
+	defer span.End()
+
+	q := conn(ctx, p.db)
+	if _, err := q.ExecContext(ctx, purge, purgeArgs...); err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	var failures int
+	err := q.QueryRowContext(ctx, count, args...).Scan(&failures)
+	span.RecordError(err)
+	return failures, err
+}
+
+func (p PostgresRepoLogin) LockEmail(ctx context.Context, email string, until time.Time) (sql.Result, error) {
+	query, args := p.tenant.and("UPDATE login_lockouts SET failures = 0, locked_until = $2 WHERE email = $1", []any{email, until.UTC()}, dollar)
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoLogin.LockEmail", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p PostgresRepoLogin) DeleteLockout(ctx context.Context, email string) (sql.Result, error) {
+	query, args := p.tenant.and("DELETE FROM login_lockouts WHERE email = $1", []any{email}, dollar)
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoLogin.DeleteLockout", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p PostgresRepoLogin) RevokeSession(ctx context.Context, id string, expiresAt time.Time) (sql.Result, error) {
+	purge, purgeArgs := p.tenant.and("DELETE FROM revoked_sessions WHERE expires_at < $1", []any{time.Now().UTC()}, dollar)
+	columns, values, args := p.tenant.insert("id, expires_at", "$1, $2", []any{id, expiresAt.UTC()}, dollar)
+	revoke := "INSERT INTO revoked_sessions (" + columns + ") VALUES (" + values + ") ON CONFLICT (id) DO NOTHING;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoLogin.RevokeSession", revoke)
+	defer span.End()
+
+	q := conn(ctx, p.db)
+	if _, err := q.ExecContext(ctx, purge, purgeArgs...); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	res, err := q.ExecContext(ctx, revoke, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p PostgresRepoLogin) IsSessionRevoked(ctx context.Context, id string) (bool, error) {
+	query, args := p.tenant.and("SELECT COUNT(*) FROM revoked_sessions WHERE id = $1", []any{id}, dollar)
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoLogin.IsSessionRevoked", query)
+	defer span.End()
+
+	var count int
+	err := conn(ctx, p.db).QueryRowContext(ctx, query, args...).Scan(&count)
+	span.RecordError(err)
+	return count > 0, err
+}
+
+func scanLockout(row rowScanner) (models.Lockout, error) {
+	var lockout models.Lockout
+	var lockedUntil sql.NullTime
+	err := row.Scan(&lockout.Email, &lockout.Failures, &lockedUntil, &lockout.FailedAt)
+	lockout.LockedUntil = lockedUntil.Time
+	return lockout, err
+}
+
+// nullTime stores zero times as NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+func NewPostgresRepoLogin(db *sql.DB) repository.IRepositoryLogin {
+	return &PostgresRepoLogin{db: db}
+}
+
+// NewPostgresRepoLoginForTenant returns the lockouts and revoked sessions of
+// the users of the tenant with tenantID.
+func NewPostgresRepoLoginForTenant(db *sql.DB, tenantID int) repository.IRepositoryLogin {
+	return &PostgresRepoLogin{db: db, tenant: tenantScope(tenantID)}
+}
@@ -0,0 +1,70 @@
+package imp
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestPostgresRepoLogin_AddFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepoLoginForTenant(db, 2)
+	now := time.Now()
+	since := now.Add(-time.Hour)
+
+	// The stale lockouts of the tenant are purged, then the failure is
+	// counted in a single upsert returning the count.
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM login_lockouts WHERE failed_at < $1 AND (locked_until IS NULL OR locked_until < $2) AND tenant_id = $3;")).
+		WithArgs(since.UTC(), now.UTC(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO login_lockouts AS l (email, failures, failed_at, tenant_id) VALUES ($1, 1, $2, $4)"+
+		" ON CONFLICT (tenant_id, email) DO UPDATE SET failures = CASE WHEN l.failed_at < $3 THEN 1 ELSE l.failures + 1 END")).
+		WithArgs("ann@example.com", now.UTC(), since.UTC(), 2).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(3))
+
+	failures, err := repo.AddFailure(context.Background(), "ann@example.com", now, since)
+	if err != nil || failures != 3 {
+		t.Fatalf("got %d, '%v', expected 3 failures", failures, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepoLogin_RevokeSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepoLogin(db)
+	expires := time.Now().Add(time.Hour)
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM revoked_sessions WHERE expires_at < $1;")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO revoked_sessions (id, expires_at) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING;")).
+		WithArgs("s1", expires.UTC()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM revoked_sessions WHERE id = $1;")).
+		WithArgs("s1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	if _, err := repo.RevokeSession(context.Background(), "s1", expires); err != nil {
+		t.Fatalf("an error '%s' was not expected when revoking session", err)
+	}
+	if revoked, err := repo.IsSessionRevoked(context.Background(), "s1"); err != nil || !revoked {
+		t.Fatalf("got %v, '%v', expected the session to be revoked", revoked, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
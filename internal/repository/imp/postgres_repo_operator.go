@@ -0,0 +1,124 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+type PostgresRepoOperator struct {
+	db *sql.DB
+}
+
+func (p PostgresRepoOperator) GetOperators(ctx context.Context) ([]models.Operator, error) {
+	const query = "SELECT id, name, role, password_hash, created_at FROM operators ORDER BY id;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoOperator.GetOperators", query)
+	defer span.End()
+
+	operators, err := queryOperators(ctx, conn(ctx, p.db), query)
+	span.RecordError(err)
+	return operators, err
+}
+
+func (p PostgresRepoOperator) GetOperatorByName(ctx context.Context, name string) (models.Operator, error) {
+	const query = "SELECT id, name, role, password_hash, created_at FROM operators WHERE name = $1;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoOperator.GetOperatorByName", query)
+	defer span.End()
+
+	operator, err := scanOperator(conn(ctx, p.db).QueryRowContext(ctx, query, name))
+	span.RecordError(err)
+	return operator, err
+}
+
+func (p PostgresRepoOperator) InsertOperator(ctx context.Context, operator models.Operator) (sql.Result, error) {
+	const query = "INSERT INTO operators (name, role, password_hash, created_at) VALUES ($1, $2, $3, $4) RETURNING id;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoOperator.InsertOperator", query)
+	defer span.End()
+
+	// lib/pq does not support LastInsertId.
+	var id int64
+	err := conn(ctx, p.db).QueryRowContext(ctx, query, operator.Name, operator.Role,
+		nullString(operator.PasswordHash), operator.CreatedAt).Scan(&id)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return staticResult{lastInsertId: id, rowsAffected: 1}, nil
+}
+
+func (p PostgresRepoOperator) UpdateOperatorById(ctx context.Context, id int, operator models.Operator) (sql.Result, error) {
+	const query = "UPDATE operators SET name = $1, role = $2, password_hash = $3 WHERE id = $4;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoOperator.UpdateOperatorById", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, operator.Name, operator.Role, nullString(operator.PasswordHash), id)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p PostgresRepoOperator) DeleteOperatorById(ctx context.Context, id int) (sql.Result, error) {
+	const query = "DELETE FROM operators WHERE id = $1;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoOperator.DeleteOperatorById", query)
+	defer span.End()
+
+	// The API tokens are deleted by the foreign key.
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, id)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p PostgresRepoOperator) InsertAPIToken(ctx context.Context, token models.APIToken) (sql.Result, error) {
+	const query = "INSERT INTO api_tokens (operator_id, name, token_hash, created_at) VALUES ($1, $2, $3, $4) RETURNING id;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoOperator.InsertAPIToken", query)
+	defer span.End()
+
+	var id int64
+	err := conn(ctx, p.db).QueryRowContext(ctx, query, token.OperatorID, token.Name, token.Hash, token.CreatedAt).Scan(&id)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return staticResult{lastInsertId: id, rowsAffected: 1}, nil
+}
+
+func (p PostgresRepoOperator) GetOperatorByTokenHash(ctx context.Context, hash string) (models.Operator, error) {
+	const query = "SELECT o.id, o.name, o.role, o.password_hash, o.created_at FROM operators o " +
+		"JOIN api_tokens t ON t.operator_id = o.id WHERE t.token_hash = $1;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoOperator.GetOperatorByTokenHash", query)
+	defer span.End()
+
+	operator, err := scanOperator(conn(ctx, p.db).QueryRowContext(ctx, query, hash))
+	span.RecordError(err)
+	return operator, err
+}
+
+func queryOperators(ctx context.Context, q querier, query string, args ...any) ([]models.Operator, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	operators := make([]models.Operator, 0)
+	for rows.Next() {
+		operator, err := scanOperator(rows)
+		if err != nil {
+			return nil, err
+		}
+		operators = append(operators, operator)
+	}
+	return operators, rows.Err()
+}
+
+func scanOperator(row rowScanner) (models.Operator, error) {
+	var operator models.Operator
+	var passwordHash sql.NullString
+	err := row.Scan(&operator.ID, &operator.Name, &operator.Role, &passwordHash, &operator.CreatedAt)
+	operator.PasswordHash = passwordHash.String
+	return operator, err
+}
+
+func NewPostgresRepoOperator(db *sql.DB) repository.IRepositoryOperator {
+	return &PostgresRepoOperator{db: db}
+}
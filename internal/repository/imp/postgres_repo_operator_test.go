@@ -0,0 +1,65 @@
+package imp
+
+import (
+	"context"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/DATA-DOG/go-sqlmock"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestPostgresRepoOperator_InsertOperator(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepoOperator(db)
+	operator := models.Operator{Name: "ann", Role: models.RoleAdmin, CreatedAt: time.Now()}
+
+	// Operators without a password store NULL.
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO operators (name, role, password_hash, created_at) VALUES ($1, $2, $3, $4) RETURNING id;")).
+		WithArgs(operator.Name, operator.Role, nil, operator.CreatedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	res, err := repo.InsertOperator(context.Background(), operator)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting operator", err)
+	}
+	if id, _ := res.LastInsertId(); id != 3 {
+		t.Fatalf("got id %d, expected 3", id)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepoOperator_GetOperatorByTokenHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepoOperator(db)
+	created := time.Now()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT o.id, o.name, o.role, o.password_hash, o.created_at FROM operators o " +
+		"JOIN api_tokens t ON t.operator_id = o.id WHERE t.token_hash = $1;")).
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role", "password_hash", "created_at"}).
+			AddRow(1, "ci", "editor", nil, created))
+
+	operator, err := repo.GetOperatorByTokenHash(context.Background(), "hash")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting operator by token", err)
+	}
+	if operator.Name != "ci" || operator.Role != models.RoleEditor || operator.PasswordHash != "" {
+		t.Fatalf("unexpected operator %+v", operator)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,116 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+type PostgresRepoRole struct {
+	db *sql.DB
+}
+
+func (p PostgresRepoRole) GetRoles(ctx context.Context) ([]models.UserRole, error) {
+	const query = "SELECT id, name, description, created_at FROM roles ORDER BY name;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoRole.GetRoles", query)
+	defer span.End()
+
+	roles, err := queryRoles(ctx, conn(ctx, p.db), query)
+	span.RecordError(err)
+	return roles, err
+}
+
+func (p PostgresRepoRole) GetRoleByName(ctx context.Context, name string) (models.UserRole, error) {
+	const query = "SELECT id, name, description, created_at FROM roles WHERE name = $1;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoRole.GetRoleByName", query)
+	defer span.End()
+
+	role, err := scanRole(conn(ctx, p.db).QueryRowContext(ctx, query, name))
+	span.RecordError(err)
+	return role, err
+}
+
+func (p PostgresRepoRole) InsertRole(ctx context.Context, role models.UserRole) (sql.Result, error) {
+	const query = "INSERT INTO roles (name, description, created_at) VALUES ($1, $2, $3) RETURNING id;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoRole.InsertRole", query)
+	defer span.End()
+
+	var id int64
+	err := conn(ctx, p.db).QueryRowContext(ctx, query, role.Name, role.Description, role.CreatedAt).Scan(&id)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return staticResult{lastInsertId: id, rowsAffected: 1}, nil
+}
+
+func (p PostgresRepoRole) DeleteRoleById(ctx context.Context, id int) (sql.Result, error) {
+	const query = "DELETE FROM roles WHERE id = $1;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoRole.DeleteRoleById", query)
+	defer span.End()
+
+	// The assignments are deleted by the foreign key.
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, id)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p PostgresRepoRole) AssignRole(ctx context.Context, roleID, userID int) (sql.Result, error) {
+	const query = "INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoRole.AssignRole", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, userID, roleID)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p PostgresRepoRole) RevokeRole(ctx context.Context, roleID, userID int) (sql.Result, error) {
+	const query = "DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoRole.RevokeRole", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, userID, roleID)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p PostgresRepoRole) GetUserRoles(ctx context.Context, userID int) ([]models.UserRole, error) {
+	const query = "SELECT roles.id, roles.name, roles.description, roles.created_at FROM roles" +
+		" JOIN user_roles ON user_roles.role_id = roles.id WHERE user_roles.user_id = $1 ORDER BY roles.name;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoRole.GetUserRoles", query)
+	defer span.End()
+
+	roles, err := queryRoles(ctx, conn(ctx, p.db), query, userID)
+	span.RecordError(err)
+	return roles, err
+}
+
+func queryRoles(ctx context.Context, q querier, query string, args ...any) ([]models.UserRole, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := make([]models.UserRole, 0)
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func scanRole(row rowScanner) (models.UserRole, error) {
+	var role models.UserRole
+	err := row.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt)
+	return role, err
+}
+
+func NewPostgresRepoRole(db *sql.DB) repository.IRepositoryRole {
+	return &PostgresRepoRole{db: db}
+}
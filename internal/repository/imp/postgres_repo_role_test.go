@@ -0,0 +1,63 @@
+package imp
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestPostgresRepoRole_GetUserRoles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepoRole(db)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT roles.id, roles.name, roles.description, roles.created_at FROM roles" +
+		" JOIN user_roles ON user_roles.role_id = roles.id WHERE user_roles.user_id = $1 ORDER BY roles.name;")).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "created_at"}).
+			AddRow(2, "admin", "Full access", time.Now()).
+			AddRow(1, "support", "", time.Now()))
+
+	roles, err := repo.GetUserRoles(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting roles", err)
+	}
+	if len(roles) != 2 || roles[0].ID != 2 || roles[0].Name != "admin" || roles[0].Description != "Full access" || roles[1].Name != "support" {
+		t.Fatalf("unexpected roles %+v", roles)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepoRole_AssignRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepoRole(db)
+	query := regexp.QuoteMeta("INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;")
+	mock.ExpectExec(query).WithArgs(7, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	// Assigning the role again conflicts and inserts nothing.
+	mock.ExpectExec(query).WithArgs(7, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	for _, expected := range []int64{1, 0} {
+		res, err := repo.AssignRole(context.Background(), 2, 7)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when assigning role", err)
+		}
+		if affected, err := res.RowsAffected(); err != nil || affected != expected {
+			t.Fatalf("got %d rows affected, '%v', expected %d", affected, err, expected)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
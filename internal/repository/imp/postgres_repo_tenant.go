@@ -0,0 +1,74 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+type PostgresRepoTenant struct {
+	db *sql.DB
+}
+
+func (p PostgresRepoTenant) GetTenants(ctx context.Context) ([]models.Tenant, error) {
+	const query = "SELECT id, name, created_at FROM tenants ORDER BY name;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoTenant.GetTenants", query)
+	defer span.End()
+
+	tenants, err := queryTenants(ctx, conn(ctx, p.db), query)
+	span.RecordError(err)
+	return tenants, err
+}
+
+func (p PostgresRepoTenant) GetTenantByName(ctx context.Context, name string) (models.Tenant, error) {
+	const query = "SELECT id, name, created_at FROM tenants WHERE name = $1;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoTenant.GetTenantByName", query)
+	defer span.End()
+
+	tenant, err := scanTenant(conn(ctx, p.db).QueryRowContext(ctx, query, name))
+	span.RecordError(err)
+	return tenant, err
+}
+
+func (p PostgresRepoTenant) InsertTenant(ctx context.Context, tenant models.Tenant) (sql.Result, error) {
+	const query = "INSERT INTO tenants (name, created_at) VALUES ($1, $2) RETURNING id;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoTenant.InsertTenant", query)
+	defer span.End()
+
+	var id int64
+	err := conn(ctx, p.db).QueryRowContext(ctx, query, tenant.Name, tenant.CreatedAt).Scan(&id)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return staticResult{lastInsertId: id, rowsAffected: 1}, nil
+}
+
+func queryTenants(ctx context.Context, q querier, query string) ([]models.Tenant, error) {
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tenants := make([]models.Tenant, 0)
+	for rows.Next() {
+		tenant, err := scanTenant(rows)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, rows.Err()
+}
+
+func scanTenant(row rowScanner) (models.Tenant, error) {
+	var tenant models.Tenant
+	err := row.Scan(&tenant.ID, &tenant.Name, &tenant.CreatedAt)
+	return tenant, err
+}
+
+func NewPostgresRepoTenant(db *sql.DB) repository.IRepositoryTenant {
+	return &PostgresRepoTenant{db: db}
+}
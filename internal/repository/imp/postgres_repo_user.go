@@ -1,44 +1,67 @@
 package imp
 
 import (
+	"cmp"
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 )
 
+// userColumns are the columns of the users table in the order scanUser reads
+// them.
+const userColumns = "id, name, email, password_hash, registered_at, email_verified_at, status, attributes"
+
 type PostgresRepoUser struct {
-	db *sql.DB
+	db     *sql.DB
+	tenant tenantScope
 }
 
-func (p PostgresRepoUser) GetUsers() ([]models.User, error) {
-	rows, err := p.db.Query("SELECT * FROM users;")
+func (p PostgresRepoUser) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	where, args := userFilterWhere(filter, p.tenant, postgresUsers)
+	query := "SELECT " + userColumns + " FROM users" + where + ";"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoUser.GetUsers", query)
+	defer span.End()
+
+	rows, err := conn(ctx, p.db).QueryContext(ctx, query, args...)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 	defer rows.Close()
 
 	users := make([]models.User, 0)
 	for rows.Next() {
-		var user models.User
-		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.RegisteredAt)
+		user, err := scanUser(rows)
 		if err != nil {
+			span.RecordError(err)
 			return nil, err
 		}
 		users = append(users, user)
 	}
 	err = rows.Err()
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 	return users, nil
 }
 
-func (p PostgresRepoUser) GetUserById(id int) (models.User, error) {
-	var user models.User
-	err := p.db.QueryRow("SELECT * FROM users WHERE id = $1;", id).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.RegisteredAt)
+func (p PostgresRepoUser) GetUserById(ctx context.Context, id int) (models.User, error) {
+	query, args := p.tenant.and("SELECT "+userColumns+" FROM users WHERE id = $1", []any{id}, dollar)
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoUser.GetUserById", query)
+	defer span.End()
+
+	user, err := scanUser(conn(ctx, p.db).QueryRowContext(ctx, query, args...))
 	if err != nil {
+		span.RecordError(err)
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, sql.ErrNoRows
 		} else {
@@ -48,21 +71,180 @@ func (p PostgresRepoUser) GetUserById(id int) (models.User, error) {
 	return user, nil
 }
 
-func (p PostgresRepoUser) InsertUser(user models.User) (sql.Result, error) {
-	res, err := p.db.Exec("INSERT INTO users (name, email, password, registered_at) VALUES ($1, $2, $3, $4);", user.Name, user.Email, user.Password, time.Now())
-	return res, err
+func (p PostgresRepoUser) InsertUser(ctx context.Context, user models.User) (sql.Result, error) {
+	attributes, err := attributesArg(user.Attributes)
+	columns, values, args := p.tenant.insert("name, email, password_hash, registered_at, email_verified_at, status, attributes", "$1, $2, $3, $4, $5, $6, $7",
+		[]any{user.Name, user.Email, user.PasswordHash, time.Now(), nullTime(user.EmailVerifiedAt), insertStatus(user), attributes}, dollar)
+	query := "INSERT INTO users (" + columns + ") VALUES (" + values + ") RETURNING id;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoUser.InsertUser", query)
+	defer span.End()
+
+	// lib/pq does not support LastInsertId, so the id is returned by the statement.
+	var id int64
+	if err == nil {
+		err = emailTaken(conn(ctx, p.db).QueryRowContext(ctx, query, args...).Scan(&id))
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return staticResult{lastInsertId: id, rowsAffected: 1}, nil
 }
 
-func (p PostgresRepoUser) DeleteUserById(id int) (sql.Result, error) {
-	res, err := p.db.Exec("DELETE FROM users WHERE id = $1;", id)
+func (p PostgresRepoUser) DeleteUserById(ctx context.Context, id int) (sql.Result, error) {
+	query, args := p.tenant.and("DELETE FROM users WHERE id = $1", []any{id}, dollar)
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoUser.DeleteUserById", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
 	return res, err
 }
 
-func (p PostgresRepoUser) UpdateUserById(id int, user models.User) (sql.Result, error) {
-	res, err := p.db.Exec("UPDATE users SET name = $1, email = $2, password = $3, registered_at = $4 WHERE id = $5;", user.Name, user.Email, user.Password, time.Now(), user.ID)
+func (p PostgresRepoUser) UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error) {
+	// A verification only holds for the email it was made for, so it is
+	// dropped when the email changes. Without a status the current one is
+	// kept.
+	query, args := p.tenant.and("UPDATE users SET email_verified_at = CASE WHEN email = $2 THEN $5::timestamp END, name = $1, email = $2, password_hash = $3, status = COALESCE(NULLIF($6, ''), status) WHERE id = $4",
+		[]any{user.Name, user.Email, user.PasswordHash, id, nullTime(user.EmailVerifiedAt), string(user.Status)}, dollar)
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoUser.UpdateUserById", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	err = emailTaken(err)
+	span.RecordError(err)
 	return res, err
 }
 
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+	var verifiedAt sql.NullTime
+	var attributes []byte
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.RegisteredAt, &verifiedAt, &user.Status, &attributes)
+	if err != nil {
+		return user, err
+	}
+	user.EmailVerifiedAt = verifiedAt.Time
+	user.Attributes, err = scanAttributes(attributes)
+	return user, err
+}
+
+// attributesArg encodes attributes for an attributes column.
+func attributesArg(attributes models.Attributes) (string, error) {
+	if len(attributes) == 0 {
+		return "{}", nil
+	}
+	b, err := json.Marshal(attributes)
+	return string(b), err
+}
+
+// scanAttributes decodes an attributes column, nil when there are none.
+func scanAttributes(raw []byte) (models.Attributes, error) {
+	var attributes models.Attributes
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if err := json.Unmarshal(raw, &attributes); err != nil {
+		return nil, fmt.Errorf("invalid attributes: %w", err)
+	}
+	if len(attributes) == 0 {
+		return nil, nil
+	}
+	return attributes, nil
+}
+
+// usersEmailIndex is the unique index on the emails of the users of a
+// tenant, the drivers name it in the errors violating it.
+const usersEmailIndex = "users_tenant_email_idx"
+
+// emailTaken returns err, or the error of a taken email if err violates
+// usersEmailIndex.
+func emailTaken(err error) error {
+	if err != nil && strings.Contains(err.Error(), usersEmailIndex) {
+		return errEmailTaken()
+	}
+	return err
+}
+
+func errEmailTaken() error {
+	return models.ValidationError{{Field: "email", Message: "is already taken"}}
+}
+
+// insertStatus is the status a user is inserted with.
+func insertStatus(user models.User) string {
+	return string(cmp.Or(user.Status, models.StatusPending))
+}
+
+// userDialect is how a backend writes the queries of userFilterWhere.
+type userDialect struct {
+	// placeholder returns the placeholder of the nth argument.
+	placeholder func(n int) string
+	// groups is the name of the groups table as the backend needs it quoted.
+	groups string
+	// attribute returns the condition keeping the users whose attribute key
+	// has the JSON value, arg adds an argument and returns its placeholder.
+	attribute func(key string, value []byte, arg func(any) string) string
+}
+
+var postgresUsers = userDialect{
+	placeholder: dollar,
+	groups:      "groups",
+	attribute: func(key string, value []byte, arg func(any) string) string {
+		doc, _ := json.Marshal(map[string]json.RawMessage{key: value})
+		return "attributes @> " + arg(string(doc)) + "::jsonb"
+	},
+}
+
+// userFilterWhere returns the WHERE clause selecting the users of filter in
+// tenant and its arguments.
+func userFilterWhere(filter models.UserFilter, tenant tenantScope, dialect userDialect) (string, []any) {
+	var conds []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return dialect.placeholder(len(args))
+	}
+	if tenant != 0 {
+		conds = append(conds, "tenant_id = "+arg(int(tenant)))
+	}
+	// Served by the unique index on the tenant and the lowered email.
+	if filter.Email != "" {
+		conds = append(conds, "lower(email) = lower("+arg(filter.Email)+")")
+	}
+	if filter.Status != "" {
+		conds = append(conds, "status = "+arg(string(filter.Status)))
+	}
+	if filter.Role != "" {
+		conds = append(conds, "id IN (SELECT user_roles.user_id FROM user_roles"+
+			" JOIN roles ON roles.id = user_roles.role_id WHERE roles.name = "+arg(filter.Role)+")")
+	}
+	if filter.Group != "" {
+		groups := dialect.groups
+		conds = append(conds, "id IN (SELECT group_members.user_id FROM group_members"+
+			" JOIN "+groups+" ON "+groups+".id = group_members.group_id WHERE "+groups+".name = "+arg(filter.Group)+")")
+	}
+	// The keys are sorted to keep the query the same for the same filter.
+	for _, key := range slices.Sorted(maps.Keys(filter.Attributes)) {
+		value, err := json.Marshal(filter.Attributes[key])
+		if err != nil {
+			// A value that cannot be stored matches no user.
+			conds = append(conds, "1 = 0")
+			continue
+		}
+		conds = append(conds, dialect.attribute(key, value, arg))
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
 func NewPostgresRepoUser(db *sql.DB) repository.IRepositoryUser {
 	return &PostgresRepoUser{db: db}
 }
+
+// NewPostgresRepoUserForTenant returns the users of the tenant with
+// tenantID, the others can neither be read nor changed through it.
+func NewPostgresRepoUserForTenant(db *sql.DB, tenantID int) repository.IRepositoryUser {
+	return &PostgresRepoUser{db: db, tenant: tenantScope(tenantID)}
+}
@@ -0,0 +1,68 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+type PostgresRepoUserAttribute struct {
+	db     *sql.DB
+	tenant tenantScope
+}
+
+func (p PostgresRepoUserAttribute) GetAttributes(ctx context.Context, userID int) (models.Attributes, error) {
+	query, args := p.tenant.and("SELECT attributes FROM users WHERE id = $1", []any{userID}, dollar)
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoUserAttribute.GetAttributes", query)
+	defer span.End()
+
+	attributes, err := queryAttributes(ctx, conn(ctx, p.db), query, args...)
+	span.RecordError(err)
+	return attributes, err
+}
+
+func (p PostgresRepoUserAttribute) SetAttribute(ctx context.Context, userID int, key string, value any) (sql.Result, error) {
+	b, err := json.Marshal(value)
+	query, args := p.tenant.and("UPDATE users SET attributes = attributes || jsonb_build_object($2::text, $3::jsonb) WHERE id = $1", []any{userID, key, string(b)}, dollar)
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoUserAttribute.SetAttribute", query)
+	defer span.End()
+
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p PostgresRepoUserAttribute) UnsetAttribute(ctx context.Context, userID int, key string) (sql.Result, error) {
+	query, args := p.tenant.and("UPDATE users SET attributes = attributes - $2::text WHERE id = $1", []any{userID, key}, dollar)
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoUserAttribute.UnsetAttribute", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+// queryAttributes reads the attributes column of the single row of query.
+func queryAttributes(ctx context.Context, q querier, query string, args ...any) (models.Attributes, error) {
+	var raw []byte
+	if err := q.QueryRowContext(ctx, query, args...).Scan(&raw); err != nil {
+		return nil, err
+	}
+	return scanAttributes(raw)
+}
+
+func NewPostgresRepoUserAttribute(db *sql.DB) repository.IRepositoryUserAttribute {
+	return &PostgresRepoUserAttribute{db: db}
+}
+
+// NewPostgresRepoUserAttributeForTenant returns the attributes of the users
+// of the tenant with tenantID.
+func NewPostgresRepoUserAttributeForTenant(db *sql.DB, tenantID int) repository.IRepositoryUserAttribute {
+	return &PostgresRepoUserAttribute{db: db, tenant: tenantScope(tenantID)}
+}
@@ -0,0 +1,44 @@
+package imp
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"regexp"
+	"testing"
+)
+
+func TestPostgresRepoUserAttribute_SetAndUnset(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepoUserAttribute(db)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET attributes = attributes || jsonb_build_object($2::text, $3::jsonb) WHERE id = $1;")).
+		WithArgs(7, "tags", `["a","b"]`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET attributes = attributes - $2::text WHERE id = $1;")).
+		WithArgs(7, "locale").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT attributes FROM users WHERE id = $1;")).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"attributes"}).AddRow(`{"tags": ["a", "b"], "age": 30}`))
+
+	if _, err := repo.SetAttribute(context.Background(), 7, "tags", []string{"a", "b"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when setting an attribute", err)
+	}
+	if _, err := repo.UnsetAttribute(context.Background(), 7, "locale"); err != nil {
+		t.Fatalf("an error '%s' was not expected when unsetting an attribute", err)
+	}
+	attributes, err := repo.GetAttributes(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting attributes", err)
+	}
+	if attributes.String() != `{"age":30,"tags":["a","b"]}` {
+		t.Fatalf("unexpected attributes %s", attributes)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
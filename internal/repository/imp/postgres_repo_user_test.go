@@ -1,6 +1,7 @@
 package imp
 
 import (
+	"context"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
 	"github.com/DATA-DOG/go-sqlmock"
 	"regexp"
@@ -12,8 +13,10 @@ func userEquals(this models.User, other models.User) bool {
 	return this.Email == other.Email &&
 		this.ID == other.ID &&
 		this.Name == other.Name &&
-		this.Password == other.Password &&
-		this.RegisteredAt == other.RegisteredAt
+		this.PasswordHash == other.PasswordHash &&
+		this.RegisteredAt == other.RegisteredAt &&
+		this.EmailVerifiedAt.Equal(other.EmailVerifiedAt) &&
+		this.Status == other.Status
 }
 
 // GetById
@@ -30,18 +33,19 @@ func TestPostgresRepoUser_GetUsers(t *testing.T) {
 		ID:           1,
 		Name:         "John Doe",
 		Email:        "john@example.com",
-		Password:     "dasfsa",
+		PasswordHash: "dasfsa",
 		RegisteredAt: time.Now(),
+		Status:       models.StatusActive,
 	}
 
-	rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "registered_at"}).
-		AddRow(expectedUser.ID, expectedUser.Name, expectedUser.Email, expectedUser.Password, expectedUser.RegisteredAt)
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes"}).
+		AddRow(expectedUser.ID, expectedUser.Name, expectedUser.Email, expectedUser.PasswordHash, expectedUser.RegisteredAt, nil, "active", "{}")
 
-	mock.ExpectQuery(`SELECT \* FROM users WHERE id = \$1`).
+	mock.ExpectQuery(`SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE id = \$1`).
 		WithArgs(1).
 		WillReturnRows(rows)
 
-	user, err := repo.GetUserById(1)
+	user, err := repo.GetUserById(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when getting user by id", err)
 	}
@@ -67,28 +71,32 @@ func TestPostgresRepoUser_GetUsers2(t *testing.T) {
 			ID:           1,
 			Name:         "John Doe",
 			Email:        "john@example.com",
-			Password:     "dasfsa",
+			PasswordHash: "dasfsa",
 			RegisteredAt: time.Now(),
+			Status:       models.StatusPending,
 		},
 		models.User{
 			ID:           2,
 			Name:         "Name",
 			Email:        "mail@example.com",
-			Password:     "dasfsa",
+			PasswordHash: "dasfsa",
 			RegisteredAt: time.Now(),
+			// Verified users are read with the time of the verification.
+			EmailVerifiedAt: time.Now(),
+			Status:          models.StatusActive,
 		},
 	}
 
 	repo := NewPostgresRepoUser(db)
 
-	rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "registered_at"}).
-		AddRow(expectedUsers[0].ID, expectedUsers[0].Name, expectedUsers[0].Email, expectedUsers[0].Password, expectedUsers[0].RegisteredAt).
-		AddRow(expectedUsers[1].ID, expectedUsers[1].Name, expectedUsers[1].Email, expectedUsers[1].Password, expectedUsers[1].RegisteredAt)
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes"}).
+		AddRow(expectedUsers[0].ID, expectedUsers[0].Name, expectedUsers[0].Email, expectedUsers[0].PasswordHash, expectedUsers[0].RegisteredAt, nil, "pending", "{}").
+		AddRow(expectedUsers[1].ID, expectedUsers[1].Name, expectedUsers[1].Email, expectedUsers[1].PasswordHash, expectedUsers[1].RegisteredAt, expectedUsers[1].EmailVerifiedAt, "active", "{}")
 
-	mock.ExpectQuery(`SELECT \* FROM users;`).
+	mock.ExpectQuery(`SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users;`).
 		WillReturnRows(rows)
 
-	users, err := repo.GetUsers()
+	users, err := repo.GetUsers(context.Background(), models.UserFilter{})
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when getting users", err)
 	}
@@ -105,6 +113,90 @@ func TestPostgresRepoUser_GetUsers2(t *testing.T) {
 	}
 }
 
+// GetAll with a filter
+func TestPostgresRepoUser_GetUsersByStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepoUser(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE status = $1;")).
+		WithArgs("suspended").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes"}).
+			AddRow(3, "Eve", "eve@example.com", "pw", time.Now(), nil, "suspended", "{}"))
+
+	users, err := repo.GetUsers(context.Background(), models.UserFilter{Status: models.StatusSuspended})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting users", err)
+	}
+	if len(users) != 1 || users[0].Status != models.StatusSuspended {
+		t.Fatalf("got %+v, expected the suspended user", users)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// GetAll by role and group
+func TestPostgresRepoUser_GetUsersByRoleAndGroup(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepoUser(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users WHERE status = $1"+
+		" AND id IN (SELECT user_roles.user_id FROM user_roles JOIN roles ON roles.id = user_roles.role_id WHERE roles.name = $2)"+
+		" AND id IN (SELECT group_members.user_id FROM group_members JOIN groups ON groups.id = group_members.group_id WHERE groups.name = $3);")).
+		WithArgs("active", "admin", "ops").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes"}).
+			AddRow(3, "Eve", "eve@example.com", "pw", time.Now(), nil, "active", "{}"))
+
+	users, err := repo.GetUsers(context.Background(), models.UserFilter{Status: models.StatusActive, Role: "admin", Group: "ops"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting users", err)
+	}
+	if len(users) != 1 || users[0].ID != 3 {
+		t.Fatalf("got %+v, expected the admin of ops", users)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// GetAll by attributes
+func TestPostgresRepoUser_GetUsersByAttributes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepoUser(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, name, email, password_hash, registered_at, email_verified_at, status, attributes FROM users"+
+		" WHERE attributes @> $1::jsonb AND attributes @> $2::jsonb;")).
+		WithArgs(`{"age":30}`, `{"department":"ops"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "registered_at", "email_verified_at", "status", "attributes"}).
+			AddRow(3, "Eve", "eve@example.com", "pw", time.Now(), nil, "active", `{"age": 30, "department": "ops"}`))
+
+	users, err := repo.GetUsers(context.Background(), models.UserFilter{Attributes: models.Attributes{"department": "ops", "age": 30}})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting users", err)
+	}
+	if len(users) != 1 || users[0].Attributes["department"] != "ops" {
+		t.Fatalf("got %+v, expected the user of ops", users)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 // InsertUser
 func TestPostgresRepoUser_CreateUser(t *testing.T) {
 	db, mock, err := sqlmock.New()
@@ -119,17 +211,18 @@ func TestPostgresRepoUser_CreateUser(t *testing.T) {
 		ID:           1,
 		Name:         "John Doe",
 		Email:        "john@example.com",
-		Password:     "dasfsa",
+		PasswordHash: "dasfsa",
 		RegisteredAt: time.Now(),
 	}
 
-	mock.ExpectExec(regexp.QuoteMeta(
-		"INSERT INTO users (name, email, password, registered_at) VALUES ($1, $2, $3, $4);",
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"INSERT INTO users (name, email, password_hash, registered_at, email_verified_at, status, attributes) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;",
 	)).
-		WithArgs(expectedUser.Name, expectedUser.Email, expectedUser.Password, sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		// Users inserted without a status are pending.
+		WithArgs(expectedUser.Name, expectedUser.Email, expectedUser.PasswordHash, sqlmock.AnyArg(), nil, "pending", "{}").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
-	res, err := repo.InsertUser(*expectedUser)
+	res, err := repo.InsertUser(context.Background(), *expectedUser)
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when inserting user", err)
 	}
@@ -159,18 +252,20 @@ func TestPostgresRepoUser_UpdateUser(t *testing.T) {
 		ID:           1,
 		Name:         "Alice",
 		Email:        "alice@example.com",
-		Password:     "securepassword",
+		PasswordHash: "securepassword",
 		RegisteredAt: time.Now(),
 	}
 
 	mock.ExpectExec(regexp.QuoteMeta(`
-		UPDATE users 
-		SET name = $1, email = $2, password = $3, registered_at = $4
-		WHERE id = $5
-	`)).WithArgs(user.Name, user.Email, user.Password, sqlmock.AnyArg(), user.ID).
+		UPDATE users
+		SET email_verified_at = CASE WHEN email = $2 THEN $5::timestamp END, name = $1, email = $2, password_hash = $3,
+			status = COALESCE(NULLIF($6, ''), status)
+		WHERE id = $4
+	`)).WithArgs(user.Name, user.Email, user.PasswordHash, 2, nil, "").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	result, err := repo.UpdateUserById(1, user)
+	// The id argument selects the row, not the ID carried by user.
+	result, err := repo.UpdateUserById(context.Background(), 2, user)
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when updating user", err)
 	}
@@ -200,7 +295,7 @@ func TestPostgresRepoUser_DeleteUserById(t *testing.T) {
 	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM users WHERE id = $1;")).
 		WithArgs(id).WillReturnResult(sqlmock.NewResult(1, 1))
 
-	result, err := repo.DeleteUserById(id)
+	result, err := repo.DeleteUserById(context.Background(), id)
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when deleting user", err)
 	}
@@ -0,0 +1,95 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"time"
+)
+
+// userTokenTables are the tables of the token purposes, queries only ever
+// name tables from here.
+var userTokenTables = map[models.TokenPurpose]string{
+	models.PurposeVerifyEmail:   "email_verification_tokens",
+	models.PurposeResetPassword: "password_reset_tokens",
+}
+
+func userTokenTable(purpose models.TokenPurpose) (string, error) {
+	table, ok := userTokenTables[purpose]
+	if !ok {
+		return "", fmt.Errorf("unknown token purpose %q", purpose)
+	}
+	return table, nil
+}
+
+type PostgresRepoUserToken struct {
+	db     *sql.DB
+	tenant tenantScope
+}
+
+func (p PostgresRepoUserToken) InsertUserToken(ctx context.Context, token models.UserToken) (sql.Result, error) {
+	table, err := userTokenTable(token.Purpose)
+	if err != nil {
+		return nil, err
+	}
+	columns, values, args := p.tenant.insert("token_hash, user_id, email, created_at, expires_at", "$1, $2, $3, $4, $5",
+		[]any{token.Hash, token.UserID, token.Email, token.CreatedAt.UTC(), token.ExpiresAt.UTC()}, dollar)
+	query := "INSERT INTO " + table + " (" + columns + ") VALUES (" + values + ");"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoUserToken.InsertUserToken", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p PostgresRepoUserToken) ConsumeUserToken(ctx context.Context, purpose models.TokenPurpose, hash string, now time.Time) (models.UserToken, error) {
+	table, err := userTokenTable(purpose)
+	if err != nil {
+		return models.UserToken{}, err
+	}
+	condition, args := p.tenant.condition([]any{now.UTC(), hash}, dollar)
+	query := "UPDATE " + table + " SET used_at = $1 WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $1" + condition +
+		" RETURNING token_hash, user_id, email, created_at, expires_at, used_at;"
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoUserToken.ConsumeUserToken", query)
+	defer span.End()
+
+	token, err := scanUserToken(conn(ctx, p.db).QueryRowContext(ctx, query, args...))
+	token.Purpose = purpose
+	span.RecordError(err)
+	return token, err
+}
+
+func (p PostgresRepoUserToken) DeleteUserTokens(ctx context.Context, purpose models.TokenPurpose, userID int) (sql.Result, error) {
+	table, err := userTokenTable(purpose)
+	if err != nil {
+		return nil, err
+	}
+	query, args := p.tenant.and("DELETE FROM "+table+" WHERE user_id = $1", []any{userID}, dollar)
+	ctx, span := startQuerySpan(ctx, "postgresql", "PostgresRepoUserToken.DeleteUserTokens", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+func scanUserToken(row rowScanner) (models.UserToken, error) {
+	var token models.UserToken
+	var usedAt sql.NullTime
+	err := row.Scan(&token.Hash, &token.UserID, &token.Email, &token.CreatedAt, &token.ExpiresAt, &usedAt)
+	token.UsedAt = usedAt.Time
+	return token, err
+}
+
+func NewPostgresRepoUserToken(db *sql.DB) repository.IRepositoryUserToken {
+	return &PostgresRepoUserToken{db: db}
+}
+
+// NewPostgresRepoUserTokenForTenant returns the tokens of the users of the
+// tenant with tenantID.
+func NewPostgresRepoUserTokenForTenant(db *sql.DB, tenantID int) repository.IRepositoryUserToken {
+	return &PostgresRepoUserToken{db: db, tenant: tenantScope(tenantID)}
+}
@@ -0,0 +1,63 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/DATA-DOG/go-sqlmock"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestPostgresRepoUserToken_ConsumeUserToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepoUserToken(db)
+	now := time.Now()
+	query := regexp.QuoteMeta("UPDATE password_reset_tokens SET used_at = $1 WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $1" +
+		" RETURNING token_hash, user_id, email, created_at, expires_at, used_at;")
+
+	mock.ExpectQuery(query).
+		WithArgs(now.UTC(), "h1").
+		WillReturnRows(sqlmock.NewRows([]string{"token_hash", "user_id", "email", "created_at", "expires_at", "used_at"}).
+			AddRow("h1", 7, "ann@example.com", now.Add(-time.Minute), now.Add(time.Hour), now))
+	mock.ExpectQuery(query).
+		WithArgs(now.UTC(), "h1").
+		WillReturnRows(sqlmock.NewRows([]string{"token_hash", "user_id", "email", "created_at", "expires_at", "used_at"}))
+
+	token, err := repo.ConsumeUserToken(context.Background(), models.PurposeResetPassword, "h1", now)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when consuming token", err)
+	}
+	if token.Purpose != models.PurposeResetPassword || token.UserID != 7 || !token.UsedAt.Equal(now) {
+		t.Fatalf("unexpected token %+v", token)
+	}
+	if _, err := repo.ConsumeUserToken(context.Background(), models.PurposeResetPassword, "h1", now); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("got '%v', expected sql.ErrNoRows for a used token", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepoUserToken_UnknownPurpose(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepoUserToken(db)
+	if _, err := repo.DeleteUserTokens(context.Background(), "users; --", 1); err == nil {
+		t.Fatalf("expected an error for an unknown token purpose")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,12 @@
+package imp
+
+import (
+	"context"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/tracing"
+)
+
+func startQuerySpan(ctx context.Context, system string, name string, query string) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, name,
+		tracing.String("db.system", system),
+		tracing.String("db.statement", query))
+}
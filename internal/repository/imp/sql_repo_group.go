@@ -0,0 +1,104 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+// SqlRepoGroup is the groups counterpart of SqlRepoUser. The memberships of
+// deleted users are deleted by a trigger. GROUPS is a reserved word in
+// MySQL, so the table is always quoted.
+type SqlRepoGroup struct {
+	db     *sql.DB
+	system string
+}
+
+func (p SqlRepoGroup) GetGroups(ctx context.Context) ([]models.Group, error) {
+	const query = "SELECT id, name, description, created_at FROM `groups` ORDER BY name;"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoGroup.GetGroups", query)
+	defer span.End()
+
+	groups, err := queryGroups(ctx, conn(ctx, p.db), query)
+	span.RecordError(err)
+	return groups, err
+}
+
+func (p SqlRepoGroup) GetGroupByName(ctx context.Context, name string) (models.Group, error) {
+	const query = "SELECT id, name, description, created_at FROM `groups` WHERE name = ?;"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoGroup.GetGroupByName", query)
+	defer span.End()
+
+	group, err := scanGroup(conn(ctx, p.db).QueryRowContext(ctx, query, name))
+	span.RecordError(err)
+	return group, err
+}
+
+func (p SqlRepoGroup) InsertGroup(ctx context.Context, group models.Group) (sql.Result, error) {
+	const query = "INSERT INTO `groups` (name, description, created_at) VALUES (?, ?, ?);"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoGroup.InsertGroup", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, group.Name, group.Description, group.CreatedAt)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoGroup) DeleteGroupById(ctx context.Context, id int) (sql.Result, error) {
+	const (
+		deleteMembers = "DELETE FROM group_members WHERE group_id = ?;"
+		deleteGroup   = "DELETE FROM `groups` WHERE id = ?;"
+	)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoGroup.DeleteGroupById", deleteGroup)
+	defer span.End()
+
+	// Like the API tokens of operators, the memberships go first.
+	q := conn(ctx, p.db)
+	if _, err := q.ExecContext(ctx, deleteMembers, id); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	res, err := q.ExecContext(ctx, deleteGroup, id)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoGroup) AddGroupMember(ctx context.Context, groupID, userID int) (sql.Result, error) {
+	const query = "REPLACE INTO group_members (user_id, group_id) VALUES (?, ?);"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoGroup.AddGroupMember", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, userID, groupID)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoGroup) RemoveGroupMember(ctx context.Context, groupID, userID int) (sql.Result, error) {
+	const query = "DELETE FROM group_members WHERE user_id = ? AND group_id = ?;"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoGroup.RemoveGroupMember", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, userID, groupID)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoGroup) GetUserGroups(ctx context.Context, userID int) ([]models.Group, error) {
+	const query = "SELECT `groups`.id, `groups`.name, `groups`.description, `groups`.created_at FROM `groups`" +
+		" JOIN group_members ON group_members.group_id = `groups`.id WHERE group_members.user_id = ? ORDER BY `groups`.name;"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoGroup.GetUserGroups", query)
+	defer span.End()
+
+	groups, err := queryGroups(ctx, conn(ctx, p.db), query, userID)
+	span.RecordError(err)
+	return groups, err
+}
+
+func NewMysqlRepoGroup(db *sql.DB) repository.IRepositoryGroup {
+	return &SqlRepoGroup{db: db, system: "mysql"}
+}
+
+func NewSqliteRepoGroup(db *sql.DB) repository.IRepositoryGroup {
+	return &SqlRepoGroup{db: db, system: "sqlite"}
+}
@@ -0,0 +1,86 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+// SqlRepoLog is the logs counterpart of SqlRepoUser.
+type SqlRepoLog struct {
+	db     *sql.DB
+	system string
+	tenant tenantScope
+}
+
+func (p SqlRepoLog) GetLogs(ctx context.Context) ([]models.Log, error) {
+	query, args := p.tenant.where("SELECT id, log_time, log_message, details, actor FROM logs", nil, question)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoLog.GetLogs", query)
+	defer span.End()
+
+	rows, err := conn(ctx, p.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+	logs := make([]models.Log, 0)
+	for rows.Next() {
+		log, err := scanLog(rows)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+	err = rows.Err()
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (p SqlRepoLog) GetLogById(ctx context.Context, id int) (models.Log, error) {
+	query, args := p.tenant.and("SELECT id, log_time, log_message, details, actor FROM logs WHERE id = ?", []any{id}, question)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoLog.GetLogById", query)
+	defer span.End()
+
+	log, err := scanLog(conn(ctx, p.db).QueryRowContext(ctx, query, args...))
+	if err != nil {
+		span.RecordError(err)
+		return models.Log{}, err
+	}
+	return log, nil
+}
+
+func (p SqlRepoLog) InsertLog(ctx context.Context, user models.Log) (sql.Result, error) {
+	columns, values, args := p.tenant.insert("log_time, log_message, details, actor", "?, ?, ?, ?",
+		[]any{user.LogTime, user.LogMessage, nullString(user.Details), nullString(user.Actor)}, question)
+	query := "INSERT INTO logs (" + columns + ") VALUES (" + values + ");"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoLog.InsertLog", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+func NewMysqlRepoLog(db *sql.DB) repository.IRepositoryLog {
+	return &SqlRepoLog{db: db, system: "mysql"}
+}
+
+func NewSqliteRepoLog(db *sql.DB) repository.IRepositoryLog {
+	return &SqlRepoLog{db: db, system: "sqlite"}
+}
+
+// NewMysqlRepoLogForTenant is NewPostgresRepoLogForTenant for MySQL.
+func NewMysqlRepoLogForTenant(db *sql.DB, tenantID int) repository.IRepositoryLog {
+	return &SqlRepoLog{db: db, system: "mysql", tenant: tenantScope(tenantID)}
+}
+
+// NewSqliteRepoLogForTenant is NewPostgresRepoLogForTenant for SQLite.
+func NewSqliteRepoLogForTenant(db *sql.DB, tenantID int) repository.IRepositoryLog {
+	return &SqlRepoLog{db: db, system: "sqlite", tenant: tenantScope(tenantID)}
+}
@@ -0,0 +1,32 @@
+package imp
+
+import (
+	"context"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"testing"
+	"time"
+)
+
+func TestSqliteRepoLog_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSqliteRepoLog(openSqlite(t))
+
+	res, err := repo.InsertLog(ctx, models.Log{LogTime: time.Now(), LogMessage: "user created"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting log", err)
+	}
+	id, _ := res.LastInsertId()
+
+	log, err := repo.GetLogById(ctx, int(id))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting log by id", err)
+	}
+	if log.Id != id || log.LogMessage != "user created" || log.LogTime.IsZero() {
+		t.Fatalf("unexpected log %+v", log)
+	}
+
+	logs, err := repo.GetLogs(ctx)
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("got %d logs and error '%v', expected 1 log", len(logs), err)
+	}
+}
@@ -0,0 +1,129 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"time"
+)
+
+// SqlRepoLogin is the login counterpart of SqlRepoUser. REPLACE is the
+// upsert MySQL and SQLite have in common.
+type SqlRepoLogin struct {
+	db     *sql.DB
+	system string
+	tenant tenantScope
+	// countFailure ends the INSERT counting a failure with the upsert, which
+	// MySQL and SQLite spell differently.
+	countFailure string
+}
+
+func (p SqlRepoLogin) GetLockout(ctx context.Context, email string) (models.Lockout, error) {
+	query, args := p.tenant.and("SELECT email, failures, locked_until, failed_at FROM login_lockouts WHERE email = ?", []any{email}, question)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoLogin.GetLockout", query)
+	defer span.End()
+
+	lockout, err := scanLockout(conn(ctx, p.db).QueryRowContext(ctx, query, args...))
+	span.RecordError(err)
+	return lockout, err
+}
+
+// AddFailure reads the failures back after counting one, which may then
+// include concurrent failures as well.
+func (p SqlRepoLogin) AddFailure(ctx context.Context, email string, at, since time.Time) (int, error) {
+	purge, purgeArgs := p.tenant.and("DELETE FROM login_lockouts WHERE failed_at < ? AND (locked_until IS NULL OR locked_until < ?)",
+		[]any{since.UTC(), at.UTC()}, question)
+	columns, values, args := p.tenant.insert("email, failures, failed_at", "?, 1, ?", []any{email, at.UTC()}, question)
+	count := "INSERT INTO login_lockouts (" + columns + ") VALUES (" + values + ")" + p.countFailure
+	read, readArgs := p.tenant.and("SELECT failures FROM login_lockouts WHERE email = ?", []any{email}, question)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoLogin.AddFailure", count)
+	defer span.End()
+
+	q := conn(ctx, p.db)
+	if _, err := q.ExecContext(ctx, purge, purgeArgs...); err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	if _, err := q.ExecContext(ctx, count, append(args, since.UTC(), at.UTC())...); err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	var failures int
+	err := q.QueryRowContext(ctx, read, readArgs...).Scan(&failures)
+	span.RecordError(err)
+	return failures, err
+}
+
+func (p SqlRepoLogin) LockEmail(ctx context.Context, email string, until time.Time) (sql.Result, error) {
+	query, args := p.tenant.and("UPDATE login_lockouts SET failures = 0, locked_until = ? WHERE email = ?", []any{until.UTC(), email}, question)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoLogin.LockEmail", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoLogin) DeleteLockout(ctx context.Context, email string) (sql.Result, error) {
+	query, args := p.tenant.and("DELETE FROM login_lockouts WHERE email = ?", []any{email}, question)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoLogin.DeleteLockout", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoLogin) RevokeSession(ctx context.Context, id string, expiresAt time.Time) (sql.Result, error) {
+	purge, purgeArgs := p.tenant.and("DELETE FROM revoked_sessions WHERE expires_at < ?", []any{time.Now().UTC()}, question)
+	columns, values, args := p.tenant.insert("id, expires_at", "?, ?", []any{id, expiresAt.UTC()}, question)
+	revoke := "REPLACE INTO revoked_sessions (" + columns + ") VALUES (" + values + ");"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoLogin.RevokeSession", revoke)
+	defer span.End()
+
+	q := conn(ctx, p.db)
+	if _, err := q.ExecContext(ctx, purge, purgeArgs...); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	res, err := q.ExecContext(ctx, revoke, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoLogin) IsSessionRevoked(ctx context.Context, id string) (bool, error) {
+	query, args := p.tenant.and("SELECT COUNT(*) FROM revoked_sessions WHERE id = ?", []any{id}, question)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoLogin.IsSessionRevoked", query)
+	defer span.End()
+
+	var count int
+	err := conn(ctx, p.db).QueryRowContext(ctx, query, args...).Scan(&count)
+	span.RecordError(err)
+	return count > 0, err
+}
+
+// MySQL evaluates the assignments in order, failures is compared with the
+// previous failed_at.
+const (
+	mysqlCountFailure  = " ON DUPLICATE KEY UPDATE failures = IF(failed_at < ?, 1, failures + 1), failed_at = ?;"
+	sqliteCountFailure = " ON CONFLICT (tenant_id, email) DO UPDATE SET failures = CASE WHEN failed_at < ? THEN 1 ELSE failures + 1 END, failed_at = ?;"
+)
+
+func NewMysqlRepoLogin(db *sql.DB) repository.IRepositoryLogin {
+	return &SqlRepoLogin{db: db, system: "mysql", countFailure: mysqlCountFailure}
+}
+
+func NewSqliteRepoLogin(db *sql.DB) repository.IRepositoryLogin {
+	return &SqlRepoLogin{db: db, system: "sqlite", countFailure: sqliteCountFailure}
+}
+
+// NewMysqlRepoLoginForTenant is NewPostgresRepoLoginForTenant for MySQL.
+func NewMysqlRepoLoginForTenant(db *sql.DB, tenantID int) repository.IRepositoryLogin {
+	return &SqlRepoLogin{db: db, system: "mysql", tenant: tenantScope(tenantID), countFailure: mysqlCountFailure}
+}
+
+// NewSqliteRepoLoginForTenant is NewPostgresRepoLoginForTenant for SQLite.
+func NewSqliteRepoLoginForTenant(db *sql.DB, tenantID int) repository.IRepositoryLogin {
+	return &SqlRepoLogin{db: db, system: "sqlite", tenant: tenantScope(tenantID), countFailure: sqliteCountFailure}
+}
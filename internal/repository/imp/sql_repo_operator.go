@@ -0,0 +1,105 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+// SqlRepoOperator is the operators counterpart of SqlRepoUser.
+type SqlRepoOperator struct {
+	db     *sql.DB
+	system string
+}
+
+func (p SqlRepoOperator) GetOperators(ctx context.Context) ([]models.Operator, error) {
+	const query = "SELECT id, name, role, password_hash, created_at FROM operators ORDER BY id;"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoOperator.GetOperators", query)
+	defer span.End()
+
+	operators, err := queryOperators(ctx, conn(ctx, p.db), query)
+	span.RecordError(err)
+	return operators, err
+}
+
+func (p SqlRepoOperator) GetOperatorByName(ctx context.Context, name string) (models.Operator, error) {
+	const query = "SELECT id, name, role, password_hash, created_at FROM operators WHERE name = ?;"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoOperator.GetOperatorByName", query)
+	defer span.End()
+
+	operator, err := scanOperator(conn(ctx, p.db).QueryRowContext(ctx, query, name))
+	span.RecordError(err)
+	return operator, err
+}
+
+func (p SqlRepoOperator) InsertOperator(ctx context.Context, operator models.Operator) (sql.Result, error) {
+	const query = "INSERT INTO operators (name, role, password_hash, created_at) VALUES (?, ?, ?, ?);"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoOperator.InsertOperator", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, operator.Name, operator.Role,
+		nullString(operator.PasswordHash), operator.CreatedAt)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoOperator) UpdateOperatorById(ctx context.Context, id int, operator models.Operator) (sql.Result, error) {
+	const query = "UPDATE operators SET name = ?, role = ?, password_hash = ? WHERE id = ?;"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoOperator.UpdateOperatorById", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, operator.Name, operator.Role, nullString(operator.PasswordHash), id)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoOperator) DeleteOperatorById(ctx context.Context, id int) (sql.Result, error) {
+	const (
+		deleteTokens   = "DELETE FROM api_tokens WHERE operator_id = ?;"
+		deleteOperator = "DELETE FROM operators WHERE id = ?;"
+	)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoOperator.DeleteOperatorById", deleteOperator)
+	defer span.End()
+
+	// SQLite only enforces foreign keys when asked to, so the tokens are
+	// deleted first. Should the second statement fail, the operator remains
+	// without tokens, which is the safe side.
+	q := conn(ctx, p.db)
+	if _, err := q.ExecContext(ctx, deleteTokens, id); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	res, err := q.ExecContext(ctx, deleteOperator, id)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoOperator) InsertAPIToken(ctx context.Context, token models.APIToken) (sql.Result, error) {
+	const query = "INSERT INTO api_tokens (operator_id, name, token_hash, created_at) VALUES (?, ?, ?, ?);"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoOperator.InsertAPIToken", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, token.OperatorID, token.Name, token.Hash, token.CreatedAt)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoOperator) GetOperatorByTokenHash(ctx context.Context, hash string) (models.Operator, error) {
+	const query = "SELECT o.id, o.name, o.role, o.password_hash, o.created_at FROM operators o " +
+		"JOIN api_tokens t ON t.operator_id = o.id WHERE t.token_hash = ?;"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoOperator.GetOperatorByTokenHash", query)
+	defer span.End()
+
+	operator, err := scanOperator(conn(ctx, p.db).QueryRowContext(ctx, query, hash))
+	span.RecordError(err)
+	return operator, err
+}
+
+func NewMysqlRepoOperator(db *sql.DB) repository.IRepositoryOperator {
+	return &SqlRepoOperator{db: db, system: "mysql"}
+}
+
+func NewSqliteRepoOperator(db *sql.DB) repository.IRepositoryOperator {
+	return &SqlRepoOperator{db: db, system: "sqlite"}
+}
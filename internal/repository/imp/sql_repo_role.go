@@ -0,0 +1,103 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+// SqlRepoRole is the roles counterpart of SqlRepoUser. The assignments of
+// deleted users are deleted by a trigger.
+type SqlRepoRole struct {
+	db     *sql.DB
+	system string
+}
+
+func (p SqlRepoRole) GetRoles(ctx context.Context) ([]models.UserRole, error) {
+	const query = "SELECT id, name, description, created_at FROM roles ORDER BY name;"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoRole.GetRoles", query)
+	defer span.End()
+
+	roles, err := queryRoles(ctx, conn(ctx, p.db), query)
+	span.RecordError(err)
+	return roles, err
+}
+
+func (p SqlRepoRole) GetRoleByName(ctx context.Context, name string) (models.UserRole, error) {
+	const query = "SELECT id, name, description, created_at FROM roles WHERE name = ?;"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoRole.GetRoleByName", query)
+	defer span.End()
+
+	role, err := scanRole(conn(ctx, p.db).QueryRowContext(ctx, query, name))
+	span.RecordError(err)
+	return role, err
+}
+
+func (p SqlRepoRole) InsertRole(ctx context.Context, role models.UserRole) (sql.Result, error) {
+	const query = "INSERT INTO roles (name, description, created_at) VALUES (?, ?, ?);"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoRole.InsertRole", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, role.Name, role.Description, role.CreatedAt)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoRole) DeleteRoleById(ctx context.Context, id int) (sql.Result, error) {
+	const (
+		deleteAssignments = "DELETE FROM user_roles WHERE role_id = ?;"
+		deleteRole        = "DELETE FROM roles WHERE id = ?;"
+	)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoRole.DeleteRoleById", deleteRole)
+	defer span.End()
+
+	// Like the API tokens of operators, the assignments go first.
+	q := conn(ctx, p.db)
+	if _, err := q.ExecContext(ctx, deleteAssignments, id); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	res, err := q.ExecContext(ctx, deleteRole, id)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoRole) AssignRole(ctx context.Context, roleID, userID int) (sql.Result, error) {
+	const query = "REPLACE INTO user_roles (user_id, role_id) VALUES (?, ?);"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoRole.AssignRole", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, userID, roleID)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoRole) RevokeRole(ctx context.Context, roleID, userID int) (sql.Result, error) {
+	const query = "DELETE FROM user_roles WHERE user_id = ? AND role_id = ?;"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoRole.RevokeRole", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, userID, roleID)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoRole) GetUserRoles(ctx context.Context, userID int) ([]models.UserRole, error) {
+	const query = "SELECT roles.id, roles.name, roles.description, roles.created_at FROM roles" +
+		" JOIN user_roles ON user_roles.role_id = roles.id WHERE user_roles.user_id = ? ORDER BY roles.name;"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoRole.GetUserRoles", query)
+	defer span.End()
+
+	roles, err := queryRoles(ctx, conn(ctx, p.db), query, userID)
+	span.RecordError(err)
+	return roles, err
+}
+
+func NewMysqlRepoRole(db *sql.DB) repository.IRepositoryRole {
+	return &SqlRepoRole{db: db, system: "mysql"}
+}
+
+func NewSqliteRepoRole(db *sql.DB) repository.IRepositoryRole {
+	return &SqlRepoRole{db: db, system: "sqlite"}
+}
@@ -0,0 +1,52 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+// SqlRepoTenant is the tenants counterpart of SqlRepoUser.
+type SqlRepoTenant struct {
+	db     *sql.DB
+	system string
+}
+
+func (p SqlRepoTenant) GetTenants(ctx context.Context) ([]models.Tenant, error) {
+	const query = "SELECT id, name, created_at FROM tenants ORDER BY name;"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoTenant.GetTenants", query)
+	defer span.End()
+
+	tenants, err := queryTenants(ctx, conn(ctx, p.db), query)
+	span.RecordError(err)
+	return tenants, err
+}
+
+func (p SqlRepoTenant) GetTenantByName(ctx context.Context, name string) (models.Tenant, error) {
+	const query = "SELECT id, name, created_at FROM tenants WHERE name = ?;"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoTenant.GetTenantByName", query)
+	defer span.End()
+
+	tenant, err := scanTenant(conn(ctx, p.db).QueryRowContext(ctx, query, name))
+	span.RecordError(err)
+	return tenant, err
+}
+
+func (p SqlRepoTenant) InsertTenant(ctx context.Context, tenant models.Tenant) (sql.Result, error) {
+	const query = "INSERT INTO tenants (name, created_at) VALUES (?, ?);"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoTenant.InsertTenant", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, tenant.Name, tenant.CreatedAt)
+	span.RecordError(err)
+	return res, err
+}
+
+func NewMysqlRepoTenant(db *sql.DB) repository.IRepositoryTenant {
+	return &SqlRepoTenant{db: db, system: "mysql"}
+}
+
+func NewSqliteRepoTenant(db *sql.DB) repository.IRepositoryTenant {
+	return &SqlRepoTenant{db: db, system: "sqlite"}
+}
@@ -0,0 +1,140 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"time"
+)
+
+// SqlRepoUser stores users in a database using ? placeholders, which is
+// shared by the MySQL and SQLite backends.
+type SqlRepoUser struct {
+	db     *sql.DB
+	system string
+	tenant tenantScope
+}
+
+// sqlUsers compares the attributes with json_extract, which MySQL and SQLite
+// both have. Parsing the value the same way keeps its JSON type in both.
+var sqlUsers = userDialect{
+	placeholder: question,
+	groups:      "`groups`",
+	attribute: func(key string, value []byte, arg func(any) string) string {
+		return "json_extract(attributes, " + arg(attributePath(key)) + ") = json_extract(" + arg(string(value)) + ", '$')"
+	},
+}
+
+// attributePath is the JSON path of the attribute key, which
+// models.ValidateAttributeKey keeps free of quotes.
+func attributePath(key string) string {
+	return `$."` + key + `"`
+}
+
+func (p SqlRepoUser) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	where, args := userFilterWhere(filter, p.tenant, sqlUsers)
+	query := "SELECT " + userColumns + " FROM users" + where + ";"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoUser.GetUsers", query)
+	defer span.End()
+
+	rows, err := conn(ctx, p.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]models.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	err = rows.Err()
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return users, nil
+}
+
+func (p SqlRepoUser) GetUserById(ctx context.Context, id int) (models.User, error) {
+	query, args := p.tenant.and("SELECT "+userColumns+" FROM users WHERE id = ?", []any{id}, question)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoUser.GetUserById", query)
+	defer span.End()
+
+	user, err := scanUser(conn(ctx, p.db).QueryRowContext(ctx, query, args...))
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, sql.ErrNoRows
+		}
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+func (p SqlRepoUser) InsertUser(ctx context.Context, user models.User) (sql.Result, error) {
+	attributes, err := attributesArg(user.Attributes)
+	columns, values, args := p.tenant.insert("name, email, password_hash, registered_at, email_verified_at, status, attributes", "?, ?, ?, ?, ?, ?, ?",
+		[]any{user.Name, user.Email, user.PasswordHash, time.Now(), nullTime(user.EmailVerifiedAt), insertStatus(user), attributes}, question)
+	query := "INSERT INTO users (" + columns + ") VALUES (" + values + ");"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoUser.InsertUser", query)
+	defer span.End()
+
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	err = emailTaken(err)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoUser) DeleteUserById(ctx context.Context, id int) (sql.Result, error) {
+	query, args := p.tenant.and("DELETE FROM users WHERE id = ?", []any{id}, question)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoUser.DeleteUserById", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoUser) UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error) {
+	// MySQL assigns from left to right, so the email is compared before it
+	// changes. Without a status the current one is kept.
+	query, args := p.tenant.and("UPDATE users SET email_verified_at = CASE WHEN email = ? THEN ? END, name = ?, email = ?, password_hash = ?, status = COALESCE(NULLIF(?, ''), status) WHERE id = ?",
+		[]any{user.Email, nullTime(user.EmailVerifiedAt), user.Name, user.Email, user.PasswordHash, string(user.Status), id}, question)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoUser.UpdateUserById", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	err = emailTaken(err)
+	span.RecordError(err)
+	return res, err
+}
+
+func NewMysqlRepoUser(db *sql.DB) repository.IRepositoryUser {
+	return &SqlRepoUser{db: db, system: "mysql"}
+}
+
+func NewSqliteRepoUser(db *sql.DB) repository.IRepositoryUser {
+	return &SqlRepoUser{db: db, system: "sqlite"}
+}
+
+// NewMysqlRepoUserForTenant is NewPostgresRepoUserForTenant for MySQL.
+func NewMysqlRepoUserForTenant(db *sql.DB, tenantID int) repository.IRepositoryUser {
+	return &SqlRepoUser{db: db, system: "mysql", tenant: tenantScope(tenantID)}
+}
+
+// NewSqliteRepoUserForTenant is NewPostgresRepoUserForTenant for SQLite.
+func NewSqliteRepoUserForTenant(db *sql.DB, tenantID int) repository.IRepositoryUser {
+	return &SqlRepoUser{db: db, system: "sqlite", tenant: tenantScope(tenantID)}
+}
@@ -0,0 +1,79 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+)
+
+// SqlRepoUserAttribute is the attributes counterpart of SqlRepoUser.
+type SqlRepoUserAttribute struct {
+	db     *sql.DB
+	system string
+	// setQuery sets an attribute to a JSON value, which MySQL and SQLite
+	// parse differently.
+	setQuery string
+	tenant   tenantScope
+}
+
+func (p SqlRepoUserAttribute) GetAttributes(ctx context.Context, userID int) (models.Attributes, error) {
+	query, args := p.tenant.and("SELECT attributes FROM users WHERE id = ?", []any{userID}, question)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoUserAttribute.GetAttributes", query)
+	defer span.End()
+
+	attributes, err := queryAttributes(ctx, conn(ctx, p.db), query, args...)
+	span.RecordError(err)
+	return attributes, err
+}
+
+func (p SqlRepoUserAttribute) SetAttribute(ctx context.Context, userID int, key string, value any) (sql.Result, error) {
+	b, err := json.Marshal(value)
+	query, args := p.tenant.and(p.setQuery, []any{attributePath(key), string(b), userID}, question)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoUserAttribute.SetAttribute", query)
+	defer span.End()
+
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoUserAttribute) UnsetAttribute(ctx context.Context, userID int, key string) (sql.Result, error) {
+	query, args := p.tenant.and("UPDATE users SET attributes = json_remove(attributes, ?) WHERE id = ?", []any{attributePath(key), userID}, question)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoUserAttribute.UnsetAttribute", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+const (
+	mysqlSetAttribute  = "UPDATE users SET attributes = json_set(attributes, ?, CAST(? AS JSON)) WHERE id = ?"
+	sqliteSetAttribute = "UPDATE users SET attributes = json_set(attributes, ?, json(?)) WHERE id = ?"
+)
+
+func NewMysqlRepoUserAttribute(db *sql.DB) repository.IRepositoryUserAttribute {
+	return &SqlRepoUserAttribute{db: db, system: "mysql", setQuery: mysqlSetAttribute}
+}
+
+func NewSqliteRepoUserAttribute(db *sql.DB) repository.IRepositoryUserAttribute {
+	return &SqlRepoUserAttribute{db: db, system: "sqlite", setQuery: sqliteSetAttribute}
+}
+
+// NewMysqlRepoUserAttributeForTenant is
+// NewPostgresRepoUserAttributeForTenant for MySQL.
+func NewMysqlRepoUserAttributeForTenant(db *sql.DB, tenantID int) repository.IRepositoryUserAttribute {
+	return &SqlRepoUserAttribute{db: db, system: "mysql", setQuery: mysqlSetAttribute, tenant: tenantScope(tenantID)}
+}
+
+// NewSqliteRepoUserAttributeForTenant is
+// NewPostgresRepoUserAttributeForTenant for SQLite.
+func NewSqliteRepoUserAttributeForTenant(db *sql.DB, tenantID int) repository.IRepositoryUserAttribute {
+	return &SqlRepoUserAttribute{db: db, system: "sqlite", setQuery: sqliteSetAttribute, tenant: tenantScope(tenantID)}
+}
@@ -0,0 +1,65 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/db"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"path/filepath"
+	"testing"
+)
+
+func openSqlite(t *testing.T) *sql.DB {
+	t.Helper()
+	cfg := db.DefaultConfig()
+	cfg.Driver = db.DriverSQLite
+	cfg.DbName = filepath.Join(t.TempDir(), "test.db")
+	con, err := db.Connect(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a sqlite database", err)
+	}
+	t.Cleanup(func() { con.Close() })
+	if err := db.Migrate(con, db.DriverSQLite); err != nil {
+		t.Fatalf("an error '%s' was not expected when migrating", err)
+	}
+	return con
+}
+
+func TestSqliteRepoUser_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSqliteRepoUser(openSqlite(t))
+
+	res, err := repo.InsertUser(ctx, models.User{Name: "John Doe", Email: "john@example.com", PasswordHash: "dasfsa"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting user", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when reading the inserted id", err)
+	}
+
+	_, err = repo.UpdateUserById(ctx, int(id), models.User{Name: "Jane Doe", Email: "jane@example.com", PasswordHash: "secret"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when updating user", err)
+	}
+	user, err := repo.GetUserById(ctx, int(id))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting user by id", err)
+	}
+	if user.ID != int(id) || user.Name != "Jane Doe" || user.Email != "jane@example.com" || user.RegisteredAt.IsZero() {
+		t.Fatalf("unexpected user %+v", user)
+	}
+
+	users, err := repo.GetUsers(ctx, models.UserFilter{})
+	if err != nil || len(users) != 1 {
+		t.Fatalf("got %d users and error '%v', expected 1 user", len(users), err)
+	}
+
+	if _, err := repo.DeleteUserById(ctx, int(id)); err != nil {
+		t.Fatalf("an error '%s' was not expected when deleting user", err)
+	}
+	if _, err := repo.GetUserById(ctx, int(id)); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("got error '%v', expected sql.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,97 @@
+package imp
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"time"
+)
+
+// SqlRepoUserToken is the token counterpart of SqlRepoUser. MySQL has no
+// RETURNING, so a token is marked used first and read afterwards.
+type SqlRepoUserToken struct {
+	db     *sql.DB
+	system string
+	tenant tenantScope
+}
+
+func (p SqlRepoUserToken) InsertUserToken(ctx context.Context, token models.UserToken) (sql.Result, error) {
+	table, err := userTokenTable(token.Purpose)
+	if err != nil {
+		return nil, err
+	}
+	columns, values, args := p.tenant.insert("token_hash, user_id, email, created_at, expires_at", "?, ?, ?, ?, ?",
+		[]any{token.Hash, token.UserID, token.Email, token.CreatedAt.UTC(), token.ExpiresAt.UTC()}, question)
+	query := "INSERT INTO " + table + " (" + columns + ") VALUES (" + values + ");"
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoUserToken.InsertUserToken", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+func (p SqlRepoUserToken) ConsumeUserToken(ctx context.Context, purpose models.TokenPurpose, hash string, now time.Time) (models.UserToken, error) {
+	table, err := userTokenTable(purpose)
+	if err != nil {
+		return models.UserToken{}, err
+	}
+	consume, consumeArgs := p.tenant.and("UPDATE "+table+" SET used_at = ? WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?",
+		[]any{now.UTC(), hash, now.UTC()}, question)
+	query, args := p.tenant.and("SELECT token_hash, user_id, email, created_at, expires_at, used_at FROM "+table+" WHERE token_hash = ?", []any{hash}, question)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoUserToken.ConsumeUserToken", consume)
+	defer span.End()
+
+	q := conn(ctx, p.db)
+	res, err := q.ExecContext(ctx, consume, consumeArgs...)
+	if err != nil {
+		span.RecordError(err)
+		return models.UserToken{}, err
+	}
+	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		span.RecordError(err)
+		return models.UserToken{}, err
+	}
+	token, err := scanUserToken(q.QueryRowContext(ctx, query, args...))
+	token.Purpose = purpose
+	span.RecordError(err)
+	return token, err
+}
+
+func (p SqlRepoUserToken) DeleteUserTokens(ctx context.Context, purpose models.TokenPurpose, userID int) (sql.Result, error) {
+	table, err := userTokenTable(purpose)
+	if err != nil {
+		return nil, err
+	}
+	query, args := p.tenant.and("DELETE FROM "+table+" WHERE user_id = ?", []any{userID}, question)
+	ctx, span := startQuerySpan(ctx, p.system, "SqlRepoUserToken.DeleteUserTokens", query)
+	defer span.End()
+
+	res, err := conn(ctx, p.db).ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+func NewMysqlRepoUserToken(db *sql.DB) repository.IRepositoryUserToken {
+	return &SqlRepoUserToken{db: db, system: "mysql"}
+}
+
+func NewSqliteRepoUserToken(db *sql.DB) repository.IRepositoryUserToken {
+	return &SqlRepoUserToken{db: db, system: "sqlite"}
+}
+
+// NewMysqlRepoUserTokenForTenant is NewPostgresRepoUserTokenForTenant for
+// MySQL.
+func NewMysqlRepoUserTokenForTenant(db *sql.DB, tenantID int) repository.IRepositoryUserToken {
+	return &SqlRepoUserToken{db: db, system: "mysql", tenant: tenantScope(tenantID)}
+}
+
+// NewSqliteRepoUserTokenForTenant is NewPostgresRepoUserTokenForTenant for
+// SQLite.
+func NewSqliteRepoUserTokenForTenant(db *sql.DB, tenantID int) repository.IRepositoryUserToken {
+	return &SqlRepoUserToken{db: db, system: "sqlite", tenant: tenantScope(tenantID)}
+}
@@ -0,0 +1,54 @@
+package imp
+
+import "strconv"
+
+// tenantScope is the tenant whose users and logs a repository works on. The
+// zero value works on those of every tenant and inserts into the tenant the
+// database defaults to, which the tests and single tenant databases use.
+type tenantScope int
+
+// and ends query, whose WHERE clause has the arguments args, with the
+// condition keeping the rows of the tenant.
+func (t tenantScope) and(query string, args []any, placeholder func(int) string) (string, []any) {
+	condition, args := t.condition(args, placeholder)
+	return query + condition + ";", args
+}
+
+// condition is the condition and adds, for queries going on after their
+// WHERE clause.
+func (t tenantScope) condition(args []any, placeholder func(int) string) (string, []any) {
+	if t == 0 {
+		return "", args
+	}
+	args = append(args, int(t))
+	return " AND tenant_id = " + placeholder(len(args)), args
+}
+
+// where is and for a query without a WHERE clause.
+func (t tenantScope) where(query string, args []any, placeholder func(int) string) (string, []any) {
+	if t == 0 {
+		return query + ";", args
+	}
+	args = append(args, int(t))
+	return query + " WHERE tenant_id = " + placeholder(len(args)) + ";", args
+}
+
+// insert adds the tenant to the columns and values of an INSERT with the
+// arguments args.
+func (t tenantScope) insert(columns, values string, args []any, placeholder func(int) string) (string, string, []any) {
+	if t == 0 {
+		return columns, values, args
+	}
+	args = append(args, int(t))
+	return columns + ", tenant_id", values + ", " + placeholder(len(args)), args
+}
+
+// dollar is the placeholder of the nth argument in Postgres.
+func dollar(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// question is the placeholder of every argument in MySQL and SQLite.
+func question(int) string {
+	return "?"
+}
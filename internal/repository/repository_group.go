@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+)
+
+// IRepositoryGroup keeps the groups of users and their members. Deleting a
+// group or a user deletes the memberships of either.
+type IRepositoryGroup interface {
+	// GetGroups returns the groups ordered by name.
+	GetGroups(ctx context.Context) ([]models.Group, error)
+	// GetGroupByName returns sql.ErrNoRows if there is no such group.
+	GetGroupByName(ctx context.Context, name string) (models.Group, error)
+	InsertGroup(ctx context.Context, group models.Group) (sql.Result, error)
+	DeleteGroupById(ctx context.Context, id int) (sql.Result, error)
+	// AddGroupMember adds the user to the group, adding it again changes
+	// nothing.
+	AddGroupMember(ctx context.Context, groupID, userID int) (sql.Result, error)
+	RemoveGroupMember(ctx context.Context, groupID, userID int) (sql.Result, error)
+	// GetUserGroups returns the groups of the user ordered by name.
+	GetUserGroups(ctx context.Context, userID int) ([]models.Group, error)
+}
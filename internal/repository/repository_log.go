@@ -1,12 +1,13 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
 )
 
 type IRepositoryLog interface {
-	GetLogs() ([]models.Log, error)
-	GetLogById(id int) (models.Log, error)
-	InsertLog(user models.Log) (sql.Result, error)
+	GetLogs(ctx context.Context) ([]models.Log, error)
+	GetLogById(ctx context.Context, id int) (models.Log, error)
+	InsertLog(ctx context.Context, user models.Log) (sql.Result, error)
 }
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"time"
+)
+
+// IRepositoryLogin keeps the state of user logins: the lockouts after failed
+// attempts and the session tokens revoked before they expire.
+type IRepositoryLogin interface {
+	// GetLockout returns the lockout of email, sql.ErrNoRows if it has none.
+	GetLockout(ctx context.Context, email string) (models.Lockout, error)
+	// AddFailure counts a failed login with email at the given time in one
+	// statement and returns the failures counted. The failures of email
+	// before since are forgotten, as are the lockouts of other emails with
+	// no failure since then which are no longer locked.
+	AddFailure(ctx context.Context, email string, at, since time.Time) (int, error)
+	// LockEmail refuses the logins with email until the given time and
+	// counts its failures anew.
+	LockEmail(ctx context.Context, email string, until time.Time) (sql.Result, error)
+	DeleteLockout(ctx context.Context, email string) (sql.Result, error)
+	// RevokeSession revokes the session with the given ID until it expires
+	// anyway, and forgets the revocations that are no longer needed.
+	RevokeSession(ctx context.Context, id string, expiresAt time.Time) (sql.Result, error)
+	IsSessionRevoked(ctx context.Context, id string) (bool, error)
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+)
+
+type IRepositoryOperator interface {
+	GetOperators(ctx context.Context) ([]models.Operator, error)
+	GetOperatorByName(ctx context.Context, name string) (models.Operator, error)
+	InsertOperator(ctx context.Context, operator models.Operator) (sql.Result, error)
+	UpdateOperatorById(ctx context.Context, id int, operator models.Operator) (sql.Result, error)
+	// DeleteOperatorById deletes the operator and its API tokens.
+	DeleteOperatorById(ctx context.Context, id int) (sql.Result, error)
+	InsertAPIToken(ctx context.Context, token models.APIToken) (sql.Result, error)
+	// GetOperatorByTokenHash returns the operator owning the token with the
+	// given hash, sql.ErrNoRows if there is none.
+	GetOperatorByTokenHash(ctx context.Context, hash string) (models.Operator, error)
+}
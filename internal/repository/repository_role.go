@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+)
+
+// IRepositoryRole keeps the roles of the users and who has them. Deleting a
+// role or a user deletes the assignments of either.
+type IRepositoryRole interface {
+	// GetRoles returns the roles ordered by name.
+	GetRoles(ctx context.Context) ([]models.UserRole, error)
+	// GetRoleByName returns sql.ErrNoRows if there is no such role.
+	GetRoleByName(ctx context.Context, name string) (models.UserRole, error)
+	InsertRole(ctx context.Context, role models.UserRole) (sql.Result, error)
+	DeleteRoleById(ctx context.Context, id int) (sql.Result, error)
+	// AssignRole gives the role to the user, giving it again changes nothing.
+	AssignRole(ctx context.Context, roleID, userID int) (sql.Result, error)
+	RevokeRole(ctx context.Context, roleID, userID int) (sql.Result, error)
+	// GetUserRoles returns the roles of the user ordered by name.
+	GetUserRoles(ctx context.Context, userID int) ([]models.UserRole, error)
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+)
+
+// IRepositoryTenant keeps the tenants. The users and logs of each are kept
+// by repositories scoped to it.
+type IRepositoryTenant interface {
+	// GetTenants returns the tenants ordered by name.
+	GetTenants(ctx context.Context) ([]models.Tenant, error)
+	// GetTenantByName returns sql.ErrNoRows if there is no such tenant.
+	GetTenantByName(ctx context.Context, name string) (models.Tenant, error)
+	InsertTenant(ctx context.Context, tenant models.Tenant) (sql.Result, error)
+}
@@ -1,14 +1,15 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
 )
 
 type IRepositoryUser interface {
-	GetUsers() ([]models.User, error)
-	GetUserById(id int) (models.User, error)
-	InsertUser(user models.User) (sql.Result, error)
-	UpdateUserById(id int, user models.User) (sql.Result, error)
-	DeleteUserById(id int) (sql.Result, error)
+	GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error)
+	GetUserById(ctx context.Context, id int) (models.User, error)
+	InsertUser(ctx context.Context, user models.User) (sql.Result, error)
+	UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error)
+	DeleteUserById(ctx context.Context, id int) (sql.Result, error)
 }
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+)
+
+// IRepositoryUserAttribute changes single attributes of the users, the
+// others stay as they are. The keys are checked by
+// models.ValidateAttributeKey.
+type IRepositoryUserAttribute interface {
+	// GetAttributes returns sql.ErrNoRows if there is no such user.
+	GetAttributes(ctx context.Context, userID int) (models.Attributes, error)
+	// SetAttribute adds the attribute or replaces its value, which is stored
+	// as JSON.
+	SetAttribute(ctx context.Context, userID int, key string, value any) (sql.Result, error)
+	UnsetAttribute(ctx context.Context, userID int, key string) (sql.Result, error)
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"time"
+)
+
+// IRepositoryUserToken keeps the email verification and password reset
+// tokens of the users, each purpose in its own table.
+type IRepositoryUserToken interface {
+	InsertUserToken(ctx context.Context, token models.UserToken) (sql.Result, error)
+	// ConsumeUserToken marks the token with hash as used at now and returns
+	// it, sql.ErrNoRows if it does not exist, expired or was used already.
+	// Of concurrent calls for one token only one succeeds.
+	ConsumeUserToken(ctx context.Context, purpose models.TokenPurpose, hash string, now time.Time) (models.UserToken, error)
+	// DeleteUserTokens deletes the tokens of a user for purpose, used or not.
+	DeleteUserTokens(ctx context.Context, purpose models.TokenPurpose, userID int) (sql.Result, error)
+}
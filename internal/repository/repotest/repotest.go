@@ -0,0 +1,1262 @@
+// Package repotest holds the conformance tests every repository backend
+// has to pass, so the SQL, in-memory and future backends behave the same.
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// RunUserTests runs the IRepositoryUser conformance tests. newRepo is called
+// once per test and must return a repository over an empty users table.
+func RunUserTests(t *testing.T, newRepo func(t *testing.T) repository.IRepositoryUser) {
+	ctx := context.Background()
+
+	t.Run("GetUsersEmpty", func(t *testing.T) {
+		users, err := newRepo(t).GetUsers(ctx, models.UserFilter{})
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when getting users", err)
+		}
+		if len(users) != 0 {
+			t.Fatalf("got %d users, expected none", len(users))
+		}
+	})
+
+	t.Run("InsertAssignsIDs", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := insertUser(t, repo, "alice")
+		bob := insertUser(t, repo, "bob")
+		if alice.ID <= 0 || bob.ID <= alice.ID {
+			t.Fatalf("expected increasing positive IDs, got %d and %d", alice.ID, bob.ID)
+		}
+
+		got, err := repo.GetUserById(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when getting user by id", err)
+		}
+		if got.Name != "alice" || got.Email != "alice@example.com" || got.PasswordHash != "alice-password" {
+			t.Fatalf("unexpected user %+v", got)
+		}
+		if got.RegisteredAt.IsZero() {
+			t.Fatalf("expected registered_at to be set")
+		}
+	})
+
+	t.Run("InsertReportsID", func(t *testing.T) {
+		repo := newRepo(t)
+		res, err := repo.InsertUser(ctx, models.User{Name: "alice", Email: "alice@example.com", PasswordHash: "alice-password"})
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when inserting user", err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when getting the inserted id", err)
+		}
+		if got, err := repo.GetUserById(ctx, int(id)); err != nil || got.Name != "alice" {
+			t.Fatalf("got %+v and error '%v' for the inserted id %d", got, err, id)
+		}
+	})
+
+	t.Run("GetMissingUser", func(t *testing.T) {
+		_, err := newRepo(t).GetUserById(ctx, 42)
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v', expected sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("UpdateById", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := insertUser(t, repo, "alice")
+		bob := insertUser(t, repo, "bob")
+
+		// The id argument selects the row, whatever ID the user carries. The
+		// registration date is kept, whatever the user carries and however
+		// late the update comes.
+		time.Sleep(10 * time.Millisecond)
+		update := models.User{ID: bob.ID, Name: "carol", Email: "carol@example.com", PasswordHash: "carol-password",
+			RegisteredAt: alice.RegisteredAt.Add(-24 * time.Hour)}
+		res, err := repo.UpdateUserById(ctx, alice.ID, update)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when updating user", err)
+		}
+		expectRowsAffected(t, res, 1)
+
+		got, err := repo.GetUserById(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when getting user by id", err)
+		}
+		if got.ID != alice.ID || got.Name != "carol" || got.Email != "carol@example.com" {
+			t.Fatalf("unexpected updated user %+v", got)
+		}
+		if !got.RegisteredAt.Equal(alice.RegisteredAt) {
+			t.Fatalf("got registered at %v, expected %v to be kept", got.RegisteredAt, alice.RegisteredAt)
+		}
+		if got, _ := repo.GetUserById(ctx, bob.ID); got.Name != "bob" {
+			t.Fatalf("update changed another user: %+v", got)
+		}
+
+		res, err = repo.UpdateUserById(ctx, bob.ID+100, update)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when updating a missing user", err)
+		}
+		expectRowsAffected(t, res, 0)
+	})
+
+	t.Run("UniqueEmail", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := insertUser(t, repo, "alice")
+		bob := insertUser(t, repo, "bob")
+
+		// Emails are unique regardless of case, a violation is reported as
+		// an invalid email.
+		expectEmailTaken := func(err error) {
+			t.Helper()
+			var verr models.ValidationError
+			if !errors.As(err, &verr) || verr.Field("email") == "" {
+				t.Fatalf("got error '%v', expected the email to be taken", err)
+			}
+		}
+		_, err := repo.InsertUser(ctx, models.User{Name: "alice2", Email: "Alice@Example.com", PasswordHash: "password"})
+		expectEmailTaken(err)
+		bob.Email = "ALICE@example.com"
+		_, err = repo.UpdateUserById(ctx, bob.ID, bob)
+		expectEmailTaken(err)
+
+		alice.Name = "alicia"
+		if _, err := repo.UpdateUserById(ctx, alice.ID, alice); err != nil {
+			t.Fatalf("an error '%s' was not expected when keeping the email", err)
+		}
+		if users, err := repo.GetUsers(ctx, models.UserFilter{}); err != nil || len(users) != 2 {
+			t.Fatalf("got %+v, '%v', expected alicia and bob", users, err)
+		}
+	})
+
+	t.Run("GetByEmail", func(t *testing.T) {
+		repo := newRepo(t)
+		insertUser(t, repo, "alice")
+		bob := insertUser(t, repo, "bob")
+
+		users, err := repo.GetUsers(ctx, models.UserFilter{Email: "BOB@example.com"})
+		if err != nil || len(users) != 1 || users[0].ID != bob.ID {
+			t.Fatalf("got %+v, '%v', expected bob", users, err)
+		}
+		if users, err := repo.GetUsers(ctx, models.UserFilter{Email: "carol@example.com"}); err != nil || len(users) != 0 {
+			t.Fatalf("got %+v, '%v', expected no user", users, err)
+		}
+	})
+
+	t.Run("EmailVerification", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := insertUser(t, repo, "alice")
+		if alice.EmailVerified() {
+			t.Fatalf("expected a new user to be unverified, got %+v", alice)
+		}
+
+		verifiedAt := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+		alice.EmailVerifiedAt = verifiedAt
+		if _, err := repo.UpdateUserById(ctx, alice.ID, alice); err != nil {
+			t.Fatalf("an error '%s' was not expected when verifying the email", err)
+		}
+		got, err := repo.GetUserById(ctx, alice.ID)
+		if err != nil || !got.EmailVerifiedAt.Equal(verifiedAt) {
+			t.Fatalf("got %+v, '%v', expected the email to be verified at %s", got, err, verifiedAt)
+		}
+
+		// The verification does not carry over to another email.
+		got.Email = "alice@example.org"
+		if _, err := repo.UpdateUserById(ctx, alice.ID, got); err != nil {
+			t.Fatalf("an error '%s' was not expected when changing the email", err)
+		}
+		if got, err := repo.GetUserById(ctx, alice.ID); err != nil || got.EmailVerified() {
+			t.Fatalf("got %+v, '%v', expected the new email to be unverified", got, err)
+		}
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := insertUser(t, repo, "alice")
+		bob := insertUser(t, repo, "bob")
+		if alice.Status != models.StatusPending {
+			t.Fatalf("got status %q, expected a new user to be pending", alice.Status)
+		}
+
+		alice.Status = models.StatusActive
+		if _, err := repo.UpdateUserById(ctx, alice.ID, alice); err != nil {
+			t.Fatalf("an error '%s' was not expected when activating the user", err)
+		}
+		// Updates without a status keep the current one.
+		alice.Status = ""
+		alice.Name = "alicia"
+		if _, err := repo.UpdateUserById(ctx, alice.ID, alice); err != nil {
+			t.Fatalf("an error '%s' was not expected when renaming the user", err)
+		}
+
+		active, err := repo.GetUsers(ctx, models.UserFilter{Status: models.StatusActive})
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when getting active users", err)
+		}
+		if len(active) != 1 || active[0].ID != alice.ID || active[0].Name != "alicia" || active[0].Status != models.StatusActive {
+			t.Fatalf("got %+v, expected only the renamed alice to be active", active)
+		}
+		pending, err := repo.GetUsers(ctx, models.UserFilter{Status: models.StatusPending})
+		if err != nil || len(pending) != 1 || pending[0].ID != bob.ID {
+			t.Fatalf("got %+v, '%v', expected only bob to be pending", pending, err)
+		}
+		if deleted, err := repo.GetUsers(ctx, models.UserFilter{Status: models.StatusDeleted}); err != nil || len(deleted) != 0 {
+			t.Fatalf("got %+v, '%v', expected no deleted users", deleted, err)
+		}
+	})
+
+	t.Run("DeleteById", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := insertUser(t, repo, "alice")
+
+		res, err := repo.DeleteUserById(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when deleting user", err)
+		}
+		expectRowsAffected(t, res, 1)
+		if _, err := repo.GetUserById(ctx, alice.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v', expected sql.ErrNoRows after delete", err)
+		}
+
+		res, err = repo.DeleteUserById(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when deleting a missing user", err)
+		}
+		expectRowsAffected(t, res, 0)
+
+		// IDs of deleted users are not handed out again.
+		if bob := insertUser(t, repo, "bob"); bob.ID <= alice.ID {
+			t.Fatalf("got ID %d after deleting %d, expected a new ID", bob.ID, alice.ID)
+		}
+	})
+
+	t.Run("ConcurrentInserts", func(t *testing.T) {
+		repo := newRepo(t)
+		const n = 10
+		var wg sync.WaitGroup
+		errs := make(chan error, n)
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				name := fmt.Sprintf("user%d", i)
+				_, err := repo.InsertUser(ctx, models.User{Name: name, Email: name + "@example.com", PasswordHash: "password"})
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when inserting concurrently", err)
+			}
+		}
+
+		users, err := repo.GetUsers(ctx, models.UserFilter{})
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when getting users", err)
+		}
+		ids := make(map[int]bool)
+		for _, user := range users {
+			ids[user.ID] = true
+		}
+		if len(users) != n || len(ids) != n {
+			t.Fatalf("got %d users with %d distinct IDs, expected %d", len(users), len(ids), n)
+		}
+	})
+}
+
+// RunLogTests runs the IRepositoryLog conformance tests. newRepo is called
+// once per test and must return a repository over an empty logs table.
+func RunLogTests(t *testing.T, newRepo func(t *testing.T) repository.IRepositoryLog) {
+	ctx := context.Background()
+
+	t.Run("GetLogsEmpty", func(t *testing.T) {
+		logs, err := newRepo(t).GetLogs(ctx)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when getting logs", err)
+		}
+		if len(logs) != 0 {
+			t.Fatalf("got %d logs, expected none", len(logs))
+		}
+	})
+
+	t.Run("InsertAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, log := range []models.Log{
+			{LogTime: time.Now(), LogMessage: "first"},
+			{LogTime: time.Now(), LogMessage: "second", Details: `{"op":"delete","user_id":1}`, Actor: "ann"},
+		} {
+			res, err := repo.InsertLog(ctx, log)
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when inserting log", err)
+			}
+			expectRowsAffected(t, res, 1)
+		}
+
+		logs, err := repo.GetLogs(ctx)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when getting logs", err)
+		}
+		if len(logs) != 2 || logs[0].Id == logs[1].Id || logs[1].Details == "" {
+			t.Fatalf("expected 2 logs with distinct IDs, got %+v", logs)
+		}
+		for _, log := range logs {
+			got, err := repo.GetLogById(ctx, int(log.Id))
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when getting log by id", err)
+			}
+			if got.LogMessage != log.LogMessage || got.Details != log.Details || got.Actor != log.Actor || got.LogTime.IsZero() {
+				t.Fatalf("got %+v, expected %+v", got, log)
+			}
+		}
+	})
+
+	t.Run("GetMissingLog", func(t *testing.T) {
+		_, err := newRepo(t).GetLogById(ctx, 42)
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v', expected sql.ErrNoRows", err)
+		}
+	})
+}
+
+// RunOperatorTests runs the IRepositoryOperator conformance tests. newRepo is
+// called once per test and must return a repository over empty operators
+// and api_tokens tables.
+func RunOperatorTests(t *testing.T, newRepo func(t *testing.T) repository.IRepositoryOperator) {
+	ctx := context.Background()
+
+	t.Run("GetOperatorsEmpty", func(t *testing.T) {
+		operators, err := newRepo(t).GetOperators(ctx)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when getting operators", err)
+		}
+		if len(operators) != 0 {
+			t.Fatalf("got %d operators, expected none", len(operators))
+		}
+	})
+
+	t.Run("InsertAndGetByName", func(t *testing.T) {
+		repo := newRepo(t)
+		ann := insertOperator(t, repo, "ann", models.RoleAdmin)
+		bob := insertOperator(t, repo, "bob", models.RoleViewer)
+		if ann.ID <= 0 || bob.ID <= ann.ID {
+			t.Fatalf("expected increasing positive IDs, got %d and %d", ann.ID, bob.ID)
+		}
+		if ann.Role != models.RoleAdmin || ann.PasswordHash != "ann-hash" || ann.CreatedAt.IsZero() {
+			t.Fatalf("unexpected operator %+v", ann)
+		}
+
+		operators, err := repo.GetOperators(ctx)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when getting operators", err)
+		}
+		if len(operators) != 2 || operators[0].Name != "ann" || operators[1].Name != "bob" {
+			t.Fatalf("got %+v, expected ann and bob", operators)
+		}
+		if _, err := repo.InsertOperator(ctx, models.Operator{Name: "ann", Role: models.RoleViewer, CreatedAt: time.Now()}); err == nil {
+			t.Fatalf("expected an error when inserting a second operator named ann")
+		}
+	})
+
+	t.Run("GetMissingOperator", func(t *testing.T) {
+		_, err := newRepo(t).GetOperatorByName(ctx, "nobody")
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v', expected sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("UpdateById", func(t *testing.T) {
+		repo := newRepo(t)
+		ann := insertOperator(t, repo, "ann", models.RoleViewer)
+		ann.Role = models.RoleEditor
+		ann.PasswordHash = ""
+		res, err := repo.UpdateOperatorById(ctx, ann.ID, ann)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when updating operator", err)
+		}
+		expectRowsAffected(t, res, 1)
+
+		got, err := repo.GetOperatorByName(ctx, "ann")
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when getting operator", err)
+		}
+		if got.Role != models.RoleEditor || got.PasswordHash != "" {
+			t.Fatalf("got %+v, expected an editor without a password", got)
+		}
+
+		res, err = repo.UpdateOperatorById(ctx, ann.ID+100, ann)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when updating a missing operator", err)
+		}
+		expectRowsAffected(t, res, 0)
+	})
+
+	t.Run("TokensAndDelete", func(t *testing.T) {
+		repo := newRepo(t)
+		ann := insertOperator(t, repo, "ann", models.RoleEditor)
+		hash := strings.Repeat("a", 64)
+		res, err := repo.InsertAPIToken(ctx, models.APIToken{OperatorID: ann.ID, Name: "ci", Hash: hash, CreatedAt: time.Now()})
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when inserting a token", err)
+		}
+		expectRowsAffected(t, res, 1)
+
+		got, err := repo.GetOperatorByTokenHash(ctx, hash)
+		if err != nil || got.Name != "ann" {
+			t.Fatalf("got %+v, '%v', expected ann", got, err)
+		}
+		if _, err := repo.GetOperatorByTokenHash(ctx, strings.Repeat("b", 64)); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v' for an unknown token, expected sql.ErrNoRows", err)
+		}
+
+		res, err = repo.DeleteOperatorById(ctx, ann.ID)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when deleting operator", err)
+		}
+		expectRowsAffected(t, res, 1)
+		if _, err := repo.GetOperatorByTokenHash(ctx, hash); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v', expected the token to be deleted with its operator", err)
+		}
+	})
+}
+
+// RunLoginTests runs the IRepositoryLogin conformance tests. newRepo is
+// called once per test and must return a repository over empty tables.
+func RunLoginTests(t *testing.T, newRepo func(t *testing.T) repository.IRepositoryLogin) {
+	ctx := context.Background()
+
+	t.Run("Lockouts", func(t *testing.T) {
+		repo := newRepo(t)
+		if _, err := repo.GetLockout(ctx, "ann@example.com"); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v', expected sql.ErrNoRows", err)
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		for want := 1; want <= 2; want++ {
+			failures, err := repo.AddFailure(ctx, "ann@example.com", now, now.Add(-time.Hour))
+			if err != nil || failures != want {
+				t.Fatalf("got %d, '%v', expected %d failures", failures, err, want)
+			}
+		}
+		got, err := repo.GetLockout(ctx, "ann@example.com")
+		if err != nil || got.Failures != 2 || !got.LockedUntil.IsZero() || !got.FailedAt.Equal(now) {
+			t.Fatalf("got %+v, '%v', expected two failures at %s without a lock", got, err, now)
+		}
+
+		until := now.Add(time.Hour)
+		res, err := repo.LockEmail(ctx, "ann@example.com", until)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when locking an email", err)
+		}
+		expectRowsAffected(t, res, 1)
+		got, err = repo.GetLockout(ctx, "ann@example.com")
+		if err != nil || got.Failures != 0 || !got.LockedUntil.Equal(until) {
+			t.Fatalf("got %+v, '%v', expected a lock until %s", got, err, until)
+		}
+
+		res, err = repo.DeleteLockout(ctx, "ann@example.com")
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when deleting a lockout", err)
+		}
+		expectRowsAffected(t, res, 1)
+		if _, err := repo.GetLockout(ctx, "ann@example.com"); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v' after delete, expected sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("StaleFailures", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now().UTC().Truncate(time.Second)
+		for _, email := range []string{"ann@example.com", "bob@example.com", "carol@example.com"} {
+			if _, err := repo.AddFailure(ctx, email, now, now.Add(-time.Hour)); err != nil {
+				t.Fatalf("an error '%s' was not expected when counting a failure", err)
+			}
+		}
+		if _, err := repo.LockEmail(ctx, "carol@example.com", now.Add(3*time.Hour)); err != nil {
+			t.Fatalf("an error '%s' was not expected when locking an email", err)
+		}
+
+		// Two hours later the failures of the last hour count.
+		later := now.Add(2 * time.Hour)
+		failures, err := repo.AddFailure(ctx, "ann@example.com", later, later.Add(-time.Hour))
+		if err != nil || failures != 1 {
+			t.Fatalf("got %d, '%v', expected the earlier failure to be forgotten", failures, err)
+		}
+		if _, err := repo.GetLockout(ctx, "bob@example.com"); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v', expected the stale lockout of bob to be deleted", err)
+		}
+		if got, err := repo.GetLockout(ctx, "carol@example.com"); err != nil || !got.Locked(later) {
+			t.Fatalf("got %+v, '%v', expected carol to stay locked", got, err)
+		}
+	})
+
+	t.Run("ConcurrentFailures", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now().UTC().Truncate(time.Second)
+		const n = 10
+		var wg sync.WaitGroup
+		errs := make(chan error, n)
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.AddFailure(ctx, "ann@example.com", now, now.Add(-time.Hour))
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when counting failures concurrently", err)
+			}
+		}
+		if got, err := repo.GetLockout(ctx, "ann@example.com"); err != nil || got.Failures != n {
+			t.Fatalf("got %+v, '%v', expected %d failures", got, err, n)
+		}
+	})
+
+	t.Run("RevokedSessions", func(t *testing.T) {
+		repo := newRepo(t)
+		if revoked, err := repo.IsSessionRevoked(ctx, "s1"); err != nil || revoked {
+			t.Fatalf("got %v, '%v', expected s1 not to be revoked", revoked, err)
+		}
+		for range 2 {
+			if _, err := repo.RevokeSession(ctx, "s1", time.Now().Add(time.Hour)); err != nil {
+				t.Fatalf("an error '%s' was not expected when revoking a session", err)
+			}
+		}
+		if revoked, err := repo.IsSessionRevoked(ctx, "s1"); err != nil || !revoked {
+			t.Fatalf("got %v, '%v', expected s1 to be revoked", revoked, err)
+		}
+
+		// Expired sessions need no revocation and are forgotten.
+		if _, err := repo.RevokeSession(ctx, "old", time.Now().Add(-time.Hour)); err != nil {
+			t.Fatalf("an error '%s' was not expected when revoking a session", err)
+		}
+		if _, err := repo.RevokeSession(ctx, "s2", time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("an error '%s' was not expected when revoking a session", err)
+		}
+		if revoked, err := repo.IsSessionRevoked(ctx, "old"); err != nil || revoked {
+			t.Fatalf("got %v, '%v', expected the expired revocation to be purged", revoked, err)
+		}
+	})
+}
+
+// RunUserTokenTests runs the IRepositoryUserToken conformance tests. newRepo
+// is called once per test and must return a repository over empty tables.
+func RunUserTokenTests(t *testing.T, newRepo func(t *testing.T) repository.IRepositoryUserToken) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Millisecond)
+	newToken := func(purpose models.TokenPurpose, hash string, userID int, expiresAt time.Time) models.UserToken {
+		return models.UserToken{Purpose: purpose, Hash: hash, UserID: userID, Email: "ann@example.com", CreatedAt: now, ExpiresAt: expiresAt}
+	}
+
+	t.Run("ConsumeOnce", func(t *testing.T) {
+		repo := newRepo(t)
+		token := newToken(models.PurposeVerifyEmail, strings.Repeat("a", 64), 7, now.Add(time.Hour))
+		if _, err := repo.InsertUserToken(ctx, token); err != nil {
+			t.Fatalf("an error '%s' was not expected when inserting a token", err)
+		}
+		if _, err := repo.ConsumeUserToken(ctx, models.PurposeResetPassword, token.Hash, now); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v', expected the token to be unknown for another purpose", err)
+		}
+
+		got, err := repo.ConsumeUserToken(ctx, models.PurposeVerifyEmail, token.Hash, now)
+		if err != nil || got.UserID != 7 || got.Email != "ann@example.com" || got.Purpose != models.PurposeVerifyEmail ||
+			!got.ExpiresAt.Equal(token.ExpiresAt) || !got.UsedAt.Equal(now) {
+			t.Fatalf("got %+v, '%v', expected the consumed token", got, err)
+		}
+		if _, err := repo.ConsumeUserToken(ctx, models.PurposeVerifyEmail, token.Hash, now); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v', expected a used token to be refused", err)
+		}
+	})
+
+	t.Run("ConsumeExpired", func(t *testing.T) {
+		repo := newRepo(t)
+		token := newToken(models.PurposeResetPassword, strings.Repeat("b", 64), 7, now.Add(time.Minute))
+		if _, err := repo.InsertUserToken(ctx, token); err != nil {
+			t.Fatalf("an error '%s' was not expected when inserting a token", err)
+		}
+		if _, err := repo.ConsumeUserToken(ctx, models.PurposeResetPassword, token.Hash, now.Add(time.Minute)); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v', expected an expired token to be refused", err)
+		}
+	})
+
+	t.Run("DeleteByUser", func(t *testing.T) {
+		repo := newRepo(t)
+		for i, userID := range []int{7, 7, 8} {
+			token := newToken(models.PurposeResetPassword, strings.Repeat(string(rune('c'+i)), 64), userID, now.Add(time.Hour))
+			if _, err := repo.InsertUserToken(ctx, token); err != nil {
+				t.Fatalf("an error '%s' was not expected when inserting a token", err)
+			}
+		}
+		res, err := repo.DeleteUserTokens(ctx, models.PurposeResetPassword, 7)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when deleting tokens", err)
+		}
+		expectRowsAffected(t, res, 2)
+		if _, err := repo.ConsumeUserToken(ctx, models.PurposeResetPassword, strings.Repeat("c", 64), now); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v', expected a deleted token to be refused", err)
+		}
+		if _, err := repo.ConsumeUserToken(ctx, models.PurposeResetPassword, strings.Repeat("e", 64), now); err != nil {
+			t.Fatalf("an error '%s' was not expected for the token of another user", err)
+		}
+	})
+}
+
+// RunRoleTests runs the IRepositoryRole conformance tests. newRepos is
+// called once per test and must return the repositories over empty users
+// and roles tables, the roles being those of the users.
+func RunRoleTests(t *testing.T, newRepos func(t *testing.T) (repository.IRepositoryUser, repository.IRepositoryRole)) {
+	ctx := context.Background()
+
+	t.Run("InsertAndGetByName", func(t *testing.T) {
+		_, repo := newRepos(t)
+		insertRole(t, repo, "support")
+		admin := insertRole(t, repo, "admin")
+		if admin.Name != "admin" || admin.Description != "admin role" || admin.ID == 0 {
+			t.Fatalf("got %+v, expected the inserted role", admin)
+		}
+		roles, err := repo.GetRoles(ctx)
+		if err != nil || len(roles) != 2 || roles[0].Name != "admin" || roles[1].Name != "support" {
+			t.Fatalf("got %+v, '%v', expected the roles ordered by name", roles, err)
+		}
+		if _, err := repo.GetRoleByName(ctx, "missing"); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v', expected sql.ErrNoRows", err)
+		}
+		if _, err := repo.InsertRole(ctx, models.UserRole{Name: "admin", CreatedAt: time.Now()}); err == nil {
+			t.Fatalf("expected an error when inserting a duplicate role name")
+		}
+	})
+
+	t.Run("AssignAndRevoke", func(t *testing.T) {
+		users, repo := newRepos(t)
+		admin := insertRole(t, repo, "admin")
+		support := insertRole(t, repo, "support")
+		alice := insertUser(t, users, "alice")
+		bob := insertUser(t, users, "bob")
+		for _, role := range []models.UserRole{admin, support, admin} {
+			if _, err := repo.AssignRole(ctx, role.ID, alice.ID); err != nil {
+				t.Fatalf("an error '%s' was not expected when assigning role %s", err, role.Name)
+			}
+		}
+		if _, err := repo.AssignRole(ctx, support.ID, bob.ID); err != nil {
+			t.Fatalf("an error '%s' was not expected when assigning role", err)
+		}
+
+		roles, err := repo.GetUserRoles(ctx, alice.ID)
+		if err != nil || len(roles) != 2 || roles[0].ID != admin.ID || roles[1].ID != support.ID {
+			t.Fatalf("got %+v, '%v', expected admin and support", roles, err)
+		}
+		admins, err := users.GetUsers(ctx, models.UserFilter{Role: "admin"})
+		if err != nil || len(admins) != 1 || admins[0].ID != alice.ID {
+			t.Fatalf("got %+v, '%v', expected only alice to be an admin", admins, err)
+		}
+		if pending, err := users.GetUsers(ctx, models.UserFilter{Role: "support", Status: models.StatusPending}); err != nil || len(pending) != 2 {
+			t.Fatalf("got %+v, '%v', expected both pending support users", pending, err)
+		}
+		if none, err := users.GetUsers(ctx, models.UserFilter{Role: "missing"}); err != nil || len(none) != 0 {
+			t.Fatalf("got %+v, '%v', expected no users for an unknown role", none, err)
+		}
+
+		res, err := repo.RevokeRole(ctx, admin.ID, alice.ID)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when revoking role", err)
+		}
+		expectRowsAffected(t, res, 1)
+		res, err = repo.RevokeRole(ctx, admin.ID, alice.ID)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when revoking role again", err)
+		}
+		expectRowsAffected(t, res, 0)
+		if admins, err := users.GetUsers(ctx, models.UserFilter{Role: "admin"}); err != nil || len(admins) != 0 {
+			t.Fatalf("got %+v, '%v', expected no admins after revoking", admins, err)
+		}
+	})
+
+	t.Run("DeletesRevoke", func(t *testing.T) {
+		users, repo := newRepos(t)
+		admin := insertRole(t, repo, "admin")
+		support := insertRole(t, repo, "support")
+		alice := insertUser(t, users, "alice")
+		bob := insertUser(t, users, "bob")
+		for _, id := range []int{alice.ID, bob.ID} {
+			for _, role := range []models.UserRole{admin, support} {
+				if _, err := repo.AssignRole(ctx, role.ID, id); err != nil {
+					t.Fatalf("an error '%s' was not expected when assigning role", err)
+				}
+			}
+		}
+
+		res, err := repo.DeleteRoleById(ctx, admin.ID)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when deleting role", err)
+		}
+		expectRowsAffected(t, res, 1)
+		if roles, err := repo.GetUserRoles(ctx, alice.ID); err != nil || len(roles) != 1 || roles[0].ID != support.ID {
+			t.Fatalf("got %+v, '%v', expected only support to remain", roles, err)
+		}
+		if _, err := users.DeleteUserById(ctx, bob.ID); err != nil {
+			t.Fatalf("an error '%s' was not expected when deleting user", err)
+		}
+		if roles, err := repo.GetUserRoles(ctx, bob.ID); err != nil || len(roles) != 0 {
+			t.Fatalf("got %+v, '%v', expected the roles of a deleted user to be revoked", roles, err)
+		}
+		if members, err := users.GetUsers(ctx, models.UserFilter{Role: "support"}); err != nil || len(members) != 1 || members[0].ID != alice.ID {
+			t.Fatalf("got %+v, '%v', expected only alice to remain", members, err)
+		}
+	})
+}
+
+// RunGroupTests runs the IRepositoryGroup conformance tests. newRepos is
+// called once per test and must return the repositories over empty users
+// and groups tables, the groups being those of the users.
+func RunGroupTests(t *testing.T, newRepos func(t *testing.T) (repository.IRepositoryUser, repository.IRepositoryGroup)) {
+	ctx := context.Background()
+
+	t.Run("InsertAndGetByName", func(t *testing.T) {
+		_, repo := newRepos(t)
+		insertGroup(t, repo, "support")
+		ops := insertGroup(t, repo, "ops")
+		if ops.Name != "ops" || ops.Description != "ops group" || ops.ID == 0 {
+			t.Fatalf("got %+v, expected the inserted group", ops)
+		}
+		groups, err := repo.GetGroups(ctx)
+		if err != nil || len(groups) != 2 || groups[0].Name != "ops" || groups[1].Name != "support" {
+			t.Fatalf("got %+v, '%v', expected the groups ordered by name", groups, err)
+		}
+		if _, err := repo.GetGroupByName(ctx, "missing"); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v', expected sql.ErrNoRows", err)
+		}
+		if _, err := repo.InsertGroup(ctx, models.Group{Name: "ops", CreatedAt: time.Now()}); err == nil {
+			t.Fatalf("expected an error when inserting a duplicate group name")
+		}
+	})
+
+	t.Run("AddAndRemoveMembers", func(t *testing.T) {
+		users, repo := newRepos(t)
+		ops := insertGroup(t, repo, "ops")
+		support := insertGroup(t, repo, "support")
+		alice := insertUser(t, users, "alice")
+		bob := insertUser(t, users, "bob")
+		for _, group := range []models.Group{ops, support, ops} {
+			if _, err := repo.AddGroupMember(ctx, group.ID, alice.ID); err != nil {
+				t.Fatalf("an error '%s' was not expected when adding to group %s", err, group.Name)
+			}
+		}
+		if _, err := repo.AddGroupMember(ctx, support.ID, bob.ID); err != nil {
+			t.Fatalf("an error '%s' was not expected when adding a member", err)
+		}
+
+		groups, err := repo.GetUserGroups(ctx, alice.ID)
+		if err != nil || len(groups) != 2 || groups[0].ID != ops.ID || groups[1].ID != support.ID {
+			t.Fatalf("got %+v, '%v', expected ops and support", groups, err)
+		}
+		opsMembers, err := users.GetUsers(ctx, models.UserFilter{Group: "ops"})
+		if err != nil || len(opsMembers) != 1 || opsMembers[0].ID != alice.ID {
+			t.Fatalf("got %+v, '%v', expected only alice in ops", opsMembers, err)
+		}
+		if pending, err := users.GetUsers(ctx, models.UserFilter{Group: "support", Status: models.StatusPending}); err != nil || len(pending) != 2 {
+			t.Fatalf("got %+v, '%v', expected both pending support users", pending, err)
+		}
+		if none, err := users.GetUsers(ctx, models.UserFilter{Group: "missing"}); err != nil || len(none) != 0 {
+			t.Fatalf("got %+v, '%v', expected no users for an unknown group", none, err)
+		}
+
+		res, err := repo.RemoveGroupMember(ctx, ops.ID, alice.ID)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when removing a member", err)
+		}
+		expectRowsAffected(t, res, 1)
+		res, err = repo.RemoveGroupMember(ctx, ops.ID, alice.ID)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when removing the member again", err)
+		}
+		expectRowsAffected(t, res, 0)
+		if opsMembers, err := users.GetUsers(ctx, models.UserFilter{Group: "ops"}); err != nil || len(opsMembers) != 0 {
+			t.Fatalf("got %+v, '%v', expected no members of ops after removing alice", opsMembers, err)
+		}
+	})
+
+	t.Run("DeletesRemoveMembers", func(t *testing.T) {
+		users, repo := newRepos(t)
+		ops := insertGroup(t, repo, "ops")
+		support := insertGroup(t, repo, "support")
+		alice := insertUser(t, users, "alice")
+		bob := insertUser(t, users, "bob")
+		for _, id := range []int{alice.ID, bob.ID} {
+			for _, group := range []models.Group{ops, support} {
+				if _, err := repo.AddGroupMember(ctx, group.ID, id); err != nil {
+					t.Fatalf("an error '%s' was not expected when adding a member", err)
+				}
+			}
+		}
+
+		res, err := repo.DeleteGroupById(ctx, ops.ID)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when deleting group", err)
+		}
+		expectRowsAffected(t, res, 1)
+		if groups, err := repo.GetUserGroups(ctx, alice.ID); err != nil || len(groups) != 1 || groups[0].ID != support.ID {
+			t.Fatalf("got %+v, '%v', expected only support to remain", groups, err)
+		}
+		if _, err := users.DeleteUserById(ctx, bob.ID); err != nil {
+			t.Fatalf("an error '%s' was not expected when deleting user", err)
+		}
+		if groups, err := repo.GetUserGroups(ctx, bob.ID); err != nil || len(groups) != 0 {
+			t.Fatalf("got %+v, '%v', expected a deleted user to leave its groups", groups, err)
+		}
+		if members, err := users.GetUsers(ctx, models.UserFilter{Group: "support"}); err != nil || len(members) != 1 || members[0].ID != alice.ID {
+			t.Fatalf("got %+v, '%v', expected only alice to remain", members, err)
+		}
+	})
+}
+
+// RunAttributeTests runs the IRepositoryUserAttribute conformance tests.
+// newRepos is called once per test and must return the repositories over an
+// empty users table.
+func RunAttributeTests(t *testing.T, newRepos func(t *testing.T) (repository.IRepositoryUser, repository.IRepositoryUserAttribute)) {
+	ctx := context.Background()
+
+	t.Run("InsertSetAndUnset", func(t *testing.T) {
+		users, repo := newRepos(t)
+		res, err := users.InsertUser(ctx, models.User{Name: "alice", Email: "alice@example.com", PasswordHash: "secret",
+			Attributes: models.Attributes{"locale": "uk"}})
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when inserting user", err)
+		}
+		expectRowsAffected(t, res, 1)
+		all, err := users.GetUsers(ctx, models.UserFilter{})
+		if err != nil || len(all) != 1 || all[0].Attributes.String() != `{"locale":"uk"}` {
+			t.Fatalf("got %+v, '%v', expected the user with its locale", all, err)
+		}
+		alice := all[0]
+
+		for key, value := range map[string]any{"department": "ops", "age": 30, "tags": []string{"a", "b"}} {
+			res, err := repo.SetAttribute(ctx, alice.ID, key, value)
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when setting %s", err, key)
+			}
+			expectRowsAffected(t, res, 1)
+		}
+		if _, err := repo.SetAttribute(ctx, alice.ID, "department", "support"); err != nil {
+			t.Fatalf("an error '%s' was not expected when replacing an attribute", err)
+		}
+		if _, err := repo.UnsetAttribute(ctx, alice.ID, "locale"); err != nil {
+			t.Fatalf("an error '%s' was not expected when unsetting an attribute", err)
+		}
+		if _, err := repo.UnsetAttribute(ctx, alice.ID, "missing"); err != nil {
+			t.Fatalf("an error '%s' was not expected when unsetting a missing attribute", err)
+		}
+
+		expected := `{"age":30,"department":"support","tags":["a","b"]}`
+		attributes, err := repo.GetAttributes(ctx, alice.ID)
+		if err != nil || attributes.String() != expected {
+			t.Fatalf("got %v, '%v', expected %s", attributes, err, expected)
+		}
+		if user, err := users.GetUserById(ctx, alice.ID); err != nil || user.Attributes.String() != expected {
+			t.Fatalf("got %+v, '%v', expected the user with %s", user, err, expected)
+		}
+		if _, err := repo.GetAttributes(ctx, alice.ID+100); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v', expected sql.ErrNoRows", err)
+		}
+		res, err = repo.SetAttribute(ctx, alice.ID+100, "department", "ops")
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when setting an attribute of a missing user", err)
+		}
+		expectRowsAffected(t, res, 0)
+	})
+
+	t.Run("UpdateKeepsAttributes", func(t *testing.T) {
+		users, repo := newRepos(t)
+		alice := insertUser(t, users, "alice")
+		if alice.Attributes != nil {
+			t.Fatalf("got %v, expected no attributes", alice.Attributes)
+		}
+		if _, err := repo.SetAttribute(ctx, alice.ID, "phone", "+380441234567"); err != nil {
+			t.Fatalf("an error '%s' was not expected when setting an attribute", err)
+		}
+		alice.Name = "alicia"
+		alice.Attributes = nil
+		if _, err := users.UpdateUserById(ctx, alice.ID, alice); err != nil {
+			t.Fatalf("an error '%s' was not expected when updating user", err)
+		}
+		if user, err := users.GetUserById(ctx, alice.ID); err != nil || user.Name != "alicia" || user.Attributes["phone"] != "+380441234567" {
+			t.Fatalf("got %+v, '%v', expected the update to keep the phone", user, err)
+		}
+	})
+
+	t.Run("FilterByAttributes", func(t *testing.T) {
+		users, repo := newRepos(t)
+		alice := insertUser(t, users, "alice")
+		bob := insertUser(t, users, "bob")
+		insertUser(t, users, "carol")
+		for _, set := range []struct {
+			id    int
+			key   string
+			value any
+		}{{alice.ID, "department", "ops"}, {alice.ID, "age", 30}, {bob.ID, "department", "ops"}, {bob.ID, "age", "30"}} {
+			if _, err := repo.SetAttribute(ctx, set.id, set.key, set.value); err != nil {
+				t.Fatalf("an error '%s' was not expected when setting an attribute", err)
+			}
+		}
+
+		ops, err := users.GetUsers(ctx, models.UserFilter{Attributes: models.Attributes{"department": "ops"}})
+		if err != nil || len(ops) != 2 {
+			t.Fatalf("got %+v, '%v', expected alice and bob", ops, err)
+		}
+		// The values keep their JSON type, the number only matches alice.
+		if aged, err := users.GetUsers(ctx, models.UserFilter{Attributes: models.Attributes{"department": "ops", "age": 30}}); err != nil || len(aged) != 1 || aged[0].ID != alice.ID {
+			t.Fatalf("got %+v, '%v', expected only alice", aged, err)
+		}
+		if aged, err := users.GetUsers(ctx, models.UserFilter{Attributes: models.Attributes{"age": "30"}}); err != nil || len(aged) != 1 || aged[0].ID != bob.ID {
+			t.Fatalf("got %+v, '%v', expected only bob", aged, err)
+		}
+		if none, err := users.GetUsers(ctx, models.UserFilter{Status: models.StatusActive, Attributes: models.Attributes{"department": "ops"}}); err != nil || len(none) != 0 {
+			t.Fatalf("got %+v, '%v', expected no active users", none, err)
+		}
+		if none, err := users.GetUsers(ctx, models.UserFilter{Attributes: models.Attributes{"missing": "ops"}}); err != nil || len(none) != 0 {
+			t.Fatalf("got %+v, '%v', expected no users for a missing attribute", none, err)
+		}
+	})
+}
+
+// RunTenantTests runs the IRepositoryTenant conformance tests. newRepo is
+// called once per test and must return a repository over a tenants table
+// holding only the default tenant.
+func RunTenantTests(t *testing.T, newRepo func(t *testing.T) repository.IRepositoryTenant) {
+	ctx := context.Background()
+
+	t.Run("DefaultTenant", func(t *testing.T) {
+		tenant, err := newRepo(t).GetTenantByName(ctx, models.DefaultTenant)
+		if err != nil || tenant.ID != models.DefaultTenantID {
+			t.Fatalf("got %+v, '%v', expected the default tenant", tenant, err)
+		}
+	})
+
+	t.Run("InsertAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, name := range []string{"zeta", "acme"} {
+			res, err := repo.InsertTenant(ctx, models.Tenant{Name: name, CreatedAt: time.Now()})
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when inserting tenant", err)
+			}
+			expectRowsAffected(t, res, 1)
+		}
+		if _, err := repo.InsertTenant(ctx, models.Tenant{Name: "acme", CreatedAt: time.Now()}); err == nil {
+			t.Fatalf("expected an error for a duplicate tenant name")
+		}
+
+		tenants, err := repo.GetTenants(ctx)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when getting tenants", err)
+		}
+		var names []string
+		for _, tenant := range tenants {
+			names = append(names, tenant.Name)
+		}
+		if strings.Join(names, ",") != "acme,default,zeta" {
+			t.Fatalf("got %v, expected the tenants ordered by name", names)
+		}
+		acme, err := repo.GetTenantByName(ctx, "acme")
+		if err != nil || acme.ID == models.DefaultTenantID || acme.CreatedAt.IsZero() {
+			t.Fatalf("got %+v, '%v', expected acme", acme, err)
+		}
+	})
+
+	t.Run("GetMissingTenant", func(t *testing.T) {
+		_, err := newRepo(t).GetTenantByName(ctx, "missing")
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v', expected sql.ErrNoRows", err)
+		}
+	})
+}
+
+// TenantRepos opens the repositories of RunTenantIsolationTests. The others
+// return repositories scoped to the tenant with the given ID.
+type TenantRepos struct {
+	Tenants    repository.IRepositoryTenant
+	Users      func(tenantID int) repository.IRepositoryUser
+	Logs       func(tenantID int) repository.IRepositoryLog
+	Attributes func(tenantID int) repository.IRepositoryUserAttribute
+	Logins     func(tenantID int) repository.IRepositoryLogin
+	Tokens     func(tenantID int) repository.IRepositoryUserToken
+}
+
+// RunTenantIsolationTests checks that repositories scoped to a tenant never
+// see or change the users, logs, logins and tokens of another one. newRepos
+// is called once per test and must return repositories over empty tables.
+func RunTenantIsolationTests(t *testing.T, newRepos func(t *testing.T) TenantRepos) {
+	ctx := context.Background()
+
+	// tenants adds the tenants acme and globex and returns their IDs.
+	tenants := func(t *testing.T, repos TenantRepos) (int, int) {
+		t.Helper()
+		var ids []int
+		for _, name := range []string{"acme", "globex"} {
+			if _, err := repos.Tenants.InsertTenant(ctx, models.Tenant{Name: name, CreatedAt: time.Now()}); err != nil {
+				t.Fatalf("an error '%s' was not expected when inserting tenant", err)
+			}
+			tenant, err := repos.Tenants.GetTenantByName(ctx, name)
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when getting tenant", err)
+			}
+			ids = append(ids, tenant.ID)
+		}
+		return ids[0], ids[1]
+	}
+
+	t.Run("Users", func(t *testing.T) {
+		repos := newRepos(t)
+		acmeID, globexID := tenants(t, repos)
+		acme, globex := repos.Users(acmeID), repos.Users(globexID)
+		alice := insertUser(t, acme, "alice")
+		bob := insertUser(t, globex, "bob")
+		// Emails are only unique within a tenant.
+		insertUser(t, globex, "alice")
+
+		for _, c := range []struct {
+			repo     repository.IRepositoryUser
+			expected string
+		}{{acme, "alice"}, {globex, "bob,alice"}} {
+			users, err := c.repo.GetUsers(ctx, models.UserFilter{})
+			var names []string
+			for _, user := range users {
+				names = append(names, user.Name)
+			}
+			if err != nil || strings.Join(names, ",") != c.expected {
+				t.Fatalf("got %+v, '%v', expected only %s", users, err, c.expected)
+			}
+		}
+		if users, err := repos.Users(models.DefaultTenantID).GetUsers(ctx, models.UserFilter{}); err != nil || len(users) != 0 {
+			t.Fatalf("got %+v, '%v', expected no users in the default tenant", users, err)
+		}
+
+		if _, err := acme.GetUserById(ctx, bob.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v' when getting another tenant's user, expected sql.ErrNoRows", err)
+		}
+		res, err := acme.UpdateUserById(ctx, bob.ID, models.User{Name: "mallory", Email: "mallory@example.com", PasswordHash: "x"})
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when updating another tenant's user", err)
+		}
+		expectRowsAffected(t, res, 0)
+		res, err = acme.DeleteUserById(ctx, bob.ID)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when deleting another tenant's user", err)
+		}
+		expectRowsAffected(t, res, 0)
+		if got, err := globex.GetUserById(ctx, bob.ID); err != nil || got.Name != "bob" {
+			t.Fatalf("got %+v, '%v', expected bob unchanged", got, err)
+		}
+		if got, err := acme.GetUserById(ctx, alice.ID); err != nil || got.Name != "alice" {
+			t.Fatalf("got %+v, '%v', expected alice", got, err)
+		}
+	})
+
+	t.Run("Attributes", func(t *testing.T) {
+		repos := newRepos(t)
+		acmeID, globexID := tenants(t, repos)
+		bob := insertUser(t, repos.Users(globexID), "bob")
+		acme, globex := repos.Attributes(acmeID), repos.Attributes(globexID)
+
+		if _, err := acme.GetAttributes(ctx, bob.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v' when getting another tenant's attributes, expected sql.ErrNoRows", err)
+		}
+		res, err := acme.SetAttribute(ctx, bob.ID, "department", "ops")
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when setting another tenant's attribute", err)
+		}
+		expectRowsAffected(t, res, 0)
+		if attributes, err := globex.GetAttributes(ctx, bob.ID); err != nil || len(attributes) != 0 {
+			t.Fatalf("got %v, '%v', expected bob without attributes", attributes, err)
+		}
+	})
+
+	t.Run("Logs", func(t *testing.T) {
+		repos := newRepos(t)
+		acmeID, globexID := tenants(t, repos)
+		acme, globex := repos.Logs(acmeID), repos.Logs(globexID)
+		for _, c := range []struct {
+			repo    repository.IRepositoryLog
+			message string
+		}{{acme, "acme log"}, {globex, "globex log"}} {
+			if _, err := c.repo.InsertLog(ctx, models.Log{LogTime: time.Now(), LogMessage: c.message}); err != nil {
+				t.Fatalf("an error '%s' was not expected when inserting log", err)
+			}
+		}
+
+		logs, err := acme.GetLogs(ctx)
+		if err != nil || len(logs) != 1 || logs[0].LogMessage != "acme log" {
+			t.Fatalf("got %+v, '%v', expected only the acme log", logs, err)
+		}
+		other, err := globex.GetLogs(ctx)
+		if err != nil || len(other) != 1 {
+			t.Fatalf("got %+v, '%v', expected only the globex log", other, err)
+		}
+		if _, err := acme.GetLogById(ctx, int(other[0].Id)); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v' when getting another tenant's log, expected sql.ErrNoRows", err)
+		}
+		if logs, err := repos.Logs(models.DefaultTenantID).GetLogs(ctx); err != nil || len(logs) != 0 {
+			t.Fatalf("got %+v, '%v', expected no logs in the default tenant", logs, err)
+		}
+	})
+
+	t.Run("Logins", func(t *testing.T) {
+		repos := newRepos(t)
+		acmeID, globexID := tenants(t, repos)
+		acme, globex := repos.Logins(acmeID), repos.Logins(globexID)
+		now := time.Now().Truncate(time.Second)
+		since := now.Add(-time.Hour)
+
+		// The failures of an email are counted per tenant.
+		for _, expected := range []int{1, 2} {
+			if failures, err := acme.AddFailure(ctx, "ann@example.com", now, since); err != nil || failures != expected {
+				t.Fatalf("got %d, '%v', expected %d failures", failures, err, expected)
+			}
+		}
+		if failures, err := globex.AddFailure(ctx, "ann@example.com", now, since); err != nil || failures != 1 {
+			t.Fatalf("got %d, '%v', expected 1 failure in the other tenant", failures, err)
+		}
+		if _, err := acme.LockEmail(ctx, "ann@example.com", now.Add(time.Hour)); err != nil {
+			t.Fatalf("an error '%s' was not expected when locking", err)
+		}
+		if lockout, err := globex.GetLockout(ctx, "ann@example.com"); err != nil || !lockout.LockedUntil.IsZero() || lockout.Failures != 1 {
+			t.Fatalf("got %+v, '%v', expected the email unlocked in the other tenant", lockout, err)
+		}
+		res, err := globex.DeleteLockout(ctx, "ann@example.com")
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when deleting the lockout", err)
+		}
+		expectRowsAffected(t, res, 1)
+		if lockout, err := acme.GetLockout(ctx, "ann@example.com"); err != nil || lockout.LockedUntil.IsZero() {
+			t.Fatalf("got %+v, '%v', expected the email still locked", lockout, err)
+		}
+
+		if _, err := acme.RevokeSession(ctx, "s1", now.Add(time.Hour)); err != nil {
+			t.Fatalf("an error '%s' was not expected when revoking a session", err)
+		}
+		if revoked, err := globex.IsSessionRevoked(ctx, "s1"); err != nil || revoked {
+			t.Fatalf("got %v, '%v', expected the session unknown to the other tenant", revoked, err)
+		}
+		if revoked, err := acme.IsSessionRevoked(ctx, "s1"); err != nil || !revoked {
+			t.Fatalf("got %v, '%v', expected the session revoked", revoked, err)
+		}
+	})
+
+	t.Run("Tokens", func(t *testing.T) {
+		repos := newRepos(t)
+		acmeID, globexID := tenants(t, repos)
+		acme, globex := repos.Tokens(acmeID), repos.Tokens(globexID)
+		now := time.Now().Truncate(time.Millisecond)
+		token := models.UserToken{Purpose: models.PurposeVerifyEmail, Hash: strings.Repeat("a", 64), UserID: 7,
+			Email: "ann@example.com", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+		if _, err := acme.InsertUserToken(ctx, token); err != nil {
+			t.Fatalf("an error '%s' was not expected when inserting a token", err)
+		}
+
+		if _, err := globex.ConsumeUserToken(ctx, token.Purpose, token.Hash, now); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got error '%v' when consuming another tenant's token, expected sql.ErrNoRows", err)
+		}
+		res, err := globex.DeleteUserTokens(ctx, token.Purpose, token.UserID)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when deleting another tenant's tokens", err)
+		}
+		expectRowsAffected(t, res, 0)
+		if got, err := acme.ConsumeUserToken(ctx, token.Purpose, token.Hash, now); err != nil || got.UserID != 7 {
+			t.Fatalf("got %+v, '%v', expected the token", got, err)
+		}
+	})
+}
+
+func insertRole(t *testing.T, repo repository.IRepositoryRole, name string) models.UserRole {
+	t.Helper()
+	ctx := context.Background()
+	res, err := repo.InsertRole(ctx, models.UserRole{Name: name, Description: name + " role", CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting role", err)
+	}
+	expectRowsAffected(t, res, 1)
+	role, err := repo.GetRoleByName(ctx, name)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting role", err)
+	}
+	return role
+}
+
+func insertGroup(t *testing.T, repo repository.IRepositoryGroup, name string) models.Group {
+	t.Helper()
+	ctx := context.Background()
+	res, err := repo.InsertGroup(ctx, models.Group{Name: name, Description: name + " group", CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting group", err)
+	}
+	expectRowsAffected(t, res, 1)
+	group, err := repo.GetGroupByName(ctx, name)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting group", err)
+	}
+	return group
+}
+
+func insertOperator(t *testing.T, repo repository.IRepositoryOperator, name string, role models.Role) models.Operator {
+	t.Helper()
+	ctx := context.Background()
+	res, err := repo.InsertOperator(ctx, models.Operator{Name: name, Role: role, PasswordHash: name + "-hash", CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting operator", err)
+	}
+	expectRowsAffected(t, res, 1)
+	operator, err := repo.GetOperatorByName(ctx, name)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting operator", err)
+	}
+	return operator
+}
+
+// insertUser inserts a user derived from name and returns it as stored. The
+// ID is looked up by email since not every driver supports LastInsertId.
+func insertUser(t *testing.T, repo repository.IRepositoryUser, name string) models.User {
+	t.Helper()
+	ctx := context.Background()
+	email := name + "@example.com"
+	res, err := repo.InsertUser(ctx, models.User{Name: name, Email: email, PasswordHash: name + "-password"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting user", err)
+	}
+	expectRowsAffected(t, res, 1)
+
+	users, err := repo.GetUsers(ctx, models.UserFilter{})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting users", err)
+	}
+	for _, user := range users {
+		if user.Email == email {
+			return user
+		}
+	}
+	t.Fatalf("inserted user %s not found", email)
+	return models.User{}
+}
+
+func expectRowsAffected(t *testing.T, res sql.Result, expected int64) {
+	t.Helper()
+	affected, err := res.RowsAffected()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting affected rows", err)
+	}
+	if affected != expected {
+		t.Fatalf("got %d rows affected, expected %d", affected, expected)
+	}
+}
@@ -0,0 +1,271 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	usermanagerv1 "github.com/BohdanIpy/simpleCLIdbManager/api/usermanager/v1"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"net/url"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrReadOnly is returned for writes to the audit log of a remote server,
+// the server writes it itself.
+var ErrReadOnly = errors.New("the remote audit log is read-only")
+
+// ErrUnauthorized is returned when the server rejects the API token.
+var ErrUnauthorized = errors.New("the server rejected the API token")
+
+// Dial connects to the server at target, grpc://host:port in plain text or
+// grpcs://host:port over TLS. Calls send token as a bearer token unless it
+// is empty.
+func Dial(target, token string) (*grpc.ClientConn, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid remote %q, expected grpc://host:port or grpcs://host:port", target)
+	}
+	var creds credentials.TransportCredentials
+	switch u.Scheme {
+	case "grpc":
+		creds = insecure.NewCredentials()
+	case "grpcs":
+		creds = credentials.NewClientTLSFromCert(nil, "")
+	default:
+		return nil, fmt.Errorf("invalid remote %q, expected grpc://host:port or grpcs://host:port", target)
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken(token)))
+	}
+	return grpc.NewClient(u.Host, opts...)
+}
+
+// bearerToken sends the API token with every call.
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{authorizationHeader: "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity allows plain text connections, e.g. to a server
+// behind a TLS terminating proxy.
+func (t bearerToken) RequireTransportSecurity() bool {
+	return false
+}
+
+// result is the sql.Result of a remote change.
+type result struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) { return r.lastInsertId, nil }
+func (r result) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+// outgoing passes the batch of ctx on to the server.
+func outgoing(ctx context.Context) context.Context {
+	if batch := middleware.BatchFromContext(ctx); batch != "" {
+		return metadata.AppendToOutgoingContext(ctx, batchHeader, batch)
+	}
+	return ctx
+}
+
+// fromStatus turns the status of a failed call back into the errors the
+// repositories return.
+func fromStatus(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch st.Code() {
+	case codes.NotFound:
+		return sql.ErrNoRows
+	case codes.Unauthenticated:
+		return ErrUnauthorized
+	case codes.PermissionDenied:
+		return fmt.Errorf("%w: %s", auth.ErrForbidden, strings.TrimPrefix(st.Message(), auth.ErrForbidden.Error()+": "))
+	case codes.InvalidArgument:
+		var verr models.ValidationError
+		for _, detail := range st.Details() {
+			if br, ok := detail.(*errdetails.BadRequest); ok {
+				for _, v := range br.GetFieldViolations() {
+					verr = append(verr, models.FieldError{Field: v.GetField(), Message: v.GetDescription()})
+				}
+			}
+		}
+		if len(verr) > 0 {
+			return verr
+		}
+	}
+	return fmt.Errorf("remote: %s (%s)", st.Message(), st.Code())
+}
+
+type RemoteRepoUser struct {
+	client usermanagerv1.UserServiceClient
+}
+
+func fromProtoUser(msg *usermanagerv1.User) models.User {
+	user := models.User{
+		ID:           int(msg.GetId()),
+		Name:         msg.GetName(),
+		Email:        msg.GetEmail(),
+		RegisteredAt: msg.GetRegisteredAt().AsTime(),
+		Status:       models.UserStatus(msg.GetStatus()),
+	}
+	if msg.GetEmailVerifiedAt() != nil {
+		user.EmailVerifiedAt = msg.GetEmailVerifiedAt().AsTime()
+	}
+	if attributes := msg.GetAttributes().AsMap(); len(attributes) > 0 {
+		user.Attributes = attributes
+	}
+	return user
+}
+
+func (p RemoteRepoUser) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	users := []models.User{}
+	req := &usermanagerv1.ListUsersRequest{PageSize: maxPageSize, Email: filter.Email, Status: string(filter.Status), Role: filter.Role, Group: filter.Group}
+	for key, value := range filter.Attributes {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if req.Attributes == nil {
+			req.Attributes = make(map[string]string)
+		}
+		req.Attributes[key] = string(b)
+	}
+	for {
+		resp, err := p.client.ListUsers(outgoing(ctx), req)
+		if err != nil {
+			return nil, fromStatus(err)
+		}
+		for _, msg := range resp.GetUsers() {
+			users = append(users, fromProtoUser(msg))
+		}
+		if resp.GetNextPageToken() == "" {
+			return users, nil
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+}
+
+// GetUserById returns the user without the password, the server does not
+// return it.
+func (p RemoteRepoUser) GetUserById(ctx context.Context, id int) (models.User, error) {
+	msg, err := p.client.GetUser(outgoing(ctx), &usermanagerv1.GetUserRequest{Id: int32(id)})
+	if err != nil {
+		return models.User{}, fromStatus(err)
+	}
+	return fromProtoUser(msg), nil
+}
+
+func (p RemoteRepoUser) InsertUser(ctx context.Context, user models.User) (sql.Result, error) {
+	msg, err := p.client.CreateUser(outgoing(ctx), &usermanagerv1.CreateUserRequest{
+		Name:     user.Name,
+		Email:    user.Email,
+		Password: user.Password,
+	})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return result{lastInsertId: int64(msg.GetId()), rowsAffected: 1}, nil
+}
+
+// UpdateUserById keeps the current password when user has none, and the
+// current status when user has none. The reason of ctx is sent along.
+func (p RemoteRepoUser) UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error) {
+	req := &usermanagerv1.UpdateUserRequest{Id: int32(id), Name: &user.Name, Email: &user.Email, Reason: middleware.ReasonFromContext(ctx)}
+	if user.Password != "" {
+		req.Password = &user.Password
+	}
+	if user.Status != "" {
+		status := string(user.Status)
+		req.Status = &status
+	}
+	_, err := p.client.UpdateUser(outgoing(ctx), req)
+	return changeResult(err)
+}
+
+func (p RemoteRepoUser) DeleteUserById(ctx context.Context, id int) (sql.Result, error) {
+	_, err := p.client.DeleteUser(outgoing(ctx), &usermanagerv1.DeleteUserRequest{Id: int32(id)})
+	return changeResult(err)
+}
+
+// changeResult reports a missing user as no affected rows, like the SQL
+// repositories do.
+func changeResult(err error) (sql.Result, error) {
+	if err == nil {
+		return result{rowsAffected: 1}, nil
+	}
+	if err = fromStatus(err); errors.Is(err, sql.ErrNoRows) {
+		return result{}, nil
+	}
+	return nil, err
+}
+
+func NewRemoteRepoUser(conn grpc.ClientConnInterface) repository.IRepositoryUser {
+	return RemoteRepoUser{client: usermanagerv1.NewUserServiceClient(conn)}
+}
+
+// RemoteRepoLog reads the audit log of a server. The audit records hold what
+// was changed but not the previous values, so only inserts can be undone.
+type RemoteRepoLog struct {
+	client usermanagerv1.LogServiceClient
+}
+
+func fromProtoLog(msg *usermanagerv1.Log) models.Log {
+	log := models.Log{Id: msg.GetId(), LogTime: msg.GetTime().AsTime(), LogMessage: msg.GetMessage(), Actor: msg.GetActor()}
+	if change := msg.GetChange(); change != nil {
+		details, _ := json.Marshal(models.AuditRecord{Op: change.GetOp(), UserID: int(change.GetUserId()), Batch: change.GetBatch(), Reason: change.GetReason()})
+		log.Details = string(details)
+	}
+	return log
+}
+
+func (p RemoteRepoLog) GetLogs(ctx context.Context) ([]models.Log, error) {
+	logs := []models.Log{}
+	req := &usermanagerv1.ListLogsRequest{PageSize: maxPageSize}
+	for {
+		resp, err := p.client.ListLogs(outgoing(ctx), req)
+		if err != nil {
+			return nil, fromStatus(err)
+		}
+		for _, msg := range resp.GetLogs() {
+			logs = append(logs, fromProtoLog(msg))
+		}
+		if resp.GetNextPageToken() == "" {
+			return logs, nil
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+}
+
+func (p RemoteRepoLog) GetLogById(ctx context.Context, id int) (models.Log, error) {
+	msg, err := p.client.GetLog(outgoing(ctx), &usermanagerv1.GetLogRequest{Id: int64(id)})
+	if err != nil {
+		return models.Log{}, fromStatus(err)
+	}
+	return fromProtoLog(msg), nil
+}
+
+func (p RemoteRepoLog) InsertLog(ctx context.Context, log models.Log) (sql.Result, error) {
+	return nil, ErrReadOnly
+}
+
+func NewRemoteRepoLog(conn grpc.ClientConnInterface) repository.IRepositoryLog {
+	return RemoteRepoLog{client: usermanagerv1.NewLogServiceClient(conn)}
+}
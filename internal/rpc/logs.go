@@ -0,0 +1,55 @@
+package rpc
+
+import (
+	"context"
+	usermanagerv1 "github.com/BohdanIpy/simpleCLIdbManager/api/usermanager/v1"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"slices"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type logServer struct {
+	usermanagerv1.UnimplementedLogServiceServer
+	logs repository.IRepositoryLog
+}
+
+// toProtoLog converts log with only what its audit record changed, the
+// previous values hold password hashes.
+func toProtoLog(log models.Log) *usermanagerv1.Log {
+	msg := &usermanagerv1.Log{Id: log.Id, Time: timestamppb.New(log.LogTime), Message: log.LogMessage, Actor: log.Actor}
+	if record, ok := log.Audit(); ok {
+		msg.Change = &usermanagerv1.Change{Op: record.Op, UserId: int32(record.UserID), Batch: record.Batch, Reason: record.Reason}
+	}
+	return msg
+}
+
+func (s *logServer) GetLog(ctx context.Context, req *usermanagerv1.GetLogRequest) (*usermanagerv1.Log, error) {
+	if err := checkID(req.GetId()); err != nil {
+		return nil, err
+	}
+	log, err := s.logs.GetLogById(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, err
+	}
+	return toProtoLog(log), nil
+}
+
+func (s *logServer) ListLogs(ctx context.Context, req *usermanagerv1.ListLogsRequest) (*usermanagerv1.ListLogsResponse, error) {
+	logs, err := s.logs.GetLogs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(logs, func(a, b models.Log) int { return int(a.Id - b.Id) })
+
+	items, next, err := page(logs, req.GetPageSize(), req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	resp := &usermanagerv1.ListLogsResponse{NextPageToken: next, TotalSize: int32(len(logs))}
+	for _, log := range items {
+		resp.Logs = append(resp.Logs, toProtoLog(log))
+	}
+	return resp, nil
+}
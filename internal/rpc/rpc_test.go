@@ -0,0 +1,344 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	usermanagerv1 "github.com/BohdanIpy/simpleCLIdbManager/api/usermanager/v1"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testServer is a server over memory repositories and a client of it.
+type testServer struct {
+	users  repository.IRepositoryUser
+	logs   repository.IRepositoryLog
+	events *middleware.UserEvents
+	conn   *grpc.ClientConn
+}
+
+func startServer(t *testing.T, users repository.IRepositoryUser, logs repository.IRepositoryLog, svc *auth.Service) *testServer {
+	t.Helper()
+	ts := &testServer{logs: logs, events: middleware.NewUserEvents()}
+	ts.users = middleware.NewEventsMiddleware(ts.events, users)
+
+	listener := bufconn.Listen(1 << 20)
+	srv := NewServer(ts.users, ts.logs, ts.events, svc)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when connecting to the server", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	ts.conn = conn
+	return ts
+}
+
+func startMemoryServer(t *testing.T) *testServer {
+	logs := imp.NewMemoryRepoLog()
+	return startServer(t, middleware.NewLoggerMiddleware(logs, middleware.NewPasswordMiddleware(imp.NewMemoryRepoUser())), logs, nil)
+}
+
+func TestRemoteRepoUser_RoundTrip(t *testing.T) {
+	ts := startMemoryServer(t)
+	remote := NewRemoteRepoUser(ts.conn)
+	ctx := context.Background()
+
+	res, err := remote.InsertUser(ctx, models.User{Name: "Ann", Email: "ann@example.com", Password: "secret"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting a user", err)
+	}
+	if id, _ := res.LastInsertId(); id != 1 {
+		t.Fatalf("got id %d, expected 1", id)
+	}
+
+	user, err := remote.GetUserById(ctx, 1)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting user by id", err)
+	}
+	if user.Name != "Ann" || user.Password != "" || user.RegisteredAt.IsZero() {
+		t.Fatalf("got %+v, expected Ann without the password", user)
+	}
+
+	// An update without a password keeps the current one.
+	user.Email = "annie@example.com"
+	if res, err = remote.UpdateUserById(ctx, 1, user); err != nil {
+		t.Fatalf("an error '%s' was not expected when updating a user", err)
+	}
+	if rows, _ := res.RowsAffected(); rows != 1 {
+		t.Fatalf("got %d affected rows, expected 1", rows)
+	}
+	stored, _ := ts.users.GetUserById(ctx, 1)
+	if stored.Email != "annie@example.com" || bcrypt.CompareHashAndPassword([]byte(stored.PasswordHash), []byte("secret")) != nil {
+		t.Fatalf("got %+v, expected the new email and the old password", stored)
+	}
+
+	var verr models.ValidationError
+	if _, err = remote.InsertUser(ctx, models.User{Name: "Bob", Email: "nope", Password: "pw"}); !errors.As(err, &verr) || verr.Field("email") == "" {
+		t.Fatalf("got '%v', expected a validation error of the email", err)
+	}
+
+	if res, err = remote.DeleteUserById(ctx, 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when deleting a user", err)
+	}
+	if rows, _ := res.RowsAffected(); rows != 1 {
+		t.Fatalf("got %d affected rows, expected 1", rows)
+	}
+	for _, change := range []func() (sql.Result, error){
+		func() (sql.Result, error) { return remote.DeleteUserById(ctx, 1) },
+		func() (sql.Result, error) { return remote.UpdateUserById(ctx, 1, user) },
+	} {
+		res, err := change()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when changing a deleted user", err)
+		}
+		if rows, _ := res.RowsAffected(); rows != 0 {
+			t.Fatalf("got %d affected rows, expected 0 for a deleted user", rows)
+		}
+	}
+	if _, err = remote.GetUserById(ctx, 1); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("got '%v', expected sql.ErrNoRows", err)
+	}
+}
+
+func TestRemoteRepoLog_KeepsBatchesWithoutPreviousValues(t *testing.T) {
+	ts := startMemoryServer(t)
+	remote := NewRemoteRepoUser(ts.conn)
+	ctx := middleware.WithBatch(context.Background(), "batch-1")
+
+	for _, name := range []string{"a", "b"} {
+		if _, err := remote.InsertUser(ctx, models.User{Name: name, Email: name + "@example.com", Password: "secret"}); err != nil {
+			t.Fatalf("an error '%s' was not expected when inserting a user", err)
+		}
+	}
+	if _, err := remote.DeleteUserById(ctx, 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when deleting a user", err)
+	}
+
+	logs, err := NewRemoteRepoLog(ts.conn).GetLogs(context.Background())
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting logs", err)
+	}
+	var ops []string
+	for _, log := range logs {
+		if strings.Contains(log.Details, "secret") {
+			t.Fatalf("the audit log exposed a password: %s", log.Details)
+		}
+		if record, ok := log.Audit(); ok {
+			if record.Batch != "batch-1" || record.Before != nil {
+				t.Fatalf("got record %+v, expected batch-1 without previous values", record)
+			}
+			ops = append(ops, record.Op)
+		}
+	}
+	if strings.Join(ops, ",") != "insert,insert,delete" {
+		t.Fatalf("got changes %v, expected two inserts and a delete", ops)
+	}
+	if _, err := NewRemoteRepoLog(ts.conn).InsertLog(ctx, models.Log{}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("got '%v', expected ErrReadOnly", err)
+	}
+}
+
+func TestUserService_ListUsersPages(t *testing.T) {
+	ts := startMemoryServer(t)
+	client := usermanagerv1.NewUserServiceClient(ts.conn)
+	ctx := context.Background()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		ts.users.InsertUser(ctx, models.User{Name: name, Email: name + "@example.com", Password: "pw", RegisteredAt: time.Now()})
+	}
+
+	var names []string
+	req := &usermanagerv1.ListUsersRequest{PageSize: 2}
+	for pages := 1; ; pages++ {
+		resp, err := client.ListUsers(ctx, req)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when listing users", err)
+		}
+		if resp.GetTotalSize() != 5 {
+			t.Fatalf("got total size %d, expected 5", resp.GetTotalSize())
+		}
+		for _, user := range resp.GetUsers() {
+			names = append(names, user.GetName())
+		}
+		if resp.GetNextPageToken() == "" {
+			if pages != 3 {
+				t.Fatalf("got %d pages, expected 3", pages)
+			}
+			break
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+	if strings.Join(names, "") != "abcde" {
+		t.Fatalf("got users %v, expected a to e in order", names)
+	}
+
+	for _, req := range []*usermanagerv1.ListUsersRequest{{PageSize: -1}, {PageSize: 501}, {PageToken: "x"}} {
+		if _, err := client.ListUsers(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("got '%v' for %v, expected InvalidArgument", err, req)
+		}
+	}
+}
+
+func TestRemoteRepoUser_FiltersByAttributes(t *testing.T) {
+	ts := startMemoryServer(t)
+	ctx := context.Background()
+	ts.users.InsertUser(ctx, models.User{Name: "Ann", Email: "ann@example.com", Password: "pw", Attributes: models.Attributes{"department": "ops", "floor": 3}})
+	ts.users.InsertUser(ctx, models.User{Name: "Bob", Email: "bob@example.com", Password: "pw", Attributes: models.Attributes{"department": "ops", "floor": "3"}})
+	ts.users.InsertUser(ctx, models.User{Name: "Cid", Email: "cid@example.com", Password: "pw"})
+
+	got, err := NewRemoteRepoUser(ts.conn).GetUsers(ctx, models.UserFilter{Attributes: models.Attributes{"department": "ops", "floor": 3}})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting users", err)
+	}
+	if len(got) != 1 || got[0].Name != "Ann" || got[0].Attributes.String() != `{"department":"ops","floor":3}` {
+		t.Fatalf("got %+v, expected Ann with her attributes", got)
+	}
+
+	req := &usermanagerv1.ListUsersRequest{Attributes: map[string]string{"a b": "1"}}
+	if _, err := usermanagerv1.NewUserServiceClient(ts.conn).ListUsers(ctx, req); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("got '%v', expected InvalidArgument for an invalid attribute name", err)
+	}
+}
+
+func TestUserService_WatchUsers(t *testing.T) {
+	ts := startMemoryServer(t)
+	client := usermanagerv1.NewUserServiceClient(ts.conn)
+	remote := NewRemoteRepoUser(ts.conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchUsers(ctx, &usermanagerv1.WatchUsersRequest{})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when watching users", err)
+	}
+	// Changes made once the headers arrived are streamed.
+	if _, err := stream.Header(); err != nil {
+		t.Fatalf("an error '%s' was not expected when waiting for the subscription", err)
+	}
+
+	user := models.User{Name: "Ann", Email: "ann@example.com", Password: "secret"}
+	remote.InsertUser(ctx, user)
+	user.Name = "Annie"
+	remote.UpdateUserById(ctx, 1, user)
+	remote.DeleteUserById(ctx, 1)
+
+	expected := []usermanagerv1.UserEvent_Type{
+		usermanagerv1.UserEvent_TYPE_CREATED,
+		usermanagerv1.UserEvent_TYPE_UPDATED,
+		usermanagerv1.UserEvent_TYPE_DELETED,
+	}
+	for _, want := range expected {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when receiving an event", err)
+		}
+		if event.GetType() != want || event.GetId() != 1 {
+			t.Fatalf("got event %v, expected %v of user 1", event, want)
+		}
+		if want == usermanagerv1.UserEvent_TYPE_DELETED && event.GetUser() != nil {
+			t.Fatalf("got a user with the delete event %v", event)
+		}
+	}
+}
+
+// failingRepo fails every listing with err.
+type failingRepo struct {
+	repository.IRepositoryUser
+	err error
+}
+
+func (f failingRepo) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	return nil, f.err
+}
+
+func TestServer_ErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{fmt.Errorf("users: %w", middleware.ErrCircuitOpen), codes.Unavailable},
+		{errors.New("syntax error at or near"), codes.Internal},
+	}
+	for _, tt := range tests {
+		ts := startServer(t, failingRepo{err: tt.err}, imp.NewMemoryRepoLog(), nil)
+		_, err := usermanagerv1.NewUserServiceClient(ts.conn).ListUsers(context.Background(), &usermanagerv1.ListUsersRequest{})
+		if status.Code(err) != tt.code || strings.Contains(err.Error(), "syntax") {
+			t.Fatalf("got '%v' for '%v', expected %s without internals", err, tt.err, tt.code)
+		}
+	}
+}
+
+func TestServer_RequiresOperatorToken(t *testing.T) {
+	svc := auth.NewService(imp.NewMemoryRepoOperator())
+	admin := auth.WithOperator(context.Background(), auth.Bootstrap)
+	tokens := map[string]string{}
+	for name, role := range map[string]models.Role{"vera": models.RoleViewer, "ed": models.RoleEditor} {
+		if err := svc.AddOperator(admin, name, role, ""); err != nil {
+			t.Fatalf("an error '%s' was not expected when adding an operator", err)
+		}
+		token, err := svc.CreateToken(admin, name, "test")
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when creating a token", err)
+		}
+		tokens[name] = token
+	}
+	logs := imp.NewMemoryRepoLog()
+	ts := startServer(t, middleware.NewLoggerMiddleware(logs, middleware.NewAuthorizationUserMiddleware(middleware.NewPasswordMiddleware(imp.NewMemoryRepoUser()))), logs, svc)
+	ctx := context.Background()
+
+	if _, err := NewRemoteRepoUser(ts.conn).GetUsers(ctx, models.UserFilter{}); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("got '%v' without a token, expected ErrUnauthorized", err)
+	}
+	client := usermanagerv1.NewUserServiceClient(ts.conn)
+	if _, err := client.ListUsers(ctx, &usermanagerv1.ListUsersRequest{}, grpc.PerRPCCredentials(bearerToken("wrong"))); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("got '%v' with a wrong token, expected Unauthenticated", err)
+	}
+	if _, err := client.ListUsers(ctx, &usermanagerv1.ListUsersRequest{}, grpc.PerRPCCredentials(bearerToken(tokens["vera"]))); err != nil {
+		t.Fatalf("an error '%s' was not expected with the token", err)
+	}
+	stream, err := client.WatchUsers(ctx, &usermanagerv1.WatchUsersRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("got '%v' when watching without a token, expected Unauthenticated", err)
+	}
+
+	user := models.User{Name: "Ann", Email: "ann@example.com", Password: "secret"}
+	_, err = client.CreateUser(ctx, &usermanagerv1.CreateUserRequest{Name: user.Name, Email: user.Email, Password: user.Password},
+		grpc.PerRPCCredentials(bearerToken(tokens["vera"])))
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("got '%v' when a viewer creates a user, expected PermissionDenied", err)
+	}
+	_, err = client.CreateUser(ctx, &usermanagerv1.CreateUserRequest{Name: user.Name, Email: user.Email, Password: user.Password},
+		grpc.PerRPCCredentials(bearerToken(tokens["ed"])))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when an editor creates a user", err)
+	}
+	entries, err := logs.GetLogs(ctx)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting logs", err)
+	}
+	if last := entries[len(entries)-1]; last.Actor != "ed" {
+		t.Fatalf("got actor %q, expected the editor to be recorded", last.Actor)
+	}
+}
@@ -0,0 +1,249 @@
+// Package rpc serves the user and log repositories over gRPC and provides
+// repositories backed by such a server.
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	usermanagerv1 "github.com/BohdanIpy/simpleCLIdbManager/api/usermanager/v1"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/tracing"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+	// batchHeader carries the batch set by middleware.WithBatch, so the
+	// changes of one remote command are undone together.
+	batchHeader = "x-batch"
+	// authorizationHeader carries the API token.
+	authorizationHeader = "authorization"
+)
+
+// NewServer returns a gRPC server with the UserService and LogService
+// registered. WatchUsers streams the events published to events, usually
+// by the events middleware in users. Unless svc is nil, calls must send the
+// API token of an operator as a bearer token.
+func NewServer(users repository.IRepositoryUser, logs repository.IRepositoryLog, events *middleware.UserEvents, svc *auth.Service) *grpc.Server {
+	authn := authenticator{svc: svc}
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(authn.unary, unaryInterceptor),
+		grpc.ChainStreamInterceptor(authn.stream, streamInterceptor),
+	)
+	usermanagerv1.RegisterUserServiceServer(s, &userServer{users: users, events: events})
+	usermanagerv1.RegisterLogServiceServer(s, &logServer{logs: logs})
+	return s
+}
+
+// Serve serves s on addr until ctx is canceled, then stops accepting calls
+// and waits up to shutdownTimeout for running ones. Open streams are
+// canceled when the time is up.
+func Serve(ctx context.Context, addr string, s *grpc.Server, shutdownTimeout time.Duration) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Serve(listener)
+	}()
+	log.Printf("[INFO] serving gRPC on %s", addr)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		s.Stop()
+		<-stopped
+	}
+	if err := <-errc; err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// authenticator rejects calls without the API token of an operator and
+// passes the operator on in the context. A nil service accepts all calls.
+type authenticator struct {
+	svc *auth.Service
+}
+
+func (a authenticator) check(ctx context.Context) (context.Context, error) {
+	if a.svc == nil {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get(authorizationHeader) {
+		token, ok := strings.CutPrefix(value, "Bearer ")
+		if !ok {
+			continue
+		}
+		operator, err := a.svc.Authenticate(ctx, token)
+		if err == nil {
+			return auth.WithOperator(ctx, operator), nil
+		}
+		if !errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, toStatus(err)
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "missing or invalid API token")
+}
+
+func (a authenticator) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := a.check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a authenticator) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.check(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+func unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, span := startCall(ctx, info.FullMethod)
+	defer span.End()
+	resp, err := handler(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, toStatus(err)
+	}
+	return resp, nil
+}
+
+func streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, span := startCall(ss.Context(), info.FullMethod)
+	defer span.End()
+	if err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx}); err != nil {
+		span.RecordError(err)
+		return toStatus(err)
+	}
+	return nil
+}
+
+// startCall starts the span of a call and restores the batch of the caller.
+func startCall(ctx context.Context, method string) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, "rpc.request", tracing.String("rpc.method", method))
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if batch := md.Get(batchHeader); len(batch) > 0 {
+			ctx = middleware.WithBatch(ctx, batch[0])
+		}
+	}
+	return ctx, span
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// toStatus turns err into the status the client gets, errors the client
+// cannot act on are logged and reported without their details.
+func toStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	var verr models.ValidationError
+	var terr *models.TransitionError
+	switch {
+	case errors.As(err, &verr):
+		st := status.New(codes.InvalidArgument, "validation failed")
+		details := &errdetails.BadRequest{}
+		for _, e := range verr {
+			details.FieldViolations = append(details.FieldViolations,
+				&errdetails.BadRequest_FieldViolation{Field: e.Field, Description: e.Message})
+		}
+		if withDetails, err := st.WithDetails(details); err == nil {
+			st = withDetails
+		}
+		return st.Err()
+	case errors.As(err, &terr):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return status.Error(codes.NotFound, "not found")
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, auth.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, middleware.ErrCircuitOpen):
+		return status.Error(codes.Unavailable, "database unavailable")
+	case errors.Is(err, middleware.ErrSubscriberTooSlow):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		log.Printf("[ERROR] call failed: %v", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func invalidArgument(format string, args ...any) error {
+	return status.Errorf(codes.InvalidArgument, format, args...)
+}
+
+// page returns the items selected by a page size and token, and the token
+// of the next page. Tokens are offsets.
+func page[T any](items []T, size int32, token string) ([]T, string, error) {
+	if size == 0 {
+		size = defaultPageSize
+	}
+	if size < 0 || size > maxPageSize {
+		return nil, "", invalidArgument("page_size must be between 1 and %d", maxPageSize)
+	}
+	offset := 0
+	if token != "" {
+		var err error
+		if offset, err = strconv.Atoi(token); err != nil || offset < 0 {
+			return nil, "", invalidArgument("invalid page_token %q", token)
+		}
+	}
+	start := min(offset, len(items))
+	end := min(start+int(size), len(items))
+	next := ""
+	if end < len(items) {
+		next = strconv.Itoa(end)
+	}
+	return items[start:end], next, nil
+}
+
+func checkID[T int32 | int64](id T) error {
+	if id < 1 {
+		return invalidArgument("invalid id %d", id)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	usermanagerv1 "github.com/BohdanIpy/simpleCLIdbManager/api/usermanager/v1"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// watchBuffer is how many events a WatchUsers stream may fall behind.
+const watchBuffer = 256
+
+type userServer struct {
+	usermanagerv1.UnimplementedUserServiceServer
+	users  repository.IRepositoryUser
+	events *middleware.UserEvents
+}
+
+// toProtoUser converts user without the password.
+func toProtoUser(user models.User) *usermanagerv1.User {
+	msg := &usermanagerv1.User{
+		Id:           int32(user.ID),
+		Name:         user.Name,
+		Email:        user.Email,
+		RegisteredAt: timestamppb.New(user.RegisteredAt),
+		Status:       string(user.Status),
+	}
+	if user.EmailVerified() {
+		msg.EmailVerifiedAt = timestamppb.New(user.EmailVerifiedAt)
+	}
+	if len(user.Attributes) > 0 {
+		// The attributes are read back from JSON, which only has values a
+		// Struct can hold.
+		msg.Attributes, _ = structpb.NewStruct(user.Attributes)
+	}
+	return msg
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *usermanagerv1.GetUserRequest) (*usermanagerv1.User, error) {
+	if err := checkID(req.GetId()); err != nil {
+		return nil, err
+	}
+	user, err := s.users.GetUserById(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, err
+	}
+	return toProtoUser(user), nil
+}
+
+func (s *userServer) ListUsers(ctx context.Context, req *usermanagerv1.ListUsersRequest) (*usermanagerv1.ListUsersResponse, error) {
+	filter := models.UserFilter{Email: req.GetEmail(), Role: req.GetRole(), Group: req.GetGroup()}
+	if req.GetStatus() != "" {
+		var err error
+		if filter.Status, err = models.ParseUserStatus(req.GetStatus()); err != nil {
+			return nil, invalidArgument("%s", err)
+		}
+	}
+	for key, value := range req.GetAttributes() {
+		if err := models.ValidateAttributeKey(key); err != nil {
+			return nil, invalidArgument("%s", err)
+		}
+		if filter.Attributes == nil {
+			filter.Attributes = make(models.Attributes)
+		}
+		filter.Attributes[key] = models.ParseAttributeValue(value)
+	}
+	users, err := s.users.GetUsers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(users, func(a, b models.User) int { return a.ID - b.ID })
+
+	items, next, err := page(users, req.GetPageSize(), req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	resp := &usermanagerv1.ListUsersResponse{NextPageToken: next, TotalSize: int32(len(users))}
+	for _, user := range items {
+		resp.Users = append(resp.Users, toProtoUser(user))
+	}
+	return resp, nil
+}
+
+func (s *userServer) CreateUser(ctx context.Context, req *usermanagerv1.CreateUserRequest) (*usermanagerv1.User, error) {
+	user := models.User{Name: req.GetName(), Email: req.GetEmail(), Password: req.GetPassword(), RegisteredAt: time.Now()}
+	if err := user.Validate(); err != nil {
+		return nil, err
+	}
+	res, err := s.users.InsertUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "the user was created, but its id is unknown")
+	}
+	created, err := s.users.GetUserById(ctx, int(id))
+	if err != nil {
+		return nil, err
+	}
+	return toProtoUser(created), nil
+}
+
+func (s *userServer) UpdateUser(ctx context.Context, req *usermanagerv1.UpdateUserRequest) (*usermanagerv1.User, error) {
+	if err := checkID(req.GetId()); err != nil {
+		return nil, err
+	}
+	id := int(req.GetId())
+	user, err := s.users.GetUserById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		user.Name = req.GetName()
+	}
+	if req.Email != nil {
+		user.Email = req.GetEmail()
+	}
+	if req.Password != nil {
+		user.Password = req.GetPassword()
+	}
+	if req.Status != nil && models.UserStatus(req.GetStatus()) != user.Status {
+		if user, err = user.Transition(models.UserStatus(req.GetStatus())); err != nil {
+			return nil, err
+		}
+	}
+	if err := user.Validate(); err != nil {
+		return nil, err
+	}
+
+	if req.GetReason() != "" {
+		ctx = middleware.WithReason(ctx, req.GetReason())
+	}
+	res, err := s.users.UpdateUserById(ctx, id, user)
+	if err != nil {
+		return nil, err
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return nil, sql.ErrNoRows
+	}
+	updated, err := s.users.GetUserById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toProtoUser(updated), nil
+}
+
+func (s *userServer) DeleteUser(ctx context.Context, req *usermanagerv1.DeleteUserRequest) (*usermanagerv1.DeleteUserResponse, error) {
+	if err := checkID(req.GetId()); err != nil {
+		return nil, err
+	}
+	res, err := s.users.DeleteUserById(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, err
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &usermanagerv1.DeleteUserResponse{}, nil
+}
+
+var eventTypes = map[middleware.UserEventType]usermanagerv1.UserEvent_Type{
+	middleware.UserCreated: usermanagerv1.UserEvent_TYPE_CREATED,
+	middleware.UserUpdated: usermanagerv1.UserEvent_TYPE_UPDATED,
+	middleware.UserDeleted: usermanagerv1.UserEvent_TYPE_DELETED,
+}
+
+func (s *userServer) WatchUsers(req *usermanagerv1.WatchUsersRequest, stream grpc.ServerStreamingServer[usermanagerv1.UserEvent]) error {
+	if s.events == nil {
+		return status.Error(codes.Unimplemented, "the server does not publish user events")
+	}
+	sub := s.events.Subscribe(watchBuffer)
+	defer sub.Cancel()
+	// The headers tell the client that the subscription is in place.
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-sub.Events():
+			if !ok {
+				return sub.Err()
+			}
+			msg := &usermanagerv1.UserEvent{Type: eventTypes[event.Type], Id: int32(event.ID)}
+			if event.Type != middleware.UserDeleted {
+				// The user may be gone already, the event is sent anyway.
+				if user, err := s.users.GetUserById(ctx, event.ID); err == nil {
+					msg.User = toProtoUser(user)
+				}
+			}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
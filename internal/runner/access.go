@@ -0,0 +1,226 @@
+package runner
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+func (r *Runner) handleRoles(ctx context.Context, args []string, opts options) error {
+	svc := r.status.Access
+	if svc == nil {
+		return errors.New("roles need a database connection")
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		roles, err := svc.Roles(ctx)
+		if err != nil {
+			return err
+		}
+		if len(roles) == 0 {
+			fmt.Fprintln(r.out, "No roles, add one with: roles add <name> [<description>]")
+			return nil
+		}
+		w := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tDESCRIPTION")
+		for _, role := range roles {
+			fmt.Fprintf(w, "%s\t%s\n", role.Name, role.Description)
+		}
+		return w.Flush()
+
+	case args[0] == "add" && (len(args) == 2 || len(args) == 3):
+		description := ""
+		if len(args) == 3 {
+			description = args[2]
+		}
+		if err := svc.AddRole(ctx, args[1], description); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "Added role %s\n", args[1])
+		return nil
+
+	case args[0] == "delete" && len(args) == 2:
+		if ok, err := r.confirmChange(ctx, opts, fmt.Sprintf("Delete role %s and revoke it from every user? [y/N]: ", args[1])); !ok || err != nil {
+			return err
+		}
+		if err := svc.DeleteRole(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "Deleted role %s\n", args[1])
+		return nil
+
+	case (args[0] == "assign" || args[0] == "revoke") && len(args) >= 3:
+		ids, err := parseIDs(args[2:])
+		if err != nil {
+			return err
+		}
+		change, done, unchanged := svc.AssignRole, "Assigned %s to user %d\n", "User %d has %s already\n"
+		if args[0] == "revoke" {
+			change, done, unchanged = svc.RevokeRole, "Revoked %s from user %d\n", "User %d does not have %s\n"
+		}
+		for _, id := range ids {
+			changed, err := change(ctx, args[1], id)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				fmt.Fprintf(r.out, "User %d not found\n", id)
+			case err != nil:
+				return err
+			case changed:
+				fmt.Fprintf(r.out, done, args[1], id)
+			default:
+				fmt.Fprintf(r.out, unchanged, id, args[1])
+			}
+		}
+		return nil
+
+	case args[0] == "show" && len(args) == 2:
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid id: %w", err)
+		}
+		roles, err := svc.UserRoles(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			fmt.Fprintln(r.out, "User not found")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		names := make([]string, len(roles))
+		for i, role := range roles {
+			names[i] = role.Name
+		}
+		r.printMemberships(id, "roles", names)
+		return nil
+
+	default:
+		return usageError("roles")
+	}
+}
+
+func (r *Runner) handleGroups(ctx context.Context, args []string, opts options) error {
+	svc := r.status.Access
+	if svc == nil {
+		return errors.New("groups need a database connection")
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		groups, err := svc.Groups(ctx)
+		if err != nil {
+			return err
+		}
+		if len(groups) == 0 {
+			fmt.Fprintln(r.out, "No groups, add one with: groups add <name> [<description>]")
+			return nil
+		}
+		w := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tDESCRIPTION")
+		for _, group := range groups {
+			fmt.Fprintf(w, "%s\t%s\n", group.Name, group.Description)
+		}
+		return w.Flush()
+
+	case args[0] == "add" && (len(args) == 2 || len(args) == 3):
+		description := ""
+		if len(args) == 3 {
+			description = args[2]
+		}
+		if err := svc.AddGroup(ctx, args[1], description); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "Added group %s\n", args[1])
+		return nil
+
+	case args[0] == "delete" && len(args) == 2:
+		if ok, err := r.confirmChange(ctx, opts, fmt.Sprintf("Delete group %s? Its members stay. [y/N]: ", args[1])); !ok || err != nil {
+			return err
+		}
+		if err := svc.DeleteGroup(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "Deleted group %s\n", args[1])
+		return nil
+
+	case (args[0] == "assign" || args[0] == "revoke") && len(args) >= 3:
+		ids, err := parseIDs(args[2:])
+		if err != nil {
+			return err
+		}
+		change, done, unchanged := svc.AddMember, "Added user %d to %s\n", "User %d is in %s already\n"
+		if args[0] == "revoke" {
+			change, done, unchanged = svc.RemoveMember, "Removed user %d from %s\n", "User %d is not in %s\n"
+		}
+		for _, id := range ids {
+			changed, err := change(ctx, args[1], id)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				fmt.Fprintf(r.out, "User %d not found\n", id)
+			case err != nil:
+				return err
+			case changed:
+				fmt.Fprintf(r.out, done, id, args[1])
+			default:
+				fmt.Fprintf(r.out, unchanged, id, args[1])
+			}
+		}
+		return nil
+
+	case args[0] == "show" && len(args) == 2:
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid id: %w", err)
+		}
+		groups, err := svc.UserGroups(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			fmt.Fprintln(r.out, "User not found")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		names := make([]string, len(groups))
+		for i, group := range groups {
+			names[i] = group.Name
+		}
+		r.printMemberships(id, "groups", names)
+		return nil
+
+	default:
+		return usageError("groups")
+	}
+}
+
+// printMemberships prints the names of the roles or groups of a user.
+func (r *Runner) printMemberships(id int, what string, names []string) {
+	if len(names) == 0 {
+		fmt.Fprintf(r.out, "User %d has no %s\n", id, what)
+		return
+	}
+	fmt.Fprintf(r.out, "User %d has %s: %s\n", id, what, strings.Join(names, ", "))
+}
+
+// parseIDs parses user IDs, dropping repeated ones.
+func parseIDs(args []string) ([]int, error) {
+	var ids []int
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id: %w", err)
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// errUnclosedQuote means the command continues on the next line, which
+	// becomes part of the quoted argument.
+	errUnclosedQuote = errors.New("unclosed quote")
+	// errLineContinues means the line ended with a backslash and the command
+	// continues on the next line.
+	errLineContinues = errors.New("line continues")
+)
+
+// splitArgs splits a command line into arguments separated by whitespace.
+// Single quotes keep everything literally, double quotes keep everything but
+// \" and \\, and outside of quotes a backslash escapes the next character.
+func splitArgs(line string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, c := range line {
+		switch {
+		case escaped:
+			if quote == '"' && c != '"' && c != '\\' {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(c)
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '\\':
+			escaped, inArg = true, true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inArg = c, true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(c)
+			inArg = true
+		}
+	}
+	if escaped {
+		return args, errLineContinues
+	}
+	if quote != 0 {
+		return args, errUnclosedQuote
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,32 @@
+package runner
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []string
+		err      error
+	}{
+		{line: "  2   7 ", expected: []string{"2", "7"}},
+		{line: `3 "Ann Lee" ann@example.com 'pa ss'`, expected: []string{"3", "Ann Lee", "ann@example.com", "pa ss"}},
+		{line: `3 Bob\ Smith "say \"hi\"" 'a\b'`, expected: []string{"3", "Bob Smith", `say "hi"`, `a\b`}},
+		{line: `3 "" x`, expected: []string{"3", "", "x"}},
+		{line: `3 "a\nb"`, expected: []string{"3", `a\nb`}},
+		{line: `3 "Ann`, err: errUnclosedQuote},
+		{line: `3 Ann \`, err: errLineContinues},
+	}
+	for _, tt := range tests {
+		args, err := splitArgs(tt.line)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("splitArgs(%q): got error '%v', expected '%v'", tt.line, err, tt.err)
+		}
+		if tt.err == nil && !slices.Equal(args, tt.expected) {
+			t.Fatalf("splitArgs(%q) = %q, expected %q", tt.line, args, tt.expected)
+		}
+	}
+}
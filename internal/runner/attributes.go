@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"maps"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+)
+
+func (r *Runner) handleAttributes(ctx context.Context, args []string) error {
+	svc := r.status.Profile
+	if svc == nil {
+		return errors.New("attributes need a database connection")
+	}
+	if len(args) < 2 {
+		return usageError("attributes")
+	}
+	id, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+	switch {
+	case args[0] == "show" && len(args) == 2:
+		attributes, err := svc.Attributes(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			fmt.Fprintln(r.out, "User not found")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		r.printAttributes(id, attributes)
+		return nil
+
+	case args[0] == "set" && len(args) == 4:
+		err := svc.Set(ctx, id, args[2], models.ParseAttributeValue(args[3]))
+		if errors.Is(err, sql.ErrNoRows) {
+			fmt.Fprintln(r.out, "User not found")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "Set %s of user %d\n", args[2], id)
+		return nil
+
+	case args[0] == "unset" && len(args) == 3:
+		unset, err := svc.Unset(ctx, id, args[2])
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			fmt.Fprintln(r.out, "User not found")
+		case err != nil:
+			return err
+		case unset:
+			fmt.Fprintf(r.out, "Unset %s of user %d\n", args[2], id)
+		default:
+			fmt.Fprintf(r.out, "User %d has no %s\n", id, args[2])
+		}
+		return nil
+
+	default:
+		return usageError("attributes")
+	}
+}
+
+// printAttributes prints the attributes of a user sorted by name, with their
+// values as JSON.
+func (r *Runner) printAttributes(id int, attributes models.Attributes) {
+	if len(attributes) == 0 {
+		fmt.Fprintf(r.out, "User %d has no attributes\n", id)
+		return
+	}
+	w := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVALUE")
+	for _, name := range slices.Sorted(maps.Keys(attributes)) {
+		value, err := json.Marshal(attributes[name])
+		if err != nil {
+			value = fmt.Appendf(nil, "%v", attributes[name])
+		}
+		fmt.Fprintf(w, "%s\t%s\n", name, value)
+	}
+	w.Flush()
+}
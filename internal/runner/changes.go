@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// change runs fn, which makes the changes of one command. The changes share
+// a batch in the audit log, so undo reverts them together. A dry run makes
+// them in a transaction on the database connection that is rolled back.
+func (r *Runner) change(ctx context.Context, opts options, fn func(ctx context.Context) error) error {
+	ctx = middleware.WithBatch(ctx, rand.Text())
+	if !opts.dryRun {
+		return fn(ctx)
+	}
+	tx, err := r.status.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return fn(repository.WithTx(ctx, tx))
+}
+
+// confirmChange asks whether to go on with a change, unless the options say
+// so already or nothing is going to be changed.
+func (r *Runner) confirmChange(ctx context.Context, opts options, prompt string) (bool, error) {
+	if opts.yes || opts.dryRun {
+		return true, nil
+	}
+	return r.confirm(ctx, prompt)
+}
+
+// confirm asks a yes or no question, anything but yes cancels.
+func (r *Runner) confirm(ctx context.Context, prompt string) (bool, error) {
+	answer, err := r.readAnswer(ctx, r.readLine, prompt)
+	if err != nil {
+		return false, err
+	}
+	if answer := strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		fmt.Fprintln(r.out, "Canceled")
+		return false, nil
+	}
+	return true, nil
+}
+
+// reportRows prints how many rows were changed, verb is e.g. "deleted".
+func (r *Runner) reportRows(opts options, verb string, rows int64) {
+	if opts.dryRun {
+		fmt.Fprintf(r.out, "Dry run: %d row(s) would be %s, nothing was changed\n", rows, verb)
+		return
+	}
+	fmt.Fprintf(r.out, "%s%s %d row(s)\n", strings.ToUpper(verb[:1]), verb[1:], rows)
+}
+
+// formatUser shows a user on one line without the password, the attributes
+// only if it has any.
+func formatUser(user models.User) string {
+	line := fmt.Sprintf("%d  %s  %s  %s", user.ID, user.Name, user.Email, user.Status)
+	if len(user.Attributes) > 0 {
+		line += "  " + user.Attributes.String()
+	}
+	return line
+}
+
+// samePassword reports whether password is the one current has, which then
+// needs no new hash. Users of a remote server come without their hash.
+func samePassword(current models.User, password string) bool {
+	return password != "" && current.PasswordHash != "" &&
+		bcrypt.CompareHashAndPassword([]byte(current.PasswordHash), []byte(password)) == nil
+}
+
+// validateChanges validates the fields that differ between current and
+// user. Unchanged fields are kept as they are, the password is never read
+// back, so it is empty unless a new one is set.
+func validateChanges(current, user models.User) error {
+	var verr models.ValidationError
+	if !errors.As(user.Validate(), &verr) {
+		return nil
+	}
+	values := map[string][2]string{
+		"name":     {current.Name, user.Name},
+		"email":    {current.Email, user.Email},
+		"password": {current.Password, user.Password},
+	}
+	var errs models.ValidationError
+	for _, e := range verr {
+		if v, ok := values[e.Field]; !ok || v[0] != v[1] {
+			errs = append(errs, e)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// describeChanges lists the fields that differ between current and user.
+func describeChanges(current, user models.User) []string {
+	var changes []string
+	if current.Name != user.Name {
+		changes = append(changes, fmt.Sprintf("name:     %s -> %s", current.Name, user.Name))
+	}
+	if current.Email != user.Email {
+		changes = append(changes, fmt.Sprintf("email:    %s -> %s", current.Email, user.Email))
+	}
+	if user.Password != "" {
+		changes = append(changes, "password: changed")
+	}
+	return changes
+}
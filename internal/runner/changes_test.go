@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/db"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunner_DryRunChangesNothing(t *testing.T) {
+	ctx := context.Background()
+	cfg := db.DefaultConfig()
+	cfg.Driver = db.DriverSQLite
+	cfg.DbName = filepath.Join(t.TempDir(), "test.db")
+	con, err := db.Connect(ctx, cfg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a sqlite database", err)
+	}
+	defer con.Close()
+	if err := db.Migrate(con, db.DriverSQLite); err != nil {
+		t.Fatalf("an error '%s' was not expected when migrating", err)
+	}
+
+	logs := imp.NewSqliteRepoLog(con)
+	repo := middleware.NewLoggerMiddleware(logs, middleware.NewPasswordMiddleware(middleware.NewTransactionalMiddleware(con, imp.NewSqliteRepoUser(con))))
+	if _, err := repo.InsertUser(ctx, models.User{Name: "Ann", Email: "ann@example.com", Password: "secret"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting user", err)
+	}
+
+	in := strings.NewReader("4 --dry-run 1\n" +
+		"5 --dry-run 1 Annie ann@example.com secret\n" +
+		"3 --dry-run Bob bob@example.com pw\n" +
+		"undo --dry-run\n")
+	var out bytes.Buffer
+	r := NewRunner(repo, logs, Status{DB: con, Driver: db.DriverSQLite}, in, &out)
+	if err := r.Run(ctx); err != nil {
+		t.Fatalf("an error '%s' was not expected when running the session", err)
+	}
+
+	for _, want := range []string{
+		"Dry run: 1 row(s) would be deleted, nothing was changed",
+		"Dry run: 1 row(s) would be updated, nothing was changed",
+		"Dry run: 1 row(s) would be inserted, nothing was changed",
+		"delete user 1, which was inserted",
+		"Dry run: 1 row(s) would be reverted, nothing was changed",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in the output:\n%s", want, out.String())
+		}
+	}
+	users, err := repo.GetUsers(ctx, models.UserFilter{})
+	if err != nil || len(users) != 1 || users[0].Name != "Ann" {
+		t.Fatalf("got %+v and error '%v', expected Ann unchanged", users, err)
+	}
+	// The audit records of the changes are rolled back with them.
+	entries, err := logs.GetLogs(ctx)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting logs", err)
+	}
+	records := 0
+	for _, entry := range entries {
+		if _, ok := entry.Audit(); ok {
+			records++
+		}
+	}
+	if records != 1 {
+		t.Fatalf("got %d audit records, expected only the one of the first insert", records)
+	}
+}
+
+func TestValidateChanges_SkipsUnchangedFields(t *testing.T) {
+	// A remote server does not return passwords.
+	current := models.User{ID: 1, Name: "Ann", Email: "ann@example.com"}
+
+	tests := []struct {
+		name   string
+		user   models.User
+		fields []string
+	}{
+		{"password kept", models.User{Name: "Annie", Email: "ann@example.com"}, nil},
+		{"invalid change", models.User{Name: "Ann", Email: "nope"}, []string{"email"}},
+		{"cleared name", models.User{Name: "", Email: "ann@example.com", Password: "pw"}, []string{"name"}},
+	}
+	for _, tt := range tests {
+		err := validateChanges(current, tt.user)
+		var fields []string
+		if verr, ok := err.(models.ValidationError); ok {
+			for _, e := range verr {
+				fields = append(fields, e.Field)
+			}
+		}
+		if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+			t.Fatalf("%s: got invalid fields %v, expected %v", tt.name, fields, tt.fields)
+		}
+	}
+}
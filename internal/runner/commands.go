@@ -0,0 +1,334 @@
+package runner
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type command struct {
+	// names are the ways to call the command, the first one is shown in the menu.
+	names   []string
+	usage   string
+	summary string
+	help    string
+	// userIDArg is the argument position completed with user IDs, 0 if none.
+	userIDArg int
+	// variadic repeats the last argument, e.g. several IDs.
+	variadic bool
+	// options are the --flags the command accepts anywhere after its name.
+	options []string
+	// passwordArg is the argument position of a password, 0 if none. Lines
+	// with a password are not saved in the history file.
+	passwordArg int
+}
+
+var commands = []command{
+	{
+		names:   []string{"1"},
+		summary: "Get all users",
+		help:    "Prints every user, one per line.",
+	},
+	{
+		names:     []string{"2"},
+		usage:     "<id>",
+		summary:   "Get user by ID",
+		help:      "Prints the user with the given ID.",
+		userIDArg: 1,
+	},
+	{
+		names:   []string{"3"},
+		usage:   "[<name> <email> <password>]",
+		summary: "Insert user",
+		help: "Adds a new user. Without arguments asks for each field, reading the\n" +
+			"password without echo. Values with spaces are quoted:\n" +
+			`  3 "Ann Lee" ann@example.com 'pa ss'` + "\n" +
+			"With --dry-run the insert is rolled back and only reported.",
+		passwordArg: 3,
+		options:     []string{optDryRun},
+	},
+	{
+		names:   []string{"4"},
+		usage:   "<id>...",
+		summary: "Delete users by ID",
+		help: "Deletes the users with the given IDs after showing them and asking\n" +
+			"for confirmation, which --yes skips. With --dry-run the deletes are\n" +
+			"rolled back and only reported.",
+		userIDArg: 1,
+		variadic:  true,
+		options:   []string{optYes, optDryRun},
+	},
+	{
+		names:   []string{"5"},
+		usage:   "<id> [<name> <email> <password>]",
+		summary: "Update user by ID",
+		help: "Replaces name, email and password of the user with the given ID.\n" +
+			"With only the ID asks for each field, offering the current values.\n" +
+			"The changes are shown and confirmed before saving, --yes skips the\n" +
+			"confirmation and --dry-run rolls the update back.",
+		userIDArg:   1,
+		passwordArg: 4,
+		options:     []string{optYes, optDryRun},
+	},
+	{
+		names:   []string{"undo"},
+		summary: "Undo the last change",
+		help: "Reverts the last insert, update or delete found in the audit log,\n" +
+			"all of it if one command changed several users. A deleted user\n" +
+			"comes back under a new ID. Running undo again redoes the change.\n" +
+			"Takes --yes and --dry-run like the commands it reverts.",
+		options: []string{optYes, optDryRun},
+	},
+	{
+		names:   []string{"s", "status"},
+		summary: "Show database status",
+		help:    "Shows the circuit breaker and cache state.",
+	},
+	{
+		names:   []string{"db"},
+		usage:   "health",
+		summary: "Check database connectivity",
+		help:    "Pings the database and shows its version and connection pool statistics.",
+	},
+	{
+		names:   []string{"users"},
+		usage:   "<action> [<argument>...]",
+		summary: "Manage user status, logins and tokens",
+		help: "Works on the status and the logins of the managed users:\n" +
+			"  users list [--status <status>] [--role <role>] [--group <group>]\n" +
+			"             [--attr <name>=<value>]\n" +
+			"                                     show the users, only those matching every filter given\n" +
+			"  users activate <id> [<reason>]     activate a pending, suspended or locked user\n" +
+			"  users suspend <id> <reason>        suspend an active user\n" +
+			"  users lock <id> <reason>           lock an active user, e.g. after a leaked password\n" +
+			"  users delete <id> <reason>         mark a user deleted for good, keeping the row\n" +
+			"  users verify <email>               check the password of a user, read without echo\n" +
+			"  users unlock <email>               lift the lockout after too many failed logins\n" +
+			"  users revoke <session token>       end a session before its token expires\n" +
+			"  users send-verification <id>       mail an email verification token to a user\n" +
+			"  users verify-email <token>         mark the email a token was sent to as verified\n" +
+			"  users send-reset <id>              mail a password reset token to a user\n" +
+			"  users reset-password <token>       set a new password, read without echo\n" +
+			"New users are pending until they verify their email or are activated,\n" +
+			"only active users may log in. Status changes and their reasons and\n" +
+			"every login attempt are recorded in the audit log. Tokens are\n" +
+			"single-use and expire, sending a new one replaces the previous one.",
+		passwordArg: 2,
+		options:     []string{optStatus, optRole, optGroup, optAttr},
+	},
+	{
+		names:   []string{"operators"},
+		usage:   "[<action> ...]",
+		summary: "Manage operators",
+		help: "Manages who may work on the users, only admins may change others:\n" +
+			"  operators list                     show every operator\n" +
+			"  operators add <name> <role>        add a viewer, editor or admin\n" +
+			"  operators role <name> <role>       change the role of an operator\n" +
+			"  operators delete <name>            delete an operator and its API tokens\n" +
+			"  operators password <name>          change a password\n" +
+			"  operators token <name> [<label>]   create an API token for the servers\n" +
+			"Passwords are read without echo. Tokens are shown once. --yes skips\n" +
+			"the confirmation of delete.",
+		options: []string{optYes},
+	},
+	{
+		names:   []string{"roles"},
+		usage:   "[<action> ...]",
+		summary: "Manage the roles of users",
+		help: "Manages the roles the applications built on the users check, unrelated\n" +
+			"to the roles of the operators:\n" +
+			"  roles list                         show every role\n" +
+			"  roles add <name> [<description>]   add a role\n" +
+			"  roles delete <name>                delete a role, revoking it from everyone\n" +
+			"  roles assign <name> <id>...        give a role to users\n" +
+			"  roles revoke <name> <id>...        take a role from users\n" +
+			"  roles show <id>                    show the roles of a user\n" +
+			"users list --role <name> shows who has a role. Changes are recorded in\n" +
+			"the audit log, --yes skips the confirmation of delete.",
+		options: []string{optYes},
+	},
+	{
+		names:   []string{"groups"},
+		usage:   "[<action> ...]",
+		summary: "Manage groups of users",
+		help: "Manages named sets of users, e.g. teams:\n" +
+			"  groups list                        show every group\n" +
+			"  groups add <name> [<description>]  add a group\n" +
+			"  groups delete <name>               delete a group, its members stay\n" +
+			"  groups assign <name> <id>...       add users to a group\n" +
+			"  groups revoke <name> <id>...       remove users from a group\n" +
+			"  groups show <id>                   show the groups of a user\n" +
+			"users list --group <name> shows the members of a group. Changes are\n" +
+			"recorded in the audit log, --yes skips the confirmation of delete.",
+		options: []string{optYes},
+	},
+	{
+		names:   []string{"attributes", "attrs"},
+		usage:   "[<action> ...]",
+		summary: "Manage custom attributes of users",
+		help: "Manages custom fields of the users, e.g. phone, department or locale:\n" +
+			"  attributes show <id>               show the attributes of a user\n" +
+			"  attributes set <id> <name> <value> set an attribute\n" +
+			"  attributes unset <id> <name>       remove an attribute\n" +
+			"Values are read as JSON when they are, so 30, true and [\"a\"] keep their\n" +
+			"type, anything else is a string. users list --attr <name>=<value> shows\n" +
+			"the users with that value. With -attributes-schema the attributes must\n" +
+			"match a JSON Schema. Changes are recorded in the audit log.",
+	},
+	{
+		names:   []string{"tenants"},
+		usage:   "[<action> ...]",
+		summary: "Manage tenants",
+		help: "Manages the customers whose users and logs are kept apart, only admins\n" +
+			"may see and add them:\n" +
+			"  tenants list                       show every tenant, * marks this one\n" +
+			"  tenants add <name>                 add a tenant without users\n" +
+			"A session works on the users and logs of the tenant given with -tenant,\n" +
+			"or of the default tenant. Additions are recorded in the audit log.",
+	},
+	{
+		names:   []string{"help"},
+		usage:   "[command]",
+		summary: "Show help for a command",
+		help:    "Without a command shows the menu.",
+	},
+	{
+		names:   []string{"q", "quit", "exit"},
+		summary: "Quit",
+		help:    "Ends the session, as does Ctrl-D on an empty line.",
+	},
+}
+
+const (
+	optYes    = "--yes"
+	optDryRun = "--dry-run"
+	// optStatus, optRole, optGroup and optAttr take a value, given as
+	// --status <value> or --status=<value>.
+	optStatus = "--status"
+	optRole   = "--role"
+	optGroup  = "--group"
+	optAttr   = "--attr"
+)
+
+// options are the flags given to a command.
+type options struct {
+	// yes skips the confirmation of a change.
+	yes bool
+	// dryRun rolls the change back after reporting it.
+	dryRun bool
+	// status, role and group keep the users with this status, this role or
+	// in this group in a listing.
+	status string
+	role   string
+	group  string
+	// attr keeps the users with an attribute, given as <name>=<value>.
+	attr string
+}
+
+// value returns the field of the option taking a value, nil for the other
+// options.
+func (o *options) value(name string) *string {
+	switch name {
+	case optStatus:
+		return &o.status
+	case optRole:
+		return &o.role
+	case optGroup:
+		return &o.group
+	case optAttr:
+		return &o.attr
+	}
+	return nil
+}
+
+// parseOptions removes the options of the command named by args[0] from
+// args. Arguments after "--" are never options, so values starting with
+// dashes can be given. The remaining arguments are returned even on error.
+func parseOptions(args []string) ([]string, options, error) {
+	var opts options
+	c, ok := findCommand(args[0])
+	if !ok || len(c.options) == 0 {
+		return args, opts, nil
+	}
+	var err error
+	rest := []string{args[0]}
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch {
+		case arg == "--":
+			return append(rest, args[i+1:]...), opts, err
+		case arg == optYes && slices.Contains(c.options, arg):
+			opts.yes = true
+		case arg == optDryRun && slices.Contains(c.options, arg):
+			opts.dryRun = true
+		case opts.value(name) != nil && slices.Contains(c.options, name):
+			if !hasValue && i+1 < len(args) {
+				i++
+				value, hasValue = args[i], true
+			}
+			if !hasValue && err == nil {
+				err = fmt.Errorf("%s needs a value", name)
+			}
+			*opts.value(name) = value
+		case strings.HasPrefix(arg, "--"):
+			if err == nil {
+				err = fmt.Errorf("unknown option %s, %s takes %s", arg, c.names[0], strings.Join(c.options, ", "))
+			}
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, opts, err
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if slices.Contains(c.names, name) {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+func (c command) synopsis() string {
+	return strings.TrimSpace(c.names[0] + " " + c.usage)
+}
+
+func usageError(name string) error {
+	c, _ := findCommand(name)
+	return fmt.Errorf("usage: %s", c.synopsis())
+}
+
+func (r *Runner) printMenu() {
+	width := 0
+	for _, c := range commands {
+		width = max(width, len(c.synopsis()))
+	}
+	fmt.Fprintln(r.out, "\nAvailable operations:")
+	for _, c := range commands {
+		fmt.Fprintf(r.out, "%-*s  - %s\n", width, c.synopsis(), c.summary)
+	}
+}
+
+func (r *Runner) handleHelp(args []string) error {
+	if len(args) == 0 {
+		r.printMenu()
+		fmt.Fprintln(r.out, "\nQuote values with spaces, end a line with \\ to continue on the next one.")
+		fmt.Fprintln(r.out, "Arguments after -- are never read as options.")
+		return nil
+	}
+	c, ok := findCommand(args[0])
+	if !ok {
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+	fmt.Fprintf(r.out, "Usage: %s\n", c.synopsis())
+	if len(c.names) > 1 {
+		fmt.Fprintf(r.out, "Aliases: %s\n", strings.Join(c.names[1:], ", "))
+	}
+	fmt.Fprintln(r.out, c.help)
+	if len(c.options) > 0 {
+		fmt.Fprintf(r.out, "Options: %s\n", strings.Join(c.options, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package runner
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// errPromptCanceled ends a guided prompt when the input ends, e.g. on Ctrl-D.
+var errPromptCanceled = errors.New("canceled")
+
+// guidedInsert asks for the fields of a new user one by one.
+func (r *Runner) guidedInsert(ctx context.Context, opts options) error {
+	var user models.User
+	if err := r.askUser(ctx, &user, false); err != nil {
+		return err
+	}
+	if ok, err := r.confirmUser(ctx, user); !ok || err != nil {
+		return err
+	}
+	return r.handleInsert(ctx, user, opts)
+}
+
+// guidedUpdate asks for the new fields of a user, offering the current
+// values as defaults.
+func (r *Runner) guidedUpdate(ctx context.Context, idStr string, opts options) error {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+	user, err := r.repo.GetUserById(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			fmt.Fprintln(r.out, "User not found")
+			return nil
+		}
+		return err
+	}
+
+	fmt.Fprintln(r.out, "Press enter to keep the current value.")
+	current := user
+	if err := r.askUser(ctx, &user, true); err != nil {
+		return err
+	}
+	return r.saveUpdate(ctx, id, current, user, opts)
+}
+
+// askUser fills user field by field, repeating a question until the answer
+// is valid. Existing values are defaults when keep is set.
+func (r *Runner) askUser(ctx context.Context, user *models.User, keep bool) error {
+	var err error
+	user.Name, err = r.askField(ctx, "Name", "name", user.Name, keep)
+	if err != nil {
+		return err
+	}
+	user.Email, err = r.askField(ctx, "Email", "email", user.Email, keep)
+	if err != nil {
+		return err
+	}
+	user.Password, err = r.askPassword(ctx, user.Password, keep)
+	return err
+}
+
+func (r *Runner) askField(ctx context.Context, label, field, current string, keep bool) (string, error) {
+	prompt := label + ": "
+	if keep {
+		prompt = fmt.Sprintf("%s [%s]: ", label, current)
+	}
+	for {
+		value, err := r.readAnswer(ctx, r.readLine, prompt)
+		if err != nil {
+			return "", err
+		}
+		value = strings.TrimSpace(value)
+		if value == "" && keep {
+			return current, nil
+		}
+		if msg := fieldError(field, value); msg != "" {
+			fmt.Fprintf(r.out, "%s %s\n", label, msg)
+			continue
+		}
+		return value, nil
+	}
+}
+
+// askPassword reads the password twice without echo.
+func (r *Runner) askPassword(ctx context.Context, current string, keep bool) (string, error) {
+	prompt := "Password: "
+	if keep {
+		prompt = "Password [unchanged]: "
+	}
+	for {
+		password, err := r.readAnswer(ctx, r.readPassword, prompt)
+		if err != nil {
+			return "", err
+		}
+		if password == "" && keep {
+			return current, nil
+		}
+		if msg := fieldError("password", password); msg != "" {
+			fmt.Fprintf(r.out, "Password %s\n", msg)
+			continue
+		}
+		repeated, err := r.readAnswer(ctx, r.readPassword, "Repeat password: ")
+		if err != nil {
+			return "", err
+		}
+		if repeated != password {
+			fmt.Fprintln(r.out, "Passwords do not match")
+			continue
+		}
+		return password, nil
+	}
+}
+
+// confirmUser shows the new user and asks whether to save it.
+func (r *Runner) confirmUser(ctx context.Context, user models.User) (bool, error) {
+	fmt.Fprintf(r.out, "\nName:     %s\nEmail:    %s\nPassword: %s\n", user.Name, user.Email, strings.Repeat("*", 8))
+	return r.confirm(ctx, "Save? [y/N]: ")
+}
+
+// readAnswer reads an answer with read, the end of the input cancels the
+// prompt. Answers are not trimmed, spaces may be part of a password.
+func (r *Runner) readAnswer(ctx context.Context, read func(context.Context, string) (string, error), prompt string) (string, error) {
+	answer, err := read(ctx, prompt)
+	if errors.Is(err, io.EOF) {
+		fmt.Fprintln(r.out)
+		return "", errPromptCanceled
+	}
+	return answer, err
+}
+
+// fieldError validates a single field of a user, empty means valid.
+func fieldError(field, value string) string {
+	var user models.User
+	switch field {
+	case "name":
+		user.Name = value
+	case "email":
+		user.Email = value
+	case "password":
+		user.Password = value
+	}
+	var verr models.ValidationError
+	if errors.As(user.Validate(), &verr) {
+		return verr.Field(field)
+	}
+	return ""
+}
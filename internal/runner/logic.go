@@ -6,183 +6,484 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/access"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/account"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/db"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/login"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/profile"
 	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
-	"log"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/tenant"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/tracing"
+	"io"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-func Logic(ctx context.Context, repo repository.IRepositoryUser) {
-	reader := bufio.NewReader(os.Stdin)
+// Status holds the components whose state is shown by the status command.
+type Status struct {
+	Breakers []*middleware.CircuitBreaker
+	Caches   []*middleware.UserCache
+	DB       *sql.DB
+	Driver   string
+	// Operators manages the operators of the database, nil when there is
+	// no database connection.
+	Operators *auth.Service
+	// Login checks the passwords of the users, nil when there is no
+	// database connection.
+	Login *login.Service
+	// Accounts mails email verification and password reset tokens to the
+	// users, nil when there is no database connection.
+	Accounts *account.Service
+	// Access manages the roles and groups of the users, nil when there is
+	// no database connection.
+	Access *access.Service
+	// Profile manages the attributes of the users, nil when there is no
+	// database connection.
+	Profile *profile.Service
+	// Tenants manages the tenants, nil when there is no database
+	// connection.
+	Tenants *tenant.Service
+}
+
+// errQuit is returned by the quit command to end the session.
+var errQuit = errors.New("quit")
+
+// Runner runs the interactive command loop, reading commands from its input
+// and writing results to out.
+type Runner struct {
+	repo repository.IRepositoryUser
+	// logs is the audit log read by undo.
+	logs   repository.IRepositoryLog
+	status Status
+	input  lineReader
+	out    io.Writer
+	// interactive shows the menu once instead of before every command.
+	interactive bool
+
+	completionMu    sync.Mutex
+	completionAt    time.Time
+	completionCache []userRef
+	// completionCtx is the context of the running session, completion reads
+	// users on behalf of its operator.
+	completionCtx context.Context
+}
+
+// NewRunner reads commands line by line from in, e.g. a pipe or a script.
+func NewRunner(repo repository.IRepositoryUser, logs repository.IRepositoryLog, status Status, in io.Reader, out io.Writer) *Runner {
+	return &Runner{
+		repo:   repo,
+		logs:   logs,
+		status: status,
+		input:  &plainReader{scanner: bufio.NewScanner(in), out: out},
+		out:    out,
+
+		completionCtx: context.Background(),
+	}
+}
+
+// NewTerminalRunner runs on the terminal behind in and out with line editing,
+// tab completion and the history kept in historyFile, empty keeps it in memory.
+func NewTerminalRunner(repo repository.IRepositoryUser, logs repository.IRepositoryLog, status Status, in, out *os.File, historyFile string) (*Runner, error) {
+	history, err := loadHistory(historyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load history: %w", err)
+	}
+	r := &Runner{repo: repo, logs: logs, status: status, out: out, interactive: true, completionCtx: context.Background()}
+	r.input = newTerminalReader(in, out, history, r.complete)
+	return r, nil
+}
 
+// Run executes commands until the input ends, the quit command is given or
+// ctx is canceled, in which case ctx.Err() is returned.
+func (r *Runner) Run(ctx context.Context) error {
+	defer r.input.Close()
+	r.completionMu.Lock()
+	r.completionCtx = ctx
+	r.completionMu.Unlock()
+
+	if r.interactive {
+		r.printMenu()
+		fmt.Fprintln(r.out, "\nType help <command> for details, tab completes commands and user IDs.")
+	}
 	for {
-		select {
-		case <-ctx.Done():
-			fmt.Println("Shutting down...")
-			return
-		default:
-			printMenu()
-			fmt.Print("Enter command: ")
+		if !r.interactive {
+			r.printMenu()
+		}
+		cmd, err := r.readCommand(ctx)
+		switch {
+		case ctx.Err() != nil:
+			fmt.Fprintln(r.out, "\nShutting down...")
+			return ctx.Err()
+		case errors.Is(err, io.EOF):
+			fmt.Fprintln(r.out)
+			return nil
+		case err != nil:
+			return fmt.Errorf("error reading input: %w", err)
+		case len(cmd) == 0:
+			continue
+		}
 
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				log.Printf("error reading input: %v\n", err)
-				continue
+		if err := r.dispatch(ctx, cmd); err != nil {
+			if errors.Is(err, errQuit) {
+				return nil
 			}
+			r.printError(err)
+		}
+	}
+}
 
-			cmd := strings.Fields(strings.TrimSpace(line))
-			if len(cmd) == 0 {
-				continue
-			}
-			select {
-			case <-ctx.Done():
-				fmt.Println("Shutting down...")
-				return
-			default:
-				if err := handleCommand(repo, cmd); err != nil {
-					fmt.Printf("Error: %v\n\n", err)
-				}
-			}
+// readCommand reads one command, which continues over several lines while a
+// quote is open or a line ends with a backslash.
+func (r *Runner) readCommand(ctx context.Context) ([]string, error) {
+	var text string
+	linePrompt := commandPrompt
+	for {
+		line, err := r.readLine(ctx, linePrompt)
+		if err != nil {
+			return nil, err
 		}
+		text += line
+		args, err := splitArgs(text)
+		switch {
+		case errors.Is(err, errLineContinues):
+			text = strings.TrimSuffix(text, `\`)
+		case errors.Is(err, errUnclosedQuote):
+			text += "\n"
+		default:
+			return args, err
+		}
+		linePrompt = continuationPrompt
 	}
 }
 
-func printMenu() {
-	fmt.Println("\nAvailable operations:")
-	fmt.Println("1                            - Get all users")
-	fmt.Println("2 <id>                       - Get user by ID")
-	fmt.Println("3 <name> <email> <password>  - Insert user")
-	fmt.Println("4 <id>                       - Delete user by ID")
-	fmt.Println("5 <id> <name> <email> <pwd>  - Update user by ID")
-	fmt.Println("q                            - Quit")
+// readLine reads a line in the background so a canceled ctx ends the session
+// even while waiting for input.
+func (r *Runner) readLine(ctx context.Context, prompt string) (string, error) {
+	return r.read(ctx, func() (string, error) { return r.input.ReadLine(prompt) })
+}
+
+// readPassword reads a line without echoing it.
+func (r *Runner) readPassword(ctx context.Context, prompt string) (string, error) {
+	return r.read(ctx, func() (string, error) { return r.input.ReadPassword(prompt) })
 }
 
-func handleCommand(repo repository.IRepositoryUser, cmd []string) error {
+func (r *Runner) read(ctx context.Context, read func() (string, error)) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		line, err := read()
+		done <- result{line, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res := <-done:
+		return res.line, res.err
+	}
+}
+
+func (r *Runner) printError(err error) {
+	fmt.Fprintf(r.out, "Error: %v\n", err)
+	if errors.Is(err, middleware.ErrCircuitOpen) {
+		fmt.Fprintln(r.out, "The database looks unreachable, so commands fail immediately instead of waiting.")
+		fmt.Fprintln(r.out, "It will be tried again automatically; use 's' to see the current status.")
+	}
+	fmt.Fprintln(r.out)
+}
+
+func (r *Runner) dispatch(ctx context.Context, cmd []string) error {
+	ctx, span := tracing.Start(ctx, "runner.command", tracing.String("command", cmd[0]))
+	defer span.End()
+
+	err := r.handleCommand(ctx, cmd)
+	span.RecordError(err)
+	return err
+}
+
+func (r *Runner) handleCommand(ctx context.Context, cmd []string) error {
+	cmd, opts, err := parseOptions(cmd)
+	if err != nil {
+		return err
+	}
+	if opts.dryRun && r.status.DB == nil {
+		return errors.New("dry run needs a database connection")
+	}
 	switch cmd[0] {
 	case "q", "quit", "exit":
-		os.Exit(0)
+		return errQuit
 
 	case "1":
-		return handleGetAll(repo)
+		return r.handleGetAll(ctx)
 
 	case "2":
 		if len(cmd) < 2 {
-			return errors.New("usage: 2 <id>")
+			return usageError("2")
 		}
-		return handleGetByID(repo, cmd[1])
+		return r.handleGetByID(ctx, cmd[1])
 
 	case "3":
+		if len(cmd) == 1 {
+			return r.guidedInsert(ctx, opts)
+		}
 		if len(cmd) < 4 {
-			return errors.New("usage: 3 <name> <email> <password>")
+			return usageError("3")
 		}
-		return handleInsert(repo, cmd[1], cmd[2], cmd[3])
+		return r.handleInsert(ctx, models.User{Name: cmd[1], Email: cmd[2], Password: cmd[3]}, opts)
 
 	case "4":
 		if len(cmd) < 2 {
-			return errors.New("usage: 4 <id>")
+			return usageError("4")
 		}
-		return handleDelete(repo, cmd[1])
+		return r.handleDelete(ctx, cmd[1:], opts)
 
 	case "5":
+		if len(cmd) == 2 {
+			return r.guidedUpdate(ctx, cmd[1], opts)
+		}
 		if len(cmd) < 5 {
-			return errors.New("usage: 5 <id> <name> <email> <password>")
+			return usageError("5")
 		}
-		return handleUpdate(repo, cmd[1], cmd[2], cmd[3], cmd[4])
+		return r.handleUpdate(ctx, cmd[1], cmd[2], cmd[3], cmd[4], opts)
+
+	case "undo":
+		return r.handleUndo(ctx, opts)
+
+	case "s", "status":
+		return r.handleStatus()
+
+	case "help":
+		return r.handleHelp(cmd[1:])
+
+	case "users":
+		return r.handleUsers(ctx, cmd[1:], opts)
+	case "operators":
+		return r.handleOperators(ctx, cmd[1:], opts)
+	case "roles":
+		return r.handleRoles(ctx, cmd[1:], opts)
+	case "groups":
+		return r.handleGroups(ctx, cmd[1:], opts)
+	case "attributes", "attrs":
+		return r.handleAttributes(ctx, cmd[1:])
+	case "tenants":
+		return r.handleTenants(ctx, cmd[1:])
+
+	case "db":
+		if len(cmd) < 2 || cmd[1] != "health" {
+			return usageError("db")
+		}
+		return r.handleHealth(ctx)
 
 	default:
 		return fmt.Errorf("unknown command: %s", cmd[0])
 	}
-	return nil
 }
 
-func handleGetAll(repo repository.IRepositoryUser) error {
-	users, err := repo.GetUsers()
+func (r *Runner) handleGetAll(ctx context.Context) error {
+	users, err := r.repo.GetUsers(ctx, models.UserFilter{})
 	if err != nil {
 		return err
 	}
 	for _, u := range users {
-		fmt.Println(u)
+		fmt.Fprintln(r.out, formatUser(u))
 	}
 	return nil
 }
 
-func handleGetByID(repo repository.IRepositoryUser, idStr string) error {
+func (r *Runner) handleGetByID(ctx context.Context, idStr string) error {
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		return fmt.Errorf("invalid id: %w", err)
 	}
-	user, err := repo.GetUserById(id)
+	user, err := r.repo.GetUserById(ctx, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			fmt.Println("User not found")
+			fmt.Fprintln(r.out, "User not found")
 			return nil
 		}
 		return err
 	}
-	fmt.Println(user)
+	fmt.Fprintln(r.out, formatUser(user))
 	return nil
 }
 
-func handleInsert(repo repository.IRepositoryUser, name, email, password string) error {
-	user := models.User{
-		Name:         name,
-		Email:        email,
-		Password:     password,
-		RegisteredAt: time.Now(),
+func (r *Runner) handleInsert(ctx context.Context, user models.User, opts options) error {
+	if err := user.Validate(); err != nil {
+		return err
 	}
-	res, err := repo.InsertUser(user)
+	user.RegisteredAt = time.Now()
+	var rows int64
+	err := r.change(ctx, opts, func(ctx context.Context) error {
+		res, err := r.repo.InsertUser(ctx, user)
+		if err != nil {
+			return err
+		}
+		rows, _ = res.RowsAffected()
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	rows, _ := res.RowsAffected()
-	fmt.Printf("Inserted %d row(s)\n", rows)
+	r.reportRows(opts, "inserted", rows)
 	return nil
 }
 
-func handleDelete(repo repository.IRepositoryUser, idStr string) error {
-	id, err := strconv.Atoi(idStr)
+func (r *Runner) handleDelete(ctx context.Context, idArgs []string, opts options) error {
+	ids, err := parseIDs(idArgs)
 	if err != nil {
-		return fmt.Errorf("invalid id: %w", err)
+		return err
 	}
-	res, err := repo.DeleteUserById(id)
+	var users []models.User
+	for _, id := range ids {
+		user, err := r.repo.GetUserById(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				fmt.Fprintf(r.out, "User %d not found\n", id)
+				continue
+			}
+			return err
+		}
+		users = append(users, user)
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(r.out, "To be deleted:")
+	for _, user := range users {
+		fmt.Fprintf(r.out, "  %s\n", formatUser(user))
+	}
+	if ok, err := r.confirmChange(ctx, opts, fmt.Sprintf("Delete %d user(s)? [y/N]: ", len(users))); !ok || err != nil {
+		return err
+	}
+
+	var rows int64
+	err = r.change(ctx, opts, func(ctx context.Context) error {
+		for _, user := range users {
+			res, err := r.repo.DeleteUserById(ctx, user.ID)
+			if err != nil {
+				return err
+			}
+			n, _ := res.RowsAffected()
+			rows += n
+		}
+		return nil
+	})
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			fmt.Println("User not found")
-			return nil
+		if rows > 0 && !opts.dryRun {
+			fmt.Fprintf(r.out, "Deleted %d row(s) before the error\n", rows)
 		}
 		return err
 	}
-	rows, _ := res.RowsAffected()
-	fmt.Printf("Deleted %d row(s)\n", rows)
+	r.reportRows(opts, "deleted", rows)
 	return nil
 }
 
-func handleUpdate(repo repository.IRepositoryUser, idStr, name, email, password string) error {
+func (r *Runner) handleUpdate(ctx context.Context, idStr, name, email, password string, opts options) error {
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		return fmt.Errorf("invalid id: %w", err)
 	}
-	user, err := repo.GetUserById(id)
+	current, err := r.repo.GetUserById(ctx, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			fmt.Println("User not found")
+			fmt.Fprintln(r.out, "User not found")
 			return nil
 		}
 		return err
 	}
 
+	user := current
 	user.Name = name
 	user.Email = email
 	user.Password = password
+	return r.saveUpdate(ctx, id, current, user, opts)
+}
+
+// saveUpdate shows how user differs from current and saves it once confirmed.
+func (r *Runner) saveUpdate(ctx context.Context, id int, current, user models.User, opts options) error {
+	if samePassword(current, user.Password) {
+		user.Password = ""
+	}
+	if err := validateChanges(current, user); err != nil {
+		return err
+	}
+	changes := describeChanges(current, user)
+	if len(changes) == 0 {
+		fmt.Fprintln(r.out, "Nothing to change")
+		return nil
+	}
+	fmt.Fprintf(r.out, "Changes to user %d:\n", id)
+	for _, change := range changes {
+		fmt.Fprintf(r.out, "  %s\n", change)
+	}
+	if ok, err := r.confirmChange(ctx, opts, "Save? [y/N]: "); !ok || err != nil {
+		return err
+	}
 
-	res, err := repo.UpdateUserById(id, user)
+	var rows int64
+	err := r.change(ctx, opts, func(ctx context.Context) error {
+		res, err := r.repo.UpdateUserById(ctx, id, user)
+		if err != nil {
+			return err
+		}
+		rows, _ = res.RowsAffected()
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	rows, _ := res.RowsAffected()
-	fmt.Printf("Updated %d row(s)\n", rows)
+	r.reportRows(opts, "updated", rows)
+	return nil
+}
+
+func (r *Runner) handleStatus() error {
+	status := r.status
+	if len(status.Breakers) == 0 && len(status.Caches) == 0 {
+		fmt.Fprintln(r.out, "Nothing to report")
+		return nil
+	}
+	for _, b := range status.Breakers {
+		st := b.Status()
+		fmt.Fprintf(r.out, "%s: %s (consecutive failures: %d)\n", st.Name, st.State, st.Failures)
+		if st.State != middleware.BreakerClosed {
+			fmt.Fprintf(r.out, "  opened at %s, next attempt at %s\n",
+				st.OpenedAt.Format(time.TimeOnly), st.RetryAt.Format(time.TimeOnly))
+		}
+		if st.LastError != nil {
+			fmt.Fprintf(r.out, "  last error: %v\n", st.LastError)
+		}
+	}
+	for _, c := range status.Caches {
+		st := c.Stats()
+		fmt.Fprintf(r.out, "users cache: %d entries, %d hits, %d negative hits, %d misses, %d invalidations, %d evictions\n",
+			st.Size, st.Hits, st.NegativeHits, st.Misses, st.Invalidations, st.Evictions)
+	}
+	return nil
+}
+
+func (r *Runner) handleHealth(ctx context.Context) error {
+	con, driver := r.status.DB, r.status.Driver
+	if con == nil {
+		return errors.New("no database connection configured")
+	}
+	health, err := db.CheckHealth(ctx, con, driver)
+	if err != nil {
+		return fmt.Errorf("database is unhealthy: %w", err)
+	}
+	fmt.Fprintf(r.out, "Database is healthy (round trip %s)\n", health.Latency.Round(time.Microsecond))
+	fmt.Fprintf(r.out, "Server version: %s\n", health.ServerVersion)
+	st := health.Stats
+	fmt.Fprintf(r.out, "Pool: %d open (%d in use, %d idle), max open %d\n", st.OpenConnections, st.InUse, st.Idle, st.MaxOpenConnections)
+	fmt.Fprintf(r.out, "Waits: %d (%s total), closed: %d idle, %d idle time, %d lifetime\n",
+		st.WaitCount, st.WaitDuration, st.MaxIdleClosed, st.MaxIdleTimeClosed, st.MaxLifetimeClosed)
 	return nil
 }
@@ -0,0 +1,135 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/access"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/account"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/login"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/mail"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/profile"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/tenant"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// fixedClockRepo pins RegisteredAt so sessions print the same output on every run.
+type fixedClockRepo struct {
+	repository.IRepositoryUser
+}
+
+var registeredAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func (f fixedClockRepo) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	users, err := f.IRepositoryUser.GetUsers(ctx, filter)
+	for i := range users {
+		users[i].RegisteredAt = registeredAt
+	}
+	return users, err
+}
+
+func (f fixedClockRepo) GetUserById(ctx context.Context, id int) (models.User, error) {
+	user, err := f.IRepositoryUser.GetUserById(ctx, id)
+	if err == nil {
+		user.RegisteredAt = registeredAt
+	}
+	return user, err
+}
+
+// TestRunner_Sessions feeds every testdata/*.input file to a runner over
+// empty in-memory repositories with an audit log and compares the output with the .golden file.
+// Sessions run as the bootstrap admin of an empty operators repository, users
+// log in with the default login configuration, mails are discarded, the
+// attributes have a small schema and the tenant is the default one.
+// Run with -update to rewrite the golden files.
+func TestRunner_Sessions(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.input"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no sessions found in testdata: %v", err)
+	}
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".input")
+		t.Run(name, func(t *testing.T) {
+			in, err := os.Open(input)
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening the session", err)
+			}
+			defer in.Close()
+
+			var out bytes.Buffer
+			logs := imp.NewMemoryRepoLog()
+			users := imp.NewMemoryRepoUser()
+			repo := fixedClockRepo{middleware.NewLoggerMiddleware(logs, middleware.NewPasswordMiddleware(users))}
+			cfg := login.DefaultConfig()
+			cfg.Secret = []byte(strings.Repeat("s", 32))
+			sessions, err := login.NewService(users, logs, imp.NewMemoryRepoLogin(), cfg)
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when creating the login service", err)
+			}
+			schema, err := profile.ParseSchema([]byte(`{"properties": {"age": {"type": "integer", "minimum": 0}}}`))
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when parsing the attributes schema", err)
+			}
+			status := Status{
+				Operators: auth.NewService(imp.NewMemoryRepoOperator()),
+				Login:     sessions,
+				Accounts:  account.NewService(nil, repo, imp.NewMemoryRepoUserToken(), mail.NewWriterMailer(io.Discard, "noreply@example.com"), account.DefaultConfig()),
+				Access:    access.NewService(users, imp.NewMemoryRepoRole(users), imp.NewMemoryRepoGroup(users), logs),
+				Profile:   profile.NewService(imp.NewMemoryRepoUserAttribute(users), logs, schema),
+				Tenants:   tenant.NewService(imp.NewMemoryRepoTenant(), logs, models.Tenant{ID: models.DefaultTenantID, Name: models.DefaultTenant}),
+			}
+			r := NewRunner(repo, logs, status, in, &out)
+			if err := r.Run(auth.WithOperator(context.Background(), auth.Bootstrap)); err != nil {
+				t.Fatalf("an error '%s' was not expected when running the session", err)
+			}
+
+			golden := strings.TrimSuffix(input, ".input") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+					t.Fatalf("an error '%s' was not expected when writing the golden file", err)
+				}
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when reading the golden file", err)
+			}
+			if out.String() != string(expected) {
+				t.Fatalf("output differs from %s:\n%s", golden, out.String())
+			}
+		})
+	}
+}
+
+func TestRunner_StopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewRunner(imp.NewMemoryRepoUser(), nil, Status{}, reader, io.Discard).Run(ctx)
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got error '%v', expected context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("runner did not stop after cancel while waiting for input")
+	}
+}
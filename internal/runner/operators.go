@@ -0,0 +1,145 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"text/tabwriter"
+)
+
+func (r *Runner) handleOperators(ctx context.Context, args []string, opts options) error {
+	svc := r.status.Operators
+	if svc == nil {
+		return errors.New("operators need a database connection")
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		return r.listOperators(ctx, svc)
+
+	case args[0] == "add" && len(args) == 3:
+		role, err := models.ParseRole(args[2])
+		if err != nil {
+			return err
+		}
+		password, err := r.askOperatorPassword(ctx, true)
+		if err != nil {
+			return err
+		}
+		if err := svc.AddOperator(ctx, args[1], role, password); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "Added %s %s\n", role, args[1])
+		return nil
+
+	case args[0] == "role" && len(args) == 3:
+		role, err := models.ParseRole(args[2])
+		if err != nil {
+			return err
+		}
+		if err := svc.SetRole(ctx, args[1], role); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "%s is now %s\n", args[1], role)
+		return nil
+
+	case args[0] == "delete" && len(args) == 2:
+		if ok, err := r.confirmChange(ctx, opts, fmt.Sprintf("Delete operator %s and its API tokens? [y/N]: ", args[1])); !ok || err != nil {
+			return err
+		}
+		if err := svc.DeleteOperator(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "Deleted %s\n", args[1])
+		return nil
+
+	case args[0] == "password" && len(args) == 2:
+		password, err := r.askOperatorPassword(ctx, false)
+		if err != nil {
+			return err
+		}
+		if err := svc.SetPassword(ctx, args[1], password); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "Changed the password of %s\n", args[1])
+		return nil
+
+	case args[0] == "token" && (len(args) == 2 || len(args) == 3):
+		label := ""
+		if len(args) == 3 {
+			label = args[2]
+		}
+		token, err := svc.CreateToken(ctx, args[1], label)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "API token of %s, it is not shown again:\n%s\n", args[1], token)
+		return nil
+
+	default:
+		return usageError("operators")
+	}
+}
+
+func (r *Runner) listOperators(ctx context.Context, svc *auth.Service) error {
+	operators, err := svc.Operators(ctx)
+	if err != nil {
+		return err
+	}
+	if len(operators) == 0 {
+		fmt.Fprintln(r.out, "No operators, add an admin with: operators add <name> admin")
+		return nil
+	}
+	w := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tROLE\tLOGIN")
+	for _, operator := range operators {
+		login := "password"
+		if operator.PasswordHash == "" {
+			login = "API tokens only"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", operator.Name, operator.Role, login)
+	}
+	return w.Flush()
+}
+
+// askOperatorPassword reads a new operator password twice without echo. If
+// optional, an empty password is accepted for operators using only API tokens.
+func (r *Runner) askOperatorPassword(ctx context.Context, optional bool) (string, error) {
+	prompt := "Password: "
+	if optional {
+		prompt = "Password (empty for API tokens only): "
+	}
+	return r.askNewPassword(ctx, prompt, optional, auth.ValidatePassword)
+}
+
+// askNewPassword reads a password twice without echo until it is valid and
+// both match. If optional, an empty password is returned as is.
+func (r *Runner) askNewPassword(ctx context.Context, prompt string, optional bool, validate func(string) error) (string, error) {
+	for {
+		password, err := r.readAnswer(ctx, r.readPassword, prompt)
+		if err != nil {
+			return "", err
+		}
+		if password == "" && optional {
+			return "", nil
+		}
+		var verr models.ValidationError
+		if err := validate(password); errors.As(err, &verr) {
+			fmt.Fprintf(r.out, "Password %s\n", verr.Field("password"))
+			continue
+		}
+		repeated, err := r.readAnswer(ctx, r.readPassword, "Repeat password: ")
+		if err != nil {
+			return "", err
+		}
+		if repeated != password {
+			fmt.Fprintln(r.out, "Passwords do not match")
+			continue
+		}
+		return password, nil
+	}
+}
@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+)
+
+func (r *Runner) handleTenants(ctx context.Context, args []string) error {
+	svc := r.status.Tenants
+	if svc == nil {
+		return errors.New("tenants need a database connection")
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		tenants, err := svc.Tenants(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "\tID\tNAME")
+		for _, tenant := range tenants {
+			current := ""
+			if tenant.ID == svc.Current().ID {
+				current = "*"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\n", current, tenant.ID, tenant.Name)
+		}
+		return w.Flush()
+
+	case args[0] == "add" && len(args) == 2:
+		if err := svc.Add(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "Added tenant %s, work on it with -tenant %s\n", args[1], args[1])
+		return nil
+
+	default:
+		return usageError("tenants")
+	}
+}
@@ -0,0 +1,335 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	commandPrompt      = "Enter command: "
+	continuationPrompt = "... "
+	historySize        = 1000
+)
+
+// lineReader reads the input one line at a time.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+	// ReadPassword reads a line without echoing it, if the input can echo.
+	ReadPassword(prompt string) (string, error)
+	Close() error
+}
+
+// plainReader reads lines from a pipe or file, printing the prompt to out.
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (p *plainReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(p.out, prompt)
+	if p.scanner.Scan() {
+		return p.scanner.Text(), nil
+	}
+	if err := p.scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+func (p *plainReader) ReadPassword(prompt string) (string, error) {
+	return p.ReadLine(prompt)
+}
+
+func (p *plainReader) Close() error {
+	return nil
+}
+
+// terminalReader edits lines on a terminal with arrow keys, history and tab
+// completion. The terminal is in raw mode only while a line is read, so
+// Ctrl-C still interrupts running commands.
+type terminalReader struct {
+	fd      int
+	term    *term.Terminal
+	history *fileHistory
+
+	mu    sync.Mutex
+	state *term.State
+}
+
+func newTerminalReader(in, out *os.File, history *fileHistory, complete func(line string, pos int) (string, int, []string)) *terminalReader {
+	t := &terminalReader{
+		fd: int(in.Fd()),
+		term: term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{in, out}, commandPrompt),
+		history: history,
+	}
+	t.term.History = history
+	t.term.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		newLine, newPos, suggestions := complete(line, pos)
+		if len(suggestions) > 0 {
+			fmt.Fprintln(t.term, strings.Join(suggestions, "  "))
+		}
+		return newLine, newPos, newLine != line || newPos != pos
+	}
+	return t
+}
+
+func (t *terminalReader) ReadLine(prompt string) (string, error) {
+	// Only commands go to the history, not their continuation lines or
+	// answers to guided prompts.
+	t.history.ignore = prompt != commandPrompt
+	return t.read(func() (string, error) {
+		t.term.SetPrompt(prompt)
+		return t.term.ReadLine()
+	})
+}
+
+func (t *terminalReader) ReadPassword(prompt string) (string, error) {
+	return t.read(func() (string, error) { return t.term.ReadPassword(prompt) })
+}
+
+// read runs read with the terminal in raw mode.
+func (t *terminalReader) read(read func() (string, error)) (string, error) {
+	state, err := term.MakeRaw(t.fd)
+	if err != nil {
+		return "", err
+	}
+	t.mu.Lock()
+	t.state = state
+	t.mu.Unlock()
+	defer t.restore()
+
+	if width, height, err := term.GetSize(t.fd); err == nil && width > 0 {
+		t.term.SetSize(width, height)
+	}
+	line, err := read()
+	if errors.Is(err, term.ErrPasteIndicator) {
+		err = nil
+	}
+	return line, err
+}
+
+func (t *terminalReader) restore() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.state != nil {
+		term.Restore(t.fd, t.state)
+		t.state = nil
+	}
+}
+
+// Close restores the terminal, also when a read is still pending after the
+// session was canceled.
+func (t *terminalReader) Close() error {
+	t.restore()
+	return t.history.Close()
+}
+
+// fileHistory is the line editing history. New lines are appended to a file
+// so they are kept across sessions, except commands carrying a password,
+// which are remembered for this session only.
+type fileHistory struct {
+	entries []string
+	file    *os.File
+	// ignore skips lines that are not commands.
+	ignore bool
+}
+
+// loadHistory reads the history file at path, empty path keeps the history
+// in memory only.
+func loadHistory(path string) (*fileHistory, error) {
+	h := &fileHistory{}
+	if path == "" {
+		return h, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for line := range strings.Lines(string(data)) {
+		if line = strings.TrimRight(line, "\n"); line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if len(h.entries) > historySize {
+		h.entries = h.entries[len(h.entries)-historySize:]
+		if err := os.WriteFile(path, []byte(strings.Join(h.entries, "\n")+"\n"), 0o600); err != nil {
+			return nil, err
+		}
+	}
+	h.file, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *fileHistory) Add(entry string) {
+	if h.ignore || strings.TrimSpace(entry) == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry) {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > historySize {
+		h.entries = h.entries[1:]
+	}
+	if h.file == nil || hasSecret(entry) {
+		return
+	}
+	fmt.Fprintln(h.file, entry)
+}
+
+func (h *fileHistory) Len() int {
+	return len(h.entries)
+}
+
+func (h *fileHistory) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
+
+func (h *fileHistory) Close() error {
+	if h.file == nil {
+		return nil
+	}
+	return h.file.Close()
+}
+
+func hasSecret(line string) bool {
+	args, _ := splitArgs(line)
+	if len(args) == 0 {
+		return false
+	}
+	args, _, _ = parseOptions(args)
+	c, ok := findCommand(args[0])
+	return ok && c.passwordArg > 0 && len(args) > c.passwordArg
+}
+
+// completion is a value offered for the word being completed, match is what
+// the word is compared with, e.g. an email completing to a user ID.
+type completion struct {
+	value string
+	match string
+	label string
+}
+
+// complete completes the word before pos in line. It returns the new line and
+// cursor position and, when the word is ambiguous, the candidates to show.
+func (r *Runner) complete(line string, pos int) (string, int, []string) {
+	prefix := line[:pos]
+	start := strings.LastIndexAny(prefix, " \t") + 1
+	word := prefix[start:]
+	if strings.ContainsAny(word, `'"\`) {
+		return line, pos, nil
+	}
+	args, err := splitArgs(prefix[:start])
+	if err != nil {
+		return line, pos, nil
+	}
+
+	var matches []completion
+	for _, c := range r.completions(args) {
+		if strings.HasPrefix(c.match, word) && !slices.ContainsFunc(matches, func(m completion) bool { return m.value == c.value }) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return line, pos, nil
+	}
+	if len(matches) == 1 {
+		value := matches[0].value + " "
+		return prefix[:start] + value + line[pos:], start + len(value), nil
+	}
+
+	common := matches[0].value
+	for _, c := range matches[1:] {
+		for !strings.HasPrefix(c.value, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len(word) && strings.HasPrefix(common, word) {
+		return prefix[:start] + common + line[pos:], start + len(common), nil
+	}
+	suggestions := make([]string, len(matches))
+	for i, c := range matches {
+		suggestions[i] = c.label
+	}
+	return line, pos, suggestions
+}
+
+// completions lists the values possible after args.
+func (r *Runner) completions(args []string) []completion {
+	if len(args) == 0 || (len(args) == 1 && args[0] == "help") {
+		var names []completion
+		for _, c := range commands {
+			for _, name := range c.names {
+				names = append(names, completion{value: name, match: name, label: name})
+			}
+		}
+		return names
+	}
+	args, _, _ = parseOptions(args)
+	c, ok := findCommand(args[0])
+	if !ok {
+		return nil
+	}
+	switch {
+	case c.names[0] == "db" && len(args) == 1:
+		return []completion{{value: "health", match: "health", label: "health"}}
+	case c.userIDArg == len(args), c.variadic && c.userIDArg > 0 && len(args) > c.userIDArg:
+		var ids []completion
+		for _, user := range r.completionUsers() {
+			id := strconv.Itoa(user.ID)
+			label := id + " (" + user.Email + ")"
+			ids = append(ids,
+				completion{value: id, match: id, label: label},
+				completion{value: id, match: user.Email, label: label})
+		}
+		return ids
+	}
+	return nil
+}
+
+// completionUsers returns the users offered by completion. They are fetched
+// at most every few seconds, so pressing tab does not flood the audit log.
+func (r *Runner) completionUsers() []userRef {
+	r.completionMu.Lock()
+	defer r.completionMu.Unlock()
+	if time.Since(r.completionAt) < 5*time.Second {
+		return r.completionCache
+	}
+
+	ctx, cancel := context.WithTimeout(r.completionCtx, 2*time.Second)
+	defer cancel()
+	users, err := r.repo.GetUsers(ctx, models.UserFilter{})
+	if err != nil {
+		return r.completionCache
+	}
+	refs := make([]userRef, len(users))
+	for i, user := range users {
+		refs[i] = userRef{ID: user.ID, Email: user.Email}
+	}
+	slices.SortFunc(refs, func(a, b userRef) int { return a.ID - b.ID })
+	r.completionCache, r.completionAt = refs, time.Now()
+	return refs
+}
+
+type userRef struct {
+	ID    int
+	Email string
+}
@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"context"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRunner_Complete(t *testing.T) {
+	repo := imp.NewMemoryRepoUser()
+	for _, email := range []string{"alice@example.com", "bob@example.com"} {
+		if _, err := repo.InsertUser(context.Background(), models.User{Name: "n", Email: email, Password: "p"}); err != nil {
+			t.Fatalf("an error '%s' was not expected when inserting user", err)
+		}
+	}
+	r := NewRunner(repo, nil, Status{}, strings.NewReader(""), io.Discard)
+
+	tests := []struct {
+		line        string
+		expected    string
+		suggestions []string
+	}{
+		{line: "he", expected: "help "},
+		{line: "help st", expected: "help status "},
+		{line: "db h", expected: "db health "},
+		{line: "q", expected: "q", suggestions: []string{"q", "quit"}},
+		{line: "2 ", expected: "2 ", suggestions: []string{"1 (alice@example.com)", "2 (bob@example.com)"}},
+		{line: "4 bo", expected: "4 2 "},
+		{line: "5 al", expected: "5 1 "},
+		{line: "4 --yes 1 bo", expected: "4 --yes 1 2 "},
+		{line: "5 1 al", expected: "5 1 al"},
+		{line: "3 al", expected: "3 al"},
+	}
+	for _, tt := range tests {
+		line, pos, suggestions := r.complete(tt.line, len(tt.line))
+		if line != tt.expected || pos != len(tt.expected) || !slices.Equal(suggestions, tt.suggestions) {
+			t.Fatalf("complete(%q) = %q, %d, %q, expected %q, %q", tt.line, line, pos, suggestions, tt.expected, tt.suggestions)
+		}
+	}
+}
+
+func TestFileHistory_KeepsNonSecretCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	h, err := loadHistory(path)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when loading history", err)
+	}
+	h.Add("2 1")
+	h.Add("2 1")
+	h.Add("3 ann ann@example.com secret")
+	h.Add("3 --dry-run ann ann@example.com secret")
+	h.Add("5 1")
+	h.ignore = true
+	h.Add("continued")
+	h.ignore = false
+	h.Add("1")
+	if h.Len() != 5 || h.At(0) != "1" || h.At(4) != "2 1" {
+		t.Fatalf("unexpected session history %q", h.entries)
+	}
+	h.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when reading history", err)
+	}
+	if string(data) != "2 1\n5 1\n1\n" {
+		t.Fatalf("got history file %q, expected only the commands without secrets", data)
+	}
+
+	h, err = loadHistory(path)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when reloading history", err)
+	}
+	defer h.Close()
+	if h.Len() != 3 || h.At(0) != "1" {
+		t.Fatalf("unexpected reloaded history %q", h.entries)
+	}
+}
@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"slices"
+)
+
+// handleUndo reverts the last change recorded in the audit log, or every
+// change of the last command when it changed several users.
+func (r *Runner) handleUndo(ctx context.Context, opts options) error {
+	if r.logs == nil {
+		return errors.New("undo needs the audit log")
+	}
+	records, err := r.lastChange(ctx)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(r.out, "Nothing to undo")
+		return nil
+	}
+
+	fmt.Fprintln(r.out, "To be undone:")
+	for _, record := range records {
+		fmt.Fprintf(r.out, "  %s\n", describeUndo(record))
+	}
+	if ok, err := r.confirmChange(ctx, opts, fmt.Sprintf("Undo %d change(s)? [y/N]: ", len(records))); !ok || err != nil {
+		return err
+	}
+
+	var rows int64
+	err = r.change(ctx, opts, func(ctx context.Context) error {
+		for _, record := range records {
+			res, err := r.undo(ctx, record)
+			if err != nil {
+				return err
+			}
+			n, _ := res.RowsAffected()
+			rows += n
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.reportRows(opts, "reverted", rows)
+	if missing := int64(len(records)) - rows; missing > 0 {
+		fmt.Fprintf(r.out, "%d change(s) were not reverted, the users no longer exist\n", missing)
+	}
+	return nil
+}
+
+// lastChange returns the audit records of the last command that changed
+// users, newest first, which is the order they are undone in.
+func (r *Runner) lastChange(ctx context.Context) ([]models.AuditRecord, error) {
+	logs, err := r.logs.GetLogs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the audit log: %w", err)
+	}
+	slices.SortFunc(logs, func(a, b models.Log) int { return int(a.Id - b.Id) })
+
+	var records []models.AuditRecord
+	for _, log := range slices.Backward(logs) {
+		record, ok := log.Audit()
+		if !ok {
+			continue
+		}
+		if len(records) > 0 && (record.Batch == "" || record.Batch != records[0].Batch) {
+			break
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func describeUndo(record models.AuditRecord) string {
+	switch {
+	case record.Op == models.AuditInsert:
+		return fmt.Sprintf("delete user %d, which was inserted", record.UserID)
+	case record.Before == nil:
+		return fmt.Sprintf("%s of user %d cannot be undone", record.Op, record.UserID)
+	case record.Op == models.AuditUpdate:
+		return fmt.Sprintf("restore user %s", formatUser(*record.Before))
+	default:
+		return fmt.Sprintf("insert deleted user %s again under a new ID", formatUser(*record.Before))
+	}
+}
+
+// undo makes the change that reverts record.
+func (r *Runner) undo(ctx context.Context, record models.AuditRecord) (sql.Result, error) {
+	if record.Op == models.AuditInsert {
+		return r.repo.DeleteUserById(ctx, record.UserID)
+	}
+	if record.Before == nil {
+		return nil, fmt.Errorf("%s of user %d cannot be undone, the previous values were not recorded", record.Op, record.UserID)
+	}
+	switch record.Op {
+	case models.AuditUpdate:
+		current, err := r.repo.GetUserById(ctx, record.UserID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return driver.RowsAffected(0), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Restoring the previous status changes it again, which the
+		// lifecycle has to allow, deleted users stay deleted.
+		if status := record.Before.Status; status != "" && status != current.Status {
+			if _, err := current.Transition(status); err != nil {
+				return nil, fmt.Errorf("user %d cannot be restored: %w", record.UserID, err)
+			}
+		}
+		return r.repo.UpdateUserById(ctx, record.UserID, *record.Before)
+	case models.AuditDelete:
+		return r.repo.InsertUser(ctx, *record.Before)
+	default:
+		return nil, fmt.Errorf("unknown change %q in the audit log", record.Op)
+	}
+}
@@ -0,0 +1,249 @@
+package runner
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/account"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/login"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"strconv"
+	"strings"
+)
+
+func (r *Runner) handleUsers(ctx context.Context, args []string, opts options) error {
+	if len(args) == 0 {
+		return usageError("users")
+	}
+	for _, opt := range []string{optStatus, optRole, optGroup, optAttr} {
+		if *opts.value(opt) != "" && args[0] != "list" {
+			return fmt.Errorf("%s only applies to users list", opt)
+		}
+	}
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			return usageError("users")
+		}
+		return r.handleList(ctx, opts)
+	case "activate", "suspend", "lock", "delete":
+		if len(args) < 2 || len(args) > 3 {
+			return usageError("users")
+		}
+		reason := ""
+		if len(args) == 3 {
+			reason = args[2]
+		}
+		return r.handleTransition(ctx, args[0], args[1], reason, opts)
+	}
+	if len(args) != 2 {
+		return usageError("users")
+	}
+	switch args[0] {
+	case "verify", "unlock", "revoke":
+		return r.handleLogins(ctx, args[0], args[1])
+	case "send-verification", "verify-email", "send-reset", "reset-password":
+		return r.handleAccounts(ctx, args[0], args[1])
+	default:
+		return usageError("users")
+	}
+}
+
+func (r *Runner) handleList(ctx context.Context, opts options) error {
+	filter := models.UserFilter{Role: opts.role, Group: opts.group}
+	if opts.status != "" {
+		status, err := models.ParseUserStatus(opts.status)
+		if err != nil {
+			return err
+		}
+		filter.Status = status
+	}
+	if opts.attr != "" {
+		name, value, ok := strings.Cut(opts.attr, "=")
+		if !ok {
+			return fmt.Errorf("%s needs <name>=<value>", optAttr)
+		}
+		if err := models.ValidateAttributeKey(name); err != nil {
+			return err
+		}
+		filter.Attributes = models.Attributes{name: models.ParseAttributeValue(value)}
+	}
+	users, err := r.repo.GetUsers(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		fmt.Fprintln(r.out, "No users found")
+		return nil
+	}
+	for _, u := range users {
+		fmt.Fprintln(r.out, formatUser(u))
+	}
+	return nil
+}
+
+// transitions are the statuses the status changing actions move users to.
+var transitions = map[string]models.UserStatus{
+	"activate": models.StatusActive,
+	"suspend":  models.StatusSuspended,
+	"lock":     models.StatusLocked,
+	"delete":   models.StatusDeleted,
+}
+
+// handleTransition moves a user through the lifecycle, reason is recorded in
+// the audit log. Only activating may go without one.
+func (r *Runner) handleTransition(ctx context.Context, action, idStr, reason string, opts options) error {
+	if err := auth.Require(ctx, models.RoleEditor); err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+	if strings.TrimSpace(reason) == "" && action != "activate" {
+		return fmt.Errorf("a reason is required to %s a user", action)
+	}
+	current, err := r.repo.GetUserById(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			fmt.Fprintln(r.out, "User not found")
+			return nil
+		}
+		return err
+	}
+	user, err := current.Transition(transitions[action])
+	if err != nil {
+		return err
+	}
+
+	err = r.change(ctx, opts, func(ctx context.Context) error {
+		if reason != "" {
+			ctx = middleware.WithReason(ctx, reason)
+		}
+		_, err := r.repo.UpdateUserById(ctx, id, user)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(r.out, "User %d is now %s (was %s)\n", id, user.Status, current.Status)
+	return nil
+}
+
+func (r *Runner) handleLogins(ctx context.Context, action, arg string) error {
+	svc := r.status.Login
+	if svc == nil {
+		return errors.New("logins need a database connection")
+	}
+	switch action {
+	case "verify":
+		if err := auth.Require(ctx, models.RoleViewer); err != nil {
+			return err
+		}
+		password, err := r.readAnswer(ctx, r.readPassword, "Password: ")
+		if err != nil {
+			return err
+		}
+		user, err := svc.Verify(ctx, arg, password)
+		var locked *login.LockedError
+		switch {
+		case errors.Is(err, login.ErrInvalidCredentials):
+			fmt.Fprintln(r.out, "Invalid email or password")
+			return nil
+		case errors.As(err, &locked):
+			fmt.Fprintf(r.out, "%s is locked after too many failed logins\n", arg)
+			return nil
+		case errors.Is(err, login.ErrInactive):
+			fmt.Fprintf(r.out, "The password of %s is correct, but the user is not active\n", arg)
+			return nil
+		case err != nil:
+			return err
+		}
+		fmt.Fprintf(r.out, "Password of %s (ID %d) is correct\n", user.Email, user.ID)
+		return nil
+
+	case "unlock":
+		if err := auth.Require(ctx, models.RoleEditor); err != nil {
+			return err
+		}
+		unlocked, err := svc.Unlock(ctx, arg)
+		if err != nil {
+			return err
+		}
+		if !unlocked {
+			fmt.Fprintf(r.out, "%s has no failed logins\n", arg)
+			return nil
+		}
+		fmt.Fprintf(r.out, "Unlocked %s\n", arg)
+		return nil
+
+	default:
+		if err := auth.Require(ctx, models.RoleEditor); err != nil {
+			return err
+		}
+		if err := svc.Revoke(ctx, arg); err != nil {
+			return err
+		}
+		fmt.Fprintln(r.out, "Revoked the session")
+		return nil
+	}
+}
+
+// handleAccounts issues and consumes the tokens mailed to the users. Tokens
+// change users, so they need an editor either way.
+func (r *Runner) handleAccounts(ctx context.Context, action, arg string) error {
+	svc := r.status.Accounts
+	if svc == nil {
+		return errors.New("email verification and password resets need a database connection")
+	}
+	if err := auth.Require(ctx, models.RoleEditor); err != nil {
+		return err
+	}
+	switch action {
+	case "send-verification", "send-reset":
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid id: %w", err)
+		}
+		send, what := svc.SendVerification, "an email verification"
+		if action == "send-reset" {
+			send, what = svc.SendPasswordReset, "a password reset"
+		}
+		user, err := send(ctx, id)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			fmt.Fprintln(r.out, "User not found")
+			return nil
+		case errors.Is(err, account.ErrAlreadyVerified):
+			fmt.Fprintf(r.out, "The email of user %d is verified already\n", id)
+			return nil
+		case err != nil:
+			return err
+		}
+		fmt.Fprintf(r.out, "Sent %s token to %s\n", what, user.Email)
+		return nil
+
+	case "verify-email":
+		user, err := svc.VerifyEmail(ctx, arg)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "Verified %s of user %d\n", user.Email, user.ID)
+		return nil
+
+	default:
+		password, err := r.askNewPassword(ctx, "New password: ", false, account.ValidatePassword)
+		if err != nil {
+			return err
+		}
+		user, err := svc.ResetPassword(ctx, arg, password)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "Changed the password of user %d\n", user.ID)
+		return nil
+	}
+}
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnauthorized is returned when the server rejects the API token.
+var ErrUnauthorized = errors.New("the server rejected the API token")
+
+// ErrReadOnly is returned for writes to the audit log of a remote server,
+// the server writes it itself.
+var ErrReadOnly = errors.New("the remote audit log is read-only")
+
+// Client calls the REST API of a server.
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient returns a client of the server at baseURL, e.g.
+// https://host:8080, sending token as a bearer token unless it is empty.
+func NewClient(baseURL, token string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid remote %q, expected http://host:port or https://host:port", baseURL)
+	}
+	return &Client{
+		baseURL: strings.TrimSuffix(u.String(), "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// do sends a request with body encoded as JSON, if given, and decodes the
+// answer into out, if given. Error answers are turned back into the errors
+// the repositories return.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if batch := middleware.BatchFromContext(ctx); batch != "" {
+		req.Header.Set(batchHeader, batch)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return responseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid answer to %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+func responseError(resp *http.Response) error {
+	var body errorResponse
+	json.NewDecoder(io.LimitReader(resp.Body, maxBodySize)).Decode(&body)
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return sql.ErrNoRows
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return fmt.Errorf("%w: %s", auth.ErrForbidden, strings.TrimPrefix(body.Error, auth.ErrForbidden.Error()+": "))
+	case http.StatusUnprocessableEntity:
+		if len(body.Fields) > 0 {
+			return models.ValidationError(body.Fields)
+		}
+	}
+	if body.Error == "" {
+		body.Error = http.StatusText(resp.StatusCode)
+	}
+	return fmt.Errorf("server: %s (%d)", body.Error, resp.StatusCode)
+}
+
+// listAll reads every page of a listing, query holds its filters.
+func listAll[T any](ctx context.Context, c *Client, path string, query url.Values) ([]T, error) {
+	items := []T{}
+	for {
+		q := maps.Clone(query)
+		if q == nil {
+			q = url.Values{}
+		}
+		q.Set("limit", strconv.Itoa(maxLimit))
+		q.Set("offset", strconv.Itoa(len(items)))
+		var p page[T]
+		if err := c.do(ctx, http.MethodGet, path+"?"+q.Encode(), nil, &p); err != nil {
+			return nil, err
+		}
+		items = append(items, p.Items...)
+		if len(p.Items) == 0 || len(items) >= p.Total {
+			return items, nil
+		}
+	}
+}
+
+// result is the sql.Result of a remote change.
+type result struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) { return r.lastInsertId, nil }
+func (r result) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+// changeResult reports a missing user as no affected rows, like the SQL
+// repositories do.
+func changeResult(err error) (sql.Result, error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		return result{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result{rowsAffected: 1}, nil
+}
+
+// RemoteRepoUser works on the users of a server. Users are returned without
+// the password, the server does not return it.
+type RemoteRepoUser struct {
+	client *Client
+}
+
+func (u userResponse) user() models.User {
+	return models.User{ID: u.ID, Name: u.Name, Email: u.Email, RegisteredAt: u.RegisteredAt, EmailVerifiedAt: u.EmailVerifiedAt, Status: u.Status, Attributes: u.Attributes}
+}
+
+func (p RemoteRepoUser) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	query := url.Values{}
+	if filter.Email != "" {
+		query.Set("email", filter.Email)
+	}
+	if filter.Status != "" {
+		query.Set("status", string(filter.Status))
+	}
+	if filter.Role != "" {
+		query.Set("role", filter.Role)
+	}
+	if filter.Group != "" {
+		query.Set("group", filter.Group)
+	}
+	for key, value := range filter.Attributes {
+		// JSON keeps the type of the value, e.g. "30" apart from 30.
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("attr."+key, string(b))
+	}
+	items, err := listAll[userResponse](ctx, p.client, "/users", query)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]models.User, len(items))
+	for i, item := range items {
+		users[i] = item.user()
+	}
+	return users, nil
+}
+
+func (p RemoteRepoUser) GetUserById(ctx context.Context, id int) (models.User, error) {
+	var resp userResponse
+	if err := p.client.do(ctx, http.MethodGet, "/users/"+strconv.Itoa(id), nil, &resp); err != nil {
+		return models.User{}, err
+	}
+	return resp.user(), nil
+}
+
+func (p RemoteRepoUser) InsertUser(ctx context.Context, user models.User) (sql.Result, error) {
+	req := createUserRequest{Name: user.Name, Email: user.Email, Password: user.Password}
+	var resp userResponse
+	if err := p.client.do(ctx, http.MethodPost, "/users", req, &resp); err != nil {
+		return nil, err
+	}
+	return result{lastInsertId: int64(resp.ID), rowsAffected: 1}, nil
+}
+
+// UpdateUserById keeps the current password when user has none, and the
+// current status when user has none. The reason of ctx is sent along.
+func (p RemoteRepoUser) UpdateUserById(ctx context.Context, id int, user models.User) (sql.Result, error) {
+	req := updateUserRequest{Name: &user.Name, Email: &user.Email, Reason: middleware.ReasonFromContext(ctx)}
+	if user.Password != "" {
+		req.Password = &user.Password
+	}
+	if user.Status != "" {
+		req.Status = &user.Status
+	}
+	return changeResult(p.client.do(ctx, http.MethodPatch, "/users/"+strconv.Itoa(id), req, nil))
+}
+
+func (p RemoteRepoUser) DeleteUserById(ctx context.Context, id int) (sql.Result, error) {
+	return changeResult(p.client.do(ctx, http.MethodDelete, "/users/"+strconv.Itoa(id), nil, nil))
+}
+
+func NewRemoteRepoUser(client *Client) repository.IRepositoryUser {
+	return RemoteRepoUser{client: client}
+}
+
+// RemoteRepoLog reads the audit log of a server. The audit records hold what
+// was changed but not the previous values, so only inserts can be undone.
+type RemoteRepoLog struct {
+	client *Client
+}
+
+func (l logResponse) log() models.Log {
+	log := models.Log{Id: l.ID, LogTime: l.Time, LogMessage: l.Message, Actor: l.Actor}
+	if l.Change != nil {
+		details, _ := json.Marshal(models.AuditRecord{Op: l.Change.Op, UserID: l.Change.UserID, Batch: l.Change.Batch, Reason: l.Change.Reason})
+		log.Details = string(details)
+	}
+	return log
+}
+
+func (p RemoteRepoLog) GetLogs(ctx context.Context) ([]models.Log, error) {
+	items, err := listAll[logResponse](ctx, p.client, "/logs", nil)
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]models.Log, len(items))
+	for i, item := range items {
+		logs[i] = item.log()
+	}
+	return logs, nil
+}
+
+func (p RemoteRepoLog) GetLogById(ctx context.Context, id int) (models.Log, error) {
+	var resp logResponse
+	if err := p.client.do(ctx, http.MethodGet, "/logs/"+strconv.Itoa(id), nil, &resp); err != nil {
+		return models.Log{}, err
+	}
+	return resp.log(), nil
+}
+
+func (p RemoteRepoLog) InsertLog(ctx context.Context, log models.Log) (sql.Result, error) {
+	return nil, ErrReadOnly
+}
+
+func NewRemoteRepoLog(client *Client) repository.IRepositoryLog {
+	return RemoteRepoLog{client: client}
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// startServer serves the API over memory repositories, requiring the API
+// token of an operator of svc unless it is nil.
+func startServer(t *testing.T, svc *auth.Service) (repository.IRepositoryUser, *httptest.Server) {
+	t.Helper()
+	logs := imp.NewMemoryRepoLog()
+	users := middleware.NewLoggerMiddleware(logs, middleware.NewPasswordMiddleware(imp.NewMemoryRepoUser()))
+	handler := NewHandler(users, logs)
+	if svc != nil {
+		handler = Authenticate(svc, NewHandler(middleware.NewAuthorizationUserMiddleware(users), logs))
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return users, srv
+}
+
+// newOperatorToken adds an operator with role to svc and returns its API token.
+func newOperatorToken(t *testing.T, svc *auth.Service, name string, role models.Role) string {
+	t.Helper()
+	admin := auth.WithOperator(context.Background(), auth.Bootstrap)
+	if err := svc.AddOperator(admin, name, role, ""); err != nil {
+		t.Fatalf("an error '%s' was not expected when adding an operator", err)
+	}
+	token, err := svc.CreateToken(admin, name, "test")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when creating a token", err)
+	}
+	return token
+}
+
+func newTestClient(t *testing.T, url, token string) *Client {
+	t.Helper()
+	client, err := NewClient(url, token)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when creating a client", err)
+	}
+	return client
+}
+
+func TestRemoteRepoUser_RoundTrip(t *testing.T) {
+	svc := auth.NewService(imp.NewMemoryRepoOperator())
+	users, srv := startServer(t, svc)
+	remote := NewRemoteRepoUser(newTestClient(t, srv.URL, newOperatorToken(t, svc, "ed", models.RoleEditor)))
+	ctx := context.Background()
+
+	res, err := remote.InsertUser(ctx, models.User{Name: "Ann", Email: "ann@example.com", Password: "secret"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting a user", err)
+	}
+	if id, _ := res.LastInsertId(); id != 1 {
+		t.Fatalf("got id %d, expected 1", id)
+	}
+
+	user, err := remote.GetUserById(ctx, 1)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting user by id", err)
+	}
+	if user.Name != "Ann" || user.Password != "" || user.RegisteredAt.IsZero() {
+		t.Fatalf("got %+v, expected Ann without the password", user)
+	}
+
+	// An update without a password keeps the current one.
+	user.Email = "annie@example.com"
+	if res, err = remote.UpdateUserById(ctx, 1, user); err != nil {
+		t.Fatalf("an error '%s' was not expected when updating a user", err)
+	}
+	if rows, _ := res.RowsAffected(); rows != 1 {
+		t.Fatalf("got %d affected rows, expected 1", rows)
+	}
+	stored, _ := users.GetUserById(ctx, 1)
+	if stored.Email != "annie@example.com" || bcrypt.CompareHashAndPassword([]byte(stored.PasswordHash), []byte("secret")) != nil {
+		t.Fatalf("got %+v, expected the new email and the old password", stored)
+	}
+
+	var verr models.ValidationError
+	if _, err = remote.InsertUser(ctx, models.User{Name: "Bob", Email: "nope", Password: "pw"}); !errors.As(err, &verr) || verr.Field("email") == "" {
+		t.Fatalf("got '%v', expected a validation error of the email", err)
+	}
+
+	if res, err = remote.DeleteUserById(ctx, 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when deleting a user", err)
+	}
+	if rows, _ := res.RowsAffected(); rows != 1 {
+		t.Fatalf("got %d affected rows, expected 1", rows)
+	}
+	for _, change := range []func() (sql.Result, error){
+		func() (sql.Result, error) { return remote.DeleteUserById(ctx, 1) },
+		func() (sql.Result, error) { return remote.UpdateUserById(ctx, 1, user) },
+	} {
+		res, err := change()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when changing a deleted user", err)
+		}
+		if rows, _ := res.RowsAffected(); rows != 0 {
+			t.Fatalf("got %d affected rows, expected 0 for a deleted user", rows)
+		}
+	}
+	if _, err = remote.GetUserById(ctx, 1); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("got '%v', expected sql.ErrNoRows", err)
+	}
+}
+
+func TestRemoteRepoUser_ReadsEveryPage(t *testing.T) {
+	users, srv := startServer(t, nil)
+	ctx := context.Background()
+	for i := range maxLimit + 3 {
+		users.InsertUser(ctx, models.User{Name: fmt.Sprint(i), Email: fmt.Sprintf("%d@example.com", i), PasswordHash: "pw-hash", RegisteredAt: time.Now()})
+	}
+
+	got, err := NewRemoteRepoUser(newTestClient(t, srv.URL, "")).GetUsers(ctx, models.UserFilter{})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting users", err)
+	}
+	if len(got) != maxLimit+3 || got[maxLimit+2].Name != fmt.Sprint(maxLimit+2) {
+		t.Fatalf("got %d users, expected %d in order", len(got), maxLimit+3)
+	}
+}
+
+func TestRemoteRepoUser_FiltersByAttributes(t *testing.T) {
+	users, srv := startServer(t, nil)
+	ctx := context.Background()
+	users.InsertUser(ctx, models.User{Name: "Ann", Email: "ann@example.com", Password: "pw", Attributes: models.Attributes{"department": "ops", "floor": 3}})
+	users.InsertUser(ctx, models.User{Name: "Bob", Email: "bob@example.com", Password: "pw", Attributes: models.Attributes{"department": "ops", "floor": "3"}})
+	users.InsertUser(ctx, models.User{Name: "Cid", Email: "cid@example.com", Password: "pw"})
+
+	got, err := NewRemoteRepoUser(newTestClient(t, srv.URL, "")).GetUsers(ctx, models.UserFilter{Attributes: models.Attributes{"department": "ops", "floor": 3}})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting users", err)
+	}
+	if len(got) != 1 || got[0].Name != "Ann" || got[0].Attributes.String() != `{"department":"ops","floor":3}` {
+		t.Fatalf("got %+v, expected Ann with her attributes", got)
+	}
+}
+
+func TestRemoteRepoLog_KeepsBatchesWithoutPreviousValues(t *testing.T) {
+	_, srv := startServer(t, nil)
+	client := newTestClient(t, srv.URL, "")
+	remote := NewRemoteRepoUser(client)
+	ctx := middleware.WithBatch(context.Background(), "batch-1")
+
+	for _, name := range []string{"a", "b"} {
+		if _, err := remote.InsertUser(ctx, models.User{Name: name, Email: name + "@example.com", Password: "secret"}); err != nil {
+			t.Fatalf("an error '%s' was not expected when inserting a user", err)
+		}
+	}
+	if _, err := remote.DeleteUserById(ctx, 1); err != nil {
+		t.Fatalf("an error '%s' was not expected when deleting a user", err)
+	}
+
+	repoLog := NewRemoteRepoLog(client)
+	logs, err := repoLog.GetLogs(context.Background())
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting logs", err)
+	}
+	var ops []string
+	for _, log := range logs {
+		if strings.Contains(log.Details, "secret") {
+			t.Fatalf("the audit log exposed a password: %s", log.Details)
+		}
+		if record, ok := log.Audit(); ok {
+			if record.Batch != "batch-1" || record.Before != nil {
+				t.Fatalf("got record %+v, expected batch-1 without previous values", record)
+			}
+			ops = append(ops, record.Op)
+		}
+	}
+	if strings.Join(ops, ",") != "insert,insert,delete" {
+		t.Fatalf("got changes %v, expected two inserts and a delete", ops)
+	}
+
+	last := logs[len(logs)-1]
+	if got, err := repoLog.GetLogById(context.Background(), int(last.Id)); err != nil || got.Details != last.Details {
+		t.Fatalf("got %+v, '%v', expected %+v", got, err, last)
+	}
+	if _, err := repoLog.InsertLog(ctx, models.Log{}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("got '%v', expected ErrReadOnly", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	svc := auth.NewService(imp.NewMemoryRepoOperator())
+	_, srv := startServer(t, svc)
+	ctx := context.Background()
+	for _, token := range []string{"", "wrong"} {
+		_, err := NewRemoteRepoUser(newTestClient(t, srv.URL, token)).GetUsers(ctx, models.UserFilter{})
+		if !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("got '%v' with token %q, expected ErrUnauthorized", err, token)
+		}
+	}
+
+	viewer := NewRemoteRepoUser(newTestClient(t, srv.URL, newOperatorToken(t, svc, "vera", models.RoleViewer)))
+	if _, err := viewer.GetUsers(ctx, models.UserFilter{}); err != nil {
+		t.Fatalf("an error '%s' was not expected when a viewer lists users", err)
+	}
+	_, err := viewer.InsertUser(ctx, models.User{Name: "Ann", Email: "ann@example.com", Password: "secret"})
+	if !errors.Is(err, auth.ErrForbidden) || !strings.Contains(err.Error(), "vera") {
+		t.Fatalf("got '%v' when a viewer inserts a user, expected ErrForbidden", err)
+	}
+
+	if _, err := NewClient("ftp://server", ""); err == nil {
+		t.Fatalf("expected an error for a remote that is not http or https")
+	}
+}
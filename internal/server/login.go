@@ -0,0 +1,87 @@
+package server
+
+import (
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/login"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token     string       `json:"token"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	User      userResponse `json:"user"`
+}
+
+type sessionResponse struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// WithLogin serves the login endpoints of the managed users in front of
+// next, which gets every other request:
+//
+//	POST   /auth/login     check an email and password, returns a session token
+//	POST   /auth/logout    revoke the session token sent as bearer token
+//	GET    /auth/session   show the session of the bearer token
+func WithLogin(svc *login.Service, next http.Handler) http.Handler {
+	s := &Server{sessions: svc, mux: http.NewServeMux()}
+	s.handle("POST /auth/login", s.login)
+	s.handle("POST /auth/logout", s.logout)
+	s.handle("GET /auth/session", s.session)
+	s.mux.Handle("/", next)
+	return s
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) error {
+	var req loginRequest
+	if err := readJSON(r, &req); err != nil {
+		return err
+	}
+	session, err := s.sessions.Login(r.Context(), req.Email, req.Password)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, loginResponse{
+		Token:     session.Token,
+		ExpiresAt: time.Unix(session.Claims.ExpiresAt, 0).UTC(),
+		User:      newUserResponse(session.User),
+	})
+	return nil
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) error {
+	if err := s.sessions.Revoke(r.Context(), bearerToken(r)); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) session(w http.ResponseWriter, r *http.Request) error {
+	claims, err := s.sessions.Validate(r.Context(), bearerToken(r))
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, sessionResponse{
+		ID:        claims.ID,
+		UserID:    claims.Subject,
+		Email:     claims.Email,
+		IssuedAt:  time.Unix(claims.IssuedAt, 0).UTC(),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(),
+	})
+	return nil
+}
+
+func bearerToken(r *http.Request) string {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/login"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithLogin(t *testing.T) {
+	users := middleware.NewPasswordMiddleware(imp.NewMemoryRepoUser())
+	user := models.User{Name: "Ann", Email: "ann@example.com", Password: "secret", RegisteredAt: time.Now(), Status: models.StatusActive}
+	if _, err := users.InsertUser(context.Background(), user); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting a user", err)
+	}
+	cfg := login.DefaultConfig()
+	cfg.MaxFailures = 2
+	cfg.Secret = login.NewSecret()
+	svc, err := login.NewService(users, imp.NewMemoryRepoLog(), imp.NewMemoryRepoLogin(), cfg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when creating the login service", err)
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	h := WithLogin(svc, next)
+
+	var resp loginResponse
+	rec := do(t, h, "POST", "/auth/login", `{"email":"ann@example.com","password":"secret"}`, &resp)
+	if rec.Code != http.StatusOK || resp.Token == "" || resp.User.ID != 1 || resp.ExpiresAt.Before(time.Now()) {
+		t.Fatalf("got %d %+v, expected a session token", rec.Code, resp)
+	}
+
+	withToken := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := withToken("GET", "/auth/session", resp.Token); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"user_id":"1"`) {
+		t.Fatalf("got %d %s, expected the session", rec.Code, rec.Body.String())
+	}
+	if rec := withToken("POST", "/auth/logout", resp.Token); rec.Code != http.StatusNoContent {
+		t.Fatalf("got status %d, expected 204", rec.Code)
+	}
+	if rec := withToken("GET", "/auth/session", resp.Token); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("got status %d, expected 401 for a revoked session", rec.Code)
+	}
+
+	var errResp errorResponse
+	rec = do(t, h, "POST", "/auth/login", `{"email":"ann@example.com","password":"wrong"}`, &errResp)
+	if rec.Code != http.StatusUnauthorized || errResp.Error != "invalid email or password" {
+		t.Fatalf("got %d %+v, expected 401", rec.Code, errResp)
+	}
+	rec = do(t, h, "POST", "/auth/login", `{"email":"ann@example.com","password":"wrong"}`, nil)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("got status %d, expected 429 with Retry-After after too many failures", rec.Code)
+	}
+	if rec = do(t, h, "POST", "/auth/login", `{"email":"ann@example.com","password":"secret"}`, nil); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d, expected the locked email to be refused", rec.Code)
+	}
+
+	if rec = do(t, h, "GET", "/users", "", nil); rec.Code != http.StatusTeapot {
+		t.Fatalf("got status %d, expected other requests to reach the next handler", rec.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// logResponse is an audit log entry. Of the audit record only what changed
+// is shown, the previous values hold password hashes.
+type logResponse struct {
+	ID      int64          `json:"id"`
+	Time    time.Time      `json:"time"`
+	Message string         `json:"message"`
+	Change  *changeSummary `json:"change,omitempty"`
+	// Actor is the operator who acted, empty when unknown.
+	Actor string `json:"actor,omitempty"`
+}
+
+type changeSummary struct {
+	Op     string `json:"op"`
+	UserID int    `json:"user_id"`
+	Batch  string `json:"batch,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func newLogResponse(log models.Log) logResponse {
+	resp := logResponse{ID: log.Id, Time: log.LogTime, Message: log.LogMessage, Actor: log.Actor}
+	if record, ok := log.Audit(); ok {
+		resp.Change = &changeSummary{Op: record.Op, UserID: record.UserID, Batch: record.Batch, Reason: record.Reason}
+	}
+	return resp
+}
+
+func (s *Server) listLogs(w http.ResponseWriter, r *http.Request) error {
+	logs, err := s.logs.GetLogs(r.Context())
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(logs, func(a, b models.Log) int { return int(a.Id - b.Id) })
+
+	p, err := paginate(r, logs)
+	if err != nil {
+		return err
+	}
+	resp := page[logResponse]{Items: make([]logResponse, len(p.Items)), Total: p.Total, Limit: p.Limit, Offset: p.Offset}
+	for i, log := range p.Items {
+		resp.Items[i] = newLogResponse(log)
+	}
+	writeJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+func (s *Server) getLog(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r)
+	if err != nil {
+		return err
+	}
+	log, err := s.logs.GetLogById(r.Context(), id)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, newLogResponse(log))
+	return nil
+}
@@ -0,0 +1,278 @@
+// Package server exposes the user and log repositories as a JSON REST API.
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/login"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/tracing"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// batchHeader carries the batch set by middleware.WithBatch, so the
+	// changes of one remote command are undone together.
+	batchHeader = "X-Batch"
+
+	defaultLimit = 50
+	maxLimit     = 500
+	// maxBodySize limits request bodies, a user is far smaller.
+	maxBodySize = 1 << 20
+)
+
+// Server serves the REST API over the repositories it is given, usually
+// the full middleware stack.
+type Server struct {
+	users repository.IRepositoryUser
+	logs  repository.IRepositoryLog
+	// sessions logs the managed users in, see WithLogin.
+	sessions *login.Service
+	mux      *http.ServeMux
+}
+
+// NewHandler returns the REST API handler:
+//
+//	GET    /users          list users, ?limit= and ?offset= page through them,
+//	                       ?status= keeps the users with that status,
+//	                       ?role= and ?group= those with a role or in a group,
+//	                       ?attr.<name>= those with that attribute value
+//	POST   /users          create a user
+//	GET    /users/{id}     get a user
+//	PATCH  /users/{id}     change some fields of a user, or its status
+//	DELETE /users/{id}     delete a user
+//	GET    /logs           list the audit log, paged like /users
+//	GET    /logs/{id}      get an audit log entry
+func NewHandler(users repository.IRepositoryUser, logs repository.IRepositoryLog) http.Handler {
+	s := &Server{users: users, logs: logs, mux: http.NewServeMux()}
+	s.handle("GET /users", s.listUsers)
+	s.handle("POST /users", s.createUser)
+	s.handle("GET /users/{id}", s.getUser)
+	s.handle("PATCH /users/{id}", s.updateUser)
+	s.handle("DELETE /users/{id}", s.deleteUser)
+	s.handle("GET /logs", s.listLogs)
+	s.handle("GET /logs/{id}", s.getLog)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handlerFunc handles a request and returns the error to answer with, if
+// nothing was written yet.
+type handlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+func (s *Server) handle(pattern string, h handlerFunc) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Start(r.Context(), "server.request",
+			tracing.String("http.method", r.Method),
+			tracing.String("http.route", pattern))
+		defer span.End()
+
+		if batch := r.Header.Get(batchHeader); batch != "" {
+			ctx = middleware.WithBatch(ctx, batch)
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		if err := h(w, r.WithContext(ctx)); err != nil {
+			span.RecordError(err)
+			writeError(w, err)
+		}
+	})
+}
+
+// Authenticate answers requests to next with 401 unless they send the API
+// token of an operator as a bearer token in the Authorization header. The
+// operator is passed on in the request context.
+func Authenticate(svc *auth.Service, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			writeError(w, auth.ErrUnauthenticated)
+			return
+		}
+		operator, err := svc.Authenticate(r.Context(), token)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithOperator(r.Context(), operator)))
+	})
+}
+
+// Serve serves handler on addr until ctx is canceled, then stops accepting
+// connections and waits up to shutdownTimeout for running requests.
+func Serve(ctx context.Context, addr string, handler http.Handler, shutdownTimeout time.Duration) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ListenAndServe()
+	}()
+	log.Printf("[INFO] serving the REST API on %s", addr)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("could not stop the server gracefully: %w", err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// apiError is an error answered with its own status and message.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, args ...any) error {
+	return &apiError{status: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+type errorResponse struct {
+	Error  string              `json:"error"`
+	Fields []models.FieldError `json:"fields,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var apiErr *apiError
+	var verr models.ValidationError
+	var maxBytesErr *http.MaxBytesError
+	var lockedErr *login.LockedError
+	var transitionErr *models.TransitionError
+	switch {
+	case errors.As(err, &apiErr):
+		writeJSON(w, apiErr.status, errorResponse{Error: apiErr.message})
+	case errors.As(err, &verr):
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "validation failed", Fields: verr})
+	case errors.As(err, &maxBytesErr):
+		writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse{Error: "request body too large"})
+	case errors.Is(err, login.ErrInvalidCredentials), errors.Is(err, login.ErrInvalidToken):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="simpleCLIdbManager"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: err.Error()})
+	case errors.As(err, &lockedErr):
+		retry := max(int(math.Ceil(time.Until(lockedErr.Until).Seconds())), 1)
+		w.Header().Set("Retry-After", strconv.Itoa(retry))
+		writeJSON(w, http.StatusTooManyRequests, errorResponse{Error: lockedErr.Error()})
+	case errors.Is(err, auth.ErrUnauthenticated), errors.Is(err, auth.ErrInvalidCredentials):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="simpleCLIdbManager"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "missing or invalid API token"})
+	case errors.Is(err, auth.ErrForbidden), errors.Is(err, login.ErrInactive):
+		writeJSON(w, http.StatusForbidden, errorResponse{Error: err.Error()})
+	case errors.As(err, &transitionErr):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "not found"})
+	case errors.Is(err, middleware.ErrCircuitOpen):
+		w.Header().Set("Retry-After", "5")
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: "database unavailable"})
+	case errors.Is(err, context.Canceled):
+		// The client is gone, nobody reads the answer.
+	default:
+		log.Printf("[ERROR] request failed: %v", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "internal error"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[WARN] could not write response: %v", err)
+	}
+}
+
+// readJSON decodes the request body into v, rejecting unknown fields.
+func readJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return err
+		}
+		return badRequest("invalid JSON body: %v", err)
+	}
+	return nil
+}
+
+// page is one page of a listing.
+type page[T any] struct {
+	Items  []T `json:"items"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// paginate returns the page of items selected by the limit and offset
+// query parameters.
+func paginate[T any](r *http.Request, items []T) (page[T], error) {
+	limit, err := queryInt(r, "limit", defaultLimit)
+	if err != nil {
+		return page[T]{}, err
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil {
+		return page[T]{}, err
+	}
+	if limit < 1 || limit > maxLimit {
+		return page[T]{}, badRequest("limit must be between 1 and %d", maxLimit)
+	}
+	if offset < 0 {
+		return page[T]{}, badRequest("offset must not be negative")
+	}
+
+	start := min(offset, len(items))
+	end := min(start+limit, len(items))
+	return page[T]{Items: items[start:end], Total: len(items), Limit: limit, Offset: offset}, nil
+}
+
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, badRequest("%s must be a number", name)
+	}
+	return n, nil
+}
+
+func pathID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		return 0, badRequest("invalid id %q", r.PathValue("id"))
+	}
+	return id, nil
+}
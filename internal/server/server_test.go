@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHandler() http.Handler {
+	logs := imp.NewMemoryRepoLog()
+	return NewHandler(middleware.NewLoggerMiddleware(logs, middleware.NewPasswordMiddleware(imp.NewMemoryRepoUser())), logs)
+}
+
+// do sends a request to h and decodes the JSON answer into out, if given.
+func do(t *testing.T, h http.Handler, method, target, body string, out any) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("an error '%s' was not expected when decoding %q", err, rec.Body.String())
+		}
+	}
+	return rec
+}
+
+func TestServer_UserLifecycle(t *testing.T) {
+	h := newTestHandler()
+
+	var created userResponse
+	rec := do(t, h, "POST", "/users", `{"name":"Ann","email":"ann@example.com","password":"secret"}`, &created)
+	if rec.Code != http.StatusCreated || created.ID != 1 || created.Name != "Ann" || rec.Header().Get("Location") != "/users/1" {
+		t.Fatalf("got %d %+v, expected the created user", rec.Code, created)
+	}
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Fatalf("the password was returned: %s", rec.Body.String())
+	}
+
+	var updated userResponse
+	rec = do(t, h, "PATCH", "/users/1", `{"email":"annie@example.com"}`, &updated)
+	if rec.Code != http.StatusOK || updated.Name != "Ann" || updated.Email != "annie@example.com" {
+		t.Fatalf("got %d %+v, expected only the email to change", rec.Code, updated)
+	}
+
+	var got userResponse
+	if rec = do(t, h, "GET", "/users/1", "", &got); rec.Code != http.StatusOK || !reflect.DeepEqual(got, updated) {
+		t.Fatalf("got %d %+v, expected %+v", rec.Code, got, updated)
+	}
+
+	if rec = do(t, h, "DELETE", "/users/1", "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("got status %d, expected 204", rec.Code)
+	}
+	for _, method := range []string{"GET", "DELETE"} {
+		var resp errorResponse
+		if rec = do(t, h, method, "/users/1", "", &resp); rec.Code != http.StatusNotFound || resp.Error != "not found" {
+			t.Fatalf("%s of a deleted user got %d %+v, expected 404", method, rec.Code, resp)
+		}
+	}
+	if rec = do(t, h, "PATCH", "/users/1", `{"name":"Bob"}`, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("got status %d, expected 404 when updating a deleted user", rec.Code)
+	}
+
+	var logs page[logResponse]
+	if rec = do(t, h, "GET", "/logs?limit=500", "", &logs); rec.Code != http.StatusOK || logs.Total == 0 {
+		t.Fatalf("got %d %+v, expected the audit log", rec.Code, logs)
+	}
+	var ops []string
+	for _, log := range logs.Items {
+		if log.Change != nil {
+			ops = append(ops, log.Change.Op)
+		}
+	}
+	if strings.Join(ops, ",") != "insert,update,delete" {
+		t.Fatalf("got changes %v, expected insert, update and delete", ops)
+	}
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Fatalf("the audit log exposed a password: %s", rec.Body.String())
+	}
+}
+
+func TestServer_ValidationErrors(t *testing.T) {
+	h := newTestHandler()
+
+	var resp errorResponse
+	rec := do(t, h, "POST", "/users", `{"name":"","email":"nope","password":"pw"}`, &resp)
+	if rec.Code != http.StatusUnprocessableEntity || len(resp.Fields) != 2 ||
+		resp.Fields[0] != (models.FieldError{Field: "name", Message: "is required"}) ||
+		resp.Fields[1] != (models.FieldError{Field: "email", Message: "is not a valid address"}) {
+		t.Fatalf("got %d %+v, expected the invalid fields", rec.Code, resp)
+	}
+
+	tests := []struct {
+		method, target, body string
+	}{
+		{"POST", "/users", `{"name":`},
+		{"POST", "/users", `{"name":"Ann","role":"admin"}`},
+		{"GET", "/users/abc", ""},
+		{"GET", "/users?limit=0", ""},
+		{"GET", "/users?offset=-1", ""},
+		{"GET", "/logs?limit=x", ""},
+	}
+	for _, tt := range tests {
+		var resp errorResponse
+		if rec := do(t, h, tt.method, tt.target, tt.body, &resp); rec.Code != http.StatusBadRequest || resp.Error == "" {
+			t.Fatalf("%s %s %s got %d %+v, expected 400", tt.method, tt.target, tt.body, rec.Code, resp)
+		}
+	}
+}
+
+func TestServer_Pagination(t *testing.T) {
+	h := newTestHandler()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		do(t, h, "POST", "/users", `{"name":"`+name+`","email":"`+name+`@example.com","password":"pw"}`, nil)
+	}
+
+	var p page[userResponse]
+	if rec := do(t, h, "GET", "/users?limit=2&offset=3", "", &p); rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, expected 200", rec.Code)
+	}
+	if p.Total != 5 || p.Limit != 2 || p.Offset != 3 || len(p.Items) != 2 || p.Items[0].Name != "d" || p.Items[1].Name != "e" {
+		t.Fatalf("unexpected page %+v", p)
+	}
+	if do(t, h, "GET", "/users?offset=10", "", &p); p.Total != 5 || len(p.Items) != 0 || p.Items == nil {
+		t.Fatalf("expected an empty item list past the end, got %+v", p)
+	}
+}
+
+// failingRepo fails every listing with err.
+type failingRepo struct {
+	repository.IRepositoryUser
+	err error
+}
+
+func (f failingRepo) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	return nil, f.err
+}
+
+func TestServer_ErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{fmt.Errorf("users: %w", middleware.ErrCircuitOpen), http.StatusServiceUnavailable},
+		{errors.New("syntax error at or near"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		h := NewHandler(failingRepo{err: tt.err}, imp.NewMemoryRepoLog())
+		var resp errorResponse
+		rec := do(t, h, "GET", "/users", "", &resp)
+		if rec.Code != tt.status || strings.Contains(resp.Error, "syntax") {
+			t.Fatalf("got %d %+v for '%v', expected %d without internals", rec.Code, resp, tt.err, tt.status)
+		}
+	}
+}
+
+func TestServe_ShutsDownGracefully(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when finding a free port", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Serve(ctx, addr, slow, 5*time.Second) }()
+
+	status := make(chan int, 1)
+	go func() {
+		for {
+			resp, err := http.Get("http://" + addr + "/")
+			if err == nil {
+				resp.Body.Close()
+				status <- resp.StatusCode
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the server did not start")
+	}
+
+	// The running request completes although shutdown has begun.
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	if code := <-status; code != http.StatusNoContent {
+		t.Fatalf("got status %d for the request running at shutdown, expected 204", code)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("an error '%s' was not expected when shutting down", err)
+	}
+}
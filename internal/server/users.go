@@ -0,0 +1,201 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// userResponse is a user as returned by the API, without the password.
+type userResponse struct {
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email"`
+	RegisteredAt time.Time `json:"registered_at"`
+	// EmailVerifiedAt is missing until the email is verified.
+	EmailVerifiedAt time.Time         `json:"email_verified_at,omitzero"`
+	Status          models.UserStatus `json:"status"`
+	Attributes      models.Attributes `json:"attributes,omitempty"`
+}
+
+func newUserResponse(user models.User) userResponse {
+	return userResponse{ID: user.ID, Name: user.Name, Email: user.Email, RegisteredAt: user.RegisteredAt, EmailVerifiedAt: user.EmailVerifiedAt, Status: user.Status, Attributes: user.Attributes}
+}
+
+type createUserRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// updateUserRequest holds the fields to change, missing ones are kept. A
+// new status must be allowed by the lifecycle, reason is recorded with it
+// in the audit log.
+type updateUserRequest struct {
+	Name     *string            `json:"name"`
+	Email    *string            `json:"email"`
+	Password *string            `json:"password"`
+	Status   *models.UserStatus `json:"status"`
+	Reason   string             `json:"reason,omitempty"`
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) error {
+	filter, err := userFilter(r)
+	if err != nil {
+		return err
+	}
+	users, err := s.users.GetUsers(r.Context(), filter)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(users, func(a, b models.User) int { return a.ID - b.ID })
+
+	p, err := paginate(r, users)
+	if err != nil {
+		return err
+	}
+	resp := page[userResponse]{Items: make([]userResponse, len(p.Items)), Total: p.Total, Limit: p.Limit, Offset: p.Offset}
+	for i, user := range p.Items {
+		resp.Items[i] = newUserResponse(user)
+	}
+	writeJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+// userFilter reads the filter of a user listing from the query, e.g.
+// ?status=active&role=admin&group=ops or ?email=ann@example.com.
+func userFilter(r *http.Request) (models.UserFilter, error) {
+	filter := models.UserFilter{Email: r.URL.Query().Get("email")}
+	if v := r.URL.Query().Get("status"); v != "" {
+		status, err := models.ParseUserStatus(v)
+		if err != nil {
+			return filter, badRequest("%s", err)
+		}
+		filter.Status = status
+	}
+	filter.Role = r.URL.Query().Get("role")
+	filter.Group = r.URL.Query().Get("group")
+	for param, values := range r.URL.Query() {
+		key, ok := strings.CutPrefix(param, "attr.")
+		if !ok {
+			continue
+		}
+		if err := models.ValidateAttributeKey(key); err != nil {
+			return filter, badRequest("%s", err)
+		}
+		if filter.Attributes == nil {
+			filter.Attributes = make(models.Attributes)
+		}
+		filter.Attributes[key] = models.ParseAttributeValue(values[0])
+	}
+	return filter, nil
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r)
+	if err != nil {
+		return err
+	}
+	user, err := s.users.GetUserById(r.Context(), id)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, newUserResponse(user))
+	return nil
+}
+
+func (s *Server) createUser(w http.ResponseWriter, r *http.Request) error {
+	var req createUserRequest
+	if err := readJSON(r, &req); err != nil {
+		return err
+	}
+	user := models.User{Name: req.Name, Email: req.Email, Password: req.Password, RegisteredAt: time.Now()}
+	if err := user.Validate(); err != nil {
+		return err
+	}
+	res, err := s.users.InsertUser(r.Context(), user)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		// The user exists, only its ID is unknown.
+		w.WriteHeader(http.StatusCreated)
+		return nil
+	}
+	created, err := s.users.GetUserById(r.Context(), int(id))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", fmt.Sprintf("/users/%d", id))
+	writeJSON(w, http.StatusCreated, newUserResponse(created))
+	return nil
+}
+
+func (s *Server) updateUser(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r)
+	if err != nil {
+		return err
+	}
+	var req updateUserRequest
+	if err := readJSON(r, &req); err != nil {
+		return err
+	}
+	user, err := s.users.GetUserById(r.Context(), id)
+	if err != nil {
+		return err
+	}
+	if req.Name != nil {
+		user.Name = *req.Name
+	}
+	if req.Email != nil {
+		user.Email = *req.Email
+	}
+	if req.Password != nil {
+		user.Password = *req.Password
+	}
+	if req.Status != nil && *req.Status != user.Status {
+		if user, err = user.Transition(*req.Status); err != nil {
+			return err
+		}
+	}
+	if err := user.Validate(); err != nil {
+		return err
+	}
+
+	ctx := r.Context()
+	if req.Reason != "" {
+		ctx = middleware.WithReason(ctx, req.Reason)
+	}
+	if _, err := s.users.UpdateUserById(ctx, id, user); err != nil {
+		return err
+	}
+	updated, err := s.users.GetUserById(r.Context(), id)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, newUserResponse(updated))
+	return nil
+}
+
+func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r)
+	if err != nil {
+		return err
+	}
+	res, err := s.users.DeleteUserById(r.Context(), id)
+	if err != nil {
+		return err
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return sql.ErrNoRows
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
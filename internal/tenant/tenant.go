@@ -0,0 +1,66 @@
+// Package tenant manages the tenants, the customers whose users and logs are
+// kept apart from each other.
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"log"
+	"time"
+)
+
+// Service keeps the tenants for a session working on the users and logs of
+// current. Only admins may see and add tenants, additions are recorded in
+// logs, which belong to current.
+type Service struct {
+	tenants repository.IRepositoryTenant
+	logs    repository.IRepositoryLog
+	current models.Tenant
+	now     func() time.Time
+}
+
+func NewService(tenants repository.IRepositoryTenant, logs repository.IRepositoryLog, current models.Tenant) *Service {
+	return &Service{tenants: tenants, logs: logs, current: current, now: time.Now}
+}
+
+// Current returns the tenant the session works on.
+func (s *Service) Current() models.Tenant {
+	return s.current
+}
+
+func (s *Service) Tenants(ctx context.Context) ([]models.Tenant, error) {
+	if err := auth.Require(ctx, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return s.tenants.GetTenants(ctx)
+}
+
+// Add adds a tenant without users. Sessions work on it when started with
+// its name.
+func (s *Service) Add(ctx context.Context, name string) error {
+	if err := auth.Require(ctx, models.RoleAdmin); err != nil {
+		return err
+	}
+	if err := models.ValidateTenantName(name); err != nil {
+		return err
+	}
+	if _, err := s.tenants.GetTenantByName(ctx, name); err == nil {
+		return fmt.Errorf("tenant %s already exists", name)
+	}
+	if _, err := s.tenants.InsertTenant(ctx, models.Tenant{Name: name, CreatedAt: s.now()}); err != nil {
+		return err
+	}
+	s.record(ctx, fmt.Sprintf("Tenant %s added", name))
+	return nil
+}
+
+// record writes msg to the audit log on behalf of the operator of ctx.
+func (s *Service) record(ctx context.Context, msg string) {
+	entry := models.Log{LogTime: s.now(), LogMessage: msg, Actor: auth.Actor(ctx)}
+	if _, err := s.logs.InsertLog(ctx, entry); err != nil {
+		log.Printf("[WARN] failed to insert log: %v", err)
+	}
+}
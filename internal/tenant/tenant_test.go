@@ -0,0 +1,47 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/auth"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"testing"
+)
+
+func TestService_AddTenants(t *testing.T) {
+	logs := imp.NewMemoryRepoLog()
+	current := models.Tenant{ID: models.DefaultTenantID, Name: models.DefaultTenant}
+	s := NewService(imp.NewMemoryRepoTenant(), logs, current)
+	admin := auth.WithOperator(context.Background(), models.Operator{Name: "ada", Role: models.RoleAdmin})
+	editor := auth.WithOperator(context.Background(), models.Operator{Name: "ed", Role: models.RoleEditor})
+
+	if s.Current() != current {
+		t.Fatalf("got %+v, expected the default tenant", s.Current())
+	}
+	if err := s.Add(editor, "acme"); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("got '%v' when an editor adds a tenant, expected ErrForbidden", err)
+	}
+	if _, err := s.Tenants(editor); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("got '%v' when an editor lists the tenants, expected ErrForbidden", err)
+	}
+	if err := s.Add(admin, "Acme Inc"); err == nil {
+		t.Fatalf("expected an error for an invalid tenant name")
+	}
+	if err := s.Add(admin, "acme"); err != nil {
+		t.Fatalf("an error '%s' was not expected when adding a tenant", err)
+	}
+	if err := s.Add(admin, "acme"); err == nil || err.Error() != "tenant acme already exists" {
+		t.Fatalf("got '%v' when adding a tenant twice", err)
+	}
+
+	tenants, err := s.Tenants(admin)
+	if err != nil || len(tenants) != 2 || tenants[0].Name != "acme" || tenants[1].Name != models.DefaultTenant {
+		t.Fatalf("got %+v, '%v', expected acme and the default tenant", tenants, err)
+	}
+
+	entries, err := logs.GetLogs(context.Background())
+	if err != nil || len(entries) != 1 || entries[0].LogMessage != "Tenant acme added" || entries[0].Actor != "ada" {
+		t.Fatalf("got %+v, '%v', expected the addition by ada", entries, err)
+	}
+}
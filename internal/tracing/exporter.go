@@ -0,0 +1,156 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// JSONExporter writes every finished span as one JSON object per line.
+type JSONExporter struct {
+	enc *json.Encoder
+}
+
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{enc: json.NewEncoder(w)}
+}
+
+type jsonSpan struct {
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	Name         string         `json:"name"`
+	Start        string         `json:"start"`
+	DurationMs   float64        `json:"duration_ms"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+func (e *JSONExporter) ExportSpan(span SpanData) error {
+	out := jsonSpan{
+		TraceID:      span.TraceID,
+		SpanID:       span.SpanID,
+		ParentSpanID: span.ParentSpanID,
+		Name:         span.Name,
+		Start:        span.Start.Format("2006-01-02T15:04:05.000000Z07:00"),
+		DurationMs:   float64(span.End.Sub(span.Start).Microseconds()) / 1000,
+		Error:        span.Err,
+	}
+	if len(span.Attrs) > 0 {
+		out.Attributes = make(map[string]any, len(span.Attrs))
+		for _, attr := range span.Attrs {
+			out.Attributes[attr.Key] = attr.Value
+		}
+	}
+	return e.enc.Encode(out)
+}
+
+// OTLPFileExporter writes spans in the OTLP/JSON file format, one
+// ExportTraceServiceRequest per line, which the OpenTelemetry collector's
+// otlpjsonfile receiver and most trace viewers can import.
+type OTLPFileExporter struct {
+	enc     *json.Encoder
+	service string
+}
+
+func NewOTLPFileExporter(w io.Writer, service string) *OTLPFileExporter {
+	return &OTLPFileExporter{enc: json.NewEncoder(w), service: service}
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusOk         = 1
+	otlpStatusError      = 2
+)
+
+func otlpAttr(attr Attr) otlpKeyValue {
+	kv := otlpKeyValue{Key: attr.Key}
+	switch v := attr.Value.(type) {
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		kv.Value.IntValue = &s
+	case bool:
+		kv.Value.BoolValue = &v
+	case string:
+		kv.Value.StringValue = &v
+	default:
+		s, _ := json.Marshal(v)
+		str := string(s)
+		kv.Value.StringValue = &str
+	}
+	return kv
+}
+
+func (e *OTLPFileExporter) ExportSpan(span SpanData) error {
+	out := otlpSpan{
+		TraceID:           span.TraceID,
+		SpanID:            span.SpanID,
+		ParentSpanID:      span.ParentSpanID,
+		Name:              span.Name,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+		Status:            otlpStatus{Code: otlpStatusOk},
+	}
+	if span.Err != "" {
+		out.Status = otlpStatus{Code: otlpStatusError, Message: span.Err}
+	}
+	for _, attr := range span.Attrs {
+		out.Attributes = append(out.Attributes, otlpAttr(attr))
+	}
+
+	var rs otlpResourceSpans
+	rs.Resource.Attributes = []otlpKeyValue{otlpAttr(String("service.name", e.service))}
+	var ss otlpScopeSpans
+	ss.Scope.Name = "github.com/BohdanIpy/simpleCLIdbManager"
+	ss.Spans = []otlpSpan{out}
+	rs.ScopeSpans = []otlpScopeSpans{ss}
+
+	return e.enc.Encode(otlpRequest{ResourceSpans: []otlpResourceSpans{rs}})
+}
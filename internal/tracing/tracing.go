@@ -0,0 +1,153 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Attr struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+func Int(key string, value int) Attr {
+	return Attr{Key: key, Value: int64(value)}
+}
+
+// SpanData is the finished span handed to an Exporter.
+type SpanData struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Start        time.Time
+	End          time.Time
+	Attrs        []Attr
+	Err          string
+}
+
+type Exporter interface {
+	ExportSpan(span SpanData) error
+}
+
+type Tracer struct {
+	exporter Exporter
+	mu       sync.Mutex
+}
+
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+// SetDefault installs the tracer used for spans started without a parent.
+// Until it is called Start does not record anything.
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+type Span struct {
+	tracer *Tracer
+	data   SpanData
+	mu     sync.Mutex
+	ended  bool
+}
+
+type spanKey struct{}
+
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// TraceID returns the id of the trace ctx belongs to, or "" when ctx is not traced.
+func TraceID(ctx context.Context) string {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.data.TraceID
+	}
+	return ""
+}
+
+// Start begins a span that is a child of the span in ctx, or a new trace
+// when ctx has none. The returned span is nil when tracing is disabled,
+// all Span methods are safe to call on nil.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	tracer := defaultTracer.Load()
+	if parent != nil {
+		tracer = parent.tracer
+	}
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	span := &Span{tracer: tracer, data: SpanData{
+		SpanID: newID(8),
+		Name:   name,
+		Start:  time.Now(),
+		Attrs:  attrs,
+	}}
+	if parent != nil {
+		span.data.TraceID = parent.data.TraceID
+		span.data.ParentSpanID = parent.data.SpanID
+	} else {
+		span.data.TraceID = newID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (s *Span) SetAttr(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Attrs = append(s.data.Attrs, attrs...)
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed, nil errors are ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Err = err.Error()
+	s.mu.Unlock()
+}
+
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	_ = s.tracer.exporter.ExportSpan(data)
+}
+
+func newID(size int) string {
+	b := make([]byte, size)
+	for i := 0; i < size; i += 8 {
+		binary.BigEndian.PutUint64(b[i:], rand.Uint64())
+	}
+	return hex.EncodeToString(b)
+}
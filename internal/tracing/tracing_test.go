@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type recordingExporter struct {
+	spans []SpanData
+}
+
+func (r *recordingExporter) ExportSpan(span SpanData) error {
+	r.spans = append(r.spans, span)
+	return nil
+}
+
+func TestStart_DisabledReturnsNilSpan(t *testing.T) {
+	SetDefault(nil)
+	ctx, span := Start(context.Background(), "noop")
+	if span != nil {
+		t.Fatalf("expected nil span without a tracer")
+	}
+	span.SetAttr(String("k", "v"))
+	span.RecordError(errors.New("boom"))
+	span.End()
+	if TraceID(ctx) != "" {
+		t.Fatalf("expected no trace id without a tracer")
+	}
+}
+
+func TestStart_PropagatesTraceThroughContext(t *testing.T) {
+	exporter := &recordingExporter{}
+	SetDefault(NewTracer(exporter))
+	defer SetDefault(nil)
+
+	ctx, root := Start(context.Background(), "root")
+	childCtx, child := Start(ctx, "child", Int("attempt", 1))
+	child.RecordError(errors.New("boom"))
+	child.End()
+	root.End()
+
+	if len(exporter.spans) != 2 {
+		t.Fatalf("got %d spans, expected 2", len(exporter.spans))
+	}
+	c, r := exporter.spans[0], exporter.spans[1]
+	if c.TraceID != r.TraceID || TraceID(childCtx) != r.TraceID {
+		t.Fatalf("child is not in the root trace")
+	}
+	if c.ParentSpanID != r.SpanID || r.ParentSpanID != "" {
+		t.Fatalf("unexpected parents: child %q, root %q", c.ParentSpanID, r.ParentSpanID)
+	}
+	if c.Err != "boom" || len(r.TraceID) != 32 || len(r.SpanID) != 16 {
+		t.Fatalf("unexpected span data: %+v", c)
+	}
+}
+
+func TestOTLPFileExporter_WritesResourceSpans(t *testing.T) {
+	var b strings.Builder
+	SetDefault(NewTracer(NewOTLPFileExporter(&b, "test-service")))
+	defer SetDefault(nil)
+
+	_, span := Start(context.Background(), "query", String("db.system", "postgresql"), Int("rows", 2))
+	span.End()
+
+	var req otlpRequest
+	if err := json.Unmarshal([]byte(b.String()), &req); err != nil {
+		t.Fatalf("an error '%s' was not expected when decoding the OTLP line", err)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 || spans[0].Name != "query" || len(spans[0].Attributes) != 2 {
+		t.Fatalf("unexpected OTLP output: %s", b.String())
+	}
+	if *req.ResourceSpans[0].Resource.Attributes[0].Value.StringValue != "test-service" {
+		t.Fatalf("unexpected service name in %s", b.String())
+	}
+	if *spans[0].Attributes[1].Value.IntValue != "2" {
+		t.Fatalf("expected int attribute to be encoded as a string, got %s", b.String())
+	}
+}
@@ -0,0 +1,334 @@
+// Package tui is a full-screen interface to the users table, running on the
+// same repository and middleware stack as the prompt.
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	logLines        = 50
+	logHeight       = 10
+	refreshInterval = 5 * time.Second
+	keyHelp         = "[yellow]a[-] add  [yellow]e[-] edit  [yellow]d[-] delete  [yellow]r[-] refresh  " +
+		"[yellow]l[-] logs  [yellow]tab[-] switch pane  [yellow]q[-] quit"
+)
+
+// App shows the users in a table with a detail pane, the latest audit logs
+// below them and forms to add, edit and delete users.
+type App struct {
+	users repository.IRepositoryUser
+	logs  repository.IRepositoryLog
+	ctx   context.Context
+
+	app     *tview.Application
+	pages   *tview.Pages
+	layout  *tview.Flex
+	table   *tview.Table
+	detail  *tview.TextView
+	logView *tview.TextView
+	status  *tview.TextView
+	showLog bool
+
+	list []models.User
+}
+
+func New(users repository.IRepositoryUser, logs repository.IRepositoryLog) *App {
+	a := &App{
+		users:   users,
+		logs:    logs,
+		ctx:     context.Background(),
+		app:     tview.NewApplication(),
+		table:   tview.NewTable().SetSelectable(true, false).SetFixed(1, 0),
+		detail:  tview.NewTextView().SetDynamicColors(true),
+		logView: tview.NewTextView().SetDynamicColors(true).SetScrollable(true),
+		status:  tview.NewTextView().SetDynamicColors(true),
+		showLog: true,
+	}
+	a.table.SetBorder(true).SetTitle(" Users ")
+	a.detail.SetBorder(true).SetTitle(" Details ")
+	a.logView.SetBorder(true).SetTitle(" Audit log ")
+	a.table.SetSelectionChangedFunc(func(row, _ int) { a.showDetail(row) })
+
+	main := tview.NewFlex().
+		AddItem(a.table, 0, 3, true).
+		AddItem(a.detail, 0, 2, false)
+	a.layout = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(main, 0, 1, true).
+		AddItem(a.logView, logHeight, 0, false).
+		AddItem(a.status, 1, 0, false).
+		AddItem(tview.NewTextView().SetDynamicColors(true).SetText(keyHelp), 1, 0, false)
+	a.pages = tview.NewPages().AddPage("main", a.layout, true, true)
+	a.app.SetRoot(a.pages, true).SetInputCapture(a.handleKey)
+	return a
+}
+
+// Run shows the interface until the user quits or ctx is canceled, in which
+// case ctx.Err() is returned.
+func (a *App) Run(ctx context.Context) error {
+	a.ctx = ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Log output would be drawn over the screen, it is shown in the status line instead.
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(statusWriter{a})
+
+	a.refresh()
+	go func() {
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				a.app.Stop()
+				return
+			case <-ticker.C:
+				a.app.QueueUpdateDraw(a.loadLogs)
+			}
+		}
+	}()
+
+	if err := a.app.Run(); err != nil {
+		return err
+	}
+	return a.ctx.Err()
+}
+
+func (a *App) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	if name, _ := a.pages.GetFrontPage(); name != "main" {
+		return event
+	}
+	switch {
+	case event.Key() == tcell.KeyTab:
+		if a.table.HasFocus() {
+			a.app.SetFocus(a.logView)
+		} else {
+			a.app.SetFocus(a.table)
+		}
+		return nil
+	case event.Key() != tcell.KeyRune:
+		return event
+	}
+	switch event.Rune() {
+	case 'q':
+		a.app.Stop()
+	case 'r':
+		a.refresh()
+	case 'a':
+		a.showForm(models.User{})
+	case 'e':
+		if user, ok := a.selected(); ok {
+			a.showForm(user)
+		}
+	case 'd':
+		if user, ok := a.selected(); ok {
+			a.confirmDelete(user)
+		}
+	case 'l':
+		a.showLog = !a.showLog
+		height := 0
+		if a.showLog {
+			height = logHeight
+		}
+		a.layout.ResizeItem(a.logView, height, 0)
+	default:
+		return event
+	}
+	return nil
+}
+
+func (a *App) refresh() {
+	a.loadUsers()
+	a.loadLogs()
+}
+
+func (a *App) loadUsers() {
+	users, err := a.users.GetUsers(a.ctx, models.UserFilter{})
+	if err != nil {
+		a.setError(err)
+		return
+	}
+	slices.SortFunc(users, func(x, y models.User) int { return x.ID - y.ID })
+	a.list = users
+
+	a.table.Clear()
+	for col, title := range []string{"ID", "Name", "Email", "Status", "Registered"} {
+		a.table.SetCell(0, col, tview.NewTableCell(title).SetTextColor(tcell.ColorYellow).SetSelectable(false))
+	}
+	for i, user := range users {
+		a.table.SetCell(i+1, 0, tview.NewTableCell(strconv.Itoa(user.ID)))
+		a.table.SetCell(i+1, 1, tview.NewTableCell(tview.Escape(user.Name)).SetExpansion(1))
+		a.table.SetCell(i+1, 2, tview.NewTableCell(tview.Escape(user.Email)).SetExpansion(1))
+		a.table.SetCell(i+1, 3, tview.NewTableCell(string(user.Status)))
+		a.table.SetCell(i+1, 4, tview.NewTableCell(user.RegisteredAt.Format(time.DateTime)))
+	}
+	row, _ := a.table.GetSelection()
+	row = min(max(row, 1), len(users))
+	a.table.Select(row, 0)
+	a.showDetail(row)
+}
+
+func (a *App) loadLogs() {
+	logs, err := a.logs.GetLogs(a.ctx)
+	if err != nil {
+		a.logView.SetText("[red]" + tview.Escape(err.Error()))
+		return
+	}
+	slices.SortFunc(logs, func(x, y models.Log) int { return int(x.Id - y.Id) })
+	logs = logs[max(0, len(logs)-logLines):]
+
+	var b strings.Builder
+	for _, l := range logs {
+		actor := ""
+		if l.Actor != "" {
+			actor = "[yellow]" + tview.Escape(l.Actor) + "[-] "
+		}
+		fmt.Fprintf(&b, "[gray]%s[-] %s%s\n", l.LogTime.Format(time.DateTime), actor, tview.Escape(l.LogMessage))
+	}
+	a.logView.SetText(b.String()).ScrollToEnd()
+}
+
+// selected returns the user in the selected table row.
+func (a *App) selected() (models.User, bool) {
+	row, _ := a.table.GetSelection()
+	if row < 1 || row > len(a.list) {
+		return models.User{}, false
+	}
+	return a.list[row-1], true
+}
+
+func (a *App) showDetail(row int) {
+	if row < 1 || row > len(a.list) {
+		a.detail.SetText("No user selected")
+		return
+	}
+	user := a.list[row-1]
+	verified := "no"
+	if user.EmailVerified() {
+		verified = user.EmailVerifiedAt.Format(time.RFC1123)
+	}
+	a.detail.SetText(fmt.Sprintf("[yellow]ID[-]          %d\n[yellow]Name[-]        %s\n[yellow]Email[-]       %s\n[yellow]Verified[-]    %s\n[yellow]Status[-]      %s\n[yellow]Registered[-]  %s\n[yellow]Attributes[-]  %s\n",
+		user.ID, tview.Escape(user.Name), tview.Escape(user.Email), verified, user.Status, user.RegisteredAt.Format(time.RFC1123), tview.Escape(user.Attributes.String())))
+}
+
+// showForm edits user, a user without ID is added.
+func (a *App) showForm(user models.User) {
+	form := tview.NewForm()
+	errText := tview.NewTextView().SetDynamicColors(true)
+	form.AddInputField("Name", user.Name, 30, nil, nil).
+		AddInputField("Email", user.Email, 30, nil, nil).
+		AddPasswordField("Password", user.Password, 30, '*', nil)
+	title := " Add user "
+	if user.ID != 0 {
+		title = fmt.Sprintf(" Edit user %d ", user.ID)
+	}
+	form.SetBorder(true).SetTitle(title)
+
+	save := func() {
+		edited := user
+		edited.Name = form.GetFormItemByLabel("Name").(*tview.InputField).GetText()
+		edited.Email = form.GetFormItemByLabel("Email").(*tview.InputField).GetText()
+		edited.Password = form.GetFormItemByLabel("Password").(*tview.InputField).GetText()
+		if err := edited.Validate(); err != nil {
+			errText.SetText("[red]" + tview.Escape(err.Error()))
+			return
+		}
+
+		var err error
+		var done string
+		if user.ID == 0 {
+			_, err = a.users.InsertUser(a.ctx, edited)
+			done = "Added " + edited.Email
+		} else {
+			_, err = a.users.UpdateUserById(a.ctx, user.ID, edited)
+			done = fmt.Sprintf("Updated user %d", user.ID)
+		}
+		if err != nil {
+			errText.SetText("[red]" + tview.Escape(err.Error()))
+			return
+		}
+		a.closeDialog()
+		a.refresh()
+		a.setStatus(done)
+	}
+	form.AddButton("Save", save).AddButton("Cancel", a.closeDialog).SetCancelFunc(a.closeDialog)
+
+	body := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(form, 11, 0, true).
+		AddItem(errText, 2, 0, false)
+	a.pages.AddPage("dialog", center(body, 50, 13), true, true)
+	a.app.SetFocus(form)
+}
+
+func (a *App) confirmDelete(user models.User) {
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("Delete user %d (%s)?", user.ID, tview.Escape(user.Email))).
+		AddButtons([]string{"Delete", "Cancel"}).
+		SetDoneFunc(func(_ int, label string) {
+			a.closeDialog()
+			if label != "Delete" {
+				return
+			}
+			if _, err := a.users.DeleteUserById(a.ctx, user.ID); err != nil {
+				a.setError(err)
+				return
+			}
+			a.refresh()
+			a.setStatus(fmt.Sprintf("Deleted user %d", user.ID))
+		})
+	a.pages.AddPage("dialog", modal, true, true)
+	a.app.SetFocus(modal)
+}
+
+func (a *App) closeDialog() {
+	a.pages.RemovePage("dialog")
+	a.app.SetFocus(a.table)
+}
+
+func (a *App) setStatus(msg string) {
+	a.status.SetText(tview.Escape(msg))
+}
+
+func (a *App) setError(err error) {
+	msg := err.Error()
+	if errors.Is(err, context.Canceled) {
+		msg = "canceled"
+	}
+	a.status.SetText("[red]Error: " + tview.Escape(msg))
+}
+
+// statusWriter shows log output in the status line.
+type statusWriter struct {
+	a *App
+}
+
+func (w statusWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	// Writes may come from the UI goroutine itself, which must not wait for
+	// its own update queue.
+	go w.a.app.QueueUpdateDraw(func() { w.a.setStatus(msg) })
+	return len(p), nil
+}
+
+func center(p tview.Primitive, width, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 0, true).
+			AddItem(nil, 0, 1, false), width, 0, true).
+		AddItem(nil, 0, 1, false)
+}
@@ -0,0 +1,172 @@
+package tui
+
+import (
+	"context"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/middleware"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/models"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository"
+	"github.com/BohdanIpy/simpleCLIdbManager/internal/repository/imp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type harness struct {
+	t      *testing.T
+	app    *App
+	screen tcell.SimulationScreen
+	store  repository.IRepositoryUser
+}
+
+// startApp runs the interface on a simulated screen over a logging in-memory
+// stack holding one user.
+func startApp(t *testing.T) *harness {
+	t.Helper()
+	store := imp.NewMemoryRepoUser()
+	users := middleware.NewPasswordMiddleware(store)
+	logs := imp.NewMemoryRepoLog()
+	if _, err := users.InsertUser(context.Background(), models.User{Name: "Ann", Email: "ann@example.com", Password: "pw"}); err != nil {
+		t.Fatalf("an error '%s' was not expected when inserting user", err)
+	}
+
+	screen := tcell.NewSimulationScreen("UTF-8")
+	if err := screen.Init(); err != nil {
+		t.Fatalf("an error '%s' was not expected when creating the screen", err)
+	}
+	screen.SetSize(100, 40)
+	h := &harness{t: t, app: New(middleware.NewLoggerMiddleware(logs, users), logs), screen: screen, store: store}
+	h.app.app.SetScreen(screen)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- h.app.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	h.waitFor("ann@example.com")
+	return h
+}
+
+// contents returns the screen as text, it is read in the event loop so it
+// does not race with drawing.
+func (h *harness) contents() string {
+	var b strings.Builder
+	h.app.app.QueueUpdate(func() {
+		cells, width, _ := h.screen.GetContents()
+		for i, cell := range cells {
+			if i > 0 && i%width == 0 {
+				b.WriteByte('\n')
+			}
+			if len(cell.Runes) > 0 {
+				b.WriteRune(cell.Runes[0])
+			} else {
+				b.WriteByte(' ')
+			}
+		}
+	})
+	return b.String()
+}
+
+func (h *harness) eventually(cond func(screen string) bool) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond(h.contents()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *harness) waitFor(text string) {
+	h.t.Helper()
+	if !h.eventually(func(screen string) bool { return strings.Contains(screen, text) }) {
+		h.t.Fatalf("%q was not shown:\n%s", text, h.contents())
+	}
+}
+
+func (h *harness) typeText(text string) {
+	for _, r := range text {
+		h.screen.InjectKey(tcell.KeyRune, r, tcell.ModNone)
+	}
+}
+
+func (h *harness) press(key tcell.Key, times int) {
+	for range times {
+		h.screen.InjectKey(key, 0, tcell.ModNone)
+	}
+}
+
+func (h *harness) users() []models.User {
+	users, err := h.store.GetUsers(context.Background(), models.UserFilter{})
+	if err != nil {
+		h.t.Fatalf("an error '%s' was not expected when getting users", err)
+	}
+	return users
+}
+
+func TestApp_AddUserWithValidation(t *testing.T) {
+	h := startApp(t)
+
+	// Save the empty form, tabbing over the three fields to the Save button.
+	h.typeText("a")
+	h.press(tcell.KeyTab, 3)
+	h.press(tcell.KeyEnter, 1)
+	h.waitFor("name is required")
+
+	// Back to the name field and fill in the form.
+	h.press(tcell.KeyTab, 2)
+	h.typeText("Bob")
+	h.press(tcell.KeyTab, 1)
+	h.typeText("bob@example.com")
+	h.press(tcell.KeyTab, 1)
+	h.typeText("secret")
+	h.press(tcell.KeyTab, 1)
+	h.press(tcell.KeyEnter, 1)
+	h.waitFor("Added bob@example.com")
+
+	users := h.users()
+	if len(users) != 2 || users[1].Name != "Bob" || bcrypt.CompareHashAndPassword([]byte(users[1].PasswordHash), []byte("secret")) != nil {
+		t.Fatalf("unexpected users %+v", users)
+	}
+	h.waitFor("Insert user succeeded")
+}
+
+func TestApp_EditUser(t *testing.T) {
+	h := startApp(t)
+
+	h.typeText("e")
+	h.waitFor("Edit user 1")
+	h.typeText("ie")
+	h.press(tcell.KeyTab, 3)
+	h.press(tcell.KeyEnter, 1)
+	h.waitFor("Updated user 1")
+
+	if users := h.users(); users[0].Name != "Annie" || bcrypt.CompareHashAndPassword([]byte(users[0].PasswordHash), []byte("pw")) != nil {
+		t.Fatalf("unexpected user after edit %+v", users[0])
+	}
+}
+
+func TestApp_DeleteAsksForConfirmation(t *testing.T) {
+	h := startApp(t)
+
+	h.typeText("d")
+	h.waitFor("Delete user 1")
+	h.press(tcell.KeyEscape, 1)
+	if !h.eventually(func(screen string) bool { return !strings.Contains(screen, "Delete user 1") }) {
+		t.Fatalf("confirmation was not closed:\n%s", h.contents())
+	}
+	if len(h.users()) != 1 {
+		t.Fatalf("user was deleted without confirmation")
+	}
+
+	h.typeText("d")
+	h.waitFor("Delete user 1")
+	h.press(tcell.KeyEnter, 1)
+	h.waitFor("Deleted user 1")
+	if len(h.users()) != 0 {
+		t.Fatalf("user was not deleted")
+	}
+}